@@ -20,11 +20,16 @@ type (
 	}
 
 	// Client is a server client
+	//
+	// Requests are authenticated with an apikey if apikeyid and apikeysecret
+	// are configured.
 	Client struct {
-		config *viper.Viper
-		httpc  *http.Client
-		flags  ClientFlags
-		addr   string
+		config       *viper.Viper
+		httpc        *http.Client
+		flags        ClientFlags
+		addr         string
+		apikeyid     string
+		apikeysecret string
 	}
 )
 
@@ -33,6 +38,8 @@ func NewClient(opts Opts) *Client {
 	v := viper.New()
 	v.SetDefault("addr", "http://localhost:8080/api")
 	v.SetDefault("timeout", "5s")
+	v.SetDefault("apikeyid", "")
+	v.SetDefault("apikeysecret", "")
 
 	v.SetConfigName(opts.ClientDefault)
 	v.SetConfigType("yaml")
@@ -68,6 +75,8 @@ func (c *Client) Init() error {
 		return ErrWithKind(err, ErrInvalidConfig{}, "Failed to read in config")
 	}
 	c.addr = c.config.GetString("addr")
+	c.apikeyid = c.config.GetString("apikeyid")
+	c.apikeysecret = c.config.GetString("apikeysecret")
 	if t, err := time.ParseDuration(c.config.GetString("timeout")); err == nil {
 		c.httpc.Timeout = t
 	}
@@ -112,6 +121,9 @@ func (c *Client) Request(method, path string, data interface{}, response interfa
 	if err != nil {
 		return 0, ErrWithKind(err, ErrInvalidClientReq{}, "Malformed request")
 	}
+	if c.apikeyid != "" {
+		req.SetBasicAuth(c.apikeyid, c.apikeysecret)
+	}
 	res, err := c.httpc.Do(req)
 	if err != nil {
 		return 0, ErrWithKind(err, ErrInvalidClientReq{}, "Failed request")
@@ -123,6 +135,8 @@ func (c *Client) Request(method, path string, data interface{}, response interfa
 			return 0, ErrWithKind(err, ErrInvalidServerRes{}, "Failed decoding response")
 		}
 		return res.StatusCode, ErrWithKind(nil, ErrServerRes{}, errres.Message)
+	} else if response == nil || res.StatusCode == http.StatusNoContent {
+		return res.StatusCode, nil
 	} else if err := json.NewDecoder(res.Body).Decode(response); err != nil {
 		return 0, ErrWithKind(err, ErrInvalidServerRes{}, "Failed decoding response")
 	}
@@ -111,6 +111,29 @@ Calls the server setup endpoint.`,
 	c.cmd = rootCmd
 }
 
+// AddCmd adds subcommands to the governor cmd
+func (c *Cmd) AddCmd(cmds ...*cobra.Command) {
+	c.cmd.AddCommand(cmds...)
+}
+
+// ClientRun returns a command run function which calls fn with an initialized
+// Client
+func (c *Cmd) ClientRun(fn func(client *Client, args []string) error) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		c.c.SetFlags(ClientFlags{
+			ConfigFile: c.configFile,
+		})
+		if err := c.c.Init(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := fn(c.c, args); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
 // Execute runs the governor cmd
 func (c *Cmd) Execute() {
 	if err := c.cmd.Execute(); err != nil {
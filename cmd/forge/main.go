@@ -0,0 +1,34 @@
+// Command forge generates the sql model code of governor services
+//
+// It implements the model subcommand of xorkevin.dev/forge with queries that
+// take a context and a db.SQLExecutor, so that they may be run on either a
+// database or a transaction, and with keyset page queries. Model packages
+// invoke it with go generate:
+//
+//	//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t users -p user -o model_gen.go Model Info
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	usage = `Usage: forge model [flags] structs...
+
+Generates sql queries for the model struct and the query structs of the
+package in the current directory.
+
+Flags:`
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "model" {
+		fmt.Fprintln(os.Stderr, "Usage: forge model [flags] structs...")
+		os.Exit(2)
+	}
+	if err := execModel(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
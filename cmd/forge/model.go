@@ -0,0 +1,684 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	modelTagName = "model"
+	queryTagName = "query"
+
+	modelHeader = "// Code generated by go generate forge model; DO NOT EDIT."
+)
+
+const (
+	queryKindGetOneEq   = "getoneeq"
+	queryKindGetGroup   = "getgroup"
+	queryKindGetGroupEq = "getgroupeq"
+	queryKindGetPage    = "getpage"
+	queryKindGetPageEq  = "getpageeq"
+	queryKindUpdEq      = "updeq"
+	queryKindDelEq      = "deleq"
+)
+
+const (
+	condModNone = ""
+	condModArr  = "arr"
+	condModLike = "like"
+	condModGt   = "gt"
+	condModGeq  = "geq"
+	condModLt   = "lt"
+	condModLeq  = "leq"
+	condModNeq  = "neq"
+	condModTie  = "tie"
+)
+
+type (
+	condOp struct {
+		Name string
+		Op   string
+	}
+)
+
+var condOps = map[string]condOp{
+	condModNone: {Name: "Eq", Op: "="},
+	condModArr:  {Name: "Has", Op: "IN"},
+	condModLike: {Name: "Like", Op: "LIKE"},
+	condModGt:   {Name: "Gt", Op: ">"},
+	condModGeq:  {Name: "Geq", Op: ">="},
+	condModLt:   {Name: "Lt", Op: "<"},
+	condModLeq:  {Name: "Leq", Op: "<="},
+	condModNeq:  {Name: "Neq", Op: "<>"},
+}
+
+type (
+	modelField struct {
+		Name    string
+		Type    string
+		Col     string
+		Def     string
+		Index   bool
+		Queries []querySpec
+	}
+
+	modelStruct struct {
+		Name   string
+		Fields []modelField
+	}
+
+	querySpec struct {
+		Kind string
+		Args []queryArg
+	}
+
+	queryArg struct {
+		Col string
+		Mod string
+	}
+
+	queryCond struct {
+		Field modelField
+		Mod   string
+	}
+
+	modelConfig struct {
+		Prefix    string
+		TableName string
+		Model     modelStruct
+	}
+)
+
+func execModel(args []string) error {
+	flags := flag.NewFlagSet("model", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	modelName := flags.String("m", "", "name of the model struct")
+	tableName := flags.String("t", "", "name of the table")
+	prefix := flags.String("p", "", "prefix of the generated identifiers")
+	output := flags.String("o", "", "output file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	queryNames := flags.Args()
+	if *modelName == "" || *tableName == "" || *prefix == "" || *output == "" || len(queryNames) == 0 {
+		flags.Usage()
+		return errors.New("Missing required arguments")
+	}
+
+	structs, pkgName, err := parseStructs(".", *output)
+	if err != nil {
+		return err
+	}
+	model, ok := structs[*modelName]
+	if !ok {
+		return fmt.Errorf("Model struct %s not found", *modelName)
+	}
+	queries := make([]modelStruct, 0, len(queryNames))
+	for _, i := range queryNames {
+		s, ok := structs[i]
+		if !ok {
+			return fmt.Errorf("Query struct %s not found", i)
+		}
+		queries = append(queries, s)
+	}
+
+	c := modelConfig{
+		Prefix:    *prefix + "Model",
+		TableName: *tableName,
+		Model:     model,
+	}
+	src, err := c.generate(pkgName, queries)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		return fmt.Errorf("Failed to write output file %s: %w", *output, err)
+	}
+	return nil
+}
+
+func parseStructs(dir string, output string) (map[string]modelStruct, string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return name != output && !strings.HasSuffix(name, "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to parse package: %w", err)
+	}
+	pkgName := os.Getenv("GOPACKAGE")
+	if pkgName == "" {
+		if len(pkgs) != 1 {
+			return nil, "", errors.New("Failed to determine package")
+		}
+		for k := range pkgs {
+			pkgName = k
+		}
+	}
+	pkg, ok := pkgs[pkgName]
+	if !ok {
+		return nil, "", fmt.Errorf("Package %s not found", pkgName)
+	}
+
+	structs := map[string]modelStruct{}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				s, err := parseStruct(ts.Name.Name, st)
+				if err != nil {
+					return nil, "", err
+				}
+				structs[s.Name] = s
+			}
+		}
+	}
+	return structs, pkgName, nil
+}
+
+func parseStruct(name string, st *ast.StructType) (modelStruct, error) {
+	s := modelStruct{
+		Name: name,
+	}
+	for _, f := range st.Fields.List {
+		if f.Tag == nil || len(f.Names) != 1 {
+			continue
+		}
+		tagstr, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return modelStruct{}, fmt.Errorf("Invalid tag on %s.%s: %w", name, f.Names[0].Name, err)
+		}
+		tag := reflect.StructTag(tagstr)
+		field := modelField{
+			Name: f.Names[0].Name,
+			Type: types.ExprString(f.Type),
+		}
+		if m, ok := tag.Lookup(modelTagName); ok {
+			if err := field.parseModelTag(m); err != nil {
+				return modelStruct{}, fmt.Errorf("Invalid model tag on %s.%s: %w", name, field.Name, err)
+			}
+		}
+		if q, ok := tag.Lookup(queryTagName); ok {
+			if err := field.parseQueryTag(q); err != nil {
+				return modelStruct{}, fmt.Errorf("Invalid query tag on %s.%s: %w", name, field.Name, err)
+			}
+		}
+		if field.Col == "" {
+			continue
+		}
+		s.Fields = append(s.Fields, field)
+	}
+	return s, nil
+}
+
+func (f *modelField) parseModelTag(tag string) error {
+	opts := strings.Split(tag, ";")
+	coldef := strings.SplitN(opts[0], ",", 2)
+	if len(coldef) != 2 || coldef[0] == "" || coldef[1] == "" {
+		return errors.New("Model tag must have a column and definition")
+	}
+	f.Col = coldef[0]
+	f.Def = coldef[1]
+	for _, i := range opts[1:] {
+		switch i {
+		case "index":
+			f.Index = true
+		default:
+			return fmt.Errorf("Invalid model option %s", i)
+		}
+	}
+	return nil
+}
+
+func (f *modelField) parseQueryTag(tag string) error {
+	specs := strings.Split(tag, ";")
+	first := strings.Split(specs[0], ",")
+	if first[0] == "" {
+		return errors.New("Query tag must have a column")
+	}
+	if f.Col != "" && f.Col != first[0] {
+		return fmt.Errorf("Query column %s does not match model column %s", first[0], f.Col)
+	}
+	f.Col = first[0]
+	if len(first) > 1 {
+		specs[0] = strings.Join(first[1:], ",")
+	} else {
+		specs = specs[1:]
+	}
+	for _, i := range specs {
+		parts := strings.Split(i, ",")
+		spec := querySpec{
+			Kind: parts[0],
+		}
+		switch spec.Kind {
+		case queryKindGetOneEq, queryKindGetGroup, queryKindGetGroupEq, queryKindGetPage, queryKindGetPageEq, queryKindUpdEq, queryKindDelEq:
+		default:
+			return fmt.Errorf("Invalid query kind %s", spec.Kind)
+		}
+		for _, j := range parts[1:] {
+			colmod := strings.SplitN(j, "|", 2)
+			arg := queryArg{
+				Col: colmod[0],
+			}
+			if len(colmod) > 1 {
+				arg.Mod = colmod[1]
+			}
+			if _, ok := condOps[arg.Mod]; !ok && arg.Mod != condModTie {
+				return fmt.Errorf("Invalid query arg modifier %s", arg.Mod)
+			}
+			spec.Args = append(spec.Args, arg)
+		}
+		f.Queries = append(f.Queries, spec)
+	}
+	return nil
+}
+
+func (s modelStruct) fieldByCol(col string) (modelField, bool) {
+	for _, i := range s.Fields {
+		if i.Col == col {
+			return i, true
+		}
+	}
+	return modelField{}, false
+}
+
+func (f modelField) paramName() string {
+	return strings.ToLower(f.Name)
+}
+
+func (c modelConfig) generate(pkgName string, queries []modelStruct) ([]byte, error) {
+	b := &strings.Builder{}
+	hasGetOne := false
+	for _, s := range queries {
+		for _, f := range s.Fields {
+			for _, q := range f.Queries {
+				if q.Kind == queryKindGetOneEq {
+					hasGetOne = true
+				}
+			}
+		}
+	}
+
+	fmt.Fprintln(b, modelHeader)
+	fmt.Fprintln(b)
+	fmt.Fprintf(b, "package %s\n\n", pkgName)
+	fmt.Fprintln(b, "import (")
+	fmt.Fprintln(b, `"context"`)
+	if hasGetOne {
+		fmt.Fprintln(b, `"database/sql"`)
+	}
+	fmt.Fprintln(b, `"fmt"`)
+	fmt.Fprintln(b, `"strings"`)
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, `"github.com/lib/pq"`)
+	fmt.Fprintln(b, `"xorkevin.dev/governor/service/db"`)
+	fmt.Fprintln(b, ")")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "const (")
+	fmt.Fprintf(b, "%sTableName = %q\n", c.Prefix, c.TableName)
+	fmt.Fprintln(b, ")")
+
+	c.genSetup(b)
+	c.genInsert(b)
+	c.genInsertBulk(b)
+	for _, s := range queries {
+		for _, f := range s.Fields {
+			for _, q := range f.Queries {
+				if err := c.genQuery(b, s, f, q); err != nil {
+					return nil, fmt.Errorf("Failed to generate query %s on %s.%s: %w", q.Kind, s.Name, f.Name, err)
+				}
+			}
+		}
+	}
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (c modelConfig) tableFields() []modelField {
+	fields := make([]modelField, 0, len(c.Model.Fields))
+	for _, i := range c.Model.Fields {
+		if i.Def != "" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+func (c modelConfig) genSetup(b *strings.Builder) {
+	fields := c.tableFields()
+	defs := make([]string, 0, len(fields))
+	for _, i := range fields {
+		defs = append(defs, i.Col+" "+i.Def)
+	}
+	fmt.Fprintf(b, "\nfunc %sSetup(ctx context.Context, d db.SQLExecutor) (int, error) {\n", c.Prefix)
+	fmt.Fprintf(b, "_, err := d.ExecContext(ctx, \"CREATE TABLE IF NOT EXISTS %s (%s);\")\n", c.TableName, strings.Join(defs, ", "))
+	fmt.Fprintln(b, "if err != nil {\nreturn 0, err\n}")
+	for _, i := range fields {
+		if !i.Index {
+			continue
+		}
+		fmt.Fprintf(b, "_, err = d.ExecContext(ctx, \"CREATE INDEX IF NOT EXISTS %[1]s_%[2]s_index ON %[1]s (%[2]s);\")\n", c.TableName, i.Col)
+		fmt.Fprintln(b, "if err != nil {")
+		genPQErrSwitch(b, "42501", "insufficient_privilege", "return 5, err", "return 0, err")
+		fmt.Fprintln(b, "}")
+	}
+	fmt.Fprintln(b, "return 0, nil\n}")
+}
+
+func (c modelConfig) genInsert(b *strings.Builder) {
+	fields := c.tableFields()
+	fmt.Fprintf(b, "\nfunc %sInsert(ctx context.Context, d db.SQLExecutor, m *%s) (int, error) {\n", c.Prefix, c.Model.Name)
+	fmt.Fprintf(b, "_, err := d.ExecContext(ctx, \"INSERT INTO %s (%s) VALUES (%s);\", %s)\n", c.TableName, colList(fields), placeholderList(1, len(fields)), fieldRefs("m.", fields))
+	genExecErr(b)
+	fmt.Fprintln(b, "}")
+}
+
+func (c modelConfig) genInsertBulk(b *strings.Builder) {
+	fields := c.tableFields()
+	n := len(fields)
+	fmts := make([]string, 0, n)
+	offsets := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		fmts = append(fmts, "$%d")
+		offsets = append(offsets, fmt.Sprintf("n+%d", i))
+	}
+	fmt.Fprintf(b, "\nfunc %sInsertBulk(ctx context.Context, d db.SQLExecutor, models []*%s, allowConflict bool) (int, error) {\n", c.Prefix, c.Model.Name)
+	fmt.Fprintln(b, "conflictSQL := \"\"\nif allowConflict {\nconflictSQL = \" ON CONFLICT DO NOTHING\"\n}")
+	fmt.Fprintln(b, "placeholders := make([]string, 0, len(models))")
+	fmt.Fprintf(b, "args := make([]interface{}, 0, len(models)*%d)\n", n)
+	fmt.Fprintln(b, "for c, m := range models {")
+	fmt.Fprintf(b, "n := c * %d\n", n)
+	fmt.Fprintf(b, "placeholders = append(placeholders, fmt.Sprintf(\"(%s)\", %s))\n", strings.Join(fmts, ", "), strings.Join(offsets, ", "))
+	fmt.Fprintf(b, "args = append(args, %s)\n", fieldRefs("m.", fields))
+	fmt.Fprintln(b, "}")
+	fmt.Fprintf(b, "_, err := d.ExecContext(ctx, \"INSERT INTO %s (%s) VALUES \"+strings.Join(placeholders, \", \")+conflictSQL+\";\", args...)\n", c.TableName, colList(fields))
+	genExecErr(b)
+	fmt.Fprintln(b, "}")
+}
+
+func (c modelConfig) resolveArgs(s modelStruct, args []queryArg) ([]queryCond, []modelField, error) {
+	conds := make([]queryCond, 0, len(args))
+	var ties []modelField
+	for _, i := range args {
+		f, ok := s.fieldByCol(i.Col)
+		if !ok {
+			f, ok = c.Model.fieldByCol(i.Col)
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("Column %s not found", i.Col)
+		}
+		if i.Mod == condModTie {
+			ties = append(ties, f)
+			continue
+		}
+		conds = append(conds, queryCond{
+			Field: f,
+			Mod:   i.Mod,
+		})
+	}
+	return conds, ties, nil
+}
+
+func (c modelConfig) genQuery(b *strings.Builder, s modelStruct, f modelField, q querySpec) error {
+	conds, ties, err := c.resolveArgs(s, q.Args)
+	if err != nil {
+		return err
+	}
+	if len(ties) > 0 && q.Kind != queryKindGetPage && q.Kind != queryKindGetPageEq {
+		return errors.New("Tie columns are only allowed in page queries")
+	}
+	switch q.Kind {
+	case queryKindGetOneEq, queryKindUpdEq, queryKindDelEq, queryKindGetGroupEq, queryKindGetPageEq:
+		if len(conds) == 0 {
+			return errors.New("Query requires conditions")
+		}
+	default:
+		if len(conds) != 0 {
+			return errors.New("Query does not take conditions")
+		}
+	}
+	switch q.Kind {
+	case queryKindGetOneEq:
+		c.genGetOne(b, s, conds)
+	case queryKindGetGroup, queryKindGetGroupEq:
+		c.genGetGroup(b, s, f, conds)
+	case queryKindGetPage, queryKindGetPageEq:
+		c.genGetPage(b, s, f, ties, conds)
+	case queryKindUpdEq:
+		if s.Name != c.Model.Name {
+			return errors.New("Update queries are only allowed on the model struct")
+		}
+		c.genUpd(b, conds)
+	case queryKindDelEq:
+		c.genDel(b, conds)
+	}
+	return nil
+}
+
+// whereClause builds the sql conditions of a query, numbering plain params
+// from start, and returns the plain params, the array params whose
+// placeholders are built at runtime, and the next param number
+func whereClause(conds []queryCond, start int) ([]string, []string, []queryCond, int) {
+	clauses := make([]string, 0, len(conds))
+	var params []string
+	var arrs []queryCond
+	n := start
+	for _, i := range conds {
+		if i.Mod == condModArr {
+			clauses = append(clauses, fmt.Sprintf("%s IN (VALUES \"+placeholders%s+\")", i.Field.Col, i.Field.paramName()))
+			arrs = append(arrs, i)
+			continue
+		}
+		clauses = append(clauses, fmt.Sprintf("%s %s $%d", i.Field.Col, condOps[i.Mod].Op, n))
+		params = append(params, i.Field.paramName())
+		n++
+	}
+	return clauses, params, arrs, n
+}
+
+func condName(conds []queryCond) string {
+	b := strings.Builder{}
+	for _, i := range conds {
+		b.WriteString(condOps[i.Mod].Name)
+		b.WriteString(i.Field.Name)
+	}
+	return b.String()
+}
+
+func condParams(conds []queryCond) string {
+	params := make([]string, 0, len(conds))
+	for _, i := range conds {
+		t := i.Field.Type
+		if i.Mod == condModArr {
+			t = "[]" + t
+		}
+		params = append(params, fmt.Sprintf(", %s %s", i.Field.paramName(), t))
+	}
+	return strings.Join(params, "")
+}
+
+// genArgs writes the args of a query with array params, and returns the
+// expression used to pass them
+func genArgs(b *strings.Builder, params []string, arrs []queryCond) string {
+	if len(arrs) == 0 {
+		return strings.Join(params, ", ")
+	}
+	lens := make([]string, 0, len(arrs))
+	for _, i := range arrs {
+		lens = append(lens, fmt.Sprintf("+len(%s)", i.Field.paramName()))
+	}
+	fmt.Fprintf(b, "paramCount := %d\n", len(params))
+	fmt.Fprintf(b, "args := make([]interface{}, 0, paramCount%s)\n", strings.Join(lens, ""))
+	if len(params) > 0 {
+		fmt.Fprintf(b, "args = append(args, %s)\n", strings.Join(params, ", "))
+	}
+	for _, i := range arrs {
+		name := i.Field.paramName()
+		fmt.Fprintf(b, "var placeholders%s string\n", name)
+		fmt.Fprintln(b, "{")
+		fmt.Fprintf(b, "placeholders := make([]string, 0, len(%s))\n", name)
+		fmt.Fprintf(b, "for _, i := range %s {\n", name)
+		fmt.Fprintln(b, "paramCount++")
+		b.WriteString("placeholders = append(placeholders, fmt.Sprintf(\"($%d)\", paramCount))\n")
+		fmt.Fprintln(b, "args = append(args, i)")
+		fmt.Fprintln(b, "}")
+		fmt.Fprintf(b, "placeholders%s = strings.Join(placeholders, \", \")\n", name)
+		fmt.Fprintln(b, "}")
+	}
+	return "args..."
+}
+
+func (c modelConfig) genGetOne(b *strings.Builder, s modelStruct, conds []queryCond) {
+	clauses, params, arrs, _ := whereClause(conds, 1)
+	fmt.Fprintf(b, "\nfunc %sGet%s%s(ctx context.Context, d db.SQLExecutor%s) (*%s, int, error) {\n", c.Prefix, s.Name, condName(conds), condParams(conds), s.Name)
+	args := genArgs(b, params, arrs)
+	fmt.Fprintf(b, "m := &%s{}\n", s.Name)
+	fmt.Fprintf(b, "if err := d.QueryRowContext(ctx, \"SELECT %s FROM %s WHERE %s;\", %s).Scan(%s); err != nil {\n", colList(s.Fields), c.TableName, strings.Join(clauses, " AND "), args, fieldRefs("&m.", s.Fields))
+	fmt.Fprintln(b, "if err == sql.ErrNoRows {\nreturn nil, 2, err\n}")
+	genPQErrSwitch(b, "42P01", "undefined_table", "return nil, 4, err", "return nil, 0, err")
+	fmt.Fprintln(b, "return nil, 0, err\n}")
+	fmt.Fprintln(b, "return m, 0, nil\n}")
+}
+
+func (c modelConfig) genGetGroup(b *strings.Builder, s modelStruct, f modelField, conds []queryCond) {
+	clauses, params, arrs, _ := whereClause(conds, 3)
+	where := ""
+	if len(clauses) > 0 {
+		where = " WHERE " + strings.Join(clauses, " AND ")
+	}
+	fmt.Fprintf(b, "\nfunc %sGet%s%sOrd%s(ctx context.Context, d db.SQLExecutor%s, orderasc bool, limit, offset int) ([]%s, error) {\n", c.Prefix, s.Name, condName(conds), f.Name, condParams(conds), s.Name)
+	args := genArgs(b, append([]string{"limit", "offset"}, params...), arrs)
+	fmt.Fprintln(b, "order := \"DESC\"\nif orderasc {\norder = \"ASC\"\n}")
+	fmt.Fprintf(b, "res := make([]%s, 0, limit)\n", s.Name)
+	fmt.Fprintf(b, "rows, err := d.QueryContext(ctx, \"SELECT %s FROM %s%s ORDER BY %s \"+order+\" LIMIT $1 OFFSET $2;\", %s)\n", colList(s.Fields), c.TableName, where, f.Col, args)
+	genRows(b, s)
+}
+
+func (c modelConfig) genGetPage(b *strings.Builder, s modelStruct, f modelField, ties []modelField, conds []queryCond) {
+	keys := append([]modelField{f}, ties...)
+	clauses, params, arrs, n := whereClause(conds, 2)
+	keyCols := make([]string, 0, len(keys))
+	keyOrders := make([]string, 0, len(keys))
+	keyNames := make([]string, 0, len(keys))
+	keyParams := make([]string, 0, len(keys))
+	keyArgs := make([]string, 0, len(keys))
+	for _, i := range keys {
+		keyCols = append(keyCols, i.Col)
+		keyOrders = append(keyOrders, i.Col+" \"+order+\"")
+		keyNames = append(keyNames, i.Name)
+		keyParams = append(keyParams, fmt.Sprintf(", after%s %s", i.paramName(), i.Type))
+		keyArgs = append(keyArgs, "after"+i.paramName())
+	}
+	if len(keys) == 1 {
+		clauses = append(clauses, fmt.Sprintf("%s \"+cmp+\" $%d", f.Col, n))
+	} else {
+		clauses = append(clauses, fmt.Sprintf("(%s) \"+cmp+\" (%s)", strings.Join(keyCols, ", "), placeholderList(n, len(keys))))
+	}
+	fmt.Fprintf(b, "\nfunc %sGet%s%sPage%s(ctx context.Context, d db.SQLExecutor%s, orderasc bool%s, limit int) ([]%s, error) {\n", c.Prefix, s.Name, condName(conds), strings.Join(keyNames, ""), condParams(conds), strings.Join(keyParams, ""), s.Name)
+	params = append([]string{"limit"}, params...)
+	args := genArgs(b, append(params, keyArgs...), arrs)
+	fmt.Fprintln(b, "order := \"DESC\"\ncmp := \"<\"\nif orderasc {\norder = \"ASC\"\ncmp = \">\"\n}")
+	fmt.Fprintf(b, "res := make([]%s, 0, limit)\n", s.Name)
+	fmt.Fprintf(b, "rows, err := d.QueryContext(ctx, \"SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT $1;\", %s)\n", colList(s.Fields), c.TableName, strings.Join(clauses, " AND "), strings.Join(keyOrders, ", "), args)
+	genRows(b, s)
+}
+
+func (c modelConfig) genUpd(b *strings.Builder, conds []queryCond) {
+	fields := c.tableFields()
+	clauses, params, arrs, _ := whereClause(conds, len(fields)+1)
+	fmt.Fprintf(b, "\nfunc %sUpd%s%s(ctx context.Context, d db.SQLExecutor, m *%s%s) (int, error) {\n", c.Prefix, c.Model.Name, condName(conds), c.Model.Name, condParams(conds))
+	refs := make([]string, 0, len(fields))
+	for _, i := range fields {
+		refs = append(refs, "m."+i.Name)
+	}
+	args := genArgs(b, append(refs, params...), arrs)
+	fmt.Fprintf(b, "_, err := d.ExecContext(ctx, \"UPDATE %s SET (%s) = ROW(%s) WHERE %s;\", %s)\n", c.TableName, colList(fields), placeholderList(1, len(fields)), strings.Join(clauses, " AND "), args)
+	genExecErr(b)
+	fmt.Fprintln(b, "}")
+}
+
+func (c modelConfig) genDel(b *strings.Builder, conds []queryCond) {
+	clauses, params, arrs, _ := whereClause(conds, 1)
+	fmt.Fprintf(b, "\nfunc %sDel%s(ctx context.Context, d db.SQLExecutor%s) error {\n", c.Prefix, condName(conds), condParams(conds))
+	args := genArgs(b, params, arrs)
+	fmt.Fprintf(b, "_, err := d.ExecContext(ctx, \"DELETE FROM %s WHERE %s;\", %s)\n", c.TableName, strings.Join(clauses, " AND "), args)
+	fmt.Fprintln(b, "return err\n}")
+}
+
+func genRows(b *strings.Builder, s modelStruct) {
+	fmt.Fprintln(b, "if err != nil {\nreturn nil, err\n}")
+	fmt.Fprintln(b, "defer func() {\nif err := rows.Close(); err != nil {\n}\n}()")
+	fmt.Fprintln(b, "for rows.Next() {")
+	fmt.Fprintf(b, "m := %s{}\n", s.Name)
+	fmt.Fprintf(b, "if err := rows.Scan(%s); err != nil {\nreturn nil, err\n}\n", fieldRefs("&m.", s.Fields))
+	fmt.Fprintln(b, "res = append(res, m)\n}")
+	fmt.Fprintln(b, "if err := rows.Err(); err != nil {\nreturn nil, err\n}")
+	fmt.Fprintln(b, "return res, nil\n}")
+}
+
+func genExecErr(b *strings.Builder) {
+	fmt.Fprintln(b, "if err != nil {")
+	genPQErrSwitch(b, "23505", "unique_violation", "return 3, err", "return 0, err")
+	fmt.Fprintln(b, "}")
+	fmt.Fprintln(b, "return 0, nil")
+}
+
+func genPQErrSwitch(b *strings.Builder, code, codeName, onCode, onDefault string) {
+	fmt.Fprintln(b, "if postgresErr, ok := err.(*pq.Error); ok {")
+	fmt.Fprintln(b, "switch postgresErr.Code {")
+	fmt.Fprintf(b, "case %q: // %s\n%s\n", code, codeName, onCode)
+	fmt.Fprintf(b, "default:\n%s\n", onDefault)
+	fmt.Fprintln(b, "}\n}")
+}
+
+func colList(fields []modelField) string {
+	cols := make([]string, 0, len(fields))
+	for _, i := range fields {
+		cols = append(cols, i.Col)
+	}
+	return strings.Join(cols, ", ")
+}
+
+func fieldRefs(prefix string, fields []modelField) string {
+	refs := make([]string, 0, len(fields))
+	for _, i := range fields {
+		refs = append(refs, prefix+i.Name)
+	}
+	return strings.Join(refs, ", ")
+}
+
+func placeholderList(start, n int) string {
+	placeholders := make([]string, 0, n)
+	for i := start; i < start+n; i++ {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
+	}
+	return strings.Join(placeholders, ", ")
+}
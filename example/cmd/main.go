@@ -5,10 +5,16 @@ import (
 	"xorkevin.dev/governor/service/courier"
 	couriermodel "xorkevin.dev/governor/service/courier/model"
 	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/db/dbadmin"
 	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/events/eventsadmin"
 	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/kvstore/kvadmin"
+	"xorkevin.dev/governor/service/kvstore/nearcache"
 	"xorkevin.dev/governor/service/mail"
 	"xorkevin.dev/governor/service/objstore"
+	"xorkevin.dev/governor/service/outbox"
+	outboxmodel "xorkevin.dev/governor/service/outbox/model"
 	"xorkevin.dev/governor/service/profile"
 	profilemodel "xorkevin.dev/governor/service/profile/model"
 	"xorkevin.dev/governor/service/ratelimit"
@@ -63,6 +69,11 @@ func main() {
 	gov.Register("events", "/null/events", events.New())
 	gov.Register("template", "/null/tpl", template.New())
 	gov.Register("mail", "/null/mail", mail.NewCtx(gov.Injector()))
+	{
+		inj := gov.Injector()
+		outboxmodel.NewInCtx(inj)
+		gov.Register("outbox", "/null/outbox", outbox.NewCtx(inj))
+	}
 	{
 		inj := gov.Injector()
 		kvstore.NewSubtreeInCtx(inj, "ratelimit")
@@ -72,12 +83,14 @@ func main() {
 		inj := gov.Injector()
 		rolemodel.NewInCtx(inj)
 		kvstore.NewSubtreeInCtx(inj, "roles")
+		gov.Register("rolecache", "/null/rolecache", nearcache.NewInCtx(inj))
 		gov.Register("role", "/null/role", role.NewCtx(inj))
 	}
 	{
 		inj := gov.Injector()
 		apikeymodel.NewInCtx(inj)
 		kvstore.NewSubtreeInCtx(inj, "apikeys")
+		gov.Register("apikeycache", "/null/apikeycache", nearcache.NewInCtx(inj))
 		gov.Register("apikey", "/null/apikey", apikey.NewCtx(inj))
 	}
 	gov.Register("token", "/null/token", token.New())
@@ -102,6 +115,7 @@ func main() {
 		oauthmodel.NewInCtx(inj)
 		connmodel.NewInCtx(inj)
 		kvstore.NewSubtreeInCtx(inj, "oauth")
+		gov.Register("oauthcache", "/null/oauthcache", nearcache.NewInCtx(inj))
 		objstore.NewBucketInCtx(inj, "oauth-app-logo")
 		gov.Register("oauth", "/oauth", oauth.NewCtx(inj))
 	}
@@ -115,10 +129,15 @@ func main() {
 		inj := gov.Injector()
 		couriermodel.NewInCtx(inj)
 		kvstore.NewSubtreeInCtx(inj, "courier")
+		gov.Register("couriercache", "/null/couriercache", nearcache.NewInCtx(inj))
 		objstore.NewBucketInCtx(inj, "link-qr-image")
 		gov.Register("courier", "/courier", courier.NewCtx(inj))
 	}
+	gov.Register("dbadmin", "/admin/db", dbadmin.NewCtx(gov.Injector()))
+	gov.Register("kvadmin", "/admin/kv", kvadmin.NewCtx(gov.Injector()))
+	gov.Register("eventsadmin", "/admin/events", eventsadmin.NewCtx(gov.Injector()))
 
 	cmd := governor.NewCmd(opts, gov, governor.NewClient(opts))
+	eventsadmin.AddCmd(cmd)
 	cmd.Execute()
 }
@@ -3,6 +3,7 @@ module xorkevin.dev/governor
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/boombuler/barcode v1.0.0
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/go-chi/cors v1.1.1
@@ -11,14 +12,15 @@ require (
 	github.com/lib/pq v1.7.0
 	github.com/minio/minio-go/v6 v6.0.57
 	github.com/nats-io/jsm.go v0.0.23
-	github.com/nats-io/nats.go v1.11.0
+	github.com/nats-io/nats-server/v2 v2.7.3
+	github.com/nats-io/nats.go v1.15.0
 	github.com/rs/zerolog v1.19.0
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.0
 	github.com/stretchr/testify v1.7.0
-	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
+	golang.org/x/crypto v0.0.0-20220112180741-5e0467b6c7ce
 	golang.org/x/image v0.0.0-20200609002522-3f4726a040e8
-	google.golang.org/protobuf v1.24.0 // indirect
+	google.golang.org/protobuf v1.24.0
 	gopkg.in/square/go-jose.v2 v2.5.1
 	xorkevin.dev/hunter2 v0.1.5
 )
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"sync"
@@ -43,6 +44,8 @@ type (
 	// environment variables
 	Config struct {
 		config         *viper.Viper
+		secretSource   string
+		secretFile     string
 		vault          *vaultapi.Client
 		vaultK8sAuth   bool
 		vaultRole      string
@@ -50,6 +53,10 @@ type (
 		vaultLoginPath string
 		vaultExpire    int64
 		vaultCache     map[string]vaultSecret
+		vaultRetired   []vaultSecret
+		vaultBackoff   map[string]vaultBackoff
+		vaultFetch     map[string]*secretFetch
+		cachemu        *sync.Mutex
 		mu             *sync.RWMutex
 		appname        string
 		version        Version
@@ -65,6 +72,7 @@ type (
 		origins        []string
 		allowpaths     []*corsPathRule
 		rewrite        []*rewriteRule
+		secHeaders     map[string]string
 		Port           string
 		BaseURL        string
 		Hostname       string
@@ -149,6 +157,14 @@ func newConfig(opts Opts) *Config {
 	v.SetDefault("alloworigins", []string{})
 	v.SetDefault("allowpaths", []string{})
 	v.SetDefault("routerewrite", []*rewriteRule{})
+	v.SetDefault("secheaders.hsts", "")
+	v.SetDefault("secheaders.csp", "")
+	v.SetDefault("secheaders.nosniff", true)
+	v.SetDefault("secheaders.referrerpolicy", "strict-origin-when-cross-origin")
+	v.SetDefault("secheaders.permissionspolicy", "")
+	v.SetDefault("secheaders.frameoptions", "DENY")
+	v.SetDefault("secrets.source", secretSourceVault)
+	v.SetDefault("secrets.file", "secrets.yaml")
 	v.SetDefault("vault.addr", "")
 	v.SetDefault("vault.k8s.auth", false)
 	v.SetDefault("vault.k8s.role", "")
@@ -168,11 +184,14 @@ func newConfig(opts Opts) *Config {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
 
 	return &Config{
-		config:     v,
-		mu:         &sync.RWMutex{},
-		appname:    opts.Appname,
-		version:    opts.Version,
-		vaultCache: map[string]vaultSecret{},
+		config:       v,
+		cachemu:      &sync.Mutex{},
+		mu:           &sync.RWMutex{},
+		appname:      opts.Appname,
+		version:      opts.Version,
+		vaultCache:   map[string]vaultSecret{},
+		vaultBackoff: map[string]vaultBackoff{},
+		vaultFetch:   map[string]*secretFetch{},
 	}
 }
 
@@ -180,6 +199,25 @@ func (c *Config) setConfigFile(file string) {
 	c.config.SetConfigFile(file)
 }
 
+const (
+	secretSourceVault = "vault"
+	secretSourceFile  = "file"
+
+	// secretMinRotateMargin is the minimum time in seconds before a leased
+	// secret expires that it is rotated
+	secretMinRotateMargin = 5
+	// secretRenewRetry is the time in seconds after which a failed lease renewal
+	// is retried
+	secretRenewRetry = 5
+	// secretInvalidateBackoff is the time in seconds after an invalidation that
+	// further invalidations of a secret are ignored, doubled on each consecutive
+	// invalidation
+	secretInvalidateBackoff = 5
+	// secretInvalidateMaxBackoff is the max time in seconds after an
+	// invalidation that further invalidations of a secret are ignored
+	secretInvalidateMaxBackoff = 300
+)
+
 type (
 	// ErrInvalidConfig is returned when the config is invalid
 	ErrInvalidConfig struct{}
@@ -221,6 +259,21 @@ func (c *Config) init() error {
 		return err
 	}
 	c.rewrite = rewrite
+	c.secHeaders = map[string]string{}
+	for k, v := range map[string]string{
+		HeaderHSTS:              c.config.GetString("secheaders.hsts"),
+		HeaderCSP:               c.config.GetString("secheaders.csp"),
+		HeaderReferrerPolicy:    c.config.GetString("secheaders.referrerpolicy"),
+		HeaderPermissionsPolicy: c.config.GetString("secheaders.permissionspolicy"),
+		HeaderFrameOptions:      c.config.GetString("secheaders.frameoptions"),
+	} {
+		if v != "" {
+			c.secHeaders[k] = v
+		}
+	}
+	if c.config.GetBool("secheaders.nosniff") {
+		c.secHeaders[HeaderContentTypeOptions] = "nosniff"
+	}
 	c.Port = c.config.GetString("port")
 	c.BaseURL = c.config.GetString("baseurl")
 	var err error
@@ -228,8 +281,19 @@ func (c *Config) init() error {
 	if err != nil {
 		return err
 	}
-	if err := c.initvault(); err != nil {
-		return err
+	c.secretSource = c.config.GetString("secrets.source")
+	switch c.secretSource {
+	case secretSourceVault:
+		if err := c.initvault(); err != nil {
+			return err
+		}
+	case secretSourceFile:
+		c.secretFile = c.config.GetString("secrets.file")
+		if c.secretFile == "" {
+			return ErrWithKind(nil, ErrInvalidConfig{}, "No secrets file set")
+		}
+	default:
+		return ErrWithKind(nil, ErrInvalidConfig{}, "Invalid secret source")
 	}
 	return nil
 }
@@ -319,16 +383,87 @@ func (c *Config) authVault() error {
 	return nil
 }
 
+// getSecret returns a secret, reading or renewing it if needed
+//
+// Secrets are read and renewed without holding cachemu, since doing so calls
+// vault. Only one read or renewal of a key is in flight at a time, and
+// concurrent readers of the key wait for it, except while a renewal is in
+// flight, since the cached secret remains valid until it is renewed.
 func (c *Config) getSecret(key string) (vaultSecretVal, error) {
-	if s, ok := c.vaultCache[key]; ok && s.isValid() {
+	c.cachemu.Lock()
+	s, cached := c.vaultCache[key]
+	if cached && !s.isValid() {
+		cached = false
+	}
+	if cached && !s.shouldRenew() {
+		c.cachemu.Unlock()
 		return s.value, nil
 	}
+	if f, ok := c.vaultFetch[key]; ok {
+		c.cachemu.Unlock()
+		if cached {
+			return s.value, nil
+		}
+		<-f.done
+		return f.value, f.err
+	}
+	f := &secretFetch{
+		done: make(chan struct{}),
+	}
+	c.vaultFetch[key] = f
+	c.cachemu.Unlock()
+
+	if cached {
+		f.value, f.err = c.renewCachedSecret(key, s)
+	} else {
+		f.value, f.err = c.readSecret(key)
+	}
+
+	c.cachemu.Lock()
+	delete(c.vaultFetch, key)
+	c.cachemu.Unlock()
+	close(f.done)
+	return f.value, f.err
+}
 
+// renewCachedSecret renews a cached secret and publishes it to the cache
+//
+// The renewed secret is not published if the secret was invalidated while it
+// was being renewed.
+func (c *Config) renewCachedSecret(key string, s vaultSecret) (vaultSecretVal, error) {
+	leaseID := s.leaseID
+	c.renewSecret(&s)
+	if !s.isValid() {
+		return c.readSecret(key)
+	}
+
+	c.cachemu.Lock()
+	defer c.cachemu.Unlock()
+	if k, ok := c.vaultCache[key]; ok && k.leaseID == leaseID {
+		c.vaultCache[key] = s
+	}
+	return s.value, nil
+}
+
+// readSecret reads a secret from its source and publishes it to the cache
+func (c *Config) readSecret(key string) (vaultSecretVal, error) {
 	kvpath := c.config.GetString(key)
 	if kvpath == "" {
 		return nil, ErrWithKind(nil, ErrInvalidConfig{}, "Empty secret key "+key)
 	}
 
+	if c.secretSource == secretSourceFile {
+		data, err := c.readFileSecret(kvpath)
+		if err != nil {
+			return nil, err
+		}
+		c.cacheSecret(vaultSecret{
+			key:   key,
+			value: data,
+		})
+		return data, nil
+	}
+
 	if err := c.ensureValidAuth(); err != nil {
 		return nil, err
 	}
@@ -344,25 +479,181 @@ func (c *Config) getSecret(key string) (vaultSecretVal, error) {
 		data = v
 	}
 
-	var expire int64
+	secret := vaultSecret{
+		key:   key,
+		value: data,
+	}
 	if s.LeaseDuration > 0 {
-		expire = time.Now().Round(0).Unix() + int64(s.LeaseDuration)
-		k := c.vaultExpire
-		if expire > k {
-			expire = k
-		}
+		now := time.Now().Round(0).Unix()
+		secret.expire = c.clampLeaseExpire(now + int64(s.LeaseDuration))
+		secret.leaseID = s.LeaseID
+		secret.renewable = s.Renewable
+		secret.duration = int64(s.LeaseDuration)
+		secret.renewAt = now + secret.duration/2
 	}
-	c.vaultCache[key] = vaultSecret{
-		key:    key,
-		value:  data,
-		expire: expire,
+	c.cacheSecret(secret)
+
+	return data, nil
+}
+
+func (c *Config) cacheSecret(s vaultSecret) {
+	c.cachemu.Lock()
+	defer c.cachemu.Unlock()
+	c.vaultCache[s.key] = s
+}
+
+// clampLeaseExpire limits the expiration time of a lease to that of the vault
+// k8s auth token, since leases are revoked along with their token
+func (c *Config) clampLeaseExpire(expire int64) int64 {
+	if !c.vaultK8sAuth {
+		return expire
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if expire > c.vaultExpire {
+		return c.vaultExpire
 	}
+	return expire
+}
 
+// renewSecret renews the lease of a secret
+//
+// A secret whose lease can no longer be extended, such as when it has reached
+// its max ttl, is rotated once it is within its rotate margin of expiring.
+// Renewal failures are retried until then.
+func (c *Config) renewSecret(s *vaultSecret) {
+	now := time.Now().Round(0).Unix()
+	s.renewAt = now + secretRenewRetry
+	if err := c.ensureValidAuth(); err != nil {
+		return
+	}
+	res, err := c.vault.Sys().Renew(s.leaseID, int(s.duration))
+	if err != nil || res == nil || res.LeaseDuration <= 0 {
+		return
+	}
+	expire := c.clampLeaseExpire(now + int64(res.LeaseDuration))
+	if expire > s.expire {
+		s.expire = expire
+	}
+	if res.LeaseID != "" {
+		s.leaseID = res.LeaseID
+	}
+	s.renewable = res.Renewable
+	s.renewAt = now + int64(res.LeaseDuration)/2
+}
+
+// readFileSecret reads a secret from the secrets file
+//
+// The secrets file is a yaml file mapping secret paths to their values, where
+// dots in a secret path separate nested keys. It is read again on each cache
+// miss so that invalidated secrets are reloaded. Lists of strings are returned
+// as string slices.
+func (c *Config) readFileSecret(kvpath string) (vaultSecretVal, error) {
+	v := viper.New()
+	v.SetConfigFile(c.secretFile)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, ErrWithKind(err, ErrInvalidConfig{}, "Failed to read secrets file")
+	}
+	if !v.IsSet(kvpath) {
+		return nil, ErrWithKind(nil, ErrInvalidConfig{}, "Secret not found in secrets file "+kvpath)
+	}
+	data := v.GetStringMap(kvpath)
+	for k, val := range data {
+		if l, ok := val.([]interface{}); ok {
+			strs := make([]string, 0, len(l))
+			for _, i := range l {
+				str, ok := i.(string)
+				if !ok {
+					break
+				}
+				strs = append(strs, str)
+			}
+			if len(strs) == len(l) {
+				data[k] = strs
+			}
+		}
+	}
 	return data, nil
 }
 
+// invalidateSecret removes a secret from the cache so that it is read again
+//
+// A service invalidates a secret when it fails to authenticate with it, which
+// for a dynamic secret leases a new credential. Invalidations are therefore
+// rate limited per key with an exponential backoff so that a service retrying
+// a failed rotation does not lease a new credential on every attempt. The lease
+// of an invalidated secret is kept until the service revokes it with
+// revokeSecret once it is no longer in use.
 func (c *Config) invalidateSecret(key string) {
+	c.cachemu.Lock()
+	defer c.cachemu.Unlock()
+
+	now := time.Now().Round(0).Unix()
+	b := c.vaultBackoff[key]
+	if now < b.until {
+		return
+	}
+	if now-b.until > b.backoff {
+		b.backoff = secretInvalidateBackoff
+	} else {
+		b.backoff *= 2
+		if b.backoff > secretInvalidateMaxBackoff {
+			b.backoff = secretInvalidateMaxBackoff
+		}
+	}
+	b.until = now + b.backoff
+	c.vaultBackoff[key] = b
+
+	retired := c.vaultRetired[:0]
+	for _, i := range c.vaultRetired {
+		if i.expire > now {
+			retired = append(retired, i)
+		}
+	}
+	c.vaultRetired = retired
+
+	s, ok := c.vaultCache[key]
+	if !ok {
+		return
+	}
 	delete(c.vaultCache, key)
+	if s.leaseID != "" && s.expire > now {
+		c.vaultRetired = append(c.vaultRetired, s)
+	}
+}
+
+// revokeSecret revokes the lease of an invalidated secret
+//
+// Only secrets which have been invalidated are revoked, and the secret is
+// identified by its value, since that is what a service holds.
+func (c *Config) revokeSecret(key string, value vaultSecretVal) error {
+	s, ok := c.takeRetiredSecret(key, value)
+	if !ok {
+		return nil
+	}
+
+	if err := c.ensureValidAuth(); err != nil {
+		return err
+	}
+	if err := c.vault.Sys().Revoke(s.leaseID); err != nil {
+		return ErrWithKind(err, ErrVault{}, "Failed to revoke vault secret lease")
+	}
+	return nil
+}
+
+// takeRetiredSecret removes an invalidated secret from the retired secrets
+func (c *Config) takeRetiredSecret(key string, value vaultSecretVal) (vaultSecret, bool) {
+	c.cachemu.Lock()
+	defer c.cachemu.Unlock()
+
+	for n, i := range c.vaultRetired {
+		if i.key == key && reflect.DeepEqual(i.value, value) {
+			c.vaultRetired = append(c.vaultRetired[:n], c.vaultRetired[n+1:]...)
+			return i, true
+		}
+	}
+	return vaultSecret{}, false
 }
 
 // IsDebug returns if the configuration is in debug mode
@@ -370,6 +661,13 @@ func (c *Config) IsDebug() bool {
 	return c.logLevel == levelDebug
 }
 
+// AllowOrigins returns the configured allowed cross origin request origins
+func (c *Config) AllowOrigins() []string {
+	k := make([]string, len(c.origins))
+	copy(k, c.origins)
+	return k
+}
+
 type (
 	// ConfigRegistrar sets default values on the config parser
 	ConfigRegistrar interface {
@@ -411,14 +709,31 @@ type (
 	SecretReader interface {
 		GetSecret(key string) (vaultSecretVal, error)
 		InvalidateSecret(key string)
+		RevokeSecret(key string, value vaultSecretVal) error
 	}
 
 	vaultSecretVal map[string]interface{}
 
 	vaultSecret struct {
-		key    string
-		value  vaultSecretVal
-		expire int64
+		key       string
+		value     vaultSecretVal
+		expire    int64
+		leaseID   string
+		renewable bool
+		duration  int64
+		renewAt   int64
+	}
+
+	vaultBackoff struct {
+		until   int64
+		backoff int64
+	}
+
+	// secretFetch is an in flight read or renewal of a secret
+	secretFetch struct {
+		done  chan struct{}
+		value vaultSecretVal
+		err   error
 	}
 
 	configReader struct {
@@ -464,8 +779,27 @@ func (r *configReader) Unmarshal(key string, val interface{}) error {
 	return r.c.config.UnmarshalKey(r.name+"."+key, val)
 }
 
+// rotateMargin returns the time in seconds before a secret expires that it
+// should be rotated
+//
+// Secrets are rotated well before they expire so that services may switch to
+// new credentials while in flight requests finish with the old ones.
+func (s *vaultSecret) rotateMargin() int64 {
+	if m := s.duration / 5; m > secretMinRotateMargin {
+		return m
+	}
+	return secretMinRotateMargin
+}
+
 func (s *vaultSecret) isValid() bool {
-	return s.expire == 0 || s.expire-time.Now().Round(0).Unix() > 5
+	return s.expire == 0 || s.expire-time.Now().Round(0).Unix() > s.rotateMargin()
+}
+
+func (s *vaultSecret) shouldRenew() bool {
+	if !s.renewable || s.leaseID == "" || s.expire == 0 {
+		return false
+	}
+	return time.Now().Round(0).Unix() >= s.renewAt && s.isValid()
 }
 
 func (r *configReader) GetSecret(key string) (vaultSecretVal, error) {
@@ -476,6 +810,10 @@ func (r *configReader) InvalidateSecret(key string) {
 	r.c.invalidateSecret(r.name + "." + key)
 }
 
+func (r *configReader) RevokeSecret(key string, value vaultSecretVal) error {
+	return r.c.revokeSecret(r.name+"."+key, value)
+}
+
 func (c *Config) reader(opt serviceOpt) ConfigReader {
 	return &configReader{
 		serviceOpt: opt,
@@ -0,0 +1,186 @@
+package governor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultSecretLease(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Round(0).Unix()
+
+	for _, tc := range []struct {
+		Test   string
+		Secret vaultSecret
+		Valid  bool
+		Renew  bool
+	}{
+		{
+			Test:   "unleased secret",
+			Secret: vaultSecret{},
+			Valid:  true,
+			Renew:  false,
+		},
+		{
+			Test: "fresh lease",
+			Secret: vaultSecret{
+				expire:    now + 3600,
+				leaseID:   "lease",
+				renewable: true,
+				duration:  3600,
+				renewAt:   now + 1800,
+			},
+			Valid: true,
+			Renew: false,
+		},
+		{
+			Test: "lease past renew time",
+			Secret: vaultSecret{
+				expire:    now + 1200,
+				leaseID:   "lease",
+				renewable: true,
+				duration:  3600,
+				renewAt:   now - 600,
+			},
+			Valid: true,
+			Renew: true,
+		},
+		{
+			Test: "non renewable lease past renew time",
+			Secret: vaultSecret{
+				expire:   now + 1200,
+				leaseID:  "lease",
+				duration: 3600,
+				renewAt:  now - 600,
+			},
+			Valid: true,
+			Renew: false,
+		},
+		{
+			Test: "lease within rotate margin",
+			Secret: vaultSecret{
+				expire:    now + 600,
+				leaseID:   "lease",
+				renewable: true,
+				duration:  3600,
+				renewAt:   now - 600,
+			},
+			Valid: false,
+			Renew: false,
+		},
+		{
+			Test: "short lease within min rotate margin",
+			Secret: vaultSecret{
+				expire:   now + 4,
+				leaseID:  "lease",
+				duration: 10,
+				renewAt:  now - 1,
+			},
+			Valid: false,
+			Renew: false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			assert.Equal(tc.Valid, tc.Secret.isValid())
+			assert.Equal(tc.Renew, tc.Secret.shouldRenew())
+		})
+	}
+}
+
+func TestInvalidateSecret(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	now := time.Now().Round(0).Unix()
+	c := newConfig(Opts{})
+	c.vaultCache["test.auth"] = vaultSecret{
+		key:     "test.auth",
+		value:   vaultSecretVal{"username": "user1"},
+		expire:  now + 3600,
+		leaseID: "lease1",
+	}
+	c.invalidateSecret("test.auth")
+	_, ok := c.vaultCache["test.auth"]
+	assert.False(ok, "Should remove the secret from the cache")
+	assert.Len(c.vaultRetired, 1, "Should keep the lease of the secret until it is revoked")
+	assert.Equal(int64(secretInvalidateBackoff), c.vaultBackoff["test.auth"].backoff)
+
+	c.vaultCache["test.auth"] = vaultSecret{
+		key:     "test.auth",
+		value:   vaultSecretVal{"username": "user2"},
+		expire:  now + 3600,
+		leaseID: "lease2",
+	}
+	c.invalidateSecret("test.auth")
+	_, ok = c.vaultCache["test.auth"]
+	assert.True(ok, "Should ignore invalidations during the backoff")
+
+	c.vaultBackoff["test.auth"] = vaultBackoff{
+		until:   now - 1,
+		backoff: secretInvalidateBackoff,
+	}
+	c.invalidateSecret("test.auth")
+	assert.Equal(int64(2*secretInvalidateBackoff), c.vaultBackoff["test.auth"].backoff, "Should double the backoff of consecutive invalidations")
+	assert.Len(c.vaultRetired, 2)
+
+	c.vaultBackoff["test.auth"] = vaultBackoff{
+		until:   now - secretInvalidateMaxBackoff - 1,
+		backoff: secretInvalidateMaxBackoff,
+	}
+	c.invalidateSecret("test.auth")
+	assert.Equal(int64(secretInvalidateBackoff), c.vaultBackoff["test.auth"].backoff, "Should reset the backoff after a quiet period")
+
+	assert.NoError(c.revokeSecret("test.auth", vaultSecretVal{"username": "user3"}))
+	assert.Len(c.vaultRetired, 2, "Should not revoke secrets which have not been invalidated")
+}
+
+func TestGetSecretInFlight(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	now := time.Now().Round(0).Unix()
+	c := newConfig(Opts{})
+	f := &secretFetch{
+		done: make(chan struct{}),
+	}
+	c.vaultFetch["test.auth"] = f
+
+	res := make(chan vaultSecretVal)
+	go func() {
+		v, _ := c.getSecret("test.auth")
+		res <- v
+	}()
+	select {
+	case <-res:
+		assert.Fail("Should wait for the in flight read of the secret")
+	case <-time.After(10 * time.Millisecond):
+	}
+	f.value = vaultSecretVal{"username": "user1"}
+	close(f.done)
+	assert.Equal(vaultSecretVal{"username": "user1"}, <-res, "Should return the secret of the in flight read")
+
+	c.vaultFetch["test.auth"] = &secretFetch{
+		done: make(chan struct{}),
+	}
+	c.vaultCache["test.auth"] = vaultSecret{
+		key:       "test.auth",
+		value:     vaultSecretVal{"username": "user2"},
+		expire:    now + 3600,
+		leaseID:   "lease2",
+		renewable: true,
+		renewAt:   now - 1,
+	}
+	v, err := c.getSecret("test.auth")
+	assert.NoError(err)
+	assert.Equal(vaultSecretVal{"username": "user2"}, v, "Should return the cached secret while it is being renewed")
+}
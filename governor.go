@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -96,6 +97,18 @@ func (s *Server) init(ctx context.Context) error {
 	i.Use(s.reqLoggerMiddleware)
 	l.Info("init request logger", nil)
 
+	if len(s.config.secHeaders) > 0 {
+		k := make([]string, 0, len(s.config.secHeaders))
+		for h := range s.config.secHeaders {
+			k = append(k, h)
+		}
+		sort.Strings(k)
+		i.Use(secHeadersMiddleware(s.config.secHeaders))
+		l.Info("init security headers middleware", map[string]string{
+			"headers": strings.Join(k, ", "),
+		})
+	}
+
 	if len(s.config.rewrite) > 0 {
 		k := make([]string, 0, len(s.config.rewrite))
 		for _, i := range s.config.rewrite {
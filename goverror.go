@@ -164,16 +164,26 @@ func (c *govcontext) WriteError(err error) {
 		})
 	}
 
+	rerr := ToErrorRes(err)
+	c.WriteJSON(rerr.Status, rerr)
+}
+
+// ToErrorRes returns the error response of an error
+//
+// Errors without a response are internal server errors with the code and
+// message of the top governor error if any.
+func ToErrorRes(err error) *ErrorRes {
 	rerr := &ErrorRes{}
-	if !errors.As(err, rerr) {
-		rerr.Status = http.StatusInternalServerError
-		if isError {
-			rerr.Code = gerr.Code
-			rerr.Message = gerr.Message
-		} else {
-			rerr.Message = "Internal Server Error"
-		}
+	if errors.As(err, rerr) {
+		return rerr
 	}
-
-	c.WriteJSON(rerr.Status, rerr)
+	rerr.Status = http.StatusInternalServerError
+	gerr := &Error{}
+	if errors.As(err, gerr) {
+		rerr.Code = gerr.Code
+		rerr.Message = gerr.Message
+	} else {
+		rerr.Message = "Internal Server Error"
+	}
+	return rerr
 }
@@ -3,6 +3,8 @@ package governor
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"mime"
@@ -12,6 +14,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
@@ -119,6 +122,7 @@ type (
 	// Context is an http request and writer wrapper
 	Context interface {
 		RealIP() net.IP
+		CSPNonce() string
 		Param(key string) string
 		Query(key string) string
 		QueryDef(key string, def string) string
@@ -136,12 +140,27 @@ type (
 		WriteString(status int, text string)
 		WriteJSON(status int, body interface{})
 		WriteFile(status int, contentType string, r io.Reader)
+		ServeObject(info ServeInfo, r io.Reader)
 		WriteError(err error)
 		Get(key interface{}) interface{}
 		Set(key, value interface{})
 		Req() *http.Request
 		Res() http.ResponseWriter
 		R() (http.ResponseWriter, *http.Request)
+		Ctx() context.Context
+	}
+
+	// ServeInfo describes an object served by ServeObject
+	//
+	// LastModified is a unix timestamp in seconds. A zero Size or LastModified
+	// is treated as unknown.
+	ServeInfo struct {
+		ContentType  string
+		Size         int64
+		ETag         string
+		LastModified int64
+		Filename     string
+		Attachment   bool
 	}
 
 	govcontext struct {
@@ -174,6 +193,10 @@ func (c *govcontext) RealIP() net.IP {
 	return net.ParseIP(host)
 }
 
+func (c *govcontext) CSPNonce() string {
+	return getCtxKeyMiddlewareCSPNonce(c.r.Context())
+}
+
 func (c *govcontext) Param(key string) string {
 	return chi.URLParam(c.r, key)
 }
@@ -338,6 +361,72 @@ func (c *govcontext) WriteFile(status int, contentType string, r io.Reader) {
 	}
 }
 
+// ServeObject writes an object to the response
+//
+// If r is an io.ReadSeeker, byte ranges including multiple ranges, If-Range,
+// and If-Modified-Since are handled by http.ServeContent. Otherwise the whole
+// object is streamed. An ETag already set on the response, e.g. by
+// cachecontrol, takes precedence over the etag of the object.
+func (c *govcontext) ServeObject(info ServeInfo, r io.Reader) {
+	h := c.w.Header()
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	if info.ETag != "" && h.Get("ETag") == "" {
+		h.Set("ETag", `"`+info.ETag+`"`)
+	}
+	disposition := "inline"
+	if info.Attachment {
+		disposition = "attachment"
+	}
+	if info.Filename != "" {
+		if k := mime.FormatMediaType(disposition, map[string]string{"filename": info.Filename}); k != "" {
+			disposition = k
+		}
+	}
+	h.Set("Content-Disposition", disposition)
+	var modtime time.Time
+	if info.LastModified > 0 {
+		modtime = time.Unix(info.LastModified, 0)
+	}
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(c.w, c.r, "", modtime, rs)
+		return
+	}
+
+	h.Set("Accept-Ranges", "none")
+	if !modtime.IsZero() {
+		h.Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+		if c.r.Header.Get("If-None-Match") == "" && (c.r.Method == http.MethodGet || c.r.Method == http.MethodHead) {
+			if t, err := http.ParseTime(c.r.Header.Get("If-Modified-Since")); err == nil && !modtime.After(t) {
+				h.Del("Content-Type")
+				h.Del("Content-Disposition")
+				c.w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	if info.Size > 0 {
+		h.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	c.w.WriteHeader(http.StatusOK)
+	if c.r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(c.w, r); err != nil {
+		if c.l != nil {
+			c.l.Error("Failed to write object", map[string]string{
+				"endpoint": c.r.URL.EscapedPath(),
+				"error":    err.Error(),
+			})
+		}
+		return
+	}
+}
+
 func (c *govcontext) Get(key interface{}) interface{} {
 	return c.r.Context().Value(key)
 }
@@ -364,6 +453,13 @@ func (c *govcontext) R() (http.ResponseWriter, *http.Request) {
 	return c.Res(), c.Req()
 }
 
+func (c *govcontext) Ctx() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return c.r.Context()
+}
+
 func (s *Server) bodyLimitMiddleware(limit int64) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -382,6 +478,21 @@ func (s *Server) bodyLimitMiddleware(limit int64) Middleware {
 	}
 }
 
+// Timeout returns a middleware that sets a deadline on the request context
+//
+// Requests have no deadline unless a route opts in with Timeout. Routes that
+// stream a response, such as serving objects, should not set one, since their
+// duration depends on the client.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func stripSlashesMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		r2 := new(http.Request)
@@ -491,3 +602,127 @@ func ipnetsContain(ip net.IP, ipnet []net.IPNet) bool {
 	}
 	return false
 }
+
+const (
+	// HeaderHSTS is the strict transport security header
+	HeaderHSTS = "Strict-Transport-Security"
+	// HeaderCSP is the content security policy header
+	HeaderCSP = "Content-Security-Policy"
+	// HeaderContentTypeOptions is the content type options header
+	HeaderContentTypeOptions = "X-Content-Type-Options"
+	// HeaderReferrerPolicy is the referrer policy header
+	HeaderReferrerPolicy = "Referrer-Policy"
+	// HeaderPermissionsPolicy is the permissions policy header
+	HeaderPermissionsPolicy = "Permissions-Policy"
+	// HeaderFrameOptions is the frame options header
+	HeaderFrameOptions = "X-Frame-Options"
+)
+
+const (
+	// CSPSandbox is a content security policy for user uploaded content that
+	// disallows loading any resources and sandboxes the response
+	CSPSandbox = "default-src 'none'; sandbox"
+)
+
+const (
+	// CSPNoncePlaceholder is replaced in security header values by the csp
+	// nonce of the request
+	CSPNoncePlaceholder = "{{nonce}}"
+
+	cspNonceSize = 16
+)
+
+type (
+	ctxKeyMiddlewareCSPNonce struct{}
+)
+
+func getCtxKeyMiddlewareCSPNonce(ctx context.Context) string {
+	k := ctx.Value(ctxKeyMiddlewareCSPNonce{})
+	if k == nil {
+		return ""
+	}
+	return k.(string)
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, cspNonceSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func headersHaveNonce(headers map[string]string) bool {
+	for _, v := range headers {
+		if strings.Contains(v, CSPNoncePlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSecHeaders sets the security headers on the response, and returns the
+// request with a csp nonce in its context if one is required
+//
+// An empty header value removes the header from the response.
+func writeSecHeaders(w http.ResponseWriter, r *http.Request, headers map[string]string, hasNonce bool) (*http.Request, error) {
+	nonce := ""
+	if hasNonce {
+		nonce = getCtxKeyMiddlewareCSPNonce(r.Context())
+		if nonce == "" {
+			var err error
+			nonce, err = newCSPNonce()
+			if err != nil {
+				return nil, err
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyMiddlewareCSPNonce{}, nonce))
+		}
+	}
+	for k, v := range headers {
+		if v == "" {
+			w.Header().Del(k)
+			continue
+		}
+		if hasNonce {
+			v = strings.ReplaceAll(v, CSPNoncePlaceholder, nonce)
+		}
+		w.Header().Set(k, v)
+	}
+	return r, nil
+}
+
+func secHeadersMiddleware(headers map[string]string) Middleware {
+	hasNonce := headersHaveNonce(headers)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r2, err := writeSecHeaders(w, r, headers, hasNonce)
+			if err != nil {
+				NewContext(w, r, nil).WriteError(ErrWithMsg(err, "Failed to generate csp nonce"))
+				return
+			}
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// SecHeaders returns a middleware that overrides the security headers set by
+// the server for a route
+//
+// An empty header value removes the header from the response. Occurrences of
+// CSPNoncePlaceholder in header values are replaced by the csp nonce of the
+// request.
+func SecHeaders(headers map[string]string) Middleware {
+	k := make(map[string]string, len(headers))
+	for h, v := range headers {
+		k[http.CanonicalHeaderKey(h)] = v
+	}
+	return secHeadersMiddleware(k)
+}
+
+// SecHeadersSandbox returns a middleware that sets the CSPSandbox content
+// security policy for a route serving user uploaded content
+func SecHeadersSandbox() Middleware {
+	return SecHeaders(map[string]string{
+		HeaderCSP: CSPSandbox,
+	})
+}
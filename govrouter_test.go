@@ -0,0 +1,165 @@
+package governor
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeObject(t *testing.T) {
+	t.Parallel()
+
+	modtime := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	info := ServeInfo{
+		ContentType:  "text/plain",
+		Size:         10,
+		ETag:         "abc",
+		LastModified: modtime.Unix(),
+		Filename:     "obj.txt",
+	}
+
+	for _, tc := range []struct {
+		Test        string
+		Seeker      bool
+		File        bool
+		Header      map[string]string
+		Status      int
+		Body        string
+		Disposition string
+	}{
+		{
+			Test:        "serves the whole object",
+			Seeker:      true,
+			Status:      http.StatusOK,
+			Body:        "0123456789",
+			Disposition: `inline; filename=obj.txt`,
+		},
+		{
+			Test:   "serves a byte range",
+			Seeker: true,
+			Header: map[string]string{
+				"Range": "bytes=2-4",
+			},
+			Status: http.StatusPartialContent,
+			Body:   "234",
+		},
+		{
+			Test:   "ignores the range on a stale If-Range",
+			Seeker: true,
+			Header: map[string]string{
+				"Range":    "bytes=2-4",
+				"If-Range": `"stale"`,
+			},
+			Status: http.StatusOK,
+			Body:   "0123456789",
+		},
+		{
+			Test:   "serves a matching If-Range",
+			Seeker: true,
+			Header: map[string]string{
+				"Range":    "bytes=2-4",
+				"If-Range": `"abc"`,
+			},
+			Status: http.StatusPartialContent,
+			Body:   "234",
+		},
+		{
+			Test: "serves a byte range of a file section",
+			File: true,
+			Header: map[string]string{
+				"Range": "bytes=7-",
+			},
+			Status: http.StatusPartialContent,
+			Body:   "789",
+		},
+		{
+			Test:   "returns not modified for a seeker",
+			Seeker: true,
+			Header: map[string]string{
+				"If-Modified-Since": modtime.Format(http.TimeFormat),
+			},
+			Status: http.StatusNotModified,
+		},
+		{
+			Test:        "streams a non seeker",
+			Status:      http.StatusOK,
+			Body:        "0123456789",
+			Disposition: `inline; filename=obj.txt`,
+		},
+		{
+			Test: "returns not modified for a non seeker",
+			Header: map[string]string{
+				"If-Modified-Since": modtime.Add(time.Hour).Format(http.TimeFormat),
+			},
+			Status: http.StatusNotModified,
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/obj", nil)
+			for k, v := range tc.Header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			c := NewContext(rec, req, nil)
+			if tc.File {
+				// objects of the fs backend are sections of files followed by
+				// their metadata
+				f, err := os.CreateTemp(t.TempDir(), "obj-")
+				assert.NoError(err)
+				_, err = f.WriteString(`0123456789{"etag":"abc"}`)
+				assert.NoError(err)
+				obj := struct {
+					*io.SectionReader
+					io.Closer
+				}{
+					SectionReader: io.NewSectionReader(f, 0, 10),
+					Closer:        f,
+				}
+				c.ServeObject(info, obj)
+				assert.NoError(obj.Close())
+			} else if tc.Seeker {
+				c.ServeObject(info, strings.NewReader("0123456789"))
+			} else {
+				c.ServeObject(info, bytes.NewBufferString("0123456789"))
+			}
+			assert.Equal(tc.Status, rec.Code)
+			assert.Equal(tc.Body, rec.Body.String())
+			if tc.Disposition != "" {
+				assert.Equal(tc.Disposition, rec.Header().Get("Content-Disposition"))
+			}
+			if tc.Status == http.StatusOK {
+				assert.Equal("10", rec.Header().Get("Content-Length"))
+				assert.Equal(modtime.Format(http.TimeFormat), rec.Header().Get("Last-Modified"))
+				assert.Equal(`"abc"`, rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	start := time.Now()
+	var deadline time.Time
+	var ok bool
+	h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(ok, "Should set a deadline on the request context")
+	assert.WithinDuration(start.Add(time.Second), deadline, 500*time.Millisecond, "Should set the route deadline")
+}
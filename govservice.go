@@ -33,8 +33,8 @@ type (
 	Service interface {
 		Register(inj Injector, r ConfigRegistrar, jr JobRegistrar)
 		Init(ctx context.Context, c Config, r ConfigReader, l Logger, m Router) error
-		Setup(req ReqSetup) error
-		PostSetup(req ReqSetup) error
+		Setup(ctx context.Context, req ReqSetup) error
+		PostSetup(ctx context.Context, req ReqSetup) error
 		Start(ctx context.Context) error
 		Stop(ctx context.Context)
 		Health() error
@@ -63,12 +63,12 @@ func (s *Server) Register(name string, url string, r Service) {
 	r.Register(s.inj, s.config.registrar(name), nil)
 }
 
-func (s *Server) setupServices(rsetup ReqSetup) error {
+func (s *Server) setupServices(ctx context.Context, rsetup ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
 	if !s.firstSetupRun {
-		m, err := s.state.Get()
+		m, err := s.state.Get(ctx)
 		if err != nil {
 			return NewError(ErrOptRes(ErrorRes{
 				Status:  http.StatusInternalServerError,
@@ -115,7 +115,7 @@ func (s *Server) setupServices(rsetup ReqSetup) error {
 
 	l.Info("Setup all services begin", nil)
 	for _, i := range s.services {
-		if err := i.r.Setup(rsetup); err != nil {
+		if err := i.r.Setup(ctx, rsetup); err != nil {
 			l.Error(fmt.Sprintf("Setup service %s failed", i.name), map[string]string{
 				"service": i.name,
 				"error":   err.Error(),
@@ -129,7 +129,7 @@ func (s *Server) setupServices(rsetup ReqSetup) error {
 
 	l.Info("Running PostSetup for all services", nil)
 	for _, i := range s.services {
-		if err := i.r.PostSetup(rsetup); err != nil {
+		if err := i.r.PostSetup(ctx, rsetup); err != nil {
 			l.Error(fmt.Sprintf("Post setup service %s failed", i.name), map[string]string{
 				"service": i.name,
 				"error":   err.Error(),
@@ -142,7 +142,7 @@ func (s *Server) setupServices(rsetup ReqSetup) error {
 	}
 
 	if rsetup.First {
-		if err := s.state.Setup(state.ReqSetup{
+		if err := s.state.Setup(ctx, state.ReqSetup{
 			Version: s.config.version.Num,
 			VHash:   s.config.version.Hash,
 		}); err != nil {
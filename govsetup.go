@@ -70,7 +70,7 @@ func (s *Server) initSetup(m Router) {
 			c.WriteError(err)
 			return
 		}
-		if err := s.setupServices(*req); err != nil {
+		if err := s.setupServices(c.Ctx(), *req); err != nil {
 			c.WriteError(err)
 			return
 		}
@@ -7,9 +7,10 @@ import (
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/courier/model"
-	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/kvstore/nearcache"
 	"xorkevin.dev/governor/service/objstore"
 	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/governor/util/bytefmt"
 )
 
 const (
@@ -29,15 +30,20 @@ type (
 
 	service struct {
 		repo          model.Repo
-		kvlinks       kvstore.KVStore
+		kvlinks       nearcache.Cache
 		courierBucket objstore.Bucket
 		linkImgDir    objstore.Dir
 		brandImgDir   objstore.Dir
+		brandUpDir    objstore.Dir
 		gate          gate.Gate
 		logger        governor.Logger
 		fallbackLink  string
 		linkPrefix    string
 		cacheTime     int64
+		reconcileTime time.Duration
+		brandQuota    int64
+		brandImgQuota *objstore.Quota
+		done          <-chan struct{}
 	}
 
 	router struct {
@@ -64,20 +70,21 @@ func setCtxCourier(inj governor.Injector, c Courier) {
 // NewCtx creates a new Courier service from a context
 func NewCtx(inj governor.Injector) Service {
 	repo := model.GetCtxRepo(inj)
-	kv := kvstore.GetCtxKVStore(inj)
+	kv := nearcache.GetCtxCache(inj)
 	obj := objstore.GetCtxBucket(inj)
 	g := gate.GetCtxGate(inj)
 	return New(repo, kv, obj, g)
 }
 
 // New creates a new Courier service
-func New(repo model.Repo, kv kvstore.KVStore, obj objstore.Bucket, g gate.Gate) Service {
+func New(repo model.Repo, kv nearcache.Cache, obj objstore.Bucket, g gate.Gate) Service {
 	return &service{
 		repo:          repo,
 		kvlinks:       kv.Subtree("links"),
 		courierBucket: obj,
 		linkImgDir:    obj.Subdir("qr"),
 		brandImgDir:   obj.Subdir("brand"),
+		brandUpDir:    obj.Subdir("brandupload"),
 		gate:          g,
 		cacheTime:     time24h,
 	}
@@ -89,6 +96,8 @@ func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr
 	r.SetDefault("fallbacklink", "")
 	r.SetDefault("linkprefix", "")
 	r.SetDefault("cachetime", "24h")
+	r.SetDefault("reconciletime", "24h")
+	r.SetDefault("brandquota", "16M")
 }
 
 func (s *service) router() *router {
@@ -110,6 +119,17 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	} else {
 		s.cacheTime = int64(t / time.Second)
 	}
+	if t, err := time.ParseDuration(r.GetStr("reconciletime")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse reconcile time")
+	} else {
+		s.reconcileTime = t
+	}
+	if q, err := bytefmt.ToBytes(r.GetStr("brandquota")); err != nil {
+		return governor.ErrWithMsg(err, "Invalid brand quota")
+	} else {
+		s.brandQuota = q
+	}
+	s.brandImgQuota = objstore.NewQuota(s.brandImgDir, s.brandQuota)
 	if len(s.fallbackLink) == 0 {
 		l.Warn("fallbacklink is not set", nil)
 	} else if err := validURL(s.fallbackLink); err != nil {
@@ -122,9 +142,11 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	}
 
 	l.Info("loaded config", map[string]string{
-		"fallbacklink": s.fallbackLink,
-		"linkprefix":   s.linkPrefix,
-		"cachetime":    strconv.FormatInt(s.cacheTime, 10),
+		"fallbacklink":  s.fallbackLink,
+		"linkprefix":    s.linkPrefix,
+		"cachetime":     strconv.FormatInt(s.cacheTime, 10),
+		"reconciletime": s.reconcileTime.String(),
+		"brandquota":    strconv.FormatInt(s.brandQuota, 10),
 	})
 
 	sr := s.router()
@@ -133,31 +155,75 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
-	if err := s.repo.Setup(); err != nil {
+	if err := s.repo.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("Created courierlinks table", nil)
 
-	if err := s.courierBucket.Init(); err != nil {
+	if err := s.courierBucket.Init(ctx); err != nil {
 		return governor.ErrWithMsg(err, "Failed to init courier bucket")
 	}
 	l.Info("Created courier bucket", nil)
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
 func (s *service) Start(ctx context.Context) error {
+	if s.reconcileTime > 0 {
+		done := make(chan struct{})
+		go s.reconcile(ctx, done)
+		s.done = done
+	}
 	return nil
 }
 
+// reconcile periodically removes stored objects with no matching db row
+func (s *service) reconcile(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.reconcileTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.reconcileLinkImages(ctx)
+			if err != nil {
+				s.logger.Error("failed to reconcile link images", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "reconcilelinkimages",
+				})
+			}
+			if count > 0 {
+				s.logger.Info("removed orphaned link images", map[string]string{
+					"actiontype": "reconcilelinkimages",
+					"count":      strconv.Itoa(count),
+				})
+			}
+		}
+	}
+}
+
 func (s *service) Stop(ctx context.Context) {
+	if s.done == nil {
+		return
+	}
+	l := s.logger.WithData(map[string]string{
+		"phase": "stop",
+	})
+	select {
+	case <-s.done:
+		return
+	case <-ctx.Done():
+		l.Warn("failed to stop", nil)
+	}
 }
 
 func (s *service) Health() error {
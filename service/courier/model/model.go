@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -8,8 +9,8 @@ import (
 	"xorkevin.dev/governor/util/uid"
 )
 
-//go:generate forge model -m LinkModel -t courierlinks -p link -o modellink_gen.go LinkModel
-//go:generate forge model -m BrandModel -t courierbrands -p brand -o modelbrand_gen.go BrandModel
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m LinkModel -t courierlinks -p link -o modellink_gen.go LinkModel
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m BrandModel -t courierbrands -p brand -o modelbrand_gen.go BrandModel
 
 const (
 	defaultUIDSize = 8
@@ -20,16 +21,18 @@ type (
 	Repo interface {
 		NewLink(creatorid, linkid, url string) *LinkModel
 		NewLinkAuto(creatorid, url string) (*LinkModel, error)
-		GetLinkGroup(creatorid string, limit, offset int) ([]LinkModel, error)
-		GetLink(linkid string) (*LinkModel, error)
-		InsertLink(m *LinkModel) error
-		DeleteLink(m *LinkModel) error
+		GetLinkGroup(ctx context.Context, creatorid string, limit, offset int) ([]LinkModel, error)
+		GetLinkGroupAfter(ctx context.Context, creatorid string, cursor *db.Cursor, limit int) ([]LinkModel, error)
+		GetLink(ctx context.Context, linkid string) (*LinkModel, error)
+		GetLinksByID(ctx context.Context, linkids []string) ([]LinkModel, error)
+		InsertLink(ctx context.Context, m *LinkModel) error
+		DeleteLink(ctx context.Context, m *LinkModel) error
 		NewBrand(creatorid, brandid string) *BrandModel
-		GetBrandGroup(creatorid string, limit, offset int) ([]BrandModel, error)
-		GetBrand(creatorid, brandid string) (*BrandModel, error)
-		InsertBrand(m *BrandModel) error
-		DeleteBrand(m *BrandModel) error
-		Setup() error
+		GetBrandGroup(ctx context.Context, creatorid string, limit, offset int) ([]BrandModel, error)
+		GetBrand(ctx context.Context, creatorid, brandid string) (*BrandModel, error)
+		InsertBrand(ctx context.Context, m *BrandModel) error
+		DeleteBrand(ctx context.Context, m *BrandModel) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -38,10 +41,10 @@ type (
 
 	// LinkModel is the db link model
 	LinkModel struct {
-		LinkID       string `model:"linkid,VARCHAR(63) PRIMARY KEY" query:"linkid,getoneeq,linkid;deleq,linkid"`
+		LinkID       string `model:"linkid,VARCHAR(63) PRIMARY KEY" query:"linkid,getoneeq,linkid;getgroupeq,linkid|arr;deleq,linkid"`
 		URL          string `model:"url,VARCHAR(2047) NOT NULL" query:"url"`
 		CreatorID    string `model:"creatorid,VARCHAR(31) NOT NULL;index" query:"creatorid"`
-		CreationTime int64  `model:"creation_time,BIGINT NOT NULL;index" query:"creation_time,getgroup;getgroupeq,creatorid"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL;index" query:"creation_time,getgroup;getgroupeq,creatorid;getpage,linkid|tie;getpageeq,creatorid,linkid|tie"`
 	}
 
 	// BrandModel is the db brand model
@@ -106,21 +109,49 @@ func (r *repo) NewLinkAuto(creatorid, url string) (*LinkModel, error) {
 }
 
 // GetLinkGroup gets a list of links ordered by creation time
-func (r *repo) GetLinkGroup(creatorid string, limit, offset int) ([]LinkModel, error) {
-	d, err := r.db.DB()
+func (r *repo) GetLinkGroup(ctx context.Context, creatorid string, limit, offset int) ([]LinkModel, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
 	if err != nil {
 		return nil, err
 	}
 
 	if creatorid != "" {
-		m, err := linkModelGetLinkModelEqCreatorIDOrdCreationTime(d, creatorid, false, limit, offset)
+		m, err := linkModelGetLinkModelEqCreatorIDOrdCreationTime(ctx, d, creatorid, false, limit, offset)
 		if err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to get links")
 		}
 		return m, nil
 	}
 
-	m, err := linkModelGetLinkModelOrdCreationTime(d, false, limit, offset)
+	m, err := linkModelGetLinkModelOrdCreationTime(ctx, d, false, limit, offset)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get links")
+	}
+	return m, nil
+}
+
+// GetLinkGroupAfter gets a page of links after a cursor ordered by creation
+// time
+//
+// The first page is returned if cursor is nil.
+func (r *repo) GetLinkGroupAfter(ctx context.Context, creatorid string, cursor *db.Cursor, limit int) ([]LinkModel, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		c := db.FirstCursor(false)
+		cursor = &c
+	}
+
+	if creatorid != "" {
+		m, err := linkModelGetLinkModelEqCreatorIDPageCreationTimeLinkID(ctx, d, creatorid, false, cursor.Time, cursor.Key, limit)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get links")
+		}
+		return m, nil
+	}
+	m, err := linkModelGetLinkModelPageCreationTimeLinkID(ctx, d, false, cursor.Time, cursor.Key, limit)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get links")
 	}
@@ -128,12 +159,12 @@ func (r *repo) GetLinkGroup(creatorid string, limit, offset int) ([]LinkModel, e
 }
 
 // GetLink returns a link model with the given id
-func (r *repo) GetLink(linkid string) (*LinkModel, error) {
-	d, err := r.db.DB()
+func (r *repo) GetLink(ctx context.Context, linkid string) (*LinkModel, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := linkModelGetLinkModelEqLinkID(d, linkid)
+	m, code, err := linkModelGetLinkModelEqLinkID(ctx, d, linkid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No link found with that id")
@@ -143,13 +174,29 @@ func (r *repo) GetLink(linkid string) (*LinkModel, error) {
 	return m, nil
 }
 
+// GetLinksByID returns link models with the given ids
+func (r *repo) GetLinksByID(ctx context.Context, linkids []string) ([]LinkModel, error) {
+	if len(linkids) == 0 {
+		return []LinkModel{}, nil
+	}
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := linkModelGetLinkModelHasLinkIDOrdLinkID(ctx, d, linkids, true, len(linkids), 0)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get links")
+	}
+	return m, nil
+}
+
 // InsertLink inserts the link model into the db
-func (r *repo) InsertLink(m *LinkModel) error {
-	d, err := r.db.DB()
+func (r *repo) InsertLink(ctx context.Context, m *LinkModel) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := linkModelInsert(d, m); err != nil {
+	if code, err := linkModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Link id must be unique")
 		}
@@ -159,12 +206,12 @@ func (r *repo) InsertLink(m *LinkModel) error {
 }
 
 // DeleteLink deletes the link model in the db
-func (r *repo) DeleteLink(m *LinkModel) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteLink(ctx context.Context, m *LinkModel) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := linkModelDelEqLinkID(d, m.LinkID); err != nil {
+	if err := linkModelDelEqLinkID(ctx, d, m.LinkID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete link")
 	}
 	return nil
@@ -180,21 +227,21 @@ func (r *repo) NewBrand(creatorid, brandid string) *BrandModel {
 }
 
 // GetBrandGroup gets a list of brands ordered by creation time
-func (r *repo) GetBrandGroup(creatorid string, limit, offset int) ([]BrandModel, error) {
-	d, err := r.db.DB()
+func (r *repo) GetBrandGroup(ctx context.Context, creatorid string, limit, offset int) ([]BrandModel, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	if creatorid != "" {
-		m, err := brandModelGetBrandModelEqCreatorIDOrdCreationTime(d, creatorid, false, limit, offset)
+		m, err := brandModelGetBrandModelEqCreatorIDOrdCreationTime(ctx, d, creatorid, false, limit, offset)
 		if err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to get brands")
 		}
 		return m, nil
 	}
 
-	m, err := brandModelGetBrandModelOrdCreationTime(d, false, limit, offset)
+	m, err := brandModelGetBrandModelOrdCreationTime(ctx, d, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get brands")
 	}
@@ -202,12 +249,12 @@ func (r *repo) GetBrandGroup(creatorid string, limit, offset int) ([]BrandModel,
 }
 
 // GetBrand returns a brand model with the given id
-func (r *repo) GetBrand(creatorid, brandid string) (*BrandModel, error) {
-	d, err := r.db.DB()
+func (r *repo) GetBrand(ctx context.Context, creatorid, brandid string) (*BrandModel, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := brandModelGetBrandModelEqCreatorIDEqBrandID(d, creatorid, brandid)
+	m, code, err := brandModelGetBrandModelEqCreatorIDEqBrandID(ctx, d, creatorid, brandid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No brand found with that id")
@@ -218,12 +265,12 @@ func (r *repo) GetBrand(creatorid, brandid string) (*BrandModel, error) {
 }
 
 // InsertBrand adds a brand to the db
-func (r *repo) InsertBrand(m *BrandModel) error {
-	d, err := r.db.DB()
+func (r *repo) InsertBrand(ctx context.Context, m *BrandModel) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := brandModelInsert(d, m); err != nil {
+	if code, err := brandModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Brand id must be unique")
 		}
@@ -233,29 +280,29 @@ func (r *repo) InsertBrand(m *BrandModel) error {
 }
 
 // DeleteBrand removes a brand from the db
-func (r *repo) DeleteBrand(m *BrandModel) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteBrand(ctx context.Context, m *BrandModel) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := brandModelDelEqCreatorIDEqBrandID(d, m.CreatorID, m.BrandID); err != nil {
+	if err := brandModelDelEqCreatorIDEqBrandID(ctx, d, m.CreatorID, m.BrandID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete brand")
 	}
 	return nil
 }
 
 // Setup creates new Courier tables
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := linkModelSetup(d); err != nil {
+	if code, err := linkModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup link model")
 		}
 	}
-	if code, err := brandModelSetup(d); err != nil {
+	if code, err := brandModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup brand model")
 		}
@@ -27,7 +27,7 @@ func (m *router) getLink(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	url, err := m.s.GetLinkFast(req.LinkID)
+	url, err := m.s.GetLinkFast(c.Ctx(), req.LinkID)
 	if err != nil {
 		if len(m.s.fallbackLink) > 0 {
 			c.Redirect(http.StatusTemporaryRedirect, m.s.fallbackLink)
@@ -48,7 +48,7 @@ func (m *router) getLinkImage(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	img, contentType, err := m.s.GetLinkImage(req.LinkID)
+	img, objinfo, err := m.s.GetLinkImage(c.Ctx(), req.LinkID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -61,14 +61,15 @@ func (m *router) getLinkImage(w http.ResponseWriter, r *http.Request) {
 			})
 		}
 	}()
-	c.WriteFile(http.StatusOK, contentType, img)
+	c.ServeObject(objinfo.ServeInfo(), img)
 }
 
 type (
 	reqGetGroup struct {
 		CreatorID string `valid:"creatorID,has" json:"-"`
 		Amount    int    `valid:"amount" json:"-"`
-		Offset    int    `valid:"offset" json:"-"`
+		Offset    int    `valid:"offset,opt" json:"-"`
+		Cursor    string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -78,13 +79,14 @@ func (m *router) getLinkGroup(w http.ResponseWriter, r *http.Request) {
 		CreatorID: c.Param("creatorid"),
 		Amount:    c.QueryInt("amount", -1),
 		Offset:    c.QueryInt("offset", -1),
+		Cursor:    c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetLinkGroup(req.CreatorID, req.Amount, req.Offset)
+	res, err := m.s.GetLinkGroup(c.Ctx(), req.CreatorID, req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -114,7 +116,7 @@ func (m *router) createLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.CreateLink(req.CreatorID, req.LinkID, req.URL, req.BrandID)
+	res, err := m.s.CreateLink(c.Ctx(), req.CreatorID, req.LinkID, req.URL, req.BrandID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -139,7 +141,7 @@ func (m *router) deleteLink(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	if err := m.s.DeleteLink(req.CreatorID, req.LinkID); err != nil {
+	if err := m.s.DeleteLink(c.Ctx(), req.CreatorID, req.LinkID); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -163,7 +165,7 @@ func (m *router) getBrandImage(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	img, contentType, err := m.s.GetBrandImage(req.CreatorID, req.BrandID)
+	img, objinfo, err := m.s.GetBrandImage(c.Ctx(), req.CreatorID, req.BrandID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -176,7 +178,7 @@ func (m *router) getBrandImage(w http.ResponseWriter, r *http.Request) {
 			})
 		}
 	}()
-	c.WriteFile(http.StatusOK, contentType, img)
+	c.ServeObject(objinfo.ServeInfo(), img)
 }
 
 func (m *router) getBrandGroup(w http.ResponseWriter, r *http.Request) {
@@ -191,7 +193,7 @@ func (m *router) getBrandGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetBrandGroup(req.CreatorID, req.Amount, req.Offset)
+	res, err := m.s.GetBrandGroup(c.Ctx(), req.CreatorID, req.Amount, req.Offset)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -223,7 +225,7 @@ func (m *router) createBrand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.CreateBrand(req.CreatorID, req.BrandID, img)
+	res, err := m.s.CreateBrand(c.Ctx(), req.CreatorID, req.BrandID, img)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -232,6 +234,44 @@ func (m *router) createBrand(w http.ResponseWriter, r *http.Request) {
 	c.WriteJSON(http.StatusCreated, res)
 }
 
+func (m *router) presignBrandUpload(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqBrandPost{
+		CreatorID: c.Param("creatorid"),
+		BrandID:   c.Param("brandid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	res, err := m.s.PresignBrandUpload(c.Ctx(), req.CreatorID, req.BrandID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (m *router) confirmBrandUpload(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqBrandPost{
+		CreatorID: c.Param("creatorid"),
+		BrandID:   c.Param("brandid"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	res, err := m.s.ConfirmBrandUpload(c.Ctx(), req.CreatorID, req.BrandID)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusCreated, res)
+}
+
 func (m *router) deleteBrand(w http.ResponseWriter, r *http.Request) {
 	c := governor.NewContext(w, r, m.s.logger)
 	req := reqBrandGet{
@@ -242,7 +282,7 @@ func (m *router) deleteBrand(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	if err := m.s.DeleteBrand(req.CreatorID, req.BrandID); err != nil {
+	if err := m.s.DeleteBrand(c.Ctx(), req.CreatorID, req.BrandID); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -257,7 +297,7 @@ func (m *router) getLinkImageCC(c governor.Context) (string, error) {
 		return "", err
 	}
 
-	objinfo, err := m.s.StatLinkImage(req.LinkID)
+	objinfo, err := m.s.StatLinkImage(c.Ctx(), req.LinkID)
 	if err != nil {
 		return "", err
 	}
@@ -274,7 +314,7 @@ func (m *router) getBrandImageCC(c governor.Context) (string, error) {
 		return "", err
 	}
 
-	objinfo, err := m.s.StatBrandImage(req.CreatorID, req.BrandID)
+	objinfo, err := m.s.StatBrandImage(c.Ctx(), req.CreatorID, req.BrandID)
 	if err != nil {
 		return "", err
 	}
@@ -308,12 +348,14 @@ const (
 
 func (m *router) mountRoutes(r governor.Router) {
 	r.Get("/link/id/{linkid}", m.getLink)
-	r.Get("/link/id/{linkid}/image", m.getLinkImage, cachecontrol.Control(m.s.logger, true, nil, 60, m.getLinkImageCC))
+	r.Get("/link/id/{linkid}/image", m.getLinkImage, governor.SecHeadersSandbox(), cachecontrol.Control(m.s.logger, true, nil, 60, m.getLinkImageCC))
 	r.Get("/link/c/{creatorid}", m.getLinkGroup, gate.MemberF(m.s.gate, m.courierOwner, scopeLinkRead))
 	r.Post("/link/c/{creatorid}", m.createLink, gate.MemberF(m.s.gate, m.courierOwner, scopeLinkWrite))
 	r.Delete("/link/c/{creatorid}/id/{linkid}", m.deleteLink, gate.MemberF(m.s.gate, m.courierOwner, scopeLinkWrite))
-	r.Get("/brand/c/{creatorid}/id/{brandid}/image", m.getBrandImage, gate.MemberF(m.s.gate, m.courierOwner, scopeBrandRead), cachecontrol.Control(m.s.logger, true, nil, 60, m.getBrandImageCC))
+	r.Get("/brand/c/{creatorid}/id/{brandid}/image", m.getBrandImage, governor.SecHeadersSandbox(), gate.MemberF(m.s.gate, m.courierOwner, scopeBrandRead), cachecontrol.Control(m.s.logger, true, nil, 60, m.getBrandImageCC))
 	r.Get("/brand/c/{creatorid}", m.getBrandGroup, gate.MemberF(m.s.gate, m.courierOwner, scopeBrandRead))
 	r.Post("/brand/c/{creatorid}", m.createBrand, gate.MemberF(m.s.gate, m.courierOwner, scopeBrandWrite))
+	r.Post("/brand/c/{creatorid}/id/{brandid}/upload", m.presignBrandUpload, gate.MemberF(m.s.gate, m.courierOwner, scopeBrandWrite))
+	r.Post("/brand/c/{creatorid}/id/{brandid}/upload/confirm", m.confirmBrandUpload, gate.MemberF(m.s.gate, m.courierOwner, scopeBrandWrite))
 	r.Delete("/brand/c/{creatorid}/id/{brandid}", m.deleteBrand, gate.MemberF(m.s.gate, m.courierOwner, scopeBrandWrite))
 }
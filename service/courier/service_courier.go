@@ -1,9 +1,11 @@
 package courier
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/barcode"
@@ -18,6 +20,15 @@ const (
 	cacheValTombstone = "-"
 )
 
+const (
+	reconcileBatchSize = 256
+)
+
+const (
+	brandUploadMaxSize = 1 << 20
+	brandUploadTime    = 15 * time.Minute
+)
+
 type (
 	resGetLink struct {
 		LinkID       string `json:"linkid"`
@@ -28,8 +39,8 @@ type (
 )
 
 // GetLink retrieves a link by id
-func (s *service) GetLink(linkid string) (*resGetLink, error) {
-	m, err := s.repo.GetLink(linkid)
+func (s *service) GetLink(ctx context.Context, linkid string) (*resGetLink, error) {
+	m, err := s.repo.GetLink(ctx, linkid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -47,8 +58,8 @@ func (s *service) GetLink(linkid string) (*resGetLink, error) {
 	}, nil
 }
 
-func (s *service) GetLinkFast(linkid string) (string, error) {
-	if cachedURL, err := s.kvlinks.Get(linkid); err != nil {
+func (s *service) GetLinkFast(ctx context.Context, linkid string) (string, error) {
+	if cachedURL, err := s.kvlinks.Get(ctx, linkid); err != nil {
 		if !errors.Is(err, kvstore.ErrNotFound{}) {
 			s.logger.Error("Failed to get linkid url from cache", map[string]string{
 				"error":      err.Error(),
@@ -63,10 +74,10 @@ func (s *service) GetLinkFast(linkid string) (string, error) {
 	} else {
 		return cachedURL, nil
 	}
-	res, err := s.repo.GetLink(linkid)
+	res, err := s.repo.GetLink(ctx, linkid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
-			if err := s.kvlinks.Set(linkid, cacheValTombstone, s.cacheTime); err != nil {
+			if err := s.kvlinks.Set(ctx, linkid, cacheValTombstone, s.cacheTime); err != nil {
 				s.logger.Error("Failed to cache linkid url", map[string]string{
 					"linkid":     linkid,
 					"error":      err.Error(),
@@ -80,7 +91,7 @@ func (s *service) GetLinkFast(linkid string) (string, error) {
 		}
 		return "", governor.ErrWithMsg(err, "Failed to get link")
 	}
-	if err := s.kvlinks.Set(linkid, res.URL, s.cacheTime); err != nil {
+	if err := s.kvlinks.Set(ctx, linkid, res.URL, s.cacheTime); err != nil {
 		s.logger.Error("Failed to cache linkid url", map[string]string{
 			"linkid":     linkid,
 			"error":      err.Error(),
@@ -90,8 +101,8 @@ func (s *service) GetLinkFast(linkid string) (string, error) {
 	return res.URL, nil
 }
 
-func (s *service) StatLinkImage(linkid string) (*objstore.ObjectInfo, error) {
-	objinfo, err := s.linkImgDir.Stat(linkid)
+func (s *service) StatLinkImage(ctx context.Context, linkid string) (*objstore.ObjectInfo, error) {
+	objinfo, err := s.linkImgDir.Stat(ctx, linkid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -104,31 +115,58 @@ func (s *service) StatLinkImage(linkid string) (*objstore.ObjectInfo, error) {
 	return objinfo, nil
 }
 
-func (s *service) GetLinkImage(linkid string) (io.ReadCloser, string, error) {
-	qrimg, objinfo, err := s.linkImgDir.Get(linkid)
+func (s *service) GetLinkImage(ctx context.Context, linkid string) (io.ReadCloser, *objstore.ObjectInfo, error) {
+	qrimg, objinfo, err := s.linkImgDir.Get(ctx, linkid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
-			return nil, "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			return nil, nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
 				Message: "Link qr code image not found",
 			}), governor.ErrOptInner(err))
 		}
-		return nil, "", governor.ErrWithMsg(err, "Failed to get link qr code image")
+		return nil, nil, governor.ErrWithMsg(err, "Failed to get link qr code image")
 	}
-	return qrimg, objinfo.ContentType, nil
+	return qrimg, objinfo, nil
 }
 
 type (
 	resLinkGroup struct {
-		Links []resGetLink `json:"links"`
+		Links      []resGetLink `json:"links"`
+		NextCursor string       `json:"next_cursor"`
 	}
 )
 
 // GetLinkGroup retrieves a group of links
-func (s *service) GetLinkGroup(creatorid string, limit, offset int) (*resLinkGroup, error) {
-	links, err := s.repo.GetLinkGroup(creatorid, limit, offset)
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to get links")
+//
+// Links are paged by cursor if a cursor is provided, and by offset otherwise.
+// The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetLinkGroup(ctx context.Context, creatorid string, limit, offset int, cursor string) (*resLinkGroup, error) {
+	paged := cursor != ""
+	var links []model.LinkModel
+	if paged {
+		after, err := db.ParseCursor(cursor)
+		if err != nil {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}), governor.ErrOptInner(err))
+		}
+		links, err = s.repo.GetLinkGroupAfter(ctx, creatorid, after, limit)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get links")
+		}
+	} else {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		var err error
+		links, err = s.repo.GetLinkGroup(ctx, creatorid, limit, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get links")
+		}
 	}
 	res := make([]resGetLink, 0, len(links))
 	for _, i := range links {
@@ -139,8 +177,17 @@ func (s *service) GetLinkGroup(creatorid string, limit, offset int) (*resLinkGro
 			CreationTime: i.CreationTime,
 		})
 	}
+	var next string
+	if paged && len(links) > 0 && len(links) == limit {
+		last := links[len(links)-1]
+		next = db.Cursor{
+			Time: last.CreationTime,
+			Key:  last.LinkID,
+		}.Encode()
+	}
 	return &resLinkGroup{
-		Links: res,
+		Links:      res,
+		NextCursor: next,
 	}, nil
 }
 
@@ -155,7 +202,7 @@ type (
 )
 
 // CreateLink creates a new link
-func (s *service) CreateLink(creatorid, linkid, url, brandid string) (*resCreateLink, error) {
+func (s *service) CreateLink(ctx context.Context, creatorid, linkid, url, brandid string) (*resCreateLink, error) {
 	var m *model.LinkModel
 	if len(linkid) == 0 {
 		var err error
@@ -168,7 +215,7 @@ func (s *service) CreateLink(creatorid, linkid, url, brandid string) (*resCreate
 	}
 
 	if brandid != "" {
-		objinfo, err := s.brandImgDir.Subdir(creatorid).Stat(brandid)
+		objinfo, err := s.brandImgDir.Subdir(creatorid).Stat(ctx, brandid)
 		if err != nil {
 			if errors.Is(err, objstore.ErrNotFound{}) {
 				return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -183,7 +230,7 @@ func (s *service) CreateLink(creatorid, linkid, url, brandid string) (*resCreate
 		}
 	}
 
-	if err := s.repo.InsertLink(m); err != nil {
+	if err := s.repo.InsertLink(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusBadRequest,
@@ -198,7 +245,7 @@ func (s *service) CreateLink(creatorid, linkid, url, brandid string) (*resCreate
 	}
 
 	if brandid != "" {
-		brandimg, _, err := s.brandImgDir.Subdir(creatorid).Get(brandid)
+		brandimg, _, err := s.brandImgDir.Subdir(creatorid).Get(ctx, brandid)
 		if err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to get brand image")
 		}
@@ -225,7 +272,7 @@ func (s *service) CreateLink(creatorid, linkid, url, brandid string) (*resCreate
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to encode qr code image")
 	}
-	if err := s.linkImgDir.Put(m.LinkID, image.MediaTypePng, int64(qrpng.Len()), qrpng); err != nil {
+	if err := s.linkImgDir.Put(ctx, m.LinkID, image.MediaTypePng, int64(qrpng.Len()), qrpng); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to save qr code image")
 	}
 
@@ -235,8 +282,8 @@ func (s *service) CreateLink(creatorid, linkid, url, brandid string) (*resCreate
 }
 
 // DeleteLink deletes a link
-func (s *service) DeleteLink(creatorid, linkid string) error {
-	m, err := s.repo.GetLink(linkid)
+func (s *service) DeleteLink(ctx context.Context, creatorid, linkid string) error {
+	m, err := s.repo.GetLink(ctx, linkid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -252,13 +299,13 @@ func (s *service) DeleteLink(creatorid, linkid string) error {
 			Message: "Link not found",
 		}))
 	}
-	if err := s.linkImgDir.Del(linkid); err != nil {
+	if err := s.linkImgDir.Del(ctx, linkid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete qr code image")
 	}
-	if err := s.repo.DeleteLink(m); err != nil {
+	if err := s.repo.DeleteLink(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete link")
 	}
-	if err := s.kvlinks.Del(linkid); err != nil {
+	if err := s.kvlinks.Del(ctx, linkid); err != nil {
 		s.logger.Error("failed to delete linkid url", map[string]string{
 			"linkid":     linkid,
 			"error":      err.Error(),
@@ -268,6 +315,49 @@ func (s *service) DeleteLink(creatorid, linkid string) error {
 	return nil
 }
 
+// reconcileLinkImages removes qr code images of links that no longer exist
+//
+// Link images are put after their link is inserted and removed before their
+// link is deleted, so an image with no link is always an orphan.
+func (s *service) reconcileLinkImages(ctx context.Context) (int, error) {
+	count := 0
+	cursor := ""
+	for {
+		objects, err := s.linkImgDir.List(ctx, "", cursor, reconcileBatchSize)
+		if err != nil {
+			return count, governor.ErrWithMsg(err, "Failed to list link images")
+		}
+		if len(objects) == 0 {
+			return count, nil
+		}
+		linkids := make([]string, 0, len(objects))
+		for _, i := range objects {
+			linkids = append(linkids, i.Name)
+		}
+		links, err := s.repo.GetLinksByID(ctx, linkids)
+		if err != nil {
+			return count, governor.ErrWithMsg(err, "Failed to get links")
+		}
+		existing := make(map[string]struct{}, len(links))
+		for _, i := range links {
+			existing[i.LinkID] = struct{}{}
+		}
+		for _, i := range linkids {
+			if _, ok := existing[i]; ok {
+				continue
+			}
+			if err := s.linkImgDir.Del(ctx, i); err != nil && !errors.Is(err, objstore.ErrNotFound{}) {
+				return count, governor.ErrWithMsg(err, "Failed to delete link image")
+			}
+			count++
+		}
+		if len(objects) < reconcileBatchSize {
+			return count, nil
+		}
+		cursor = objects[len(objects)-1].Name
+	}
+}
+
 type (
 	resGetBrand struct {
 		BrandID      string `json:"brandid"`
@@ -281,8 +371,8 @@ type (
 )
 
 // GetBrandGroup gets a list of brand images
-func (s *service) GetBrandGroup(creatorid string, limit, offset int) (*resBrandGroup, error) {
-	brands, err := s.repo.GetBrandGroup(creatorid, limit, offset)
+func (s *service) GetBrandGroup(ctx context.Context, creatorid string, limit, offset int) (*resBrandGroup, error) {
+	brands, err := s.repo.GetBrandGroup(ctx, creatorid, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get links")
 	}
@@ -299,8 +389,8 @@ func (s *service) GetBrandGroup(creatorid string, limit, offset int) (*resBrandG
 	}, nil
 }
 
-func (s *service) StatBrandImage(creatorid, brandid string) (*objstore.ObjectInfo, error) {
-	objinfo, err := s.brandImgDir.Subdir(creatorid).Stat(brandid)
+func (s *service) StatBrandImage(ctx context.Context, creatorid, brandid string) (*objstore.ObjectInfo, error) {
+	objinfo, err := s.brandImgDir.Subdir(creatorid).Stat(ctx, brandid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -313,18 +403,18 @@ func (s *service) StatBrandImage(creatorid, brandid string) (*objstore.ObjectInf
 	return objinfo, nil
 }
 
-func (s *service) GetBrandImage(creatorid, brandid string) (io.ReadCloser, string, error) {
-	brandimg, objinfo, err := s.brandImgDir.Subdir(creatorid).Get(brandid)
+func (s *service) GetBrandImage(ctx context.Context, creatorid, brandid string) (io.ReadCloser, *objstore.ObjectInfo, error) {
+	brandimg, objinfo, err := s.brandImgDir.Subdir(creatorid).Get(ctx, brandid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
-			return nil, "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			return nil, nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
 				Message: "Brand image not found",
 			}), governor.ErrOptInner(err))
 		}
-		return nil, "", governor.ErrWithMsg(err, "Failed to get brand image")
+		return nil, nil, governor.ErrWithMsg(err, "Failed to get brand image")
 	}
-	return brandimg, objinfo.ContentType, nil
+	return brandimg, objinfo, nil
 }
 
 type (
@@ -335,9 +425,9 @@ type (
 )
 
 // CreateBrand adds a brand image
-func (s *service) CreateBrand(creatorid, brandid string, img image.Image) (*resCreateBrand, error) {
+func (s *service) CreateBrand(ctx context.Context, creatorid, brandid string, img image.Image) (*resCreateBrand, error) {
 	m := s.repo.NewBrand(creatorid, brandid)
-	if err := s.repo.InsertBrand(m); err != nil {
+	if err := s.repo.InsertBrand(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
@@ -351,7 +441,19 @@ func (s *service) CreateBrand(creatorid, brandid string, img image.Image) (*resC
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to encode png image")
 	}
-	if err := s.brandImgDir.Subdir(creatorid).Put(m.BrandID, image.MediaTypePng, int64(imgpng.Len()), imgpng); err != nil {
+	if err := s.brandImgQuota.Dir(creatorid).Put(ctx, m.BrandID, image.MediaTypePng, int64(imgpng.Len()), imgpng); err != nil {
+		if errors.Is(err, objstore.ErrQuota{}) {
+			if err := s.repo.DeleteBrand(ctx, m); err != nil {
+				s.logger.Error("Failed to delete brand over quota", map[string]string{
+					"actiontype": "createbrand",
+					"error":      err.Error(),
+				})
+			}
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Brand image storage quota exceeded",
+			}), governor.ErrOptInner(err))
+		}
 		return nil, governor.ErrWithMsg(err, "Failed to save image")
 	}
 	return &resCreateBrand{
@@ -360,9 +462,134 @@ func (s *service) CreateBrand(creatorid, brandid string, img image.Image) (*resC
 	}, nil
 }
 
+type (
+	resPresignBrand struct {
+		URL      string            `json:"url"`
+		FormData map[string]string `json:"formdata"`
+		Expires  int64             `json:"expires"`
+	}
+)
+
+// PresignBrandUpload returns a presigned upload for a brand image
+//
+// The image is uploaded to a staging object, and must be confirmed with
+// ConfirmBrandUpload to create the brand. Unconfirmed staging objects are
+// removed by the objstore expire job. Staged uploads of the creator and the
+// max size of the new upload are counted against the brand image quota.
+func (s *service) PresignBrandUpload(ctx context.Context, creatorid, brandid string) (*resPresignBrand, error) {
+	if _, err := s.repo.GetBrand(ctx, creatorid, brandid); err != nil {
+		if !errors.Is(err, db.ErrNotFound{}) {
+			return nil, governor.ErrWithMsg(err, "Failed to get brand")
+		}
+	} else {
+		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusBadRequest,
+			Message: "Brand name must be unique",
+		}))
+	}
+	dir := s.brandUpDir.Subdir(creatorid)
+	staged, err := objstore.DirUsage(ctx, dir)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get staged brand image uploads")
+	}
+	used, err := s.brandImgQuota.Usage(ctx, creatorid)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get brand image storage usage")
+	}
+	if used+staged.Bytes+brandUploadMaxSize > s.brandQuota {
+		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusBadRequest,
+			Message: "Brand image storage quota exceeded",
+		}))
+	}
+	upload, err := dir.PresignPut(ctx, brandid, image.MediaTypePng, brandUploadMaxSize, brandUploadTime)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to presign brand image upload")
+	}
+	return &resPresignBrand{
+		URL:      upload.URL,
+		FormData: upload.FormData,
+		Expires:  upload.Expires,
+	}, nil
+}
+
+// ConfirmBrandUpload creates a brand from an uploaded brand image
+//
+// The staged upload is decoded and stored as the brand image as with
+// CreateBrand, and is then deleted.
+func (s *service) ConfirmBrandUpload(ctx context.Context, creatorid, brandid string) (*resCreateBrand, error) {
+	dir := s.brandUpDir.Subdir(creatorid)
+	if _, err := objstore.ConfirmUpload(ctx, dir, brandid, image.MediaTypePng, brandUploadMaxSize); err != nil {
+		if errors.Is(err, objstore.ErrNotFound{}) {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Brand image not uploaded",
+			}), governor.ErrOptInner(err))
+		}
+		if !errors.Is(err, objstore.ErrInvalidUpload{}) {
+			return nil, governor.ErrWithMsg(err, "Failed to get brand image upload")
+		}
+		s.delBrandUpload(ctx, dir, brandid)
+		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid brand image",
+		}), governor.ErrOptInner(err))
+	}
+	defer s.delBrandUpload(ctx, dir, brandid)
+
+	img, err := s.getBrandUpload(ctx, dir, brandid)
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateBrand(ctx, creatorid, brandid, img)
+}
+
+// getBrandUpload decodes a staged brand image upload
+func (s *service) getBrandUpload(ctx context.Context, dir objstore.Dir, brandid string) (image.Image, error) {
+	obj, _, err := dir.Get(ctx, brandid)
+	if err != nil {
+		if errors.Is(err, objstore.ErrNotFound{}) {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Brand image not uploaded",
+			}), governor.ErrOptInner(err))
+		}
+		return nil, governor.ErrWithMsg(err, "Failed to get brand image upload")
+	}
+	defer func() {
+		if err := obj.Close(); err != nil {
+			s.logger.Error("Failed to close brand image upload", map[string]string{
+				"actiontype": "confirmbrandupload",
+				"error":      err.Error(),
+			})
+		}
+	}()
+	img, err := image.FromPng(io.LimitReader(obj, brandUploadMaxSize))
+	if err != nil {
+		if errors.Is(err, image.ErrInvalidImage{}) {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid brand image",
+			}), governor.ErrOptInner(err))
+		}
+		return nil, governor.ErrWithMsg(err, "Failed to decode brand image")
+	}
+	return img, nil
+}
+
+// delBrandUpload deletes a staged brand image upload
+func (s *service) delBrandUpload(ctx context.Context, dir objstore.Dir, brandid string) {
+	if err := dir.Del(ctx, brandid); err != nil {
+		s.logger.Error("Failed to delete brand image upload", map[string]string{
+			"actiontype": "confirmbrandupload",
+			"error":      err.Error(),
+		})
+	}
+}
+
 // DeleteBrand removes a brand image
-func (s *service) DeleteBrand(creatorid, brandid string) error {
-	m, err := s.repo.GetBrand(creatorid, brandid)
+func (s *service) DeleteBrand(ctx context.Context, creatorid, brandid string) error {
+	m, err := s.repo.GetBrand(ctx, creatorid, brandid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -372,10 +599,10 @@ func (s *service) DeleteBrand(creatorid, brandid string) error {
 		}
 		return governor.ErrWithMsg(err, "Failed to delete brand")
 	}
-	if err := s.brandImgDir.Del(brandid); err != nil {
+	if err := s.brandImgQuota.Dir(creatorid).Del(ctx, brandid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete brand image")
 	}
-	if err := s.repo.DeleteBrand(m); err != nil {
+	if err := s.repo.DeleteBrand(ctx, m); err != nil {
 		return err
 	}
 	return nil
@@ -16,7 +16,10 @@ func (r reqGetGroup) valid() error {
 	if err := validAmount(r.Amount); err != nil {
 		return err
 	}
-	if err := validOffset(r.Offset); err != nil {
+	if err := validoptOffset(r.Offset); err != nil {
+		return err
+	}
+	if err := validoptCursor(r.Cursor); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+	"strings"
+
+	"xorkevin.dev/governor"
+)
+
+const (
+	// CursorFirst is the cursor token of the first page of a query, with which
+	// a client without a cursor requests keyset pagination
+	//
+	// It is never the encoding of a cursor, since unpadded base64 is never one
+	// more than a multiple of 4 characters long.
+	CursorFirst = "first"
+)
+
+type (
+	// Cursor is a keyset pagination cursor
+	//
+	// A cursor holds the sort column value and the unique tiebreak column value
+	// of the last row of a page, and the next page starts after it. Time is
+	// unused for queries sorted by a unique key alone.
+	Cursor struct {
+		Time int64
+		Key  string
+	}
+
+	// ErrInvalidCursor is returned when a cursor cannot be decoded
+	ErrInvalidCursor struct{}
+)
+
+func (e ErrInvalidCursor) Error() string {
+	return "Invalid cursor"
+}
+
+// FirstCursor returns a cursor before the first row of a query
+//
+// Keys are assumed to be non-empty, so that every key sorts after the empty
+// key of the first cursor in ascending order.
+func FirstCursor(orderasc bool) Cursor {
+	if orderasc {
+		return Cursor{
+			Time: math.MinInt64,
+		}
+	}
+	return Cursor{
+		Time: math.MaxInt64,
+	}
+}
+
+// Encode returns the cursor as an opaque url safe token
+func (c Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Time, 10) + ":" + c.Key))
+}
+
+// DecodeCursor decodes a cursor token
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrInvalidCursor{}, "Invalid cursor encoding")
+	}
+	k := strings.SplitN(string(b), ":", 2)
+	if len(k) != 2 {
+		return nil, governor.ErrWithKind(nil, ErrInvalidCursor{}, "Malformed cursor")
+	}
+	t, err := strconv.ParseInt(k[0], 10, 64)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrInvalidCursor{}, "Malformed cursor time")
+	}
+	return &Cursor{
+		Time: t,
+		Key:  k[1],
+	}, nil
+}
+
+// ParseCursor decodes an optional cursor token, and returns a nil cursor for
+// an empty token or CursorFirst
+func ParseCursor(s string) (*Cursor, error) {
+	if s == "" || s == CursorFirst {
+		return nil, nil
+	}
+	return DecodeCursor(s)
+}
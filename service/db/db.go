@@ -3,26 +3,87 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq" // depends upon postgres
+	"github.com/lib/pq"
 	"xorkevin.dev/governor"
 )
 
+const (
+	// ReplicaStaleness is the replication lag tolerated by reads that may be
+	// served by a read replica
+	ReplicaStaleness = 5 * time.Second
+)
+
+const (
+	// txMaxRetries is the number of times a transaction is retried on a
+	// serialization failure
+	txMaxRetries = 8
+	// txRetryBase is the initial delay before a transaction is retried
+	txRetryBase = 8 * time.Millisecond
+	// txRetryMax is the max delay before a transaction is retried
+	txRetryMax = 512 * time.Millisecond
+
+	// drainTimeout is the max time a draining client is kept open while it
+	// still has connections in use
+	drainTimeout = time.Minute
+
+	// replicaLagQuery returns the replication lag of a replica in seconds
+	//
+	// A replica that has replayed all received wal is considered to have no lag,
+	// since the last replayed transaction timestamp is not updated while the
+	// primary is idle. This only holds while its wal receiver is streaming from
+	// the primary, and the lag is null otherwise. The status of the wal receiver
+	// is only visible to members of pg_read_all_stats, and a running receiver
+	// with a hidden status is assumed to be streaming.
+	replicaLagQuery = "SELECT CASE WHEN NOT EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming') THEN NULL WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END;"
+
+	// replicaLagUnknown is the lag of a replica which is not streaming from the
+	// primary, and is greater than any staleness
+	replicaLagUnknown = time.Duration(math.MaxInt64)
+)
+
 type (
 	// Database is a service wrapper around an sql.DB instance
 	//
-	// DB returns the wrapped sql database instance
+	// DB returns the wrapped sql database instance of the primary. ReadDB
+	// returns the sql database instance of a healthy read replica whose
+	// replication lag is within staleness, and falls back to the primary if
+	// there is none. Executor and ReadExecutor return the same instances
+	// wrapped by an executor which records the stats of each query.
 	Database interface {
-		DB() (*sql.DB, error)
+		DB(ctx context.Context) (*sql.DB, error)
+		ReadDB(ctx context.Context, staleness time.Duration) (*sql.DB, error)
+		Executor(ctx context.Context) (SQLExecutor, error)
+		ReadExecutor(ctx context.Context, staleness time.Duration) (SQLExecutor, error)
+		WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx SQLExecutor) error) error
+	}
+
+	// SQLExecutor executes queries on a db or in a transaction
+	SQLExecutor interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...interface{}) (Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) Row
+	}
+
+	// sqlExecutor is the subset of methods shared by *sql.DB and *sql.Tx
+	sqlExecutor interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 	}
 
 	// Service is a DB and governor.Service
 	Service interface {
 		governor.Service
 		Database
+		Statser
 	}
 
 	pgauth struct {
@@ -30,27 +91,83 @@ type (
 		password string
 	}
 
+	// replicaConfig is the config of a read replica
+	replicaConfig struct {
+		Name    string `mapstructure:"name"`
+		Host    string `mapstructure:"host"`
+		Port    string `mapstructure:"port"`
+		SSLMode string `mapstructure:"sslmode"`
+	}
+
+	// pool is the connection pool of a single postgres server
+	//
+	// When credentials are rotated, the client of the previous credentials is
+	// kept as the draining client so that in flight queries may finish. It is
+	// closed once none of its connections are in use, or after drainTimeout.
+	pool struct {
+		name      string
+		connopts  string
+		replica   bool
+		client    *sql.DB
+		auth      pgauth
+		ready     bool
+		hbfailed  int
+		lag       time.Duration
+		checking  bool
+		draining  *sql.DB
+		drainauth pgauth
+		drainAt   time.Time
+	}
+
+	// replicaCheck is the result of a health check of a replica
+	//
+	// err is the error of checking the current client of the replica. client
+	// is set to a new client if the credentials have changed, and clientErr is
+	// set if it could not be created.
+	replicaCheck struct {
+		p         *pool
+		lag       time.Duration
+		err       error
+		client    *sql.DB
+		auth      pgauth
+		clientLag time.Duration
+		clientErr error
+	}
+
 	getClientRes struct {
 		client *sql.DB
 		err    error
 	}
 
 	getOp struct {
-		res chan<- getClientRes
+		replica   bool
+		staleness time.Duration
+		res       chan<- getClientRes
+	}
+
+	statsOp struct {
+		res chan<- []PoolStats
 	}
 
 	service struct {
-		client     *sql.DB
-		auth       pgauth
-		connopts   string
-		config     governor.SecretReader
-		logger     governor.Logger
-		ops        chan getOp
-		ready      bool
-		hbfailed   int
-		hbinterval int
-		hbmaxfail  int
-		done       <-chan struct{}
+		primary     *pool
+		replicas    []*pool
+		nextReplica int
+		config      governor.SecretReader
+		secrets     map[pgauth]map[string]interface{}
+		curauth     pgauth
+		logger      governor.Logger
+		ops         chan getOp
+		statsops    chan statsOp
+		checks      chan replicaCheck
+		instrument  *instrumenter
+		maxopen     int
+		maxidle     int
+		maxlifetime time.Duration
+		hbinterval  int
+		hbmaxfail   int
+		hbtimeout   time.Duration
+		done        <-chan struct{}
 	}
 
 	ctxKeyDatabase struct{}
@@ -70,12 +187,32 @@ func setCtxDB(inj governor.Injector, d Database) {
 	inj.Set(ctxKeyDatabase{}, d)
 }
 
+// GetCtxStatser returns a Statser for the db service from the context
+//
+// An error is returned if the Database in the context does not report stats.
+func GetCtxStatser(inj governor.Injector) (Statser, error) {
+	v := inj.Get(ctxKeyDatabase{})
+	if v == nil {
+		return nil, governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "DB service not registered")
+	}
+	st, ok := v.(Statser)
+	if !ok {
+		return nil, governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "DB service does not report stats")
+	}
+	return st, nil
+}
+
 // New creates a new db service
 func New() Service {
 	return &service{
-		ops:      make(chan getOp),
-		ready:    false,
-		hbfailed: 0,
+		primary: &pool{
+			name: "primary",
+		},
+		ops:        make(chan getOp),
+		statsops:   make(chan statsOp),
+		secrets:    map[pgauth]map[string]interface{}{},
+		checks:     make(chan replicaCheck),
+		instrument: newInstrumenter(),
 	}
 }
 
@@ -87,8 +224,15 @@ func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr
 	r.SetDefault("host", "localhost")
 	r.SetDefault("port", "5432")
 	r.SetDefault("sslmode", "disable")
+	r.SetDefault("replicas", []interface{}{})
+	r.SetDefault("maxopen", 16)
+	r.SetDefault("maxidle", 8)
+	r.SetDefault("maxlifetime", "30m")
 	r.SetDefault("hbinterval", 5)
 	r.SetDefault("hbmaxfail", 5)
+	r.SetDefault("slowquery", "250ms")
+	r.SetDefault("explain", false)
+	r.SetDefault("explaininterval", "1m")
 }
 
 type (
@@ -100,6 +244,8 @@ type (
 	ErrNotFound struct{}
 	// ErrUnique is returned when a unique constraint is violated
 	ErrUnique struct{}
+	// ErrConflict is returned when a transaction keeps failing to serialize
+	ErrConflict struct{}
 )
 
 func (e ErrConn) Error() string {
@@ -118,6 +264,10 @@ func (e ErrUnique) Error() string {
 	return "Uniqueness constraint violated"
 }
 
+func (e ErrConflict) Error() string {
+	return "Transaction conflict"
+}
+
 func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
 	s.logger = l
 	l = s.logger.WithData(map[string]string{
@@ -126,21 +276,79 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 
 	s.config = r
 
-	s.connopts = fmt.Sprintf("dbname=%s host=%s port=%s sslmode=%s", r.GetStr("dbname"), r.GetStr("host"), r.GetStr("port"), r.GetStr("sslmode"))
+	dbname := r.GetStr("dbname")
+	s.primary.connopts = fmt.Sprintf("dbname=%s host=%s port=%s sslmode=%s", dbname, r.GetStr("host"), r.GetStr("port"), r.GetStr("sslmode"))
+	var replicas []replicaConfig
+	if err := r.Unmarshal("replicas", &replicas); err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Invalid replicas config")
+	}
+	for n, i := range replicas {
+		if i.Host == "" {
+			return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Replica host must be set")
+		}
+		name := i.Name
+		if name == "" {
+			name = "replica" + strconv.Itoa(n)
+		}
+		port := i.Port
+		if port == "" {
+			port = r.GetStr("port")
+		}
+		sslmode := i.SSLMode
+		if sslmode == "" {
+			sslmode = r.GetStr("sslmode")
+		}
+		s.replicas = append(s.replicas, &pool{
+			name:     name,
+			connopts: fmt.Sprintf("dbname=%s host=%s port=%s sslmode=%s", dbname, i.Host, port, sslmode),
+			replica:  true,
+		})
+	}
+	s.maxopen = r.GetInt("maxopen")
+	s.maxidle = r.GetInt("maxidle")
+	if t, err := time.ParseDuration(r.GetStr("maxlifetime")); err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Failed to parse max conn lifetime")
+	} else {
+		s.maxlifetime = t
+	}
 	s.hbinterval = r.GetInt("hbinterval")
 	s.hbmaxfail = r.GetInt("hbmaxfail")
+	// health checks time out before the next heartbeat
+	s.hbtimeout = time.Duration(s.hbinterval) * time.Second
+
+	s.instrument.logger = s.logger.Subtree("query")
+	if t, err := time.ParseDuration(r.GetStr("slowquery")); err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Failed to parse slow query threshold")
+	} else {
+		s.instrument.slowThreshold = t
+	}
+	// plans are only sampled in debug mode since explaining a query executes it
+	// again
+	s.instrument.explain = r.GetBool("explain") && c.IsDebug()
+	if t, err := time.ParseDuration(r.GetStr("explaininterval")); err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Failed to parse explain interval")
+	} else {
+		s.instrument.explainInterval = t
+	}
 
 	l.Info("loaded config", map[string]string{
-		"connopts":   s.connopts,
-		"hbinterval": strconv.Itoa(s.hbinterval),
-		"hbmaxfail":  strconv.Itoa(s.hbmaxfail),
+		"connopts":        s.primary.connopts,
+		"replicas":        strconv.Itoa(len(s.replicas)),
+		"maxopen":         strconv.Itoa(s.maxopen),
+		"maxidle":         strconv.Itoa(s.maxidle),
+		"maxlifetime":     s.maxlifetime.String(),
+		"hbinterval":      strconv.Itoa(s.hbinterval),
+		"hbmaxfail":       strconv.Itoa(s.hbmaxfail),
+		"slowquery":       s.instrument.slowThreshold.String(),
+		"explain":         strconv.FormatBool(s.instrument.explain),
+		"explaininterval": s.instrument.explainInterval.String(),
 	})
 
 	done := make(chan struct{})
 	go s.execute(ctx, done)
 	s.done = done
 
-	if _, err := s.DB(); err != nil {
+	if _, err := s.getClient(ctx, false, 0); err != nil {
 		return err
 	}
 	return nil
@@ -150,129 +358,460 @@ func (s *service) execute(ctx context.Context, done chan<- struct{}) {
 	defer close(done)
 	ticker := time.NewTicker(time.Duration(s.hbinterval) * time.Second)
 	defer ticker.Stop()
+	var checks sync.WaitGroup
+	for _, i := range s.replicas {
+		s.handlePingReplica(ctx, &checks, i)
+	}
 	for {
 		select {
 		case <-ctx.Done():
-			s.closeClient()
+			// replica checks are cancelled with ctx, and must finish before the
+			// clients they use are closed
+			checks.Wait()
+			s.closeClient(s.primary)
+			for _, i := range s.replicas {
+				s.closeClient(i)
+			}
 			return
 		case <-ticker.C:
-			s.handlePing()
+			s.handlePing(ctx, &checks)
+		case r := <-s.checks:
+			s.handleReplicaCheck(r)
 		case op := <-s.ops:
-			client, err := s.handleGetClient()
+			var client *sql.DB
+			var err error
+			if op.replica {
+				client, err = s.handleGetReplica(ctx, op.staleness)
+			} else {
+				client, err = s.handleGetClient(ctx, s.primary)
+			}
 			op.res <- getClientRes{
 				client: client,
 				err:    err,
 			}
 			close(op.res)
+		case op := <-s.statsops:
+			op.res <- s.handlePoolStats()
+			close(op.res)
 		}
 	}
 }
 
-func (s *service) handlePing() {
-	if s.client != nil {
-		err := s.client.Ping()
-		if err == nil {
-			s.ready = true
-			s.hbfailed = 0
-			return
-		}
-		s.hbfailed++
-		if s.hbfailed < s.hbmaxfail {
-			s.logger.Warn("failed to ping db", map[string]string{
-				"error":      err.Error(),
-				"actiontype": "pingdb",
-				"connection": s.connopts,
-				"username":   s.auth.username,
-			})
-			return
+func (s *service) handlePing(ctx context.Context, checks *sync.WaitGroup) {
+	s.handlePingPool(ctx, s.primary)
+	for _, i := range s.replicas {
+		s.handlePingReplica(ctx, checks, i)
+	}
+}
+
+// handlePingPool checks the health of the primary
+//
+// The credentials of a healthy pool are also checked on each heartbeat so that
+// they are rotated before they expire.
+func (s *service) handlePingPool(ctx context.Context, p *pool) {
+	s.handleDraining(p)
+	if p.client != nil {
+		hctx, cancel := context.WithTimeout(ctx, s.hbtimeout)
+		err := p.client.PingContext(hctx)
+		cancel()
+		if err != nil {
+			if !s.handlePoolFailure(p, err) {
+				return
+			}
+		} else {
+			p.ready = true
+			p.hbfailed = 0
 		}
-		s.logger.Error("failed max pings to db", map[string]string{
+	}
+	if _, err := s.handleGetClient(ctx, p); err != nil {
+		s.logger.Error("failed to create db client", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "createdbclient",
+			"pool":       p.name,
+		})
+	}
+}
+
+// handlePoolFailure records a failed health check of a pool, and returns true
+// if the pool has failed hbmaxfail checks and should be reconnected
+func (s *service) handlePoolFailure(p *pool, err error) bool {
+	p.hbfailed++
+	if p.hbfailed < s.hbmaxfail {
+		s.logger.Warn("failed to ping db", map[string]string{
 			"error":      err.Error(),
-			"actiontype": "pingdbmax",
-			"connection": s.connopts,
-			"username":   s.auth.username,
+			"actiontype": "pingdb",
+			"pool":       p.name,
+			"connection": p.connopts,
+			"username":   p.auth.username,
 		})
-		s.ready = false
-		s.hbfailed = 0
-		s.auth = pgauth{}
-		s.config.InvalidateSecret("auth")
+		return false
 	}
-	if _, err := s.handleGetClient(); err != nil {
+	s.logger.Error("failed max pings to db", map[string]string{
+		"error":      err.Error(),
+		"actiontype": "pingdbmax",
+		"pool":       p.name,
+		"connection": p.connopts,
+		"username":   p.auth.username,
+	})
+	p.ready = false
+	p.hbfailed = 0
+	p.auth = pgauth{}
+	s.config.InvalidateSecret("auth")
+	return true
+}
+
+// handlePingReplica starts a health check of a replica
+//
+// Replicas are checked asynchronously so that an unreachable replica does not
+// delay access to the primary, and a replica is not checked again until its
+// previous check finishes.
+func (s *service) handlePingReplica(ctx context.Context, checks *sync.WaitGroup, p *pool) {
+	if p.checking {
+		return
+	}
+	s.handleDraining(p)
+	auth, err := s.getAuth()
+	if err != nil {
 		s.logger.Error("failed to create db client", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "createdbclient",
+			"pool":       p.name,
 		})
+		if p.client == nil {
+			return
+		}
+		auth = p.auth
+	}
+	p.checking = true
+	checks.Add(1)
+	go s.checkReplica(ctx, checks, p, p.client, p.auth, auth)
+}
+
+// checkReplica gets the replication lag of the client of a replica, and
+// creates a new client if the credentials have changed
+//
+// It runs outside of the execute loop, and only reads the fields of the pool
+// which do not change.
+func (s *service) checkReplica(ctx context.Context, checks *sync.WaitGroup, p *pool, client *sql.DB, cur, auth pgauth) {
+	defer checks.Done()
+	r := s.runReplicaCheck(ctx, p, client, cur, auth)
+	select {
+	case <-ctx.Done():
+		if r.client != nil {
+			s.closeSQLClient(p, r.client, r.auth)
+		}
+	case s.checks <- r:
 	}
 }
 
-func (s *service) handleGetClient() (*sql.DB, error) {
+func (s *service) runReplicaCheck(ctx context.Context, p *pool, client *sql.DB, cur, auth pgauth) replicaCheck {
+	ctx, cancel := context.WithTimeout(ctx, s.hbtimeout)
+	defer cancel()
+	r := replicaCheck{
+		p: p,
+	}
+	if client != nil {
+		r.lag, r.err = getReplicaLag(ctx, client)
+	}
+	if auth == cur {
+		return r
+	}
+	next, err := s.newClient(ctx, p, auth)
+	if err != nil {
+		r.clientErr = err
+		return r
+	}
+	lag, err := getReplicaLag(ctx, next)
+	if err != nil {
+		s.closeSQLClient(p, next, auth)
+		r.clientErr = err
+		return r
+	}
+	r.client = next
+	r.auth = auth
+	r.clientLag = lag
+	return r
+}
+
+// handleReplicaCheck updates a replica with the result of its health check
+func (s *service) handleReplicaCheck(r replicaCheck) {
+	p := r.p
+	p.checking = false
+	if p.client != nil {
+		if r.err != nil {
+			s.handlePoolFailure(p, r.err)
+		} else {
+			p.ready = true
+			p.hbfailed = 0
+			p.lag = r.lag
+		}
+	}
+	if r.clientErr != nil {
+		if p.client != nil && p.ready {
+			// the previous credentials remain valid until they expire
+			s.logger.Warn("failed to rotate db credentials", map[string]string{
+				"error":      r.clientErr.Error(),
+				"actiontype": "rotatedbauth",
+				"pool":       p.name,
+				"connection": p.connopts,
+				"username":   p.auth.username,
+			})
+		} else {
+			s.logger.Error("failed to create db client", map[string]string{
+				"error":      r.clientErr.Error(),
+				"actiontype": "createdbclient",
+				"pool":       p.name,
+			})
+		}
+	}
+	if r.client != nil {
+		s.setClient(p, r.client, r.auth)
+		p.lag = r.clientLag
+	}
+}
+
+func getReplicaLag(ctx context.Context, client *sql.DB) (time.Duration, error) {
+	var lag sql.NullFloat64
+	if err := client.QueryRowContext(ctx, replicaLagQuery).Scan(&lag); err != nil {
+		return 0, governor.ErrWithKind(err, ErrConn{}, "Failed to get replica lag")
+	}
+	if !lag.Valid {
+		return replicaLagUnknown, nil
+	}
+	return time.Duration(lag.Float64 * float64(time.Second)), nil
+}
+
+// getAuth returns the current db credentials
+//
+// The secret of each set of credentials is kept so that its lease may be
+// revoked once no client uses the credentials.
+func (s *service) getAuth() (pgauth, error) {
 	authsecret, err := s.config.GetSecret("auth")
 	if err != nil {
-		return nil, err
+		return pgauth{}, err
 	}
 	username, ok := authsecret["username"].(string)
 	if !ok || username == "" {
-		return nil, governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid secret")
+		return pgauth{}, governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid secret")
 	}
 	password, ok := authsecret["password"].(string)
 	if !ok || password == "" {
-		return nil, governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid secret")
+		return pgauth{}, governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid secret")
 	}
 	auth := pgauth{
 		username: username,
 		password: password,
 	}
-	if auth == s.auth {
-		return s.client, nil
+	s.secrets[auth] = authsecret
+	s.curauth = auth
+	return auth, nil
+}
+
+func (s *service) handleGetClient(ctx context.Context, p *pool) (*sql.DB, error) {
+	auth, err := s.getAuth()
+	if err != nil {
+		return nil, err
+	}
+	if auth == p.auth {
+		return p.client, nil
+	}
+
+	hctx, cancel := context.WithTimeout(ctx, s.hbtimeout)
+	defer cancel()
+	client, err := s.newClient(hctx, p, auth)
+	if err != nil {
+		if p.client != nil && p.ready {
+			// the previous credentials remain valid until they expire
+			s.logger.Warn("failed to rotate db credentials", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "rotatedbauth",
+				"pool":       p.name,
+				"connection": p.connopts,
+				"username":   p.auth.username,
+			})
+			return p.client, nil
+		}
+		return nil, err
 	}
+	s.setClient(p, client, auth)
+	return p.client, nil
+}
 
-	s.closeClient()
+// setClient replaces the client of a pool with a new client
+func (s *service) setClient(p *pool, client *sql.DB, auth pgauth) {
+	if p.client != nil {
+		// queries in flight on the previous client are allowed to finish before
+		// it is closed
+		s.closeDraining(p)
+		p.draining = p.client
+		p.drainauth = p.auth
+		p.drainAt = time.Now()
+	}
+	p.client = client
+	p.auth = auth
+	p.ready = true
+	p.hbfailed = 0
+	s.logger.Info(fmt.Sprintf("established connection to %s with user %s", p.connopts, p.auth.username), nil)
+}
 
-	opts := fmt.Sprintf("user=%s password=%s %s", auth.username, auth.password, s.connopts)
+// newClient opens and pings a new client for a pool
+func (s *service) newClient(ctx context.Context, p *pool, auth pgauth) (*sql.DB, error) {
+	opts := fmt.Sprintf("user=%s password=%s %s", auth.username, auth.password, p.connopts)
 	client, err := sql.Open("postgres", opts)
 	if err != nil {
 		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to init db conn")
 	}
-	if err := client.Ping(); err != nil {
+	client.SetMaxOpenConns(s.maxopen)
+	client.SetMaxIdleConns(s.maxidle)
+	client.SetConnMaxLifetime(s.maxlifetime)
+	if err := client.PingContext(ctx); err != nil {
+		s.closeSQLClient(p, client, auth)
 		s.config.InvalidateSecret("auth")
 		return nil, governor.ErrWithKind(err, ErrConn{}, "Failed to ping db")
 	}
+	return client, nil
+}
 
-	s.client = client
-	s.auth = auth
-	s.ready = true
-	s.hbfailed = 0
-	s.logger.Info(fmt.Sprintf("established connection to %s with user %s", s.connopts, s.auth.username), nil)
-	return s.client, nil
+// handleGetReplica returns the client of the next ready replica in round robin
+// order whose lag is within staleness
+//
+// The lag of each replica is the lag as of its last heartbeat. The primary is
+// returned if there is no such replica.
+func (s *service) handleGetReplica(ctx context.Context, staleness time.Duration) (*sql.DB, error) {
+	for range s.replicas {
+		p := s.replicas[s.nextReplica]
+		s.nextReplica = (s.nextReplica + 1) % len(s.replicas)
+		if p.client != nil && p.ready && p.lag <= staleness {
+			return p.client, nil
+		}
+	}
+	return s.handleGetClient(ctx, s.primary)
+}
+
+func (s *service) handlePoolStats() []PoolStats {
+	pools := make([]*pool, 0, len(s.replicas)+1)
+	pools = append(pools, s.primary)
+	pools = append(pools, s.replicas...)
+	res := make([]PoolStats, 0, len(pools))
+	for _, i := range pools {
+		m := PoolStats{
+			Name:  i.name,
+			Ready: i.ready,
+			LagMS: i.lag.Milliseconds(),
+		}
+		if i.lag == replicaLagUnknown {
+			m.LagMS = -1
+		}
+		if i.client != nil {
+			st := i.client.Stats()
+			m.MaxOpen = st.MaxOpenConnections
+			m.Open = st.OpenConnections
+			m.InUse = st.InUse
+			m.Idle = st.Idle
+			m.WaitCount = st.WaitCount
+			m.WaitDurationMS = st.WaitDuration.Milliseconds()
+		}
+		res = append(res, m)
+	}
+	return res
 }
 
-func (s *service) closeClient() {
-	if s.client == nil {
+func (s *service) closeClient(p *pool) {
+	s.closeDraining(p)
+	if p.client == nil {
+		return
+	}
+	s.closeSQLClient(p, p.client, p.auth)
+	p.client = nil
+	p.auth = pgauth{}
+	p.ready = false
+}
+
+// handleDraining closes the draining client of a pool once it has drained
+//
+// A draining client is kept for at least one heartbeat, and then until none of
+// its connections are in use or it has been draining for drainTimeout.
+func (s *service) handleDraining(p *pool) {
+	if p.draining == nil {
+		return
+	}
+	since := time.Since(p.drainAt)
+	if since < time.Duration(s.hbinterval)*time.Second {
 		return
 	}
-	if err := s.client.Close(); err != nil {
+	if since < drainTimeout && p.draining.Stats().InUse > 0 {
+		return
+	}
+	s.closeDraining(p)
+}
+
+func (s *service) closeDraining(p *pool) {
+	if p.draining == nil {
+		return
+	}
+	s.closeSQLClient(p, p.draining, p.drainauth)
+	p.draining = nil
+	p.drainauth = pgauth{}
+	s.revokeSecrets()
+}
+
+// revokeSecrets revokes the leases of all previous credentials which are no
+// longer used by any client
+func (s *service) revokeSecrets() {
+	for auth, secret := range s.secrets {
+		if auth == s.curauth || s.authInUse(auth) {
+			continue
+		}
+		delete(s.secrets, auth)
+		if err := s.config.RevokeSecret("auth", secret); err != nil {
+			s.logger.Error("failed to revoke db credentials", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "revokedbauth",
+				"username":   auth.username,
+			})
+		}
+	}
+}
+
+func (s *service) authInUse(auth pgauth) bool {
+	pools := make([]*pool, 0, len(s.replicas)+1)
+	pools = append(pools, s.primary)
+	pools = append(pools, s.replicas...)
+	for _, i := range pools {
+		if i.client != nil && i.auth == auth {
+			return true
+		}
+		if i.draining != nil && i.drainauth == auth {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) closeSQLClient(p *pool, client *sql.DB, auth pgauth) {
+	if err := client.Close(); err != nil {
 		s.logger.Error("failed to close db connection", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "closedberr",
-			"connection": s.connopts,
-			"username":   s.auth.username,
+			"pool":       p.name,
+			"connection": p.connopts,
+			"username":   auth.username,
 		})
 	} else {
 		s.logger.Info("closed db connection", map[string]string{
 			"actiontype": "closedbok",
-			"connection": s.connopts,
-			"username":   s.auth.username,
+			"pool":       p.name,
+			"connection": p.connopts,
+			"username":   auth.username,
 		})
 	}
-	s.client = nil
-	s.auth = pgauth{}
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -292,24 +831,184 @@ func (s *service) Stop(ctx context.Context) {
 	}
 }
 
+// Health reports the health of the primary
+//
+// Unhealthy replicas do not fail the health check, since reads fall back to
+// the primary.
 func (s *service) Health() error {
-	if !s.ready {
+	if !s.primary.ready {
 		return governor.ErrWithKind(nil, ErrConn{}, "DB service not ready")
 	}
 	return nil
 }
 
-// DB implements Database.DB by returning its wrapped sql.DB
-func (s *service) DB() (*sql.DB, error) {
+func (s *service) getClient(ctx context.Context, replica bool, staleness time.Duration) (*sql.DB, error) {
 	res := make(chan getClientRes)
 	op := getOp{
-		res: res,
+		replica:   replica,
+		staleness: staleness,
+		res:       res,
 	}
 	select {
 	case <-s.done:
 		return nil, governor.ErrWithKind(nil, ErrConn{}, "DB service shutdown")
+	case <-ctx.Done():
+		return nil, governor.ErrWithKind(ctx.Err(), ErrConn{}, "Context cancelled")
 	case s.ops <- op:
 		v := <-res
 		return v.client, v.err
 	}
 }
+
+// DB implements Database.DB by returning the client of the primary
+func (s *service) DB(ctx context.Context) (*sql.DB, error) {
+	return s.getClient(ctx, false, 0)
+}
+
+// ReadDB implements Database.ReadDB by returning the client of a read replica
+//
+// Results read from a replica may be up to staleness out of date, and must
+// not be used to make subsequent writes.
+func (s *service) ReadDB(ctx context.Context, staleness time.Duration) (*sql.DB, error) {
+	return s.getClient(ctx, true, staleness)
+}
+
+// Executor implements Database.Executor by returning an instrumented executor
+// of the primary
+func (s *service) Executor(ctx context.Context) (SQLExecutor, error) {
+	d, err := s.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.instrument.executor(d, d), nil
+}
+
+// ReadExecutor implements Database.ReadExecutor by returning an instrumented
+// executor of a read replica
+//
+// See ReadDB.
+func (s *service) ReadExecutor(ctx context.Context, staleness time.Duration) (SQLExecutor, error) {
+	d, err := s.ReadDB(ctx, staleness)
+	if err != nil {
+		return nil, err
+	}
+	return s.instrument.executor(d, d), nil
+}
+
+// Stats returns the stats of recorded queries and of each pool
+func (s *service) Stats(ctx context.Context) (*Stats, error) {
+	res := make(chan []PoolStats)
+	op := statsOp{
+		res: res,
+	}
+	select {
+	case <-s.done:
+		return nil, governor.ErrWithKind(nil, ErrConn{}, "DB service shutdown")
+	case <-ctx.Done():
+		return nil, governor.ErrWithKind(ctx.Err(), ErrConn{}, "Context cancelled")
+	case s.statsops <- op:
+		return &Stats{
+			Queries: s.instrument.stats(),
+			Pools:   <-res,
+		}, nil
+	}
+}
+
+// WithTx runs fn in a transaction
+//
+// The transaction is begun with opts, and runs at the default read committed
+// isolation level if opts is nil. It is committed if fn returns nil, and is
+// otherwise rolled back with the error of fn returned. The transaction is
+// retried from the start of fn if it fails with a serialization failure or
+// deadlock, so fn must not have side effects outside of the transaction.
+// Serialization failures only occur at the repeatable read and serializable
+// isolation levels. ErrConflict is returned if the transaction keeps failing.
+func (s *service) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx SQLExecutor) error) error {
+	d, err := s.getClient(ctx, false, 0)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < txMaxRetries; i++ {
+		err = s.execTx(ctx, d, opts, fn)
+		if err == nil {
+			return nil
+		}
+		if !isSerializationFailure(err) {
+			return err
+		}
+		if i+1 == txMaxRetries {
+			break
+		}
+		timer := time.NewTimer(txBackoff(i))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return governor.ErrWithKind(ctx.Err(), ErrConflict{}, "Context cancelled")
+		case <-timer.C:
+		}
+	}
+	return governor.ErrWithKind(err, ErrConflict{}, "Failed to serialize transaction")
+}
+
+var (
+	txJitterMu sync.Mutex
+	txJitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// txBackoff returns the delay before retrying a transaction after a number of
+// previous retries
+//
+// The delay is doubled for each retry up to txRetryMax, and is jittered
+// between half and all of it so that conflicting transactions do not retry in
+// lockstep.
+func txBackoff(retries int) time.Duration {
+	delay := txRetryBase
+	for i := 0; i < retries && delay < txRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > txRetryMax {
+		delay = txRetryMax
+	}
+	half := int64(delay / 2)
+	txJitterMu.Lock()
+	defer txJitterMu.Unlock()
+	return time.Duration(half + txJitter.Int63n(half+1))
+}
+
+func (s *service) execTx(ctx context.Context, d *sql.DB, opts *sql.TxOptions, fn func(tx SQLExecutor) error) error {
+	tx, err := d.BeginTx(ctx, opts)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to begin transaction")
+	}
+	// queries in a transaction are not explained, since they may wait on locks
+	// held by the transaction
+	if err := fn(s.instrument.executor(tx, nil)); err != nil {
+		if err := tx.Rollback(); err != nil {
+			s.logger.Error("failed to rollback transaction", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "rollbacktx",
+			})
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to commit transaction")
+	}
+	return nil
+}
+
+// isSerializationFailure returns if an error is caused by a postgres
+// serialization failure or deadlock, after which a transaction may be retried
+func isSerializationFailure(err error) bool {
+	var pqerr *pq.Error
+	if !errors.As(err, &pqerr) {
+		return false
+	}
+	switch pqerr.Code {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	default:
+		return false
+	}
+}
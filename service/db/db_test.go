@@ -0,0 +1,287 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+type (
+	testLogger struct{}
+)
+
+func (l testLogger) Debug(msg string, data map[string]string) {}
+
+func (l testLogger) Info(msg string, data map[string]string) {}
+
+func (l testLogger) Warn(msg string, data map[string]string) {}
+
+func (l testLogger) Error(msg string, data map[string]string) {}
+
+func (l testLogger) Fatal(msg string, data map[string]string) {}
+
+func (l testLogger) Subtree(module string) governor.Logger {
+	return l
+}
+
+func (l testLogger) WithData(data map[string]string) governor.Logger {
+	return l
+}
+
+type (
+	testInjector struct {
+		values map[interface{}]interface{}
+	}
+
+	testDatabase struct {
+		Database
+	}
+)
+
+func (i *testInjector) Get(key interface{}) interface{} {
+	return i.values[key]
+}
+
+func (i *testInjector) Set(key, value interface{}) {
+	i.values[key] = value
+}
+
+func (i *testInjector) Clone() governor.Injector {
+	values := make(map[interface{}]interface{}, len(i.values))
+	for k, v := range i.values {
+		values[k] = v
+	}
+	return &testInjector{
+		values: values,
+	}
+}
+
+func TestGetCtxStatser(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	inj := &testInjector{
+		values: map[interface{}]interface{}{},
+	}
+	_, err := GetCtxStatser(inj)
+	assert.Error(err, "Should fail without a registered db")
+
+	setCtxDB(inj, testDatabase{})
+	_, err = GetCtxStatser(inj)
+	assert.Error(err, "Should fail for a db which does not report stats")
+
+	s := New()
+	setCtxDB(inj, s)
+	st, err := GetCtxStatser(inj)
+	assert.NoError(err)
+	assert.Equal(s, st)
+}
+
+func TestIsSerializationFailure(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	assert.True(isSerializationFailure(&pq.Error{Code: "40001"}))
+	assert.True(isSerializationFailure(&pq.Error{Code: "40P01"}))
+	assert.True(isSerializationFailure(governor.ErrWithMsg(&pq.Error{Code: "40001"}, "Failed to update")))
+	assert.False(isSerializationFailure(&pq.Error{Code: "23505"}))
+	assert.False(isSerializationFailure(errors.New("test error")))
+	assert.False(isSerializationFailure(nil))
+}
+
+func TestTxBackoff(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	for i := 0; i < txMaxRetries; i++ {
+		max := txRetryBase << i
+		if max > txRetryMax {
+			max = txRetryMax
+		}
+		for j := 0; j < 16; j++ {
+			delay := txBackoff(i)
+			assert.True(delay >= max/2 && delay <= max, "Should jitter within the doubled delay")
+		}
+	}
+	assert.True(txBackoff(64) <= txRetryMax, "Should cap the delay")
+}
+
+func TestHandleGetReplica(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	client1, err := sql.Open("postgres", "")
+	assert.NoError(err)
+	client2, err := sql.Open("postgres", "")
+	assert.NoError(err)
+	client3, err := sql.Open("postgres", "")
+	assert.NoError(err)
+
+	s := &service{
+		primary: &pool{
+			name: "primary",
+		},
+		replicas: []*pool{
+			{name: "replica0", replica: true, client: client1, ready: true, lag: 0},
+			{name: "replica1", replica: true, client: client2, ready: true, lag: 10 * time.Second},
+			{name: "replica2", replica: true, client: client3, ready: true, lag: replicaLagUnknown},
+		},
+	}
+
+	c, err := s.handleGetReplica(context.Background(), time.Minute)
+	assert.NoError(err)
+	assert.Equal(client1, c)
+	c, err = s.handleGetReplica(context.Background(), time.Minute)
+	assert.NoError(err)
+	assert.Equal(client2, c)
+	c, err = s.handleGetReplica(context.Background(), time.Minute)
+	assert.NoError(err)
+	assert.Equal(client1, c, "Should skip replicas which are not streaming")
+	c, err = s.handleGetReplica(context.Background(), time.Second)
+	assert.NoError(err)
+	assert.Equal(client1, c)
+	c, err = s.handleGetReplica(context.Background(), time.Second)
+	assert.NoError(err)
+	assert.Equal(client1, c)
+}
+
+func TestHandleReplicaCheck(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	client1, err := sql.Open("postgres", "")
+	assert.NoError(err)
+	client2, err := sql.Open("postgres", "")
+	assert.NoError(err)
+
+	p := &pool{
+		name:     "replica0",
+		replica:  true,
+		client:   client1,
+		auth:     pgauth{username: "user1", password: "pass1"},
+		ready:    true,
+		checking: true,
+	}
+	s := &service{
+		logger:    testLogger{},
+		hbmaxfail: 5,
+		replicas:  []*pool{p},
+	}
+
+	s.handleReplicaCheck(replicaCheck{
+		p:   p,
+		lag: 2 * time.Second,
+	})
+	assert.False(p.checking, "Should allow the next check")
+	assert.True(p.ready)
+	assert.Equal(2*time.Second, p.lag)
+
+	p.checking = true
+	s.handleReplicaCheck(replicaCheck{
+		p:         p,
+		err:       errors.New("test error"),
+		clientErr: errors.New("test error"),
+	})
+	assert.False(p.checking)
+	assert.True(p.ready, "Should tolerate fewer than max failed checks")
+	assert.Equal(1, p.hbfailed)
+	assert.Equal(client1, p.client, "Should keep the client if it could not be replaced")
+
+	p.checking = true
+	auth := pgauth{username: "user2", password: "pass2"}
+	s.handleReplicaCheck(replicaCheck{
+		p:         p,
+		lag:       time.Second,
+		client:    client2,
+		auth:      auth,
+		clientLag: 0,
+	})
+	assert.Equal(client2, p.client, "Should replace the client with new credentials")
+	assert.Equal(auth, p.auth)
+	assert.Equal(client1, p.draining, "Should drain the previous client")
+	assert.Equal(0, p.hbfailed)
+	assert.Equal(time.Duration(0), p.lag)
+}
+
+func TestHandleDraining(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	client1, err := sql.Open("postgres", "")
+	assert.NoError(err)
+	client2, err := sql.Open("postgres", "")
+	assert.NoError(err)
+
+	auth1 := pgauth{username: "user1", password: "pass1"}
+	auth2 := pgauth{username: "user2", password: "pass2"}
+	p := &pool{
+		name:      "primary",
+		client:    client2,
+		auth:      auth2,
+		ready:     true,
+		draining:  client1,
+		drainauth: auth1,
+		drainAt:   time.Now(),
+	}
+	s := &service{
+		primary:    p,
+		secrets:    map[pgauth]map[string]interface{}{},
+		curauth:    auth2,
+		logger:     testLogger{},
+		hbinterval: 5,
+	}
+
+	assert.True(s.authInUse(auth1))
+	assert.True(s.authInUse(auth2))
+	s.handleDraining(p)
+	assert.Equal(client1, p.draining, "Should keep the draining client for a heartbeat")
+
+	p.drainAt = time.Now().Add(-10 * time.Second)
+	s.handleDraining(p)
+	assert.Nil(p.draining, "Should close the draining client once no connections are in use")
+	assert.False(s.authInUse(auth1))
+	assert.True(s.authInUse(auth2))
+}
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	for _, i := range []Cursor{
+		{Time: 1621234567, Key: "abc:def"},
+		{Time: -5, Key: ""},
+		FirstCursor(true),
+		FirstCursor(false),
+	} {
+		c, err := DecodeCursor(i.Encode())
+		assert.NoError(err)
+		assert.Equal(i, *c)
+	}
+
+	for _, i := range []string{"", "!!!", "bm9jb2xvbg", "YWJjOmRlZg"} {
+		_, err := DecodeCursor(i)
+		assert.Error(err)
+		assert.True(errors.Is(err, ErrInvalidCursor{}))
+	}
+
+	for _, i := range []string{"", CursorFirst} {
+		c, err := ParseCursor(i)
+		assert.NoError(err)
+		assert.Nil(c)
+	}
+	_, err := DecodeCursor(CursorFirst)
+	assert.Error(err, "Should not decode the first cursor token as a cursor")
+}
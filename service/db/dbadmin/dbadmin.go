@@ -0,0 +1,118 @@
+package dbadmin
+
+import (
+	"context"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+type (
+	// Service is a db admin governor.Service
+	Service interface {
+		governor.Service
+	}
+
+	service struct {
+		db     db.Statser
+		dberr  error
+		gate   gate.Gate
+		logger governor.Logger
+	}
+
+	router struct {
+		s service
+	}
+)
+
+// NewCtx creates a new db admin service from a context
+//
+// The service fails to init if the db service does not report stats.
+func NewCtx(inj governor.Injector) Service {
+	d, err := db.GetCtxStatser(inj)
+	g := gate.GetCtxGate(inj)
+	s := New(d, g).(*service)
+	s.dberr = err
+	return s
+}
+
+// New returns a new db admin service
+func New(d db.Statser, g gate.Gate) Service {
+	return &service{
+		db:   d,
+		gate: g,
+	}
+}
+
+func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
+}
+
+func (s *service) router() *router {
+	return &router{
+		s: *s,
+	}
+}
+
+func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
+	s.logger = l
+	l = s.logger.WithData(map[string]string{
+		"phase": "init",
+	})
+
+	if s.dberr != nil {
+		return governor.ErrWithMsg(s.dberr, "Failed to get db stats")
+	}
+
+	sr := s.router()
+	sr.mountRoute(m)
+	l.Info("mounted http routes", nil)
+
+	return nil
+}
+
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) Start(ctx context.Context) error {
+	return nil
+}
+
+func (s *service) Stop(ctx context.Context) {
+}
+
+func (s *service) Health() error {
+	return nil
+}
+
+func (s *service) GetStats(ctx context.Context) (*db.Stats, error) {
+	m, err := s.db.Stats(ctx)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get db stats")
+	}
+	return m, nil
+}
+
+func (m *router) getStats(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	res, err := m.s.GetStats(c.Ctx())
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+const (
+	scopeStatsRead = "gov.db.stats:read"
+)
+
+func (m *router) mountRoute(r governor.Router) {
+	r.Get("/stats", m.getStats, gate.Admin(m.s.gate, scopeStatsRead))
+}
@@ -0,0 +1,382 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xorkevin.dev/governor"
+)
+
+const (
+	// explainTimeout is the max duration of explaining a slow query
+	explainTimeout = 5 * time.Second
+)
+
+type (
+	// Rows is the subset of methods of *sql.Rows used to read query results
+	Rows interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Err() error
+		Close() error
+	}
+
+	// Row is the subset of methods of *sql.Row used to read a query result
+	Row interface {
+		Scan(dest ...interface{}) error
+	}
+
+	// QueryStats are the latency and row count stats of a named query
+	QueryStats struct {
+		Name    string `json:"name"`
+		Count   int64  `json:"count"`
+		Errors  int64  `json:"errors"`
+		Slow    int64  `json:"slow"`
+		Rows    int64  `json:"rows"`
+		TotalUS int64  `json:"total_us"`
+		MaxUS   int64  `json:"max_us"`
+	}
+
+	// PoolStats are the connection stats of a db pool
+	//
+	// LagMS is -1 for a replica which is not streaming from the primary.
+	PoolStats struct {
+		Name           string `json:"name"`
+		Ready          bool   `json:"ready"`
+		LagMS          int64  `json:"lag_ms"`
+		MaxOpen        int    `json:"max_open"`
+		Open           int    `json:"open"`
+		InUse          int    `json:"in_use"`
+		Idle           int    `json:"idle"`
+		WaitCount      int64  `json:"wait_count"`
+		WaitDurationMS int64  `json:"wait_duration_ms"`
+	}
+
+	// Stats are the query and pool stats of the db service
+	Stats struct {
+		Queries []QueryStats `json:"queries"`
+		Pools   []PoolStats  `json:"pools"`
+	}
+
+	// Statser reports stats on queries and db pools
+	Statser interface {
+		Stats(ctx context.Context) (*Stats, error)
+	}
+
+	// instrumenter records the stats of queries made through its executors
+	instrumenter struct {
+		mu              *sync.Mutex
+		queries         map[string]*QueryStats
+		lastExplain     map[string]time.Time
+		slowThreshold   time.Duration
+		explain         bool
+		explainInterval time.Duration
+		logger          governor.Logger
+	}
+
+	// instrumentedExecutor records the stats of each query made through it
+	//
+	// Query plans of slow queries are explained on base, and are not explained
+	// if base is nil.
+	instrumentedExecutor struct {
+		inst *instrumenter
+		d    sqlExecutor
+		base *sql.DB
+	}
+
+	// queryRecord is an in progress query
+	queryRecord struct {
+		inst  *instrumenter
+		base  *sql.DB
+		name  string
+		query string
+		args  []interface{}
+		start time.Time
+	}
+
+	instrumentedRows struct {
+		*sql.Rows
+		rec    queryRecord
+		count  int64
+		closed bool
+	}
+
+	instrumentedRow struct {
+		row *sql.Row
+		rec queryRecord
+	}
+)
+
+func newInstrumenter() *instrumenter {
+	return &instrumenter{
+		mu:          &sync.Mutex{},
+		queries:     map[string]*QueryStats{},
+		lastExplain: map[string]time.Time{},
+	}
+}
+
+// executor returns an SQLExecutor that instruments queries made on d
+func (i *instrumenter) executor(d sqlExecutor, base *sql.DB) SQLExecutor {
+	return &instrumentedExecutor{
+		inst: i,
+		d:    d,
+		base: base,
+	}
+}
+
+// queryName returns the name of the function that made a query
+//
+// For repo queries this is the name of the model query function, e.g.
+// userModelGetModelEqUserid.
+func queryName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	if k := strings.LastIndexByte(name, '/'); k >= 0 {
+		name = name[k+1:]
+	}
+	if k := strings.IndexByte(name, '.'); k >= 0 {
+		name = name[k+1:]
+	}
+	return name
+}
+
+func (i *instrumenter) start(base *sql.DB, name, query string, args []interface{}) queryRecord {
+	return queryRecord{
+		inst:  i,
+		base:  base,
+		name:  name,
+		query: query,
+		args:  args,
+		start: time.Now(),
+	}
+}
+
+// finish records a completed query, and logs it if it is slow
+//
+// errors that are not failures of the query itself, such as sql.ErrNoRows,
+// should not be passed as err.
+func (r queryRecord) finish(rows int64, err error) {
+	elapsed := time.Since(r.start)
+	slow := r.inst.slowThreshold > 0 && elapsed >= r.inst.slowThreshold
+	explain := r.inst.record(r.name, elapsed, rows, slow, err)
+	if !slow {
+		return
+	}
+	data := map[string]string{
+		"actiontype": "slowquery",
+		"query":      r.name,
+		"sql":        r.query,
+		"args":       redactArgs(r.args),
+		"duration":   elapsed.String(),
+		"rows":       strconv.FormatInt(rows, 10),
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	r.inst.logger.Warn("slow query", data)
+	if explain {
+		// the query is explained in the background so that the caller is not
+		// delayed further
+		go r.explain()
+	}
+}
+
+// record updates the stats of a query, and returns if the plan of the query
+// should be sampled
+func (i *instrumenter) record(name string, elapsed time.Duration, rows int64, slow bool, err error) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	q, ok := i.queries[name]
+	if !ok {
+		q = &QueryStats{
+			Name: name,
+		}
+		i.queries[name] = q
+	}
+	q.Count++
+	if err != nil {
+		q.Errors++
+	}
+	q.Rows += rows
+	us := elapsed.Microseconds()
+	q.TotalUS += us
+	if us > q.MaxUS {
+		q.MaxUS = us
+	}
+	if !slow {
+		return false
+	}
+	q.Slow++
+	if !i.explain {
+		return false
+	}
+	now := time.Now()
+	if last, ok := i.lastExplain[name]; ok && now.Sub(last) < i.explainInterval {
+		return false
+	}
+	i.lastExplain[name] = now
+	return true
+}
+
+// explain logs the plan of a slow query
+//
+// Only select statements are explained, since EXPLAIN ANALYZE executes the
+// statement again. The explain is given explainTimeout to finish.
+func (r queryRecord) explain() {
+	if r.base == nil || !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(r.query)), "SELECT") {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+	rows, err := r.base.QueryContext(ctx, "EXPLAIN ANALYZE "+r.query, r.args...)
+	if err != nil {
+		r.inst.logger.Error("failed to explain query", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "explainquery",
+			"query":      r.name,
+		})
+		return
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.inst.logger.Error("failed to close explain rows", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "explainquery",
+				"query":      r.name,
+			})
+		}
+	}()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			r.inst.logger.Error("failed to scan query plan", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "explainquery",
+				"query":      r.name,
+			})
+			return
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		r.inst.logger.Error("failed to read query plan", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "explainquery",
+			"query":      r.name,
+		})
+		return
+	}
+	r.inst.logger.Debug("slow query plan", map[string]string{
+		"actiontype": "explainquery",
+		"query":      r.name,
+		"plan":       strings.Join(plan, "\n"),
+	})
+}
+
+// redactArgs describes query arguments by their types only
+func redactArgs(args []interface{}) string {
+	s := make([]string, 0, len(args))
+	for n, i := range args {
+		t := "null"
+		if i != nil {
+			t = fmt.Sprintf("%T", i)
+		}
+		s = append(s, "$"+strconv.Itoa(n+1)+":"+t)
+	}
+	return strings.Join(s, ", ")
+}
+
+// stats returns the stats of all recorded queries sorted by name
+func (i *instrumenter) stats() []QueryStats {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	res := make([]QueryStats, 0, len(i.queries))
+	for _, v := range i.queries {
+		res = append(res, *v)
+	}
+	sort.Slice(res, func(a, b int) bool {
+		return res[a].Name < res[b].Name
+	})
+	return res
+}
+
+func (e *instrumentedExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	rec := e.inst.start(e.base, queryName(1), query, args)
+	res, err := e.d.ExecContext(ctx, query, args...)
+	var rows int64
+	if err == nil {
+		if n, err := res.RowsAffected(); err == nil {
+			rows = n
+		}
+	}
+	rec.finish(rows, err)
+	return res, err
+}
+
+func (e *instrumentedExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	rec := e.inst.start(e.base, queryName(1), query, args)
+	rows, err := e.d.QueryContext(ctx, query, args...)
+	if err != nil {
+		rec.finish(0, err)
+		return nil, err
+	}
+	return &instrumentedRows{
+		Rows: rows,
+		rec:  rec,
+	}, nil
+}
+
+func (e *instrumentedExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) Row {
+	rec := e.inst.start(e.base, queryName(1), query, args)
+	return &instrumentedRow{
+		row: e.d.QueryRowContext(ctx, query, args...),
+		rec: rec,
+	}
+}
+
+func (r *instrumentedRows) Next() bool {
+	if !r.Rows.Next() {
+		return false
+	}
+	r.count++
+	return true
+}
+
+// Close closes the rows and records the query, whose latency includes the
+// time taken to read its results
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.rec.finish(r.count, r.Rows.Err())
+	}
+	return err
+}
+
+func (r *instrumentedRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	switch {
+	case err == nil:
+		r.rec.finish(1, nil)
+	case err == sql.ErrNoRows:
+		r.rec.finish(0, nil)
+	default:
+		r.rec.finish(0, err)
+	}
+	return err
+}
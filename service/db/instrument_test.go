@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactArgs(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	assert.Equal("", redactArgs(nil))
+	assert.Equal("$1:string, $2:int, $3:null", redactArgs([]interface{}{"secret", 5, nil}))
+}
+
+func TestQueryName(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	assert.Equal("TestQueryName", queryName(0))
+}
+
+func TestInstrumenterRecord(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	inst := newInstrumenter()
+	inst.explain = true
+	inst.explainInterval = time.Minute
+
+	assert.False(inst.record("userModelGet", 2*time.Millisecond, 1, false, nil))
+	assert.True(inst.record("userModelGet", 4*time.Millisecond, 3, true, nil))
+	assert.False(inst.record("userModelGet", time.Millisecond, 0, true, errors.New("test error")), "explains are sampled once per interval")
+	assert.Equal([]QueryStats{
+		{
+			Name:    "userModelGet",
+			Count:   3,
+			Errors:  1,
+			Slow:    2,
+			Rows:    4,
+			TotalUS: 7000,
+			MaxUS:   4000,
+		},
+	}, inst.stats())
+}
@@ -0,0 +1,448 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+
+	jsmapi "github.com/nats-io/jsm.go/api"
+	jsadvisory "github.com/nats-io/jsm.go/api/jetstream/advisory"
+	"github.com/nats-io/nats.go"
+	"xorkevin.dev/governor"
+)
+
+type (
+	// Admin manages streams and consumers
+	Admin interface {
+		ListStreams(ctx context.Context) ([]StreamInfo, error)
+		ListConsumers(ctx context.Context, stream string) ([]ConsumerInfo, error)
+		GetStreamMsg(ctx context.Context, stream string, seq uint64) (*StreamMsg, error)
+		PurgeStream(ctx context.Context, stream string) error
+		ReplayConsumer(ctx context.Context, stream, consumer string, opts ReplayOpts) error
+		RedriveDLQ(ctx context.Context, targetStream, targetConsumer string, stream string, limit int) (int, error)
+	}
+
+	// StreamInfo is the state of a stream
+	//
+	// Times are unix timestamps in seconds.
+	StreamInfo struct {
+		Name      string   `json:"name"`
+		Subjects  []string `json:"subjects"`
+		Msgs      uint64   `json:"msgs"`
+		Bytes     uint64   `json:"bytes"`
+		FirstSeq  uint64   `json:"first_seq"`
+		FirstTime int64    `json:"first_time"`
+		LastSeq   uint64   `json:"last_seq"`
+		LastTime  int64    `json:"last_time"`
+		Consumers int      `json:"consumers"`
+	}
+
+	// ConsumerInfo is the state of a stream consumer
+	//
+	// NumPending is the number of messages not yet delivered, and
+	// NumAckPending is the number of messages delivered but not yet acked.
+	ConsumerInfo struct {
+		Stream         string `json:"stream"`
+		Name           string `json:"name"`
+		DeliveredSeq   uint64 `json:"delivered_seq"`
+		AckFloorSeq    uint64 `json:"ack_floor_seq"`
+		NumPending     uint64 `json:"num_pending"`
+		NumAckPending  int    `json:"num_ack_pending"`
+		NumRedelivered int    `json:"num_redelivered"`
+		NumWaiting     int    `json:"num_waiting"`
+	}
+
+	// StreamMsg is a message stored in a stream
+	StreamMsg struct {
+		Stream   string              `json:"stream"`
+		Subject  string              `json:"subject"`
+		Sequence uint64              `json:"seq"`
+		Time     int64               `json:"time"`
+		Headers  map[string][]string `json:"headers,omitempty"`
+		Data     []byte              `json:"data"`
+	}
+
+	// ReplayOpts are opts for replaying a consumer
+	//
+	// Exactly one of Seq and Time should be set.
+	ReplayOpts struct {
+		Seq  uint64
+		Time time.Time
+	}
+)
+
+type (
+	// ErrNotFound is returned when a stream, consumer, or message is not found
+	ErrNotFound struct{}
+)
+
+func (e ErrNotFound) Error() string {
+	return "Events not found"
+}
+
+// GetCtxAdmin returns an Admin from the context
+func GetCtxAdmin(inj governor.Injector) Admin {
+	v := inj.Get(ctxKeyAdmin{})
+	if v == nil {
+		return nil
+	}
+	return v.(Admin)
+}
+
+// setCtxAdmin sets an Admin in the context
+func setCtxAdmin(inj governor.Injector, a Admin) {
+	inj.Set(ctxKeyAdmin{}, a)
+}
+
+const (
+	jsAPIStreamInfo     = "$JS.API.STREAM.INFO.%s"
+	jsAPIStreamPurge    = "$JS.API.STREAM.PURGE.%s"
+	jsAPIMsgGet         = "$JS.API.STREAM.MSG.GET.%s"
+	jsAPIConsumerInfo   = "$JS.API.CONSUMER.INFO.%s.%s"
+	jsAPIRequestTimeout = 5 * time.Second
+	jsErrNotFound       = 404
+	jsErrMsgNotFound    = 10037
+)
+
+type (
+	// jsAPIError is an error returned by the JetStream API
+	//
+	// The nats client only returns the description of API errors, so
+	// requests which must distinguish missing streams, consumers, and messages
+	// are made with jsAPIRequest.
+	jsAPIError struct {
+		Code        int    `json:"code"`
+		ErrCode     int    `json:"err_code,omitempty"`
+		Description string `json:"description,omitempty"`
+	}
+
+	jsAPIRes struct {
+		Error *jsAPIError `json:"error,omitempty"`
+	}
+
+	jsMsgGetReq struct {
+		Seq uint64 `json:"seq"`
+	}
+
+	jsMsgGetRes struct {
+		Message *jsStoredMsg `json:"message"`
+	}
+
+	jsStoredMsg struct {
+		Subject  string    `json:"subject"`
+		Sequence uint64    `json:"seq"`
+		Header   []byte    `json:"hdrs,omitempty"`
+		Data     []byte    `json:"data,omitempty"`
+		Time     time.Time `json:"time"`
+	}
+)
+
+func (e *jsAPIError) Error() string {
+	return e.Description
+}
+
+// jsAPIRequest makes a JetStream API request and decodes its response into res
+//
+// API errors are returned as a *jsAPIError.
+func jsAPIRequest(ctx context.Context, conn *nats.Conn, subj string, req, res interface{}) error {
+	var b []byte
+	if req != nil {
+		var err error
+		b, err = json.Marshal(req)
+		if err != nil {
+			return err
+		}
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, jsAPIRequestTimeout)
+		defer cancel()
+	}
+	msg, err := conn.RequestWithContext(ctx, subj, b)
+	if err != nil {
+		return err
+	}
+	r := jsAPIRes{}
+	if err := json.Unmarshal(msg.Data, &r); err != nil {
+		return err
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.Data, res)
+}
+
+// isNotFound returns if err is a JetStream API error for a missing stream,
+// consumer, or message
+func isNotFound(err error) bool {
+	var jerr *jsAPIError
+	if !errors.As(err, &jerr) {
+		return false
+	}
+	return jerr.Code == jsErrNotFound || jerr.ErrCode == jsErrMsgNotFound
+}
+
+// getStreamMsg returns a raw message of a stream
+func getStreamMsg(ctx context.Context, conn *nats.Conn, stream string, req jsMsgGetReq) (*jsStoredMsg, error) {
+	res := jsMsgGetRes{}
+	if err := jsAPIRequest(ctx, conn, fmt.Sprintf(jsAPIMsgGet, stream), req, &res); err != nil {
+		return nil, err
+	}
+	if res.Message == nil {
+		return nil, &jsAPIError{
+			Code:        jsErrNotFound,
+			ErrCode:     jsErrMsgNotFound,
+			Description: "no message found",
+		}
+	}
+	return res.Message, nil
+}
+
+// decodeMsgHeader decodes the raw headers of a stored message
+func decodeMsgHeader(b []byte) (map[string][]string, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	l, err := r.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(l, "NATS/1.0") {
+		return nil, errors.New("Invalid message header version")
+	}
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ListStreams returns the state of all streams
+func (s *service) ListStreams(ctx context.Context) ([]StreamInfo, error) {
+	_, client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := []StreamInfo{}
+	for i := range client.StreamsInfo(nats.Context(ctx)) {
+		res = append(res, StreamInfo{
+			Name:      i.Config.Name,
+			Subjects:  i.Config.Subjects,
+			Msgs:      i.State.Msgs,
+			Bytes:     i.State.Bytes,
+			FirstSeq:  i.State.FirstSeq,
+			FirstTime: i.State.FirstTime.Unix(),
+			LastSeq:   i.State.LastSeq,
+			LastTime:  i.State.LastTime.Unix(),
+			Consumers: i.State.Consumers,
+		})
+	}
+	return res, nil
+}
+
+// ListConsumers returns the state of all consumers of a stream
+func (s *service) ListConsumers(ctx context.Context, stream string) ([]ConsumerInfo, error) {
+	conn, client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := jsAPIRequest(ctx, conn, fmt.Sprintf(jsAPIStreamInfo, stream), nil, nil); err != nil {
+		if isNotFound(err) {
+			return nil, governor.ErrWithKind(err, ErrNotFound{}, "Stream not found")
+		}
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get stream")
+	}
+	res := []ConsumerInfo{}
+	for i := range client.ConsumersInfo(stream, nats.Context(ctx)) {
+		res = append(res, ConsumerInfo{
+			Stream:         i.Stream,
+			Name:           i.Name,
+			DeliveredSeq:   i.Delivered.Stream,
+			AckFloorSeq:    i.AckFloor.Stream,
+			NumPending:     i.NumPending,
+			NumAckPending:  i.NumAckPending,
+			NumRedelivered: i.NumRedelivered,
+			NumWaiting:     i.NumWaiting,
+		})
+	}
+	return res, nil
+}
+
+// GetStreamMsg returns a message of a stream by sequence
+func (s *service) GetStreamMsg(ctx context.Context, stream string, seq uint64) (*StreamMsg, error) {
+	conn, _, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := getStreamMsg(ctx, conn, stream, jsMsgGetReq{
+		Seq: seq,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, governor.ErrWithKind(err, ErrNotFound{}, "Message not found")
+		}
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get message")
+	}
+	headers, err := decodeMsgHeader(msg.Header)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to decode message headers")
+	}
+	return &StreamMsg{
+		Stream:   stream,
+		Subject:  msg.Subject,
+		Sequence: msg.Sequence,
+		Time:     msg.Time.Unix(),
+		Headers:  headers,
+		Data:     msg.Data,
+	}, nil
+}
+
+// PurgeStream removes all messages of a stream
+func (s *service) PurgeStream(ctx context.Context, stream string) error {
+	conn, _, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := jsAPIRequest(ctx, conn, fmt.Sprintf(jsAPIStreamPurge, stream), nil, nil); err != nil {
+		if isNotFound(err) {
+			return governor.ErrWithKind(err, ErrNotFound{}, "Stream not found")
+		}
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to purge stream")
+	}
+	return nil
+}
+
+// ReplayConsumer redelivers messages of a stream to a consumer starting from
+// a sequence or time
+//
+// The consumer is recreated with the same config and the new start position,
+// which discards its pending acks. Subscriptions to the consumer resubscribe
+// to the recreated consumer.
+func (s *service) ReplayConsumer(ctx context.Context, stream, consumer string, opts ReplayOpts) error {
+	conn, client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	info := nats.ConsumerInfo{}
+	if err := jsAPIRequest(ctx, conn, fmt.Sprintf(jsAPIConsumerInfo, stream, consumer), nil, &info); err != nil {
+		if isNotFound(err) {
+			return governor.ErrWithKind(err, ErrNotFound{}, "Consumer not found")
+		}
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to get consumer")
+	}
+	cfg := info.Config
+	cfg.OptStartSeq = 0
+	cfg.OptStartTime = nil
+	if opts.Seq > 0 {
+		cfg.DeliverPolicy = nats.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = opts.Seq
+	} else if !opts.Time.IsZero() {
+		t := opts.Time
+		cfg.DeliverPolicy = nats.DeliverByStartTimePolicy
+		cfg.OptStartTime = &t
+	} else {
+		return governor.ErrWithKind(nil, ErrClient{}, "Replay start sequence or time must be provided")
+	}
+	if err := client.DeleteConsumer(stream, consumer, nats.Context(ctx)); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to delete consumer")
+	}
+	if _, err := client.AddConsumer(stream, &cfg, nats.Context(ctx)); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to recreate consumer")
+	}
+	return nil
+}
+
+// parseDLQMsg parses a dead letter queue message of a target stream consumer
+func parseDLQMsg(msgdata []byte, targetStream, targetConsumer string) (*jsadvisory.ConsumerDeliveryExceededAdvisoryV1, error) {
+	schemaType, advmsg, err := jsmapi.ParseMessage(msgdata)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to parse dead letter queue message with unknown type")
+	}
+	jse, ok := advmsg.(*jsadvisory.ConsumerDeliveryExceededAdvisoryV1)
+	if !ok {
+		return nil, governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, fmt.Sprintf("Failed to parse dead letter queue message with type: %s", schemaType))
+	}
+	if jse.Stream != targetStream || jse.Consumer != targetConsumer {
+		return nil, governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, fmt.Sprintf("Invalid target stream and consumer: %s, %s", jse.Stream, jse.Consumer))
+	}
+	return jse, nil
+}
+
+// RedriveDLQ republishes up to limit messages of the dead letter queue of a
+// target stream consumer to their original subjects
+//
+// stream is the stream collecting the dead letter queue, as subscribed to by
+// DLQSubscribe. Redriven messages are removed from it, and the number of
+// redriven messages is returned. Messages are republished without their
+// original headers, so that they are not deduplicated by their original
+// message id. Dead letters of messages no longer in the target stream are
+// removed without being redriven.
+func (s *service) RedriveDLQ(ctx context.Context, targetStream, targetConsumer string, stream string, limit int) (int, error) {
+	conn, client, err := s.getClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := jsAPIRequest(ctx, conn, fmt.Sprintf(jsAPIStreamInfo, stream), nil, nil); err != nil {
+		if isNotFound(err) {
+			return 0, governor.ErrWithKind(err, ErrNotFound{}, "Stream not found")
+		}
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to get dead letter queue stream")
+	}
+	sub, err := client.SubscribeSync(channelMaxDelivery(targetStream, targetConsumer), nats.BindStream(stream), nats.DeliverAll(), nats.AckNone())
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to subscribe to dead letter queue")
+	}
+	defer func() {
+		if err := sub.Unsubscribe(); err != nil {
+			s.logger.Error("Failed to close dead letter queue subscription", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "redrivedlq",
+			})
+		}
+	}()
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to get dead letter queue consumer")
+	}
+	// messages may already have been delivered to the subscription by the
+	// time its info is returned
+	count := 0
+	for total := info.Delivered.Consumer + info.NumPending; total > 0 && count < limit; total-- {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return count, governor.ErrWithKind(err, ErrClient{}, "Failed to get dead letter queue message")
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return count, governor.ErrWithKind(err, ErrClient{}, "Failed to get dead letter queue message metadata")
+		}
+		jse, err := parseDLQMsg(msg.Data, targetStream, targetConsumer)
+		if err != nil {
+			return count, err
+		}
+		if orig, err := getStreamMsg(ctx, conn, targetStream, jsMsgGetReq{Seq: jse.StreamSeq}); err != nil {
+			if !isNotFound(err) {
+				return count, governor.ErrWithKind(err, ErrClient{}, fmt.Sprintf("Failed to get msg from stream: %d", jse.StreamSeq))
+			}
+		} else {
+			if _, err := client.Publish(orig.Subject, orig.Data, nats.Context(ctx)); err != nil {
+				return count, governor.ErrWithKind(err, ErrClient{}, "Failed to republish message to stream")
+			}
+			count++
+		}
+		if err := client.DeleteMsg(stream, meta.Sequence.Stream, nats.Context(ctx)); err != nil {
+			return count, governor.ErrWithKind(err, ErrClient{}, "Failed to remove message from dead letter queue")
+		}
+	}
+	return count, nil
+}
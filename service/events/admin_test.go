@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+func TestIsNotFound(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	assert.True(isNotFound(governor.ErrWithMsg(&jsAPIError{Code: 404, Description: "stream not found"}, "wrapped")))
+	assert.True(isNotFound(&jsAPIError{Code: 404, Description: "no message found"}), "Should match by code regardless of description")
+	assert.True(isNotFound(&jsAPIError{Code: 400, ErrCode: 10037}))
+	assert.False(isNotFound(&jsAPIError{Code: 500, Description: "not found"}))
+	assert.False(isNotFound(errors.New("stream not found")), "Should not match errors by text")
+}
+
+func TestDecodeMsgHeader(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	h, err := decodeMsgHeader([]byte("NATS/1.0\r\nNats-Msg-Id: test\r\n\r\n"))
+	assert.NoError(err)
+	assert.Equal(map[string][]string{"Nats-Msg-Id": {"test"}}, h)
+	h, err = decodeMsgHeader(nil)
+	assert.NoError(err)
+	assert.Nil(h)
+	_, err = decodeMsgHeader([]byte("HTTP/1.1\r\n\r\n"))
+	assert.Error(err)
+}
+
+func TestGetStreamMsg(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s, js := newTestEvents(t)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "test",
+		Subjects: []string{"test.>"},
+	})
+	assert.NoError(err)
+	_, err = js.Publish("test.msg", []byte("a"), nats.MsgId("msg-a"))
+	assert.NoError(err)
+
+	ctx := context.Background()
+	msg, err := s.GetStreamMsg(ctx, "test", 1)
+	assert.NoError(err)
+	assert.Equal("test.msg", msg.Subject)
+	assert.Equal([]byte("a"), msg.Data)
+	assert.Equal([]string{"msg-a"}, msg.Headers["Nats-Msg-Id"])
+
+	_, err = s.GetStreamMsg(ctx, "test", 2)
+	assert.True(errors.Is(err, ErrNotFound{}), "Should return not found for a missing message")
+	_, err = s.GetStreamMsg(ctx, "bogus", 1)
+	assert.True(errors.Is(err, ErrNotFound{}), "Should return not found for a missing stream")
+}
+
+func TestReplayConsumer(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s, js := newTestEvents(t)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "test",
+		Subjects: []string{"test.>"},
+	})
+	assert.NoError(err)
+	for _, i := range []string{"a", "b", "c"} {
+		_, err := js.Publish("test.msg", []byte(i))
+		assert.NoError(err)
+	}
+	sub, err := js.PullSubscribe("test.msg", "worker", nats.BindStream("test"), nats.ManualAck(), nats.AckExplicit())
+	assert.NoError(err)
+	msgs, err := sub.Fetch(3, nats.MaxWait(time.Second))
+	assert.NoError(err)
+	assert.Len(msgs, 3)
+	for _, i := range msgs {
+		assert.NoError(i.AckSync())
+	}
+
+	ctx := context.Background()
+	assert.NoError(s.ReplayConsumer(ctx, "test", "worker", ReplayOpts{Seq: 2}))
+	info, err := js.ConsumerInfo("test", "worker")
+	assert.NoError(err)
+	assert.Equal(nats.DeliverByStartSequencePolicy, info.Config.DeliverPolicy)
+	assert.Equal(nats.AckExplicitPolicy, info.Config.AckPolicy, "Should keep the consumer config")
+	assert.Equal(uint64(2), info.NumPending, "Should redeliver messages from the start sequence")
+
+	err = s.ReplayConsumer(ctx, "test", "bogus", ReplayOpts{Seq: 1})
+	assert.True(errors.Is(err, ErrNotFound{}), "Should return not found for a missing consumer")
+	err = s.ReplayConsumer(ctx, "test", "worker", ReplayOpts{})
+	assert.True(errors.Is(err, ErrClient{}), "Should require a start position")
+}
+
+func TestRedriveDLQ(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s, js := newTestEvents(t)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "test",
+		Subjects: []string{"test.>"},
+	})
+	assert.NoError(err)
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     "dlq",
+		Subjects: []string{channelMaxDelivery("test", "worker")},
+	})
+	assert.NoError(err)
+	for n, i := range []string{"a", "b", "c"} {
+		_, err := js.Publish("test.msg", []byte(i), nats.MsgId(i))
+		assert.NoError(err)
+		b, err := json.Marshal(map[string]interface{}{
+			"type":       "io.nats.jetstream.advisory.v1.max_deliver",
+			"id":         i,
+			"timestamp":  time.Now().Format(time.RFC3339Nano),
+			"stream":     "test",
+			"consumer":   "worker",
+			"stream_seq": n + 1,
+			"deliveries": 5,
+		})
+		assert.NoError(err)
+		_, err = js.Publish(channelMaxDelivery("test", "worker"), b)
+		assert.NoError(err)
+	}
+	assert.NoError(js.DeleteMsg("test", 2))
+
+	ctx := context.Background()
+	count, err := s.RedriveDLQ(ctx, "test", "worker", "dlq", 8)
+	assert.NoError(err, "Should skip dead letters of removed messages")
+	assert.Equal(2, count)
+
+	info, err := js.StreamInfo("dlq")
+	assert.NoError(err)
+	assert.Equal(uint64(0), info.State.Msgs, "Should remove all redriven and skipped dead letters")
+	for n, i := range []string{"a", "c"} {
+		msg, err := s.GetStreamMsg(ctx, "test", uint64(n+4))
+		assert.NoError(err)
+		assert.Equal([]byte(i), msg.Data, "Should republish without deduplication")
+	}
+
+	_, err = s.RedriveDLQ(ctx, "test", "worker", "bogus", 8)
+	assert.True(errors.Is(err, ErrNotFound{}), "Should return not found for a missing dead letter queue")
+}
@@ -0,0 +1,246 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"xorkevin.dev/governor"
+)
+
+const (
+	defaultRetryMax = 5 * time.Minute
+	// defaultAckWait is the ack wait of the stream server when none is set
+	defaultAckWait = 30 * time.Second
+)
+
+type (
+	// ErrWorkerTerm is returned by a stream worker for a message which should
+	// not be redelivered
+	ErrWorkerTerm struct{}
+	// ErrWorkerRetry is returned by a stream worker for a message which should
+	// be redelivered after Delay
+	ErrWorkerRetry struct {
+		Delay time.Duration
+	}
+)
+
+func (e ErrWorkerTerm) Error() string {
+	return "Events worker terminate message"
+}
+
+func (e ErrWorkerRetry) Error() string {
+	return "Events worker retry message"
+}
+
+// Is returns true for any ErrWorkerRetry regardless of its delay
+func (e ErrWorkerRetry) Is(target error) bool {
+	_, ok := target.(ErrWorkerRetry)
+	return ok
+}
+
+// WorkerRetryAfter returns an error for a stream worker to redeliver a message
+// after delay instead of the backoff of its consumer
+func WorkerRetryAfter(err error, delay time.Duration) error {
+	return governor.ErrWithKind(err, ErrWorkerRetry{Delay: delay}, "Retry message")
+}
+
+// WorkerTerm returns an error for a stream worker to stop redelivery of a
+// message
+//
+// Workers should return it for poison messages which will never be processed
+// successfully. Messages failing with ErrInvalidStreamMsg are also not
+// redelivered. Terminated messages are not sent to the dead letter queue.
+func WorkerTerm(err error) error {
+	return governor.ErrWithKind(err, ErrWorkerTerm{}, "Terminate message")
+}
+
+// workerRetryDelay returns the delay of the first ErrWorkerRetry in the error
+// chain
+func workerRetryDelay(err error) (time.Duration, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if gerr, ok := err.(*governor.Error); ok {
+			if k, ok := gerr.Kind.(ErrWorkerRetry); ok {
+				return k.Delay, true
+			}
+		}
+	}
+	return 0, false
+}
+
+type (
+	// batchProgress marks messages of a batch which are waiting to be processed
+	// as in progress, so that they are not redelivered before they are
+	// processed
+	batchProgress struct {
+		mu      *sync.Mutex
+		pending map[*nats.Msg]struct{}
+	}
+)
+
+// watchBatch marks messages of a batch as in progress every half ack wait
+// until each is started, and returns a function to stop watching the batch
+func (s *streamSubscription) watchBatch(msgs []*nats.Msg) (*batchProgress, func()) {
+	p := &batchProgress{
+		mu:      &sync.Mutex{},
+		pending: make(map[*nats.Msg]struct{}, len(msgs)),
+	}
+	for _, i := range msgs {
+		p.pending[i] = struct{}{}
+	}
+	ackWait := s.opts.AckWait
+	if ackWait <= 0 {
+		ackWait = defaultAckWait
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ackWait / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				for i := range p.pending {
+					if err := i.InProgress(); err != nil {
+						s.logger.Error("Failed to mark pending message in progress", map[string]string{
+							"error": err.Error(),
+						})
+					}
+				}
+				p.mu.Unlock()
+			}
+		}
+	}()
+	return p, func() {
+		close(stop)
+		<-done
+	}
+}
+
+// start removes a message from the pending messages of the batch
+func (p *batchProgress) start(msg *nats.Msg) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, msg)
+}
+
+// handleBatch processes a batch of fetched messages
+func (s *streamSubscription) handleBatch(ctx context.Context, msgs []*nats.Msg) {
+	progress, stop := s.watchBatch(msgs)
+	defer stop()
+
+	if s.opts.Ordered {
+		for n, msg := range msgs {
+			progress.start(msg)
+			if delay, ok := s.handleMsg(ctx, msg); !ok {
+				// later messages of the batch are redelivered after the failed
+				// message so that they are not processed before it
+				for _, i := range msgs[n+1:] {
+					progress.start(i)
+					s.nak(i, delay)
+				}
+				return
+			}
+		}
+		return
+	}
+
+	sem := make(chan struct{}, s.opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, msg := range msgs {
+		sem <- struct{}{}
+		progress.start(msg)
+		wg.Add(1)
+		go func(msg *nats.Msg) {
+			defer wg.Done()
+			defer func() {
+				<-sem
+			}()
+			s.handleMsg(ctx, msg)
+		}(msg)
+	}
+	wg.Wait()
+}
+
+// handleMsg executes the worker on a message, and returns false with the
+// redelivery delay if the message is to be retried
+func (s *streamSubscription) handleMsg(ctx context.Context, msg *nats.Msg) (time.Duration, bool) {
+	err := s.worker(ctx, &pinger{msg: msg}, msg.Data)
+	if err == nil {
+		if err := msg.Ack(); err != nil {
+			s.logger.Error("Failed to ack message", map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return 0, true
+	}
+	if errors.Is(err, ErrWorkerTerm{}) || errors.Is(err, ErrInvalidStreamMsg{}) {
+		s.logger.Error("Terminated message", map[string]string{
+			"error": err.Error(),
+		})
+		if err := msg.Term(); err != nil {
+			s.logger.Error("Failed to term message", map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return 0, true
+	}
+	delay, ok := workerRetryDelay(err)
+	if ok {
+		s.logger.Debug("Retrying message", map[string]string{
+			"error": err.Error(),
+			"delay": delay.String(),
+		})
+	} else {
+		s.logger.Error("Failed executing worker", map[string]string{
+			"error": err.Error(),
+		})
+		delay = s.backoff(msg)
+	}
+	s.nak(msg, delay)
+	return delay, false
+}
+
+// backoff returns the redelivery delay of a message, doubled for each
+// previous delivery
+func (s *streamSubscription) backoff(msg *nats.Msg) time.Duration {
+	if s.opts.RetryBase <= 0 {
+		return 0
+	}
+	delivered := uint64(1)
+	if meta, err := msg.Metadata(); err == nil && meta.NumDelivered > 0 {
+		delivered = meta.NumDelivered
+	}
+	delay := s.opts.RetryBase
+	for i := uint64(1); i < delivered && delay < s.opts.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > s.opts.RetryMax {
+		delay = s.opts.RetryMax
+	}
+	return delay
+}
+
+// nak naks a message to be redelivered after delay
+//
+// Delayed naks require nats server 2.7 or later. Older servers only accept a
+// plain nak, and would otherwise redeliver the message after the ack wait.
+func (s *streamSubscription) nak(msg *nats.Msg, delay time.Duration) {
+	var err error
+	if delay > 0 {
+		err = msg.NakWithDelay(delay)
+	} else {
+		err = msg.Nak()
+	}
+	if err != nil {
+		s.logger.Error("Failed to nak message", map[string]string{
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+func TestWorkerResults(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	{
+		err := governor.ErrWithMsg(WorkerRetryAfter(errors.New("test"), 5*time.Second), "wrapped")
+		assert.True(errors.Is(err, ErrWorkerRetry{}), "Should match any retry delay")
+		assert.False(errors.Is(err, ErrWorkerTerm{}))
+		delay, ok := workerRetryDelay(err)
+		assert.True(ok)
+		assert.Equal(5*time.Second, delay, "Should find the delay of a wrapped retry")
+	}
+	{
+		err := governor.ErrWithMsg(WorkerTerm(errors.New("test")), "wrapped")
+		assert.True(errors.Is(err, ErrWorkerTerm{}))
+		_, ok := workerRetryDelay(err)
+		assert.False(ok)
+	}
+	{
+		_, ok := workerRetryDelay(errors.New("test"))
+		assert.False(ok, "Should have no delay for other errors")
+	}
+}
+
+func TestWatchBatch(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s, js := newTestEvents(t)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "test",
+		Subjects: []string{"test.>"},
+	})
+	assert.NoError(err)
+	for _, i := range []string{"a", "b"} {
+		_, err := js.Publish("test.msg", []byte(i))
+		assert.NoError(err)
+	}
+	sub, err := js.PullSubscribe("test.msg", "worker", nats.BindStream("test"), nats.ManualAck(), nats.AckExplicit(), nats.AckWait(time.Second))
+	assert.NoError(err)
+	msgs, err := sub.Fetch(2, nats.MaxWait(time.Second))
+	assert.NoError(err)
+	assert.Len(msgs, 2)
+
+	ss := &streamSubscription{
+		s: s,
+		opts: StreamConsumerOpts{
+			AckWait: time.Second,
+		},
+		logger: s.logger,
+	}
+	progress, stop := ss.watchBatch(msgs)
+	time.Sleep(2 * time.Second)
+	_, err = sub.Fetch(1, nats.MaxWait(500*time.Millisecond))
+	assert.True(errors.Is(err, nats.ErrTimeout), "Should not redeliver pending messages")
+	for _, i := range msgs {
+		progress.start(i)
+		assert.NoError(i.Ack())
+	}
+	stop()
+}
+
+func TestStreamSubscribeOrdered(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s := New().(*service)
+	s.logger = testLogger{}
+	go func() {
+		<-s.subops
+	}()
+	sub, err := s.StreamSubscribe("test", "test.msg", "worker", nil, StreamConsumerOpts{
+		MaxPending: 8,
+		Ordered:    true,
+	})
+	assert.NoError(err)
+	assert.Equal(1, sub.(*streamSubscription).opts.MaxPending, "Should limit ordered consumers to one pending message")
+}
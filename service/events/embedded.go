@@ -0,0 +1,126 @@
+package events
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/util/bytefmt"
+)
+
+const (
+	embeddedReadyTimeout = 10 * time.Second
+)
+
+type (
+	// embeddedLogger writes the logs of an embedded nats server to a governor
+	// logger
+	embeddedLogger struct {
+		logger governor.Logger
+	}
+)
+
+func (l *embeddedLogger) Noticef(format string, v ...interface{}) {
+	l.logger.Info(fmt.Sprintf(format, v...), nil)
+}
+
+func (l *embeddedLogger) Warnf(format string, v ...interface{}) {
+	l.logger.Warn(fmt.Sprintf(format, v...), nil)
+}
+
+// Fatalf logs fatal embedded server errors as errors, since the embedded
+// server stops itself on fatal errors without exiting the process
+func (l *embeddedLogger) Fatalf(format string, v ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, v...), nil)
+}
+
+func (l *embeddedLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, v...), nil)
+}
+
+func (l *embeddedLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, v...), nil)
+}
+
+func (l *embeddedLogger) Tracef(format string, v ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, v...), nil)
+}
+
+// startEmbedded starts an embedded nats server with jetstream
+//
+// The server listens on a random local port, so that the service connects to
+// it in the same way as to an external nats server. It requires the token of
+// the auth secret at the time it is started. Streams are stored in the
+// embedded store dir, and persist across restarts.
+func (s *service) startEmbedded(r governor.ConfigReader, l governor.Logger) error {
+	authsecret, err := s.config.GetSecret("auth")
+	if err != nil {
+		return err
+	}
+	auth, ok := authsecret["password"].(string)
+	if !ok || auth == "" {
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid secret")
+	}
+
+	storedir, err := filepath.Abs(r.GetStr("embedded.storedir"))
+	if err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Invalid embedded store dir")
+	}
+	maxmemory, err := bytefmt.ToBytes(r.GetStr("embedded.maxmemory"))
+	if err != nil {
+		return governor.ErrWithMsg(err, "Invalid embedded max memory")
+	}
+	maxstore, err := bytefmt.ToBytes(r.GetStr("embedded.maxstore"))
+	if err != nil {
+		return governor.ErrWithMsg(err, "Invalid embedded max store")
+	}
+
+	ns, err := server.NewServer(&server.Options{
+		ServerName:         s.clientname,
+		Host:               "127.0.0.1",
+		Port:               server.RANDOM_PORT,
+		Authorization:      auth,
+		NoSigs:             true,
+		JetStream:          true,
+		JetStreamMaxMemory: maxmemory,
+		JetStreamMaxStore:  maxstore,
+		StoreDir:           storedir,
+	})
+	if err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Invalid embedded events config")
+	}
+	ns.SetLogger(&embeddedLogger{
+		logger: s.logger.Subtree("embedded"),
+	}, false, false)
+	go ns.Start()
+	if !ns.ReadyForConnections(embeddedReadyTimeout) {
+		ns.Shutdown()
+		return governor.ErrWithKind(nil, ErrConn{}, "Failed to start embedded events server")
+	}
+	s.addr = ns.Addr().String()
+	l.Info("Started embedded events server", map[string]string{
+		"addr":      s.addr,
+		"storedir":  storedir,
+		"maxmemory": r.GetStr("embedded.maxmemory"),
+		"maxstore":  r.GetStr("embedded.maxstore"),
+	})
+
+	s.embedded = ns
+	return nil
+}
+
+// closeEmbedded stops the embedded nats server
+func (s *service) closeEmbedded() {
+	if s.embedded == nil {
+		return
+	}
+	s.embedded.Shutdown()
+	s.embedded.WaitForShutdown()
+	s.embedded = nil
+	s.logger.Info("Stopped embedded events server", map[string]string{
+		"actiontype": "stopembeddedevents",
+		"address":    s.addr,
+	})
+}
@@ -0,0 +1,277 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/util/uid"
+)
+
+const (
+	eventIDSize = 16
+
+	// ContentTypeJSON is the content type of json encoded event data
+	ContentTypeJSON = "application/json"
+	// ContentTypeProtobuf is the content type of protobuf encoded event data
+	ContentTypeProtobuf = "application/protobuf"
+)
+
+type (
+	// Envelope is the standard envelope of a published event
+	//
+	// Time is a unix timestamp in seconds. Data is encoded by the codec of
+	// ContentType.
+	Envelope struct {
+		ID            string            `json:"id"`
+		Type          string            `json:"type"`
+		Version       int               `json:"version"`
+		Source        string            `json:"source"`
+		Time          int64             `json:"time"`
+		CorrelationID string            `json:"correlationid,omitempty"`
+		Headers       map[string]string `json:"headers,omitempty"`
+		ContentType   string            `json:"contenttype"`
+		Data          []byte            `json:"data"`
+	}
+
+	// Event is an event to be published
+	Event struct {
+		Type          string
+		Version       int
+		Source        string
+		CorrelationID string
+		Headers       map[string]string
+		Data          interface{}
+	}
+
+	// Codec encodes and decodes event data
+	Codec interface {
+		ContentType() string
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	jsonCodec struct{}
+
+	protoCodec struct{}
+
+	// UpcastFunc converts event data of a schema version to the data of the
+	// next version
+	UpcastFunc = func(codec Codec, data []byte) ([]byte, error)
+
+	// Schema is a versioned event type
+	//
+	// Upcasts maps a version to the function which converts its data to the
+	// following version. Consumers decode events of any older version which
+	// has a chain of upcasts to Version.
+	Schema struct {
+		Type    string
+		Version int
+		Upcasts map[int]UpcastFunc
+	}
+
+	// EventWorkerFunc is a type alias for an event stream subscriber handler
+	EventWorkerFunc = func(ctx context.Context, pinger Pinger, env *Envelope) error
+)
+
+var (
+	// JSONCodec encodes event data as json
+	JSONCodec Codec = jsonCodec{}
+	// ProtoCodec encodes event data, which must be a proto.Message, as
+	// protobuf
+	ProtoCodec Codec = protoCodec{}
+
+	codecs  = map[string]Codec{}
+	codecMu = &sync.RWMutex{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(ProtoCodec)
+}
+
+// RegisterCodec registers a codec used to decode events of its content type
+func RegisterCodec(c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[c.ContentType()] = c
+}
+
+func getCodec(contentType string) (Codec, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	c, ok := codecs[contentType]
+	if !ok {
+		return nil, governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, "Unknown event content type")
+	}
+	return c, nil
+}
+
+func (c jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (c jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to encode event data to json")
+	}
+	return b, nil
+}
+
+func (c jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to decode json event data")
+	}
+	return nil
+}
+
+func (c protoCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (c protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, "Event data is not a proto message")
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to encode event data to protobuf")
+	}
+	return b, nil
+}
+
+func (c protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, "Event data is not a proto message")
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to decode protobuf event data")
+	}
+	return nil
+}
+
+// NewEnvelope creates an envelope for an event with a new id and the current
+// time
+func NewEnvelope(codec Codec, e Event) (*Envelope, error) {
+	u, err := uid.New(eventIDSize)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to create new event id")
+	}
+	data, err := codec.Marshal(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		ID:            u.Base64(),
+		Type:          e.Type,
+		Version:       e.Version,
+		Source:        e.Source,
+		Time:          time.Now().Round(0).Unix(),
+		CorrelationID: e.CorrelationID,
+		Headers:       e.Headers,
+		ContentType:   codec.ContentType(),
+		Data:          data,
+	}, nil
+}
+
+// EncodeEvent encodes an event in a new envelope
+func EncodeEvent(codec Codec, e Event) ([]byte, error) {
+	env, err := NewEnvelope(codec, e)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to encode event envelope")
+	}
+	return b, nil
+}
+
+// StreamPublishEvent publishes an event in a new envelope to a stream
+//
+// Producers publish events with StreamPublishEvent, or with the outbox when
+// the event must be published only if a db transaction commits.
+func StreamPublishEvent(ctx context.Context, ev Events, channel string, codec Codec, e Event) error {
+	b, err := EncodeEvent(codec, e)
+	if err != nil {
+		return err
+	}
+	if err := ev.StreamPublish(ctx, channel, b); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeEnvelope decodes an event envelope
+//
+// Messages published without an envelope are returned in an envelope with no
+// id or type at version 1 with json data, so that consumers may handle
+// messages published before envelopes were introduced.
+func DecodeEnvelope(msgdata []byte) (*Envelope, error) {
+	env := &Envelope{}
+	if err := json.Unmarshal(msgdata, env); err != nil || env.ID == "" {
+		return &Envelope{
+			Version:     1,
+			ContentType: ContentTypeJSON,
+			Data:        msgdata,
+		}, nil
+	}
+	return env, nil
+}
+
+// Decode upcasts the data of an envelope to the schema version and decodes it
+// into v
+func (s Schema) Decode(env *Envelope, v interface{}) error {
+	if env.Type != "" && env.Type != s.Type {
+		return governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, "Unexpected event type")
+	}
+	if env.Version > s.Version {
+		return governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, "Unsupported future event version")
+	}
+	codec, err := getCodec(env.ContentType)
+	if err != nil {
+		return err
+	}
+	data := env.Data
+	for i := env.Version; i < s.Version; i++ {
+		upcast, ok := s.Upcasts[i]
+		if !ok {
+			return governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, "No upcast for event version")
+		}
+		data, err = upcast(codec, data)
+		if err != nil {
+			return governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to upcast event")
+		}
+	}
+	return codec.Unmarshal(data, v)
+}
+
+// DecodeEvent decodes an event envelope and its data into v
+func DecodeEvent(msgdata []byte, s Schema, v interface{}) (*Envelope, error) {
+	env, err := DecodeEnvelope(msgdata)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Decode(env, v); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// EventWorker returns a stream subscriber handler which decodes event
+// envelopes for worker
+func EventWorker(worker EventWorkerFunc) StreamWorkerFunc {
+	return func(ctx context.Context, pinger Pinger, msgdata []byte) error {
+		env, err := DecodeEnvelope(msgdata)
+		if err != nil {
+			return err
+		}
+		return worker(ctx, pinger, env)
+	}
+}
@@ -0,0 +1,211 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	type (
+		propsV1 struct {
+			Name string `json:"name"`
+		}
+		propsV2 struct {
+			FirstName string `json:"first_name"`
+		}
+	)
+
+	schema := Schema{
+		Type:    "test.create",
+		Version: 2,
+		Upcasts: map[int]UpcastFunc{
+			1: func(codec Codec, data []byte) ([]byte, error) {
+				v := propsV1{}
+				if err := codec.Unmarshal(data, &v); err != nil {
+					return nil, err
+				}
+				return codec.Marshal(propsV2{
+					FirstName: v.Name,
+				})
+			},
+		},
+	}
+
+	{
+		b, err := EncodeEvent(JSONCodec, Event{
+			Type:          schema.Type,
+			Version:       1,
+			Source:        "test",
+			CorrelationID: "corr",
+			Data: propsV1{
+				Name: "first",
+			},
+		})
+		assert.NoError(err)
+		v := propsV2{}
+		env, err := DecodeEvent(b, schema, &v)
+		assert.NoError(err)
+		assert.Equal("first", v.FirstName, "Should upcast older versions")
+		assert.NotEqual("", env.ID, "Should assign an event id")
+		assert.Equal("test", env.Source)
+		assert.Equal("corr", env.CorrelationID)
+		assert.Equal(ContentTypeJSON, env.ContentType)
+	}
+	{
+		b, err := json.Marshal(propsV1{
+			Name: "legacy",
+		})
+		assert.NoError(err)
+		v := propsV2{}
+		env, err := DecodeEvent(b, schema, &v)
+		assert.NoError(err)
+		assert.Equal("legacy", v.FirstName, "Should decode messages without an envelope as version 1")
+		assert.Equal("", env.ID)
+	}
+	{
+		b, err := EncodeEvent(JSONCodec, Event{
+			Type:    "test.other",
+			Version: 2,
+			Data:    propsV2{},
+		})
+		assert.NoError(err)
+		_, err = DecodeEvent(b, schema, &propsV2{})
+		assert.Error(err, "Should reject other event types")
+		b, err = EncodeEvent(JSONCodec, Event{
+			Type:    schema.Type,
+			Version: 3,
+			Data:    propsV2{},
+		})
+		assert.NoError(err)
+		_, err = DecodeEvent(b, schema, &propsV2{})
+		assert.Error(err, "Should reject future versions")
+	}
+}
+
+func TestSchemaDecode(t *testing.T) {
+	t.Parallel()
+
+	type (
+		propsV1 struct {
+			Name string `json:"name"`
+		}
+		propsV2 struct {
+			FirstName string `json:"first_name"`
+		}
+		propsV3 struct {
+			FirstName string `json:"first_name"`
+			LastName  string `json:"last_name"`
+		}
+	)
+
+	upcastV1 := func(codec Codec, data []byte) ([]byte, error) {
+		v := propsV1{}
+		if err := codec.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return codec.Marshal(propsV2{
+			FirstName: v.Name,
+		})
+	}
+	upcastV2 := func(codec Codec, data []byte) ([]byte, error) {
+		v := propsV2{}
+		if err := codec.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return codec.Marshal(propsV3{
+			FirstName: v.FirstName,
+			LastName:  "unknown",
+		})
+	}
+
+	for _, tc := range []struct {
+		Test    string
+		Schema  Schema
+		Version int
+		Data    interface{}
+		Exp     propsV3
+		Err     bool
+	}{
+		{
+			Test: "upcasts through each version",
+			Schema: Schema{
+				Type:    "test.create",
+				Version: 3,
+				Upcasts: map[int]UpcastFunc{
+					1: upcastV1,
+					2: upcastV2,
+				},
+			},
+			Version: 1,
+			Data: propsV1{
+				Name: "first",
+			},
+			Exp: propsV3{
+				FirstName: "first",
+				LastName:  "unknown",
+			},
+		},
+		{
+			Test: "decodes the current version without upcasting",
+			Schema: Schema{
+				Type:    "test.create",
+				Version: 3,
+			},
+			Version: 3,
+			Data: propsV3{
+				FirstName: "first",
+				LastName:  "last",
+			},
+			Exp: propsV3{
+				FirstName: "first",
+				LastName:  "last",
+			},
+		},
+		{
+			Test: "rejects versions missing an upcast",
+			Schema: Schema{
+				Type:    "test.create",
+				Version: 3,
+				Upcasts: map[int]UpcastFunc{
+					2: upcastV2,
+				},
+			},
+			Version: 1,
+			Data: propsV1{
+				Name: "first",
+			},
+			Err: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			env, err := NewEnvelope(JSONCodec, Event{
+				Type:    tc.Schema.Type,
+				Version: tc.Version,
+				Source:  "test",
+				Data:    tc.Data,
+			})
+			assert.NoError(err)
+			v := propsV3{}
+			err = tc.Schema.Decode(env, &v)
+			if tc.Err {
+				assert.Error(err)
+				assert.True(errors.Is(err, ErrInvalidStreamMsg{}))
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.Exp, v)
+		})
+	}
+}
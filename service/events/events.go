@@ -8,44 +8,80 @@ import (
 	"time"
 
 	jsmapi "github.com/nats-io/jsm.go/api"
-	jsadvisory "github.com/nats-io/jsm.go/api/jetstream/advisory"
+	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
 	"xorkevin.dev/governor"
 )
 
+const (
+	backendNATS     = "nats"
+	backendEmbedded = "embedded"
+)
+
 type (
 	// WorkerFunc is a type alias for a subscriber handler
 	WorkerFunc = func(msgdata []byte)
 
 	// StreamWorkerFunc is a type alias for a stream subscriber handler
-	StreamWorkerFunc = func(pinger Pinger, msgdata []byte) error
+	StreamWorkerFunc = func(ctx context.Context, pinger Pinger, msgdata []byte) error
 
 	// StreamOpts are opts for streams
+	//
+	// Duplicates is the window in which messages published with the same
+	// message id are deduplicated.
 	StreamOpts struct {
 		Replicas   int
 		MaxAge     time.Duration
 		MaxBytes   int64
 		MaxMsgSize int32
 		MaxMsgs    int64
+		Duplicates time.Duration
+	}
+
+	// StreamPublishOpt is a stream publish option
+	StreamPublishOpt = func(o *streamPublishOpts)
+
+	streamPublishOpts struct {
+		msgid string
 	}
 
 	// StreamConsumerOpts are opts for stream consumers
+	//
+	// BatchSize is the max number of messages fetched at once, of which up to
+	// Concurrency are processed at once. Messages of a batch waiting to be
+	// processed are marked in progress so that they are not redelivered. If
+	// Ordered is set, messages of a batch are instead processed one at a time
+	// in order, and the remainder of a batch is redelivered after the first
+	// message to be retried. Ordered consumers have a MaxPending of 1, so that
+	// subscribers of the same group do not process messages concurrently.
+	// Failed messages are retried with an exponential backoff from RetryBase
+	// up to RetryMax.
 	StreamConsumerOpts struct {
 		AckWait     time.Duration
 		MaxDeliver  int
 		MaxPending  int
 		MaxRequests int
+		BatchSize   int
+		Concurrency int
+		Ordered     bool
+		RetryBase   time.Duration
+		RetryMax    time.Duration
 	}
 
 	// Events is a service wrapper around an event stream client
 	Events interface {
-		Publish(channel string, msgdata []byte) error
+		Publish(ctx context.Context, channel string, msgdata []byte) error
 		Subscribe(channel, group string, worker WorkerFunc) (Subscription, error)
-		StreamPublish(channel string, msgdata []byte) error
+		Request(ctx context.Context, channel string, msgdata []byte, timeout time.Duration) ([]byte, error)
+		Serve(channel, group string, handler ServeFunc) (Subscription, error)
+		StreamPublish(ctx context.Context, channel string, msgdata []byte, opts ...StreamPublishOpt) error
+		StreamPublishAt(ctx context.Context, channel string, msgdata []byte, t time.Time, opts ...StreamPublishOpt) error
+		StreamPublishAfter(ctx context.Context, channel string, msgdata []byte, delay time.Duration, opts ...StreamPublishOpt) error
+		CancelScheduled(ctx context.Context, msgid string) error
 		StreamSubscribe(stream, channel, group string, worker StreamWorkerFunc, opts StreamConsumerOpts) (Subscription, error)
-		InitStream(name string, subjects []string, opts StreamOpts) error
-		DeleteStream(name string) error
-		DeleteConsumer(stream, consumer string) error
+		InitStream(ctx context.Context, name string, subjects []string, opts StreamOpts) error
+		DeleteStream(ctx context.Context, name string) error
+		DeleteConsumer(ctx context.Context, stream, consumer string) error
 		DLQSubscribe(targetStream, targetConsumer string, stream, group string, worker StreamWorkerFunc, opts StreamConsumerOpts) (Subscription, error)
 	}
 
@@ -53,6 +89,7 @@ type (
 	Service interface {
 		governor.Service
 		Events
+		Admin
 	}
 
 	getClientRes struct {
@@ -72,23 +109,28 @@ type (
 	}
 
 	service struct {
-		client          *nats.Conn
-		stream          nats.JetStreamContext
-		clientname      string
-		auth            string
-		addr            string
-		config          governor.SecretReader
-		logger          governor.Logger
-		ops             chan getOp
-		subops          chan subOp
-		subs            map[*subscription]struct{}
-		streamSubs      map[*streamSubscription]struct{}
-		ready           bool
-		canary          <-chan struct{}
-		hbinterval      int
-		hbmaxfail       int
-		minpullduration time.Duration
-		done            <-chan struct{}
+		client           *nats.Conn
+		stream           nats.JetStreamContext
+		clientname       string
+		auth             string
+		backend          string
+		embedded         *server.Server
+		addr             string
+		config           governor.SecretReader
+		logger           governor.Logger
+		ops              chan getOp
+		subops           chan subOp
+		subs             map[*subscription]struct{}
+		streamSubs       map[*streamSubscription]struct{}
+		ready            bool
+		canary           <-chan struct{}
+		hbinterval       int
+		hbmaxfail        int
+		minpullduration  time.Duration
+		serveConcurrency int
+		schedMaxAge      time.Duration
+		schedMaxPending  int
+		done             <-chan struct{}
 	}
 
 	// Subscription manages an active subscription
@@ -101,6 +143,8 @@ type (
 		channel string
 		group   string
 		worker  WorkerFunc
+		server  ServeFunc
+		sem     chan struct{}
 		logger  governor.Logger
 		sub     *nats.Subscription
 	}
@@ -127,6 +171,8 @@ type (
 	}
 
 	ctxKeyEvents struct{}
+
+	ctxKeyAdmin struct{}
 )
 
 // GetCtxEvents returns an Events from the context
@@ -159,13 +205,21 @@ func New() Service {
 
 func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
 	setCtxEvents(inj, s)
+	setCtxAdmin(inj, s)
 
+	r.SetDefault("backend", backendNATS)
 	r.SetDefault("auth", "")
 	r.SetDefault("host", "localhost")
 	r.SetDefault("port", "4222")
+	r.SetDefault("embedded.storedir", "events")
+	r.SetDefault("embedded.maxmemory", "64M")
+	r.SetDefault("embedded.maxstore", "1G")
 	r.SetDefault("hbinterval", 5)
 	r.SetDefault("hbmaxfail", 3)
 	r.SetDefault("minpullduration", "100ms")
+	r.SetDefault("serveconcurrency", 16)
+	r.SetDefault("schedmaxage", "720h")
+	r.SetDefault("schedmaxpending", 65536)
 }
 
 type (
@@ -206,19 +260,46 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	if err != nil {
 		return governor.ErrWithMsg(err, "Failed to parse min pull duration")
 	}
+	s.serveConcurrency = r.GetInt("serveconcurrency")
+	if s.serveConcurrency < 1 {
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid serve concurrency")
+	}
+	s.schedMaxAge, err = time.ParseDuration(r.GetStr("schedmaxage"))
+	if err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse schedule max age")
+	}
+	s.schedMaxPending = r.GetInt("schedmaxpending")
+	if s.schedMaxPending < 1 {
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid schedule max pending")
+	}
+
+	s.backend = r.GetStr("backend")
+	switch s.backend {
+	case backendNATS:
+	case backendEmbedded:
+		if err := s.startEmbedded(r, l); err != nil {
+			return err
+		}
+	default:
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid events backend")
+	}
 
 	l.Info("loaded config", map[string]string{
-		"addr":            s.addr,
-		"hbinterval":      strconv.Itoa(s.hbinterval),
-		"hbmaxfail":       strconv.Itoa(s.hbmaxfail),
-		"minpullduration": r.GetStr("minpullduration"),
+		"backend":          s.backend,
+		"addr":             s.addr,
+		"hbinterval":       strconv.Itoa(s.hbinterval),
+		"hbmaxfail":        strconv.Itoa(s.hbmaxfail),
+		"minpullduration":  r.GetStr("minpullduration"),
+		"serveconcurrency": strconv.Itoa(s.serveConcurrency),
+		"schedmaxage":      s.schedMaxAge.String(),
+		"schedmaxpending":  strconv.Itoa(s.schedMaxPending),
 	})
 
 	done := make(chan struct{})
 	go s.execute(ctx, done)
 	s.done = done
 
-	if _, _, err := s.getClient(); err != nil {
+	if _, _, err := s.getClient(ctx); err != nil {
 		return err
 	}
 	return nil
@@ -232,6 +313,7 @@ func (s *service) execute(ctx context.Context, done chan<- struct{}) {
 		select {
 		case <-ctx.Done():
 			s.closeClient()
+			s.closeEmbedded()
 			return
 		case <-ticker.C:
 			s.handlePing()
@@ -407,7 +489,7 @@ func (s *service) closeClient() {
 	s.deinitSubs()
 }
 
-func (s *service) getClient() (*nats.Conn, nats.JetStreamContext, error) {
+func (s *service) getClient(ctx context.Context) (*nats.Conn, nats.JetStreamContext, error) {
 	res := make(chan getClientRes)
 	op := getOp{
 		res: res,
@@ -415,21 +497,56 @@ func (s *service) getClient() (*nats.Conn, nats.JetStreamContext, error) {
 	select {
 	case <-s.done:
 		return nil, nil, governor.ErrWithKind(nil, ErrConn{}, "Events service shutdown")
+	case <-ctx.Done():
+		return nil, nil, governor.ErrWithKind(ctx.Err(), ErrConn{}, "Context cancelled")
 	case s.ops <- op:
 		v := <-res
 		return v.client, v.stream, v.err
 	}
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	l := s.logger.WithData(map[string]string{
+		"phase": "setup",
+	})
+
+	// scheduled messages are kept for a day past the max schedule age so that
+	// they are not removed before they are published
+	if err := s.InitStream(ctx, schedStream, []string{schedStreamChannels}, StreamOpts{
+		Replicas:   1,
+		MaxAge:     s.schedMaxAge + 24*time.Hour,
+		Duplicates: 2 * time.Minute,
+	}); err != nil {
+		return governor.ErrWithMsg(err, "Failed to init scheduler stream")
+	}
+	l.Info("Created scheduler stream", nil)
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
 func (s *service) Start(ctx context.Context) error {
+	l := s.logger.WithData(map[string]string{
+		"phase": "start",
+	})
+
+	// messages which are not yet due are pending redelivery, so max pending
+	// bounds the number of messages which may be scheduled at once, as
+	// enforced by StreamPublishAt
+	if _, err := s.StreamSubscribe(schedStream, schedMsgChannel+".*", schedWorker, s.schedSubscriber, StreamConsumerOpts{
+		AckWait:     15 * time.Second,
+		MaxPending:  s.schedMaxPending,
+		MaxRequests: 32,
+		BatchSize:   32,
+		Concurrency: 8,
+		RetryBase:   time.Second,
+		RetryMax:    time.Minute,
+	}); err != nil {
+		return governor.ErrWithMsg(err, "Failed to subscribe to scheduler stream")
+	}
+	l.Info("Subscribed to scheduler stream", nil)
 	return nil
 }
 
@@ -477,8 +594,8 @@ func (s *service) rmSub(sub *subscription, stream *streamSubscription) {
 }
 
 // Publish publishes to a channel
-func (s *service) Publish(channel string, msgdata []byte) error {
-	client, _, err := s.getClient()
+func (s *service) Publish(ctx context.Context, channel string, msgdata []byte) error {
+	client, _, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -532,6 +649,10 @@ func (s *subscription) ok() bool {
 }
 
 func (s *subscription) subscriber(msg *nats.Msg) {
+	if s.server != nil {
+		s.dispatch(msg)
+		return
+	}
 	s.worker(msg.Data)
 }
 
@@ -550,13 +671,30 @@ func (s *subscription) Close() error {
 	return nil
 }
 
+// StreamPublishOptMsgID sets the message id used by the stream to
+// deduplicate messages
+func StreamPublishOptMsgID(msgid string) StreamPublishOpt {
+	return func(o *streamPublishOpts) {
+		o.msgid = msgid
+	}
+}
+
 // StreamPublish publishes to a stream
-func (s *service) StreamPublish(channel string, msgdata []byte) error {
-	_, client, err := s.getClient()
+func (s *service) StreamPublish(ctx context.Context, channel string, msgdata []byte, opts ...StreamPublishOpt) error {
+	_, client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := client.Publish(channel, msgdata); err != nil {
+	o := streamPublishOpts{}
+	for _, i := range opts {
+		i(&o)
+	}
+	args := make([]nats.PubOpt, 0, 2)
+	args = append(args, nats.Context(ctx))
+	if o.msgid != "" {
+		args = append(args, nats.MsgId(o.msgid))
+	}
+	if _, err := client.Publish(channel, msgdata, args...); err != nil {
 		return governor.ErrWithKind(err, ErrClient{}, "Failed to publish message to stream")
 	}
 	return nil
@@ -570,6 +708,18 @@ func (s *service) StreamSubscribe(stream, channel, group string, worker StreamWo
 		"channel": channel,
 		"group":   group,
 	})
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.RetryMax <= 0 {
+		opts.RetryMax = defaultRetryMax
+	}
+	if opts.Ordered {
+		opts.MaxPending = 1
+	}
 	done := make(chan struct{})
 	close(done)
 	sub := &streamSubscription{
@@ -642,26 +792,14 @@ func (s *streamSubscription) subscriber(ctx context.Context, sub *nats.Subscript
 			return
 		default:
 		}
-		msgs, err := sub.Fetch(1, nats.Context(ctx))
+		msgs, err := sub.Fetch(s.opts.BatchSize, nats.Context(ctx))
 		if err != nil {
 			s.logger.Error("Failed obtaining messages", map[string]string{
 				"error": err.Error(),
 			})
 			return
 		}
-		for _, msg := range msgs {
-			if err := s.worker(&pinger{msg: msg}, msg.Data); err != nil {
-				s.logger.Error("Failed executing worker", map[string]string{
-					"error": err.Error(),
-				})
-			} else {
-				if err := msg.Ack(); err != nil {
-					s.logger.Error("Failed to ack message", map[string]string{
-						"error": err.Error(),
-					})
-				}
-			}
-		}
+		s.handleBatch(ctx, msgs)
 		now := time.Now()
 		delta := s.s.minpullduration - now.Sub(start)
 		start = now
@@ -689,8 +827,8 @@ func (p *pinger) Ping() error {
 }
 
 // InitStream initializes a stream
-func (s *service) InitStream(name string, subjects []string, opts StreamOpts) error {
-	_, client, err := s.getClient()
+func (s *service) InitStream(ctx context.Context, name string, subjects []string, opts StreamOpts) error {
+	_, client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -705,16 +843,17 @@ func (s *service) InitStream(name string, subjects []string, opts StreamOpts) er
 		MaxBytes:   opts.MaxBytes,
 		MaxMsgSize: opts.MaxMsgSize,
 		MaxMsgs:    opts.MaxMsgs,
+		Duplicates: opts.Duplicates,
 	}
-	if _, err := client.StreamInfo(name); err != nil {
+	if _, err := client.StreamInfo(name, nats.Context(ctx)); err != nil {
 		if !strings.Contains(err.Error(), "not found") {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to get stream")
 		}
-		if _, err := client.AddStream(cfg); err != nil {
+		if _, err := client.AddStream(cfg, nats.Context(ctx)); err != nil {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to create stream")
 		}
 	} else {
-		if _, err := client.UpdateStream(cfg); err != nil {
+		if _, err := client.UpdateStream(cfg, nats.Context(ctx)); err != nil {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to update stream")
 		}
 	}
@@ -722,17 +861,17 @@ func (s *service) InitStream(name string, subjects []string, opts StreamOpts) er
 }
 
 // DeleteStream deletes a stream
-func (s *service) DeleteStream(name string) error {
-	_, client, err := s.getClient()
+func (s *service) DeleteStream(ctx context.Context, name string) error {
+	_, client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := client.StreamInfo(name); err != nil {
+	if _, err := client.StreamInfo(name, nats.Context(ctx)); err != nil {
 		if !strings.Contains(err.Error(), "not found") {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to get stream")
 		}
 	} else {
-		if err := client.DeleteStream(name); err != nil {
+		if err := client.DeleteStream(name, nats.Context(ctx)); err != nil {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to delete stream")
 		}
 	}
@@ -740,17 +879,17 @@ func (s *service) DeleteStream(name string) error {
 }
 
 // DeleteConsumer deletes a consumer
-func (s *service) DeleteConsumer(stream, consumer string) error {
-	_, client, err := s.getClient()
+func (s *service) DeleteConsumer(ctx context.Context, stream, consumer string) error {
+	_, client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := client.ConsumerInfo(stream, consumer); err != nil {
+	if _, err := client.ConsumerInfo(stream, consumer, nats.Context(ctx)); err != nil {
 		if !strings.Contains(err.Error(), "not found") {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to get consumer")
 		}
 	} else {
-		if err := client.DeleteConsumer(stream, consumer); err != nil {
+		if err := client.DeleteConsumer(stream, consumer, nats.Context(ctx)); err != nil {
 			return governor.ErrWithKind(err, ErrClient{}, "Failed to delete consumer")
 		}
 	}
@@ -764,26 +903,19 @@ func channelMaxDelivery(stream, consumer string) string {
 
 // DLQSubscribe subscribes to the deadletter queue of another stream consumer
 func (s *service) DLQSubscribe(targetStream, targetConsumer string, stream, group string, worker StreamWorkerFunc, opts StreamConsumerOpts) (Subscription, error) {
-	return s.StreamSubscribe(stream, channelMaxDelivery(targetStream, targetConsumer), group, func(pinger Pinger, msgdata []byte) error {
-		schemaType, advmsg, err := jsmapi.ParseMessage(msgdata)
+	return s.StreamSubscribe(stream, channelMaxDelivery(targetStream, targetConsumer), group, func(ctx context.Context, pinger Pinger, msgdata []byte) error {
+		jse, err := parseDLQMsg(msgdata, targetStream, targetConsumer)
 		if err != nil {
-			return governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to parse dead letter queue message with unknown type")
-		}
-		jse, ok := advmsg.(*jsadvisory.ConsumerDeliveryExceededAdvisoryV1)
-		if !ok {
-			return governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, fmt.Sprintf("Failed to parse dead letter queue message with type: %s", schemaType))
-		}
-		if jse.Stream != targetStream || jse.Consumer != targetConsumer {
-			return governor.ErrWithKind(nil, ErrInvalidStreamMsg{}, fmt.Sprintf("Invalid target stream and consumer: %s, %s", jse.Stream, jse.Consumer))
+			return err
 		}
-		_, client, err := s.getClient()
+		_, client, err := s.getClient(ctx)
 		if err != nil {
 			return err
 		}
-		msg, err := client.GetMsg(targetStream, jse.StreamSeq)
+		msg, err := client.GetMsg(targetStream, jse.StreamSeq, nats.Context(ctx))
 		if err != nil {
 			return governor.ErrWithKind(err, ErrClient{}, fmt.Sprintf("Failed to get msg from stream: %d", jse.StreamSeq))
 		}
-		return worker(pinger, msg.Data)
+		return worker(ctx, pinger, msg.Data)
 	}, opts)
 }
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"xorkevin.dev/governor"
+)
+
+type (
+	testLogger struct{}
+)
+
+func (l testLogger) Debug(msg string, data map[string]string) {}
+
+func (l testLogger) Info(msg string, data map[string]string) {}
+
+func (l testLogger) Warn(msg string, data map[string]string) {}
+
+func (l testLogger) Error(msg string, data map[string]string) {}
+
+func (l testLogger) Fatal(msg string, data map[string]string) {}
+
+func (l testLogger) Subtree(module string) governor.Logger {
+	return l
+}
+
+func (l testLogger) WithData(data map[string]string) governor.Logger {
+	return l
+}
+
+// newTestEvents returns an events service connected to an embedded server
+func newTestEvents(t *testing.T) (*service, nats.JetStreamContext) {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(embeddedReadyTimeout) {
+		ns.Shutdown()
+		t.Fatal("Failed to start embedded events server")
+	}
+	client, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		ns.Shutdown()
+		t.Fatal(err)
+	}
+	stream, err := client.JetStream()
+	if err != nil {
+		client.Close()
+		ns.Shutdown()
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s := New().(*service)
+	s.logger = testLogger{}
+	s.done = done
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case op := <-s.ops:
+				op.res <- getClientRes{
+					client: client,
+					stream: stream,
+				}
+				close(op.res)
+			case <-s.subops:
+				// subscriptions are initialized by the tests
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		client.Close()
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	})
+	return s, stream
+}
@@ -0,0 +1,169 @@
+package eventsadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/events"
+)
+
+const (
+	cmdBasePath = "/admin/events"
+)
+
+// AddCmd adds the events admin client subcommands to the governor cmd
+//
+// The subcommands call the routes of an events admin service mounted at
+// /admin/events.
+func AddCmd(cmd *governor.Cmd) {
+	eventsCmd := &cobra.Command{
+		Use:   "events",
+		Short: "manages event streams",
+		Long: `Manages event streams
+
+Lists streams and consumers, and replays and redrives stream messages.`,
+	}
+
+	streamsCmd := &cobra.Command{
+		Use:   "streams",
+		Short: "lists streams",
+		Long:  `Lists streams and their state`,
+		Args:  cobra.NoArgs,
+		Run: cmd.ClientRun(func(c *governor.Client, args []string) error {
+			res := &resStreams{}
+			if _, err := c.Request(http.MethodGet, cmdBasePath+"/stream", nil, res); err != nil {
+				return err
+			}
+			return printJSON(res)
+		}),
+	}
+
+	consumersCmd := &cobra.Command{
+		Use:   "consumers stream",
+		Short: "lists consumers of a stream",
+		Long:  `Lists consumers of a stream and their pending and redelivered counts`,
+		Args:  cobra.ExactArgs(1),
+		Run: cmd.ClientRun(func(c *governor.Client, args []string) error {
+			res := &resConsumers{}
+			if _, err := c.Request(http.MethodGet, streamPath(args[0])+"/consumer", nil, res); err != nil {
+				return err
+			}
+			return printJSON(res)
+		}),
+	}
+
+	msgCmd := &cobra.Command{
+		Use:   "msg stream seq",
+		Short: "gets a stream message",
+		Long:  `Gets a stream message by sequence`,
+		Args:  cobra.ExactArgs(2),
+		Run: cmd.ClientRun(func(c *governor.Client, args []string) error {
+			seq, err := strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return governor.ErrWithMsg(err, "Invalid sequence")
+			}
+			res := &events.StreamMsg{}
+			if _, err := c.Request(http.MethodGet, fmt.Sprintf("%s/msg?seq=%d", streamPath(args[0]), seq), nil, res); err != nil {
+				return err
+			}
+			return printJSON(res)
+		}),
+	}
+
+	purgeCmd := &cobra.Command{
+		Use:   "purge stream",
+		Short: "purges a stream",
+		Long:  `Removes all messages of a stream`,
+		Args:  cobra.ExactArgs(1),
+		Run: cmd.ClientRun(func(c *governor.Client, args []string) error {
+			if _, err := c.Request(http.MethodPost, streamPath(args[0])+"/purge", nil, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Purged stream %s\n", args[0])
+			return nil
+		}),
+	}
+
+	var replaySeq uint64
+	var replayTime string
+	replayCmd := &cobra.Command{
+		Use:   "replay stream consumer",
+		Short: "replays messages to a consumer",
+		Long: `Replays messages to a consumer
+
+Redelivers stream messages to a consumer starting from a sequence or time.`,
+		Args: cobra.ExactArgs(2),
+		Run: cmd.ClientRun(func(c *governor.Client, args []string) error {
+			req := reqReplay{
+				Seq: int(replaySeq),
+			}
+			if replayTime != "" {
+				t, err := time.Parse(time.RFC3339, replayTime)
+				if err != nil {
+					return governor.ErrWithMsg(err, "Invalid time")
+				}
+				req.Time = t.Unix()
+			}
+			if _, err := c.Request(http.MethodPost, consumerPath(args[0], args[1])+"/replay", req, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Replayed consumer %s of stream %s\n", args[1], args[0])
+			return nil
+		}),
+	}
+	replayCmd.PersistentFlags().Uint64Var(&replaySeq, "seq", 0, "start sequence")
+	replayCmd.PersistentFlags().StringVar(&replayTime, "time", "", "start time in RFC3339 format")
+
+	var redriveDLQStream string
+	var redriveAmount int
+	redriveCmd := &cobra.Command{
+		Use:   "redrive stream consumer",
+		Short: "redrives dead letter queue messages",
+		Long: `Redrives dead letter queue messages
+
+Republishes messages of the dead letter queue of a consumer to their original
+subjects.`,
+		Args: cobra.ExactArgs(2),
+		Run: cmd.ClientRun(func(c *governor.Client, args []string) error {
+			req := reqRedrive{
+				DLQStream: redriveDLQStream,
+				Amount:    redriveAmount,
+			}
+			res := &resRedrive{}
+			if _, err := c.Request(http.MethodPost, consumerPath(args[0], args[1])+"/dlq/redrive", req, res); err != nil {
+				return err
+			}
+			fmt.Printf("Redrove %d messages\n", res.Count)
+			return nil
+		}),
+	}
+	redriveCmd.PersistentFlags().StringVar(&redriveDLQStream, "dlq", "", "dead letter queue stream")
+	redriveCmd.PersistentFlags().IntVar(&redriveAmount, "amount", 256, "max number of messages")
+
+	eventsCmd.AddCommand(streamsCmd, consumersCmd, msgCmd, purgeCmd, replayCmd, redriveCmd)
+	cmd.AddCmd(eventsCmd)
+}
+
+func streamPath(stream string) string {
+	return cmdBasePath + "/stream/" + url.PathEscape(stream)
+}
+
+func consumerPath(stream, consumer string) string {
+	return streamPath(stream) + "/consumer/" + url.PathEscape(consumer)
+}
+
+func printJSON(v interface{}) error {
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return governor.ErrWithMsg(err, "Failed to encode response")
+	}
+	return nil
+}
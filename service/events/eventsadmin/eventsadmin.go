@@ -0,0 +1,206 @@
+package eventsadmin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+type (
+	// Service is an events admin governor.Service
+	Service interface {
+		governor.Service
+	}
+
+	service struct {
+		admin  events.Admin
+		gate   gate.Gate
+		logger governor.Logger
+	}
+
+	router struct {
+		s service
+	}
+)
+
+// NewCtx creates a new events admin service from a context
+func NewCtx(inj governor.Injector) Service {
+	admin := events.GetCtxAdmin(inj)
+	g := gate.GetCtxGate(inj)
+	return New(admin, g)
+}
+
+// New returns a new events admin service
+func New(admin events.Admin, g gate.Gate) Service {
+	return &service{
+		admin: admin,
+		gate:  g,
+	}
+}
+
+func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
+}
+
+func (s *service) router() *router {
+	return &router{
+		s: *s,
+	}
+}
+
+func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
+	s.logger = l
+	l = s.logger.WithData(map[string]string{
+		"phase": "init",
+	})
+
+	sr := s.router()
+	sr.mountRoutes(m)
+	l.Info("mounted http routes", nil)
+
+	return nil
+}
+
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) Start(ctx context.Context) error {
+	return nil
+}
+
+func (s *service) Stop(ctx context.Context) {
+}
+
+func (s *service) Health() error {
+	return nil
+}
+
+type (
+	resStreams struct {
+		Streams []events.StreamInfo `json:"streams"`
+	}
+)
+
+func (s *service) GetStreams(ctx context.Context) (*resStreams, error) {
+	m, err := s.admin.ListStreams(ctx)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get streams")
+	}
+	return &resStreams{
+		Streams: m,
+	}, nil
+}
+
+type (
+	resConsumers struct {
+		Consumers []events.ConsumerInfo `json:"consumers"`
+	}
+)
+
+func (s *service) GetConsumers(ctx context.Context, stream string) (*resConsumers, error) {
+	m, err := s.admin.ListConsumers(ctx, stream)
+	if err != nil {
+		if errors.Is(err, events.ErrNotFound{}) {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Stream not found",
+			}), governor.ErrOptInner(err))
+		}
+		return nil, governor.ErrWithMsg(err, "Failed to get consumers")
+	}
+	return &resConsumers{
+		Consumers: m,
+	}, nil
+}
+
+func (s *service) GetMsg(ctx context.Context, stream string, seq uint64) (*events.StreamMsg, error) {
+	m, err := s.admin.GetStreamMsg(ctx, stream, seq)
+	if err != nil {
+		if errors.Is(err, events.ErrNotFound{}) {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Message not found",
+			}), governor.ErrOptInner(err))
+		}
+		return nil, governor.ErrWithMsg(err, "Failed to get message")
+	}
+	return m, nil
+}
+
+func (s *service) PurgeStream(ctx context.Context, stream string) error {
+	if err := s.admin.PurgeStream(ctx, stream); err != nil {
+		if errors.Is(err, events.ErrNotFound{}) {
+			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Stream not found",
+			}), governor.ErrOptInner(err))
+		}
+		return governor.ErrWithMsg(err, "Failed to purge stream")
+	}
+	s.logger.Info("purged stream", map[string]string{
+		"actiontype": "purgestream",
+		"stream":     stream,
+	})
+	return nil
+}
+
+func (s *service) ReplayConsumer(ctx context.Context, stream, consumer string, seq uint64, t int64) error {
+	if (seq == 0) == (t == 0) {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusBadRequest,
+			Message: "Exactly one of start sequence or time must be provided",
+		}))
+	}
+	opts := events.ReplayOpts{
+		Seq: seq,
+	}
+	if t != 0 {
+		opts.Time = time.Unix(t, 0)
+	}
+	if err := s.admin.ReplayConsumer(ctx, stream, consumer, opts); err != nil {
+		if errors.Is(err, events.ErrNotFound{}) {
+			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Consumer not found",
+			}), governor.ErrOptInner(err))
+		}
+		return governor.ErrWithMsg(err, "Failed to replay consumer")
+	}
+	s.logger.Info("replayed consumer", map[string]string{
+		"actiontype": "replayconsumer",
+		"stream":     stream,
+		"consumer":   consumer,
+	})
+	return nil
+}
+
+type (
+	resRedrive struct {
+		Count int `json:"count"`
+	}
+)
+
+func (s *service) RedriveDLQ(ctx context.Context, stream, consumer string, dlqStream string, amount int) (*resRedrive, error) {
+	count, err := s.admin.RedriveDLQ(ctx, stream, consumer, dlqStream, amount)
+	if err != nil {
+		if errors.Is(err, events.ErrNotFound{}) {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusNotFound,
+				Message: "Dead letter queue stream not found",
+			}), governor.ErrOptInner(err))
+		}
+		return nil, governor.ErrWithMsg(err, "Failed to redrive dead letter queue")
+	}
+	return &resRedrive{
+		Count: count,
+	}, nil
+}
@@ -0,0 +1,157 @@
+package eventsadmin
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+//go:generate forge validation -o validation_eventsadmin_gen.go reqStream reqStreamMsg reqReplay reqRedrive
+
+type (
+	reqStream struct {
+		Stream string `valid:"stream,has" json:"-"`
+	}
+)
+
+func (m *router) getStreams(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	res, err := m.s.GetStreams(c.Ctx())
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (m *router) getConsumers(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqStream{
+		Stream: c.Param("stream"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := m.s.GetConsumers(c.Ctx(), req.Stream)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+func (m *router) purgeStream(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqStream{
+		Stream: c.Param("stream"),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := m.s.PurgeStream(c.Ctx(), req.Stream); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	reqStreamMsg struct {
+		Stream string `valid:"stream,has" json:"-"`
+		Seq    int    `valid:"seq,has" json:"-"`
+	}
+)
+
+func (m *router) getMsg(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqStreamMsg{
+		Stream: c.Param("stream"),
+		Seq:    c.QueryInt("seq", -1),
+	}
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := m.s.GetMsg(c.Ctx(), req.Stream, uint64(req.Seq))
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+type (
+	reqReplay struct {
+		Stream   string `valid:"stream,has" json:"-"`
+		Consumer string `valid:"consumer,has" json:"-"`
+		Seq      int    `valid:"seq" json:"seq"`
+		Time     int64  `valid:"time" json:"time"`
+	}
+)
+
+func (m *router) replayConsumer(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqReplay{}
+	if err := c.Bind(&req); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Stream = c.Param("stream")
+	req.Consumer = c.Param("consumer")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	if err := m.s.ReplayConsumer(c.Ctx(), req.Stream, req.Consumer, uint64(req.Seq), req.Time); err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteStatus(http.StatusNoContent)
+}
+
+type (
+	reqRedrive struct {
+		Stream    string `valid:"stream,has" json:"-"`
+		Consumer  string `valid:"consumer,has" json:"-"`
+		DLQStream string `valid:"stream,has" json:"dlqstream"`
+		Amount    int    `valid:"amount" json:"amount"`
+	}
+)
+
+func (m *router) redriveDLQ(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	req := reqRedrive{}
+	if err := c.Bind(&req); err != nil {
+		c.WriteError(err)
+		return
+	}
+	req.Stream = c.Param("stream")
+	req.Consumer = c.Param("consumer")
+	if err := req.valid(); err != nil {
+		c.WriteError(err)
+		return
+	}
+	res, err := m.s.RedriveDLQ(c.Ctx(), req.Stream, req.Consumer, req.DLQStream, req.Amount)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+const (
+	scopeStreamRead  = "gov.events.stream:read"
+	scopeStreamWrite = "gov.events.stream:write"
+)
+
+func (m *router) mountRoutes(r governor.Router) {
+	r.Get("/stream", m.getStreams, gate.Admin(m.s.gate, scopeStreamRead))
+	r.Get("/stream/{stream}/consumer", m.getConsumers, gate.Admin(m.s.gate, scopeStreamRead))
+	r.Get("/stream/{stream}/msg", m.getMsg, gate.Admin(m.s.gate, scopeStreamRead))
+	r.Post("/stream/{stream}/purge", m.purgeStream, gate.Admin(m.s.gate, scopeStreamWrite))
+	r.Post("/stream/{stream}/consumer/{consumer}/replay", m.replayConsumer, gate.Admin(m.s.gate, scopeStreamWrite))
+	r.Post("/stream/{stream}/consumer/{consumer}/dlq/redrive", m.redriveDLQ, gate.Admin(m.s.gate, scopeStreamWrite))
+}
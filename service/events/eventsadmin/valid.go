@@ -0,0 +1,90 @@
+package eventsadmin
+
+import (
+	"net/http"
+
+	"xorkevin.dev/governor"
+)
+
+const (
+	lengthCapName = 255
+	amountCap     = 1024
+)
+
+func validhasStream(stream string) error {
+	if len(stream) == 0 {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Stream must be provided",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	if len(stream) > lengthCapName {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Stream must be shorter than 256 characters",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	return nil
+}
+
+func validhasConsumer(consumer string) error {
+	if len(consumer) == 0 {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Consumer must be provided",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	if len(consumer) > lengthCapName {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Consumer must be shorter than 256 characters",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	return nil
+}
+
+func validhasSeq(seq int) error {
+	if seq < 1 {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Sequence must be positive",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	return nil
+}
+
+func validSeq(seq int) error {
+	if seq < 0 {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Sequence must not be negative",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	return nil
+}
+
+func validTime(t int64) error {
+	if t < 0 {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Time must not be negative",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	return nil
+}
+
+func validAmount(amt int) error {
+	if amt < 1 {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Amount must be positive",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	if amt > amountCap {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Amount must be less than 1024",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	return nil
+}
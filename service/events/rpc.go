@@ -0,0 +1,190 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"xorkevin.dev/governor"
+)
+
+const (
+	// rpcDeadlineHeader is the header of the deadline of a request as unix
+	// nanoseconds
+	rpcDeadlineHeader = "Governor-Deadline"
+)
+
+type (
+	// ServeFunc is a type alias for a request handler
+	ServeFunc = func(ctx context.Context, msgdata []byte) ([]byte, error)
+
+	rpcRes struct {
+		Data  []byte    `json:"data,omitempty"`
+		Error *rpcError `json:"error,omitempty"`
+	}
+
+	rpcError struct {
+		User    bool   `json:"user,omitempty"`
+		Status  int    `json:"status"`
+		Code    string `json:"code,omitempty"`
+		Message string `json:"message"`
+	}
+)
+
+type (
+	// ErrTimeout is returned when a request receives no response in time
+	ErrTimeout struct{}
+	// ErrRemote is returned for a non user error returned by a request handler
+	ErrRemote struct{}
+)
+
+func (e ErrTimeout) Error() string {
+	return "Events request timeout"
+}
+
+func (e ErrRemote) Error() string {
+	return "Events remote error"
+}
+
+// Request sends a request to a channel and waits for the response of a
+// handler registered with Serve
+//
+// The request is cancelled with ctx, or after timeout if timeout is greater
+// than 0. The deadline is sent with the request so that the handler stops
+// when the requester is no longer waiting. Errors returned by the handler are
+// returned as a *governor.Error with the error response of the handler, and
+// are user errors if the handler returned a user error.
+func (s *service) Request(ctx context.Context, channel string, msgdata []byte, timeout time.Duration) ([]byte, error) {
+	client, _, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	msg := nats.NewMsg(channel)
+	msg.Data = msgdata
+	if deadline, ok := ctx.Deadline(); ok {
+		msg.Header.Set(rpcDeadlineHeader, strconv.FormatInt(deadline.UnixNano(), 10))
+	}
+	resmsg, err := client.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+			return nil, governor.ErrWithKind(err, ErrTimeout{}, "Request timed out")
+		}
+		if errors.Is(err, nats.ErrNoResponders) {
+			return nil, governor.ErrWithKind(err, ErrClient{}, "No handlers for request")
+		}
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to send request")
+	}
+	res := rpcRes{}
+	if err := json.Unmarshal(resmsg.Data, &res); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to decode response")
+	}
+	if e := res.Error; e != nil {
+		kind := governor.ErrOptKind(ErrRemote{})
+		if e.User {
+			kind = governor.ErrOptUser
+		}
+		return nil, governor.NewError(kind, governor.ErrOptRes(governor.ErrorRes{
+			Status:  e.Status,
+			Code:    e.Code,
+			Message: e.Message,
+		}))
+	}
+	return res.Data, nil
+}
+
+// Serve handles requests to a channel
+//
+// Requests are load balanced among the handlers of a group, and up to
+// serveconcurrency requests are handled at once by each handler. As with
+// Subscribe, the handler is resubscribed when the events connection is
+// reestablished.
+func (s *service) Serve(channel, group string, handler ServeFunc) (Subscription, error) {
+	l := s.logger.WithData(map[string]string{
+		"agent":   "server",
+		"channel": channel,
+		"group":   group,
+	})
+	concurrency := s.serveConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sub := &subscription{
+		s:       s,
+		channel: channel,
+		group:   group,
+		server:  handler,
+		sem:     make(chan struct{}, concurrency),
+		logger:  l,
+	}
+	s.addSub(sub, nil)
+	return sub, nil
+}
+
+// dispatch handles a request once a worker is available
+//
+// The subscription callback blocks while all workers are busy, so that
+// pending requests are bounded by the pending limits of the subscription.
+func (s *subscription) dispatch(msg *nats.Msg) {
+	s.sem <- struct{}{}
+	go func() {
+		defer func() {
+			<-s.sem
+		}()
+		s.serve(msg)
+	}()
+}
+
+func (s *subscription) serve(msg *nats.Msg) {
+	ctx := context.Background()
+	if v := msg.Header.Get(rpcDeadlineHeader); v != "" {
+		if t, err := strconv.ParseInt(v, 10, 64); err == nil {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, time.Unix(0, t))
+			defer cancel()
+		}
+	}
+	res := rpcRes{}
+	if data, err := s.server(ctx, msg.Data); err != nil {
+		res.Error = s.rpcError(err)
+	} else {
+		res.Data = data
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		s.logger.Error("Failed to encode response", map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := msg.Respond(b); err != nil {
+		s.logger.Error("Failed to send response", map[string]string{
+			"error": err.Error(),
+		})
+	}
+}
+
+// rpcError converts a handler error to an error response in the same way as
+// governor.Context.WriteError
+func (s *subscription) rpcError(err error) *rpcError {
+	user := errors.Is(err, governor.ErrorUser{})
+	if !user {
+		s.logger.Error("Failed executing handler", map[string]string{
+			"error": err.Error(),
+		})
+	}
+	rerr := governor.ToErrorRes(err)
+	return &rpcError{
+		User:    user,
+		Status:  rerr.Status,
+		Code:    rerr.Code,
+		Message: rerr.Message,
+	}
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+func TestRPCError(t *testing.T) {
+	t.Parallel()
+
+	s := &subscription{
+		logger: testLogger{},
+	}
+
+	for _, tc := range []struct {
+		Test string
+		Err  error
+		Res  rpcError
+	}{
+		{
+			Test: "user error",
+			Err: governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Code:    "test_code",
+				Message: "Invalid request",
+			})),
+			Res: rpcError{
+				User:    true,
+				Status:  http.StatusBadRequest,
+				Code:    "test_code",
+				Message: "Invalid request",
+			},
+		},
+		{
+			Test: "governor error",
+			Err:  governor.ErrWithMsg(errors.New("test"), "Failed to handle request"),
+			Res: rpcError{
+				Status:  http.StatusInternalServerError,
+				Message: "Failed to handle request",
+			},
+		},
+		{
+			Test: "other error",
+			Err:  errors.New("test"),
+			Res: rpcError{
+				Status:  http.StatusInternalServerError,
+				Message: "Internal Server Error",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			assert.Equal(tc.Res, *s.rpcError(tc.Err))
+		})
+	}
+}
+
+func TestRequest(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ctx := context.Background()
+	s, _ := newTestEvents(t)
+	s.serveConcurrency = 2
+	client, _, err := s.getClient(ctx)
+	assert.NoError(err)
+
+	deadlines := make(chan time.Time, 1)
+	sub, err := s.Serve("test.rpc", "worker", func(ctx context.Context, msgdata []byte) ([]byte, error) {
+		switch string(msgdata) {
+		case "deadline":
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return nil, governor.ErrWithMsg(nil, "No deadline")
+			}
+			deadlines <- deadline
+			return nil, nil
+		case "user":
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid request",
+			}))
+		case "internal":
+			return nil, governor.ErrWithMsg(errors.New("test"), "Failed to handle request")
+		case "slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return append([]byte("echo "), msgdata...), nil
+	})
+	assert.NoError(err)
+	assert.NoError(sub.(*subscription).init(client))
+	defer func() {
+		assert.NoError(sub.Close())
+	}()
+
+	res, err := s.Request(ctx, "test.rpc", []byte("hello"), time.Second)
+	assert.NoError(err)
+	assert.Equal("echo hello", string(res))
+
+	start := time.Now()
+	_, err = s.Request(ctx, "test.rpc", []byte("deadline"), 5*time.Second)
+	assert.NoError(err)
+	deadline := <-deadlines
+	assert.WithinDuration(start.Add(5*time.Second), deadline, time.Second, "Should send the request deadline to the handler")
+
+	_, err = s.Request(ctx, "test.rpc", []byte("user"), time.Second)
+	var gerr *governor.Error
+	assert.True(errors.As(err, &gerr))
+	assert.True(errors.Is(err, governor.ErrorUser{}), "Should return handler user errors as user errors")
+	assert.Equal(http.StatusBadRequest, gerr.Status)
+	assert.Equal("Invalid request", gerr.Message)
+
+	_, err = s.Request(ctx, "test.rpc", []byte("internal"), time.Second)
+	assert.True(errors.As(err, &gerr))
+	assert.True(errors.Is(err, ErrRemote{}), "Should return other handler errors as remote errors")
+	assert.Equal(http.StatusInternalServerError, gerr.Status)
+	assert.Equal("Failed to handle request", gerr.Message)
+
+	_, err = s.Request(ctx, "test.rpc", []byte("slow"), 100*time.Millisecond)
+	assert.True(errors.Is(err, ErrTimeout{}), "Should time out requests")
+
+	// slow requests occupy all workers until their deadline, after which
+	// requests are handled again
+	for i := 0; i < 2; i++ {
+		_, err := s.Request(ctx, "test.rpc", []byte("slow"), 100*time.Millisecond)
+		assert.True(errors.Is(err, ErrTimeout{}))
+	}
+	res, err = s.Request(ctx, "test.rpc", []byte("again"), time.Second)
+	assert.NoError(err)
+	assert.Equal("echo again", string(res))
+
+	_, err = s.Request(ctx, "test.none", []byte("hello"), time.Second)
+	assert.Error(err, "Should fail without handlers")
+}
@@ -0,0 +1,185 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/util/uid"
+)
+
+const (
+	schedStream         = "DEV_XORKEVIN_GOV_EVENTS_SCHED"
+	schedStreamChannels = schedStream + ".>"
+	schedMsgChannel     = schedStream + ".msg"
+	schedWorker         = "DEV_XORKEVIN_GOV_EVENTS_WORKER_SCHED"
+	schedMsgIDSize      = 16
+	schedMaxDelay       = 15 * time.Minute
+)
+
+type (
+	// schedMsg is a message scheduled for delivery
+	//
+	// Time is a unix timestamp in nanoseconds.
+	schedMsg struct {
+		MsgID   string `json:"msgid"`
+		Channel string `json:"channel"`
+		Time    int64  `json:"time"`
+		Data    []byte `json:"data"`
+	}
+)
+
+func schedMsgSubject(msgid string) string {
+	return schedMsgChannel + "." + msgid
+}
+
+func validSchedMsgID(msgid string) error {
+	if msgid == "" || strings.ContainsAny(msgid, ".*> \t\r\n") {
+		return governor.ErrWithKind(nil, ErrClient{}, "Invalid scheduled message id")
+	}
+	return nil
+}
+
+// StreamPublishAt publishes to a stream at a time
+//
+// The message is stored durably until it is published to channel at t, and
+// is delivered to the stream subscribers of channel. It may be cancelled
+// before then with CancelScheduled with the id set by StreamPublishOptMsgID,
+// which must be a valid subject token. A message id is generated otherwise.
+// The scheduled message is published with the same message id, and
+// scheduling a message id more than once within the duplicate window of the
+// scheduler stream has no effect. t must be within the schedmaxage config.
+//
+// Messages which are not yet due are held pending by the scheduler, so that
+// at most schedmaxpending messages may be scheduled at once. Scheduling more
+// fails rather than delaying the delivery of messages which are due.
+func (s *service) StreamPublishAt(ctx context.Context, channel string, msgdata []byte, t time.Time, opts ...StreamPublishOpt) error {
+	conn, client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if t.Sub(time.Now()) > s.schedMaxAge {
+		return governor.ErrWithKind(nil, ErrClient{}, "Scheduled time exceeds max schedule age")
+	}
+	info := nats.ConsumerInfo{}
+	if err := jsAPIRequest(ctx, conn, fmt.Sprintf(jsAPIConsumerInfo, schedStream, schedWorker), nil, &info); err != nil {
+		// the scheduler consumer is created on start, and no messages are
+		// pending before then
+		if !isNotFound(err) {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to get scheduler consumer")
+		}
+	} else if info.NumPending+uint64(info.NumAckPending) >= uint64(s.schedMaxPending) {
+		return governor.ErrWithKind(nil, ErrClient{}, "Too many scheduled messages")
+	}
+	o := streamPublishOpts{}
+	for _, i := range opts {
+		i(&o)
+	}
+	msgid := o.msgid
+	if msgid == "" {
+		u, err := uid.New(schedMsgIDSize)
+		if err != nil {
+			return governor.ErrWithMsg(err, "Failed to create new message id")
+		}
+		msgid = u.Base64()
+	}
+	if err := validSchedMsgID(msgid); err != nil {
+		return err
+	}
+	b, err := json.Marshal(schedMsg{
+		MsgID:   msgid,
+		Channel: channel,
+		Time:    t.UnixNano(),
+		Data:    msgdata,
+	})
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to encode scheduled message")
+	}
+	if _, err := client.Publish(schedMsgSubject(msgid), b, nats.Context(ctx), nats.MsgId(msgid)); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to schedule message")
+	}
+	return nil
+}
+
+// StreamPublishAfter publishes to a stream after a delay
+//
+// See StreamPublishAt.
+func (s *service) StreamPublishAfter(ctx context.Context, channel string, msgdata []byte, delay time.Duration, opts ...StreamPublishOpt) error {
+	return s.StreamPublishAt(ctx, channel, msgdata, time.Now().Add(delay), opts...)
+}
+
+// CancelScheduled cancels a scheduled message by its message id
+//
+// All stored messages scheduled with the message id are removed. Cancelling a
+// message which has already been published has no effect.
+func (s *service) CancelScheduled(ctx context.Context, msgid string) error {
+	_, client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validSchedMsgID(msgid); err != nil {
+		return err
+	}
+	sub, err := client.SubscribeSync(schedMsgSubject(msgid), nats.BindStream(schedStream), nats.DeliverAll(), nats.AckNone())
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to subscribe to scheduled messages")
+	}
+	defer func() {
+		if err := sub.Unsubscribe(); err != nil {
+			s.logger.Error("Failed to close scheduled message subscription", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "cancelscheduled",
+			})
+		}
+	}()
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to get scheduled message consumer")
+	}
+	// messages may already have been delivered to the subscription by the
+	// time its info is returned
+	total := info.Delivered.Consumer + info.NumPending
+	if total == 0 {
+		return governor.ErrWithKind(nil, ErrNotFound{}, "Scheduled message not found")
+	}
+	for ; total > 0; total-- {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to get scheduled message")
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to get scheduled message metadata")
+		}
+		if err := client.DeleteMsg(schedStream, meta.Sequence.Stream, nats.Context(ctx)); err != nil {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to cancel scheduled message")
+		}
+	}
+	return nil
+}
+
+// schedSubscriber publishes scheduled messages once they are due
+//
+// Messages which are not yet due are redelivered once they are, or after
+// schedMaxDelay if sooner, so that a nak is never held for longer than that.
+// This relies on the delayed naks of nats server 2.7 or later.
+func (s *service) schedSubscriber(ctx context.Context, pinger Pinger, msgdata []byte) error {
+	m := schedMsg{}
+	if err := json.Unmarshal(msgdata, &m); err != nil {
+		return governor.ErrWithKind(err, ErrInvalidStreamMsg{}, "Failed to decode scheduled message")
+	}
+	if delay := time.Until(time.Unix(0, m.Time)); delay > 0 {
+		if delay > schedMaxDelay {
+			delay = schedMaxDelay
+		}
+		return WorkerRetryAfter(nil, delay)
+	}
+	if err := s.StreamPublish(ctx, m.Channel, m.Data, StreamPublishOptMsgID(m.MsgID)); err != nil {
+		return governor.ErrWithMsg(err, "Failed to publish scheduled message")
+	}
+	return nil
+}
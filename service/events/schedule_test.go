@@ -0,0 +1,149 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+func TestSchedSubscriberDelay(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s := New().(*service)
+	for _, tc := range []struct {
+		Test  string
+		After time.Duration
+		Max   time.Duration
+	}{
+		{
+			Test:  "soon",
+			After: time.Minute,
+			Max:   time.Minute,
+		},
+		{
+			Test:  "later",
+			After: 24 * time.Hour,
+			Max:   schedMaxDelay,
+		},
+	} {
+		b, err := json.Marshal(schedMsg{
+			MsgID:   tc.Test,
+			Channel: "test.msg",
+			Time:    time.Now().Add(tc.After).UnixNano(),
+		})
+		assert.NoError(err)
+		err = s.schedSubscriber(context.Background(), nil, b)
+		delay, ok := workerRetryDelay(err)
+		assert.True(ok, "Should retry messages which are not yet due")
+		assert.True(delay > 0 && delay <= tc.Max, "Should bound the retry delay")
+	}
+	{
+		err := s.schedSubscriber(context.Background(), nil, []byte("bogus"))
+		assert.True(errors.Is(err, ErrInvalidStreamMsg{}))
+	}
+}
+
+func TestStreamPublishAt(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s, js := newTestEvents(t)
+	s.schedMaxAge = time.Hour
+	s.schedMaxPending = 2
+	ctx := context.Background()
+	assert.NoError(s.Setup(ctx, governor.ReqSetup{}))
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "test",
+		Subjects: []string{"test.>"},
+	})
+	assert.NoError(err)
+	_, err = js.AddConsumer(schedStream, &nats.ConsumerConfig{
+		Durable:       schedWorker,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		FilterSubject: schedMsgChannel + ".*",
+	})
+	assert.NoError(err)
+
+	assert.NoError(s.StreamPublishAfter(ctx, "test.msg", []byte("later"), 30*time.Minute, StreamPublishOptMsgID("later")))
+	assert.NoError(s.StreamPublishAt(ctx, "test.msg", []byte("due"), time.Now().Add(-time.Second), StreamPublishOptMsgID("due")))
+	err = s.StreamPublishAfter(ctx, "test.msg", []byte("full"), time.Minute)
+	assert.True(errors.Is(err, ErrClient{}), "Should fail once max pending messages are scheduled")
+	err = s.StreamPublishAfter(ctx, "test.msg", []byte("old"), 2*time.Hour)
+	assert.True(errors.Is(err, ErrClient{}), "Should fail past the max schedule age")
+	err = s.StreamPublishAfter(ctx, "test.msg", []byte("invalid"), time.Minute, StreamPublishOptMsgID("a.b"))
+	assert.True(errors.Is(err, ErrClient{}), "Should fail for invalid message ids")
+
+	due, err := s.GetStreamMsg(ctx, schedStream, 2)
+	assert.NoError(err)
+	assert.Equal(schedMsgSubject("due"), due.Subject)
+	assert.NoError(s.schedSubscriber(ctx, nil, due.Data))
+	msg, err := s.GetStreamMsg(ctx, "test", 1)
+	assert.NoError(err)
+	assert.Equal("test.msg", msg.Subject)
+	assert.Equal([]byte("due"), msg.Data, "Should publish due messages")
+	assert.Equal([]string{"due"}, msg.Headers["Nats-Msg-Id"])
+
+	assert.NoError(s.CancelScheduled(ctx, "later"))
+	_, err = s.GetStreamMsg(ctx, schedStream, 1)
+	assert.True(errors.Is(err, ErrNotFound{}), "Should remove cancelled messages")
+	err = s.CancelScheduled(ctx, "later")
+	assert.True(errors.Is(err, ErrNotFound{}), "Should return not found for cancelled messages")
+	err = s.CancelScheduled(ctx, "a.b")
+	assert.True(errors.Is(err, ErrClient{}))
+	assert.NoError(s.StreamPublishAfter(ctx, "test.msg", []byte("again"), time.Minute), "Should free capacity of cancelled messages")
+}
+
+func TestSchedRedelivery(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	s, js := newTestEvents(t)
+	s.schedMaxAge = time.Hour
+	s.schedMaxPending = 2
+	ctx := context.Background()
+	assert.NoError(s.Setup(ctx, governor.ReqSetup{}))
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "test",
+		Subjects: []string{"test.>"},
+	})
+	assert.NoError(err)
+	sub, err := js.PullSubscribe(schedMsgChannel+".*", schedWorker, nats.BindStream(schedStream), nats.ManualAck(), nats.AckExplicit(), nats.AckWait(15*time.Second))
+	assert.NoError(err)
+
+	assert.NoError(s.StreamPublishAfter(ctx, "test.msg", []byte("soon"), 2*time.Second, StreamPublishOptMsgID("soon")))
+	msgs, err := sub.Fetch(1, nats.MaxWait(time.Second))
+	assert.NoError(err)
+	assert.Len(msgs, 1)
+	err = s.schedSubscriber(ctx, nil, msgs[0].Data)
+	delay, ok := workerRetryDelay(err)
+	assert.True(ok, "Should retry messages which are not yet due")
+
+	ss := &streamSubscription{
+		s:      s,
+		logger: s.logger,
+	}
+	start := time.Now()
+	ss.nak(msgs[0], delay)
+	_, err = sub.Fetch(1, nats.MaxWait(500*time.Millisecond))
+	assert.True(errors.Is(err, nats.ErrTimeout), "Should not redeliver messages before the nak delay")
+	msgs, err = sub.Fetch(1, nats.MaxWait(5*time.Second))
+	assert.NoError(err, "Should redeliver messages after the nak delay rather than the ack wait")
+	assert.Len(msgs, 1)
+	assert.True(time.Since(start) >= delay-100*time.Millisecond, "Should redeliver messages once they are due")
+	assert.NoError(s.schedSubscriber(ctx, nil, msgs[0].Data))
+	assert.NoError(msgs[0].Ack())
+	msg, err := s.GetStreamMsg(ctx, "test", 1)
+	assert.NoError(err)
+	assert.Equal([]byte("soon"), msg.Data, "Should publish messages once they are due")
+}
@@ -0,0 +1,676 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/go-redis/redis/v7"
+	"xorkevin.dev/governor"
+)
+
+func fieldsToArgs(fields map[string]string) []interface{} {
+	args := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	return args
+}
+
+func strsToArgs(vals []string) []interface{} {
+	args := make([]interface{}, 0, len(vals))
+	for _, i := range vals {
+		args = append(args, i)
+	}
+	return args
+}
+
+func zmembersToArgs(members []ZMember) []*redis.Z {
+	args := make([]*redis.Z, 0, len(members))
+	for _, i := range members {
+		args = append(args, &redis.Z{
+			Score:  i.Score,
+			Member: i.Member,
+		})
+	}
+	return args
+}
+
+func zscoreBound(score float64) string {
+	if math.IsInf(score, 1) {
+		return "+inf"
+	}
+	if math.IsInf(score, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func zrangeBy(min, max float64, limit, offset int) *redis.ZRangeBy {
+	count := int64(limit)
+	if count <= 0 {
+		// a negative count returns all remaining members after the offset
+		count = -1
+	}
+	return &redis.ZRangeBy{
+		Min:    zscoreBound(min),
+		Max:    zscoreBound(max),
+		Offset: int64(offset),
+		Count:  count,
+	}
+}
+
+func zsliceToMembers(zs []redis.Z) ([]ZMember, error) {
+	res := make([]ZMember, 0, len(zs))
+	for _, i := range zs {
+		m, ok := i.Member.(string)
+		if !ok {
+			return nil, governor.ErrWithKind(nil, ErrVal{}, "Invalid sorted set member")
+		}
+		res = append(res, ZMember{
+			Score:  i.Score,
+			Member: m,
+		})
+	}
+	return res, nil
+}
+
+func (s *service) HGet(ctx context.Context, key, field string) (string, error) {
+	if s.mem != nil {
+		return s.mem.HGet(ctx, key, field)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	val, err := client.HGet(key, field).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", governor.ErrWithKind(err, ErrNotFound{}, "Field not found")
+		}
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to get hash field")
+	}
+	return val, nil
+}
+
+func (s *service) HSet(ctx context.Context, key string, fields map[string]string) error {
+	if s.mem != nil {
+		return s.mem.HSet(ctx, key, fields)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.HSet(key, fieldsToArgs(fields)...).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to set hash fields")
+	}
+	return nil
+}
+
+func (s *service) HDel(ctx context.Context, key string, field ...string) error {
+	if s.mem != nil {
+		return s.mem.HDel(ctx, key, field...)
+	}
+
+	if len(field) == 0 {
+		return nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.HDel(key, field...).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to delete hash fields")
+	}
+	return nil
+}
+
+func (s *service) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if s.mem != nil {
+		return s.mem.HGetAll(ctx, key)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, err := client.HGetAll(key).Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get hash")
+	}
+	return val, nil
+}
+
+func (s *service) SAdd(ctx context.Context, key string, member ...string) error {
+	if s.mem != nil {
+		return s.mem.SAdd(ctx, key, member...)
+	}
+
+	if len(member) == 0 {
+		return nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.SAdd(key, strsToArgs(member)...).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to add set members")
+	}
+	return nil
+}
+
+func (s *service) SRem(ctx context.Context, key string, member ...string) error {
+	if s.mem != nil {
+		return s.mem.SRem(ctx, key, member...)
+	}
+
+	if len(member) == 0 {
+		return nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.SRem(key, strsToArgs(member)...).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove set members")
+	}
+	return nil
+}
+
+func (s *service) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	if s.mem != nil {
+		return s.mem.SIsMember(ctx, key, member)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	val, err := client.SIsMember(key, member).Result()
+	if err != nil {
+		return false, governor.ErrWithKind(err, ErrClient{}, "Failed to check set member")
+	}
+	return val, nil
+}
+
+func (s *service) SMembers(ctx context.Context, key string) ([]string, error) {
+	if s.mem != nil {
+		return s.mem.SMembers(ctx, key)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, err := client.SMembers(key).Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get set members")
+	}
+	return val, nil
+}
+
+func (s *service) ZAdd(ctx context.Context, key string, member ...ZMember) error {
+	if s.mem != nil {
+		return s.mem.ZAdd(ctx, key, member...)
+	}
+
+	if len(member) == 0 {
+		return nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.ZAdd(key, zmembersToArgs(member)...).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to add sorted set members")
+	}
+	return nil
+}
+
+func (s *service) ZRangeByScore(ctx context.Context, key string, min, max float64, limit, offset int) ([]ZMember, error) {
+	if s.mem != nil {
+		return s.mem.ZRangeByScore(ctx, key, min, max, limit, offset)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, err := client.ZRangeByScoreWithScores(key, zrangeBy(min, max, limit, offset)).Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get sorted set range")
+	}
+	return zsliceToMembers(val)
+}
+
+func (s *service) ZRank(ctx context.Context, key, member string) (int64, error) {
+	if s.mem != nil {
+		return s.mem.ZRank(ctx, key, member)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	val, err := client.ZRank(key, member).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, governor.ErrWithKind(err, ErrNotFound{}, "Member not found")
+		}
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to get sorted set rank")
+	}
+	return val, nil
+}
+
+func (s *service) ZIncrBy(ctx context.Context, key string, delta float64, member string) (float64, error) {
+	if s.mem != nil {
+		return s.mem.ZIncrBy(ctx, key, delta, member)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	val, err := client.ZIncrBy(key, delta, member).Result()
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to incr sorted set member")
+	}
+	return val, nil
+}
+
+func (s *service) LPush(ctx context.Context, key string, val ...string) error {
+	if s.mem != nil {
+		return s.mem.LPush(ctx, key, val...)
+	}
+
+	if len(val) == 0 {
+		return nil
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.LPush(key, strsToArgs(val)...).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to push list values")
+	}
+	return nil
+}
+
+func (s *service) RPop(ctx context.Context, key string) (string, error) {
+	if s.mem != nil {
+		return s.mem.RPop(ctx, key)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	val, err := client.RPop(key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", governor.ErrWithKind(err, ErrNotFound{}, "List empty")
+		}
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to pop list value")
+	}
+	return val, nil
+}
+
+func (s *service) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	if s.mem != nil {
+		return s.mem.LRange(ctx, key, start, stop)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, err := client.LRange(key, start, stop).Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get list range")
+	}
+	return val, nil
+}
+
+func (s *service) LTrim(ctx context.Context, key string, start, stop int64) error {
+	if s.mem != nil {
+		return s.mem.LTrim(ctx, key, start, stop)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.LTrim(key, start, stop).Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to trim list")
+	}
+	return nil
+}
+
+func (t *baseMulti) HGet(key, field string) Resulter {
+	t.keys.check(key)
+	return &resulter{
+		res: t.base.HGet(key, field),
+	}
+}
+
+func (t *baseMulti) HSet(key string, fields map[string]string) {
+	t.keys.check(key)
+	if len(fields) == 0 {
+		return
+	}
+	t.base.HSet(key, fieldsToArgs(fields)...)
+}
+
+func (t *baseMulti) HDel(key string, field ...string) {
+	t.keys.check(key)
+	if len(field) == 0 {
+		return
+	}
+	t.base.HDel(key, field...)
+}
+
+func (t *baseMulti) HGetAll(key string) StrMapResulter {
+	t.keys.check(key)
+	return &strMapResulter{
+		res: t.base.HGetAll(key),
+	}
+}
+
+func (t *baseMulti) SAdd(key string, member ...string) {
+	t.keys.check(key)
+	if len(member) == 0 {
+		return
+	}
+	t.base.SAdd(key, strsToArgs(member)...)
+}
+
+func (t *baseMulti) SRem(key string, member ...string) {
+	t.keys.check(key)
+	if len(member) == 0 {
+		return
+	}
+	t.base.SRem(key, strsToArgs(member)...)
+}
+
+func (t *baseMulti) SIsMember(key, member string) BoolResulter {
+	t.keys.check(key)
+	return &boolResulter{
+		res: t.base.SIsMember(key, member),
+	}
+}
+
+func (t *baseMulti) SMembers(key string) StrSliceResulter {
+	t.keys.check(key)
+	return &strSliceResulter{
+		res: t.base.SMembers(key),
+	}
+}
+
+func (t *baseMulti) ZAdd(key string, member ...ZMember) {
+	t.keys.check(key)
+	if len(member) == 0 {
+		return
+	}
+	t.base.ZAdd(key, zmembersToArgs(member)...)
+}
+
+func (t *baseMulti) ZRangeByScore(key string, min, max float64, limit, offset int) ZMemberResulter {
+	t.keys.check(key)
+	return &zmemberResulter{
+		res: t.base.ZRangeByScoreWithScores(key, zrangeBy(min, max, limit, offset)),
+	}
+}
+
+func (t *baseMulti) ZRank(key, member string) IntResulter {
+	t.keys.check(key)
+	return &intCmdResulter{
+		res: t.base.ZRank(key, member),
+	}
+}
+
+func (t *baseMulti) ZIncrBy(key string, delta float64, member string) FloatResulter {
+	t.keys.check(key)
+	return &floatResulter{
+		res: t.base.ZIncrBy(key, delta, member),
+	}
+}
+
+func (t *baseMulti) LPush(key string, val ...string) {
+	t.keys.check(key)
+	if len(val) == 0 {
+		return
+	}
+	t.base.LPush(key, strsToArgs(val)...)
+}
+
+func (t *baseMulti) RPop(key string) Resulter {
+	t.keys.check(key)
+	return &resulter{
+		res: t.base.RPop(key),
+	}
+}
+
+func (t *baseMulti) LRange(key string, start, stop int64) StrSliceResulter {
+	t.keys.check(key)
+	return &strSliceResulter{
+		res: t.base.LRange(key, start, stop),
+	}
+}
+
+func (t *baseMulti) LTrim(key string, start, stop int64) {
+	t.keys.check(key)
+	t.base.LTrim(key, start, stop)
+}
+
+func (t *multi) HGet(key, field string) Resulter {
+	return t.base.HGet(t.key(key), field)
+}
+
+func (t *multi) HSet(key string, fields map[string]string) {
+	t.base.HSet(t.key(key), fields)
+}
+
+func (t *multi) HDel(key string, field ...string) {
+	t.base.HDel(t.key(key), field...)
+}
+
+func (t *multi) HGetAll(key string) StrMapResulter {
+	return t.base.HGetAll(t.key(key))
+}
+
+func (t *multi) SAdd(key string, member ...string) {
+	t.base.SAdd(t.key(key), member...)
+}
+
+func (t *multi) SRem(key string, member ...string) {
+	t.base.SRem(t.key(key), member...)
+}
+
+func (t *multi) SIsMember(key, member string) BoolResulter {
+	return t.base.SIsMember(t.key(key), member)
+}
+
+func (t *multi) SMembers(key string) StrSliceResulter {
+	return t.base.SMembers(t.key(key))
+}
+
+func (t *multi) ZAdd(key string, member ...ZMember) {
+	t.base.ZAdd(t.key(key), member...)
+}
+
+func (t *multi) ZRangeByScore(key string, min, max float64, limit, offset int) ZMemberResulter {
+	return t.base.ZRangeByScore(t.key(key), min, max, limit, offset)
+}
+
+func (t *multi) ZRank(key, member string) IntResulter {
+	return t.base.ZRank(t.key(key), member)
+}
+
+func (t *multi) ZIncrBy(key string, delta float64, member string) FloatResulter {
+	return t.base.ZIncrBy(t.key(key), delta, member)
+}
+
+func (t *multi) LPush(key string, val ...string) {
+	t.base.LPush(t.key(key), val...)
+}
+
+func (t *multi) RPop(key string) Resulter {
+	return t.base.RPop(t.key(key))
+}
+
+func (t *multi) LRange(key string, start, stop int64) StrSliceResulter {
+	return t.base.LRange(t.key(key), start, stop)
+}
+
+func (t *multi) LTrim(key string, start, stop int64) {
+	t.base.LTrim(t.key(key), start, stop)
+}
+
+func (t *tree) HGet(ctx context.Context, key, field string) (string, error) {
+	return t.base.HGet(ctx, t.key(key), field)
+}
+
+func (t *tree) HSet(ctx context.Context, key string, fields map[string]string) error {
+	return t.base.HSet(ctx, t.key(key), fields)
+}
+
+func (t *tree) HDel(ctx context.Context, key string, field ...string) error {
+	return t.base.HDel(ctx, t.key(key), field...)
+}
+
+func (t *tree) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return t.base.HGetAll(ctx, t.key(key))
+}
+
+func (t *tree) SAdd(ctx context.Context, key string, member ...string) error {
+	return t.base.SAdd(ctx, t.key(key), member...)
+}
+
+func (t *tree) SRem(ctx context.Context, key string, member ...string) error {
+	return t.base.SRem(ctx, t.key(key), member...)
+}
+
+func (t *tree) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	return t.base.SIsMember(ctx, t.key(key), member)
+}
+
+func (t *tree) SMembers(ctx context.Context, key string) ([]string, error) {
+	return t.base.SMembers(ctx, t.key(key))
+}
+
+func (t *tree) ZAdd(ctx context.Context, key string, member ...ZMember) error {
+	return t.base.ZAdd(ctx, t.key(key), member...)
+}
+
+func (t *tree) ZRangeByScore(ctx context.Context, key string, min, max float64, limit, offset int) ([]ZMember, error) {
+	return t.base.ZRangeByScore(ctx, t.key(key), min, max, limit, offset)
+}
+
+func (t *tree) ZRank(ctx context.Context, key, member string) (int64, error) {
+	return t.base.ZRank(ctx, t.key(key), member)
+}
+
+func (t *tree) ZIncrBy(ctx context.Context, key string, delta float64, member string) (float64, error) {
+	return t.base.ZIncrBy(ctx, t.key(key), delta, member)
+}
+
+func (t *tree) LPush(ctx context.Context, key string, val ...string) error {
+	return t.base.LPush(ctx, t.key(key), val...)
+}
+
+func (t *tree) RPop(ctx context.Context, key string) (string, error) {
+	return t.base.RPop(ctx, t.key(key))
+}
+
+func (t *tree) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return t.base.LRange(ctx, t.key(key), start, stop)
+}
+
+func (t *tree) LTrim(ctx context.Context, key string, start, stop int64) error {
+	return t.base.LTrim(ctx, t.key(key), start, stop)
+}
+
+type (
+	floatResulter struct {
+		res *redis.FloatCmd
+	}
+)
+
+func (r *floatResulter) Result() (float64, error) {
+	val, err := r.res.Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, governor.ErrWithKind(err, ErrNotFound{}, "Key not found")
+		}
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	return val, nil
+}
+
+type (
+	boolResulter struct {
+		res *redis.BoolCmd
+	}
+)
+
+func (r *boolResulter) Result() (bool, error) {
+	val, err := r.res.Result()
+	if err != nil {
+		return false, governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	return val, nil
+}
+
+type (
+	strSliceResulter struct {
+		res *redis.StringSliceCmd
+	}
+)
+
+func (r *strSliceResulter) Result() ([]string, error) {
+	val, err := r.res.Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	return val, nil
+}
+
+type (
+	strMapResulter struct {
+		res *redis.StringStringMapCmd
+	}
+)
+
+func (r *strMapResulter) Result() (map[string]string, error) {
+	val, err := r.res.Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	return val, nil
+}
+
+type (
+	zmemberResulter struct {
+		res *redis.ZSliceCmd
+	}
+)
+
+func (r *zmemberResulter) Result() ([]ZMember, error) {
+	val, err := r.res.Result()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	return zsliceToMembers(val)
+}
@@ -0,0 +1,131 @@
+package kvadmin
+
+import (
+	"context"
+	"net/http"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/kvstore/nearcache"
+	"xorkevin.dev/governor/service/user/gate"
+)
+
+type (
+	// Service is a kvstore admin governor.Service
+	Service interface {
+		governor.Service
+	}
+
+	service struct {
+		kv     kvstore.Statser
+		caches nearcache.Statser
+		gate   gate.Gate
+		logger governor.Logger
+	}
+
+	router struct {
+		s service
+	}
+)
+
+// NewCtx creates a new kvstore admin service from a context
+func NewCtx(inj governor.Injector) Service {
+	kv := kvstore.GetCtxStatser(inj)
+	caches := nearcache.GetCtxStatser(inj)
+	g := gate.GetCtxGate(inj)
+	return New(kv, caches, g)
+}
+
+// New returns a new kvstore admin service
+//
+// caches may be nil if no near caches are registered.
+func New(kv kvstore.Statser, caches nearcache.Statser, g gate.Gate) Service {
+	return &service{
+		kv:     kv,
+		caches: caches,
+		gate:   g,
+	}
+}
+
+func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
+}
+
+func (s *service) router() *router {
+	return &router{
+		s: *s,
+	}
+}
+
+func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
+	s.logger = l
+	l = s.logger.WithData(map[string]string{
+		"phase": "init",
+	})
+
+	sr := s.router()
+	sr.mountRoute(m)
+	l.Info("mounted http routes", nil)
+
+	return nil
+}
+
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) Start(ctx context.Context) error {
+	return nil
+}
+
+func (s *service) Stop(ctx context.Context) {
+}
+
+func (s *service) Health() error {
+	return nil
+}
+
+type (
+	resStats struct {
+		Keys       int64                  `json:"keys"`
+		Subtrees   []kvstore.SubtreeStats `json:"subtrees"`
+		NearCaches []nearcache.Stats      `json:"nearcaches"`
+	}
+)
+
+func (s *service) GetStats(ctx context.Context) (*resStats, error) {
+	m, err := s.kv.Stats(ctx)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get kvstore stats")
+	}
+	caches := []nearcache.Stats{}
+	if s.caches != nil {
+		caches = s.caches.Stats()
+	}
+	return &resStats{
+		Keys:       m.Keys,
+		Subtrees:   m.Subtrees,
+		NearCaches: caches,
+	}, nil
+}
+
+func (m *router) getStats(w http.ResponseWriter, r *http.Request) {
+	c := governor.NewContext(w, r, m.s.logger)
+	res, err := m.s.GetStats(c.Ctx())
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteJSON(http.StatusOK, res)
+}
+
+const (
+	scopeStatsRead = "gov.kvstore.stats:read"
+)
+
+func (m *router) mountRoute(r governor.Router) {
+	r.Get("/stats", m.getStats, gate.Admin(m.s.gate, scopeStatsRead))
+}
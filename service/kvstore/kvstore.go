@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v7"
@@ -16,6 +17,25 @@ const (
 	kvpathSeparator = ":"
 )
 
+const (
+	// watchMaxRetries is the number of times a watch is retried on conflict
+	watchMaxRetries = 8
+	// drainTimeout is how long a draining client may wait for its busy
+	// connections to be returned before it is closed anyway
+	drainTimeout = time.Minute
+)
+
+const (
+	backendRedis = "redis"
+	backendMem   = "mem"
+)
+
+const (
+	modeStandalone = "standalone"
+	modeSentinel   = "sentinel"
+	modeCluster    = "cluster"
+)
+
 type (
 	// Resulter returns the result of a string command in a multi after it has executed
 	Resulter interface {
@@ -27,6 +47,61 @@ type (
 		Result() (int64, error)
 	}
 
+	// FloatResulter returns the result of a float command in a multi after it has executed
+	FloatResulter interface {
+		Result() (float64, error)
+	}
+
+	// BoolResulter returns the result of a bool command in a multi after it has executed
+	BoolResulter interface {
+		Result() (bool, error)
+	}
+
+	// StrSliceResulter returns the result of a string slice command in a multi after it has executed
+	StrSliceResulter interface {
+		Result() ([]string, error)
+	}
+
+	// StrMapResulter returns the result of a string map command in a multi after it has executed
+	StrMapResulter interface {
+		Result() (map[string]string, error)
+	}
+
+	// ZMemberResulter returns the result of a sorted set command in a multi after it has executed
+	ZMemberResulter interface {
+		Result() ([]ZMember, error)
+	}
+
+	// ScanIterator iterates over keys matching a scan pattern
+	ScanIterator interface {
+		Next() bool
+		Key() string
+		Err() error
+	}
+
+	// SubtreeStats are key count and memory usage stats of a subtree
+	//
+	// Truncated is true if the scan of the subtree stopped at the stats scan
+	// limit, in which case Keys and Memory are lower bounds.
+	SubtreeStats struct {
+		Prefix    string `json:"prefix"`
+		Keys      int64  `json:"keys"`
+		Memory    int64  `json:"memory"`
+		Truncated bool   `json:"truncated"`
+	}
+
+	// Stats are the total key count and subtree stats of the kvstore
+	Stats struct {
+		Keys     int64          `json:"keys"`
+		Subtrees []SubtreeStats `json:"subtrees"`
+	}
+
+	// ZMember is a sorted set member and its score
+	ZMember struct {
+		Score  float64
+		Member string
+	}
+
 	// Multi is a kvstore multi
 	Multi interface {
 		Get(key string) Resulter
@@ -35,33 +110,91 @@ type (
 		Del(key ...string)
 		Incr(key string, delta int64) IntResulter
 		Expire(key string, seconds int64)
+		TTL(key string) IntResulter
+		HGet(key, field string) Resulter
+		HSet(key string, fields map[string]string)
+		HDel(key string, field ...string)
+		HGetAll(key string) StrMapResulter
+		SAdd(key string, member ...string)
+		SRem(key string, member ...string)
+		SIsMember(key, member string) BoolResulter
+		SMembers(key string) StrSliceResulter
+		ZAdd(key string, member ...ZMember)
+		ZRangeByScore(key string, min, max float64, limit, offset int) ZMemberResulter
+		ZRank(key, member string) IntResulter
+		ZIncrBy(key string, delta float64, member string) FloatResulter
+		LPush(key string, val ...string)
+		RPop(key string) Resulter
+		LRange(key string, start, stop int64) StrSliceResulter
+		LTrim(key string, start, stop int64)
 		Subkey(keypath ...string) string
 		Subtree(prefix string) Multi
-		Exec() error
+		Exec(ctx context.Context) error
+	}
+
+	// Watcher reads watched keys and queues commands within a Watch
+	//
+	// Commands queued on the Multi returned by Tx are executed atomically
+	// after the watch function returns, and only if none of the watched keys
+	// have been modified. Exec must not be called on the Multi.
+	Watcher interface {
+		Get(ctx context.Context, key string) (string, error)
+		GetInt(ctx context.Context, key string) (int64, error)
+		Tx() Multi
+		Subkey(keypath ...string) string
+		Subtree(prefix string) Watcher
 	}
 
 	// KVStore is a service wrapper around a kv store client
 	KVStore interface {
-		Get(key string) (string, error)
-		GetInt(key string) (int64, error)
-		Set(key, val string, seconds int64) error
-		Del(key ...string) error
-		Incr(key string, delta int64) (int64, error)
-		Expire(key string, seconds int64) error
+		Get(ctx context.Context, key string) (string, error)
+		GetInt(ctx context.Context, key string) (int64, error)
+		Set(ctx context.Context, key, val string, seconds int64) error
+		SetNX(ctx context.Context, key, val string, seconds int64) (bool, error)
+		CompareAndSet(ctx context.Context, key, old, val string, seconds int64) (bool, error)
+		Del(ctx context.Context, key ...string) error
+		Incr(ctx context.Context, key string, delta int64) (int64, error)
+		Expire(ctx context.Context, key string, seconds int64) error
+		TTL(ctx context.Context, key string) (int64, error)
+		HGet(ctx context.Context, key, field string) (string, error)
+		HSet(ctx context.Context, key string, fields map[string]string) error
+		HDel(ctx context.Context, key string, field ...string) error
+		HGetAll(ctx context.Context, key string) (map[string]string, error)
+		SAdd(ctx context.Context, key string, member ...string) error
+		SRem(ctx context.Context, key string, member ...string) error
+		SIsMember(ctx context.Context, key, member string) (bool, error)
+		SMembers(ctx context.Context, key string) ([]string, error)
+		ZAdd(ctx context.Context, key string, member ...ZMember) error
+		ZRangeByScore(ctx context.Context, key string, min, max float64, limit, offset int) ([]ZMember, error)
+		ZRank(ctx context.Context, key, member string) (int64, error)
+		ZIncrBy(ctx context.Context, key string, delta float64, member string) (float64, error)
+		LPush(ctx context.Context, key string, val ...string) error
+		RPop(ctx context.Context, key string) (string, error)
+		LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+		LTrim(ctx context.Context, key string, start, stop int64) error
+		Scan(ctx context.Context, pattern string, batch int) (ScanIterator, error)
+		DelSubtree(ctx context.Context) error
 		Subkey(keypath ...string) string
-		Multi() (Multi, error)
-		Tx() (Multi, error)
+		Multi(ctx context.Context) (Multi, error)
+		Tx(ctx context.Context) (Multi, error)
+		Watch(ctx context.Context, keys []string, fn func(tx Watcher) error) error
 		Subtree(prefix string) KVStore
 	}
 
+	// Statser reports stats on registered subtrees
+	Statser interface {
+		Stats(ctx context.Context) (*Stats, error)
+	}
+
 	// Service is a KVStore and governor.Service
 	Service interface {
 		governor.Service
 		KVStore
+		Statser
 	}
 
 	getClientRes struct {
-		client *redis.Client
+		client redis.UniversalClient
 		err    error
 	}
 
@@ -70,18 +203,35 @@ type (
 	}
 
 	service struct {
-		client     *redis.Client
-		auth       string
-		addr       string
-		dbname     int
-		config     governor.SecretReader
-		logger     governor.Logger
-		ops        chan getOp
-		ready      bool
-		hbfailed   int
-		hbinterval int
-		hbmaxfail  int
-		done       <-chan struct{}
+		client      redis.UniversalClient
+		auth        string
+		secret      map[string]interface{}
+		draining    redis.UniversalClient
+		drainsecret map[string]interface{}
+		drainAt     time.Time
+		backend     string
+		mode        string
+		addr        string
+		addrs       []string
+		master      string
+		dbname      int
+		config      governor.SecretReader
+		logger      governor.Logger
+		ops         chan getOp
+		ready       bool
+		hbfailed    int
+		hbinterval  int
+		hbmaxfail   int
+		statslimit  int
+		done        <-chan struct{}
+		mem         *memStore
+		memdone     <-chan struct{}
+		// nomemusage is set for servers which do not report memory usage
+		nomemusage bool
+		subtrees   map[string]struct{}
+		submu      *sync.RWMutex
+		// hashTags is set in cluster mode, where subtree keys are hash tagged
+		hashTags bool
 	}
 
 	ctxKeyRootKV struct{}
@@ -90,19 +240,28 @@ type (
 )
 
 // getCtxRootKV returns a root KVStore from the context
-func getCtxRootKV(inj governor.Injector) KVStore {
+func getCtxRootKV(inj governor.Injector) *service {
 	v := inj.Get(ctxKeyRootKV{})
 	if v == nil {
 		return nil
 	}
-	return v.(KVStore)
+	return v.(*service)
 }
 
 // setCtxRootKV sets a root KVStore in the context
-func setCtxRootKV(inj governor.Injector, k KVStore) {
+func setCtxRootKV(inj governor.Injector, k *service) {
 	inj.Set(ctxKeyRootKV{}, k)
 }
 
+// GetCtxStatser returns a Statser for the root KVStore from the context
+func GetCtxStatser(inj governor.Injector) Statser {
+	v := getCtxRootKV(inj)
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
 // GetCtxKVStore returns a KVStore from the context
 func GetCtxKVStore(inj governor.Injector) KVStore {
 	v := inj.Get(ctxKeyKVStore{})
@@ -118,8 +277,12 @@ func setCtxKVStore(inj governor.Injector, k KVStore) {
 }
 
 // NewSubtreeInCtx creates a new kv subtree with a prefix and sets it in the context
+//
+// The subtree is registered with the root KVStore and is reported in its
+// stats.
 func NewSubtreeInCtx(inj governor.Injector, prefix string) {
 	kv := getCtxRootKV(inj)
+	kv.registerSubtree(prefix)
 	setCtxKVStore(inj, kv.Subtree(prefix))
 }
 
@@ -129,18 +292,26 @@ func New() Service {
 		ops:      make(chan getOp),
 		ready:    false,
 		hbfailed: 0,
+		subtrees: map[string]struct{}{},
+		submu:    &sync.RWMutex{},
 	}
 }
 
 func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
 	setCtxRootKV(inj, s)
 
+	r.SetDefault("backend", backendRedis)
 	r.SetDefault("auth", "")
+	r.SetDefault("mode", modeStandalone)
 	r.SetDefault("dbname", 0)
 	r.SetDefault("host", "localhost")
 	r.SetDefault("port", "6379")
+	r.SetDefault("sentinel.master", "")
+	r.SetDefault("sentinel.addrs", []string{})
+	r.SetDefault("cluster.addrs", []string{})
 	r.SetDefault("hbinterval", 5)
 	r.SetDefault("hbmaxfail", 5)
+	r.SetDefault("statsscanlimit", 65536)
 }
 
 type (
@@ -152,6 +323,8 @@ type (
 	ErrNotFound struct{}
 	// ErrVal is returned for invalid value errors
 	ErrVal struct{}
+	// ErrConflict is returned when a watched key is modified
+	ErrConflict struct{}
 )
 
 func (e ErrConn) Error() string {
@@ -170,6 +343,10 @@ func (e ErrVal) Error() string {
 	return "Invalid value"
 }
 
+func (e ErrConflict) Error() string {
+	return "Watched key modified"
+}
+
 func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
 	s.logger = l
 	l = s.logger.WithData(map[string]string{
@@ -178,23 +355,71 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 
 	s.config = r
 
-	s.addr = fmt.Sprintf("%s:%s", r.GetStr("host"), r.GetStr("port"))
+	s.backend = r.GetStr("backend")
+	s.mode = r.GetStr("mode")
 	s.dbname = r.GetInt("dbname")
+	switch s.backend {
+	case backendRedis:
+	case backendMem:
+		s.mode = modeStandalone
+		s.dbname = 0
+	default:
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid kvstore backend")
+	}
+	switch s.mode {
+	case modeStandalone:
+		s.addr = fmt.Sprintf("%s:%s", r.GetStr("host"), r.GetStr("port"))
+	case modeSentinel:
+		s.master = r.GetStr("sentinel.master")
+		s.addrs = r.GetStrSlice("sentinel.addrs")
+		if s.master == "" || len(s.addrs) == 0 {
+			return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Sentinel mode requires a master name and sentinel addrs")
+		}
+		s.addr = strings.Join(s.addrs, ",")
+	case modeCluster:
+		s.addrs = r.GetStrSlice("cluster.addrs")
+		if len(s.addrs) == 0 {
+			return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Cluster mode requires cluster addrs")
+		}
+		if s.dbname != 0 {
+			return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Cluster mode only supports dbname 0")
+		}
+		s.addr = strings.Join(s.addrs, ",")
+		// subtree keys are only hash tagged in cluster mode, and are not
+		// migrated when the mode of an existing keyspace changes
+		s.hashTags = true
+	default:
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid kvstore mode")
+	}
 	s.hbinterval = r.GetInt("hbinterval")
 	s.hbmaxfail = r.GetInt("hbmaxfail")
+	s.statslimit = r.GetInt("statsscanlimit")
+
+	if s.backend == backendMem {
+		s.startMem(ctx)
+		l.Info("loaded config", map[string]string{
+			"backend":    s.backend,
+			"statslimit": strconv.Itoa(s.statslimit),
+		})
+		return nil
+	}
 
 	l.Info("loaded config", map[string]string{
+		"backend":    s.backend,
+		"mode":       s.mode,
+		"master":     s.master,
 		"addr":       s.addr,
 		"dbname":     strconv.Itoa(s.dbname),
 		"hbinterval": strconv.Itoa(s.hbinterval),
 		"hbmaxfail":  strconv.Itoa(s.hbmaxfail),
+		"statslimit": strconv.Itoa(s.statslimit),
 	})
 
 	done := make(chan struct{})
 	go s.execute(ctx, done)
 	s.done = done
 
-	if _, err := s.getClient(); err != nil {
+	if _, err := s.getClient(ctx); err != nil {
 		return err
 	}
 	return nil
@@ -222,34 +447,38 @@ func (s *service) execute(ctx context.Context, done chan<- struct{}) {
 	}
 }
 
+// handlePing checks the health of the client
+//
+// The credentials of a healthy client are also checked on each heartbeat so
+// that they are rotated before they expire.
 func (s *service) handlePing() {
+	s.handleDraining()
 	if s.client != nil {
-		_, err := s.client.Ping().Result()
-		if err == nil {
-			s.ready = true
-			s.hbfailed = 0
-			return
-		}
-		s.hbfailed++
-		if s.hbfailed < s.hbmaxfail {
-			s.logger.Warn("failed to ping kvstore", map[string]string{
+		if _, err := s.client.Ping().Result(); err != nil {
+			s.hbfailed++
+			if s.hbfailed < s.hbmaxfail {
+				s.logger.Warn("failed to ping kvstore", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "pingkv",
+					"address":    s.addr,
+					"dbname":     strconv.Itoa(s.dbname),
+				})
+				return
+			}
+			s.logger.Error("failed max pings to kvstore", map[string]string{
 				"error":      err.Error(),
-				"actiontype": "pingkv",
+				"actiontype": "pingkvmax",
 				"address":    s.addr,
 				"dbname":     strconv.Itoa(s.dbname),
 			})
-			return
+			s.ready = false
+			s.hbfailed = 0
+			s.auth = ""
+			s.config.InvalidateSecret("auth")
+		} else {
+			s.ready = true
+			s.hbfailed = 0
 		}
-		s.logger.Error("failed max pings to kvstore", map[string]string{
-			"error":      err.Error(),
-			"actiontype": "pingkvmax",
-			"address":    s.addr,
-			"dbname":     strconv.Itoa(s.dbname),
-		})
-		s.ready = false
-		s.hbfailed = 0
-		s.auth = ""
-		s.config.InvalidateSecret("auth")
 	}
 	if _, err := s.handleGetClient(); err != nil {
 		s.logger.Error("failed to create kvstore client", map[string]string{
@@ -259,7 +488,30 @@ func (s *service) handlePing() {
 	}
 }
 
-func (s *service) handleGetClient() (*redis.Client, error) {
+func (s *service) newClient(auth string) redis.UniversalClient {
+	switch s.mode {
+	case modeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    s.master,
+			SentinelAddrs: s.addrs,
+			Password:      auth,
+			DB:            s.dbname,
+		})
+	case modeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    s.addrs,
+			Password: auth,
+		})
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:     s.addr,
+			Password: auth,
+			DB:       s.dbname,
+		})
+	}
+}
+
+func (s *service) handleGetClient() (redis.UniversalClient, error) {
 	authsecret, err := s.config.GetSecret("auth")
 	if err != nil {
 		return nil, err
@@ -272,31 +524,102 @@ func (s *service) handleGetClient() (*redis.Client, error) {
 		return s.client, nil
 	}
 
-	s.closeClient()
-
-	client := redis.NewClient(&redis.Options{
-		Addr:     s.addr,
-		Password: auth,
-		DB:       s.dbname,
-	})
+	client := s.newClient(auth)
 	if _, err := client.Ping().Result(); err != nil {
+		if err := client.Close(); err != nil {
+			s.logger.Error("failed to close kvstore client", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "closekverr",
+			})
+		}
 		s.config.InvalidateSecret("auth")
+		if s.client != nil && s.ready {
+			// the previous credentials remain valid until they expire
+			s.logger.Warn("failed to rotate kvstore credentials", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "rotatekvauth",
+				"address":    s.addr,
+				"dbname":     strconv.Itoa(s.dbname),
+			})
+			return s.client, nil
+		}
 		return nil, governor.ErrWithKind(err, ErrConn{}, "Failed to ping kvstore")
 	}
 
+	if s.client != nil {
+		// commands in flight on the previous client are allowed to finish before
+		// it is closed
+		s.closeDraining()
+		s.draining = s.client
+		s.drainsecret = s.secret
+		s.drainAt = time.Now()
+	}
 	s.client = client
 	s.auth = auth
+	s.secret = authsecret
 	s.ready = true
 	s.hbfailed = 0
-	s.logger.Info(fmt.Sprintf("established connection to %s dbname %d", s.addr, s.dbname), nil)
+	s.logger.Info(fmt.Sprintf("established %s connection to %s dbname %d", s.mode, s.addr, s.dbname), nil)
 	return s.client, nil
 }
 
 func (s *service) closeClient() {
+	s.closeDraining()
 	if s.client == nil {
 		return
 	}
-	if err := s.client.Close(); err != nil {
+	s.closeRedisClient(s.client)
+	s.client = nil
+	s.auth = ""
+	s.secret = nil
+}
+
+type (
+	poolStatser interface {
+		PoolStats() *redis.PoolStats
+	}
+)
+
+// handleDraining closes the draining client after a heartbeat, once its pool
+// has no busy connections or drainTimeout has passed
+func (s *service) handleDraining() {
+	if s.draining == nil {
+		return
+	}
+	since := time.Since(s.drainAt)
+	if since < time.Duration(s.hbinterval)*time.Second {
+		return
+	}
+	if since < drainTimeout {
+		if st, ok := s.draining.(poolStatser); ok {
+			if stats := st.PoolStats(); stats.TotalConns > stats.IdleConns {
+				return
+			}
+		}
+	}
+	s.closeDraining()
+}
+
+// closeDraining closes the draining client and revokes its credentials
+func (s *service) closeDraining() {
+	if s.draining == nil {
+		return
+	}
+	s.closeRedisClient(s.draining)
+	s.draining = nil
+	if s.drainsecret != nil {
+		if err := s.config.RevokeSecret("auth", s.drainsecret); err != nil {
+			s.logger.Error("failed to revoke kvstore credentials", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "revokekvauth",
+			})
+		}
+	}
+	s.drainsecret = nil
+}
+
+func (s *service) closeRedisClient(client redis.UniversalClient) {
+	if err := client.Close(); err != nil {
 		s.logger.Error("failed to close kvstore connection", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "closekverr",
@@ -310,15 +633,13 @@ func (s *service) closeClient() {
 			"dbname":     strconv.Itoa(s.dbname),
 		})
 	}
-	s.client = nil
-	s.auth = ""
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -330,11 +651,16 @@ func (s *service) Stop(ctx context.Context) {
 	l := s.logger.WithData(map[string]string{
 		"phase": "stop",
 	})
-	select {
-	case <-s.done:
-		return
-	case <-ctx.Done():
-		l.Warn("failed to stop", nil)
+	for _, i := range []<-chan struct{}{s.done, s.memdone} {
+		if i == nil {
+			continue
+		}
+		select {
+		case <-i:
+		case <-ctx.Done():
+			l.Warn("failed to stop", nil)
+			return
+		}
 	}
 }
 
@@ -345,7 +671,19 @@ func (s *service) Health() error {
 	return nil
 }
 
-func (s *service) getClient() (*redis.Client, error) {
+// clientWithContext returns a client bound to a context
+func clientWithContext(ctx context.Context, client redis.UniversalClient) redis.UniversalClient {
+	switch c := client.(type) {
+	case *redis.ClusterClient:
+		return c.WithContext(ctx)
+	case *redis.Client:
+		return c.WithContext(ctx)
+	default:
+		return client
+	}
+}
+
+func (s *service) getClient(ctx context.Context) (redis.UniversalClient, error) {
 	res := make(chan getClientRes)
 	op := getOp{
 		res: res,
@@ -353,14 +691,23 @@ func (s *service) getClient() (*redis.Client, error) {
 	select {
 	case <-s.done:
 		return nil, governor.ErrWithKind(nil, ErrConn{}, "KVStore service shutdown")
+	case <-ctx.Done():
+		return nil, governor.ErrWithKind(ctx.Err(), ErrConn{}, "Context cancelled")
 	case s.ops <- op:
 		v := <-res
-		return v.client, v.err
+		if v.err != nil {
+			return nil, v.err
+		}
+		return clientWithContext(ctx, v.client), nil
 	}
 }
 
-func (s *service) Get(key string) (string, error) {
-	client, err := s.getClient()
+func (s *service) Get(ctx context.Context, key string) (string, error) {
+	if s.mem != nil {
+		return s.mem.Get(ctx, key)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -374,8 +721,12 @@ func (s *service) Get(key string) (string, error) {
 	return val, nil
 }
 
-func (s *service) GetInt(key string) (int64, error) {
-	client, err := s.getClient()
+func (s *service) GetInt(ctx context.Context, key string) (int64, error) {
+	if s.mem != nil {
+		return s.mem.GetInt(ctx, key)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -393,8 +744,12 @@ func (s *service) GetInt(key string) (int64, error) {
 	return num, nil
 }
 
-func (s *service) Set(key, val string, seconds int64) error {
-	client, err := s.getClient()
+func (s *service) Set(ctx context.Context, key, val string, seconds int64) error {
+	if s.mem != nil {
+		return s.mem.Set(ctx, key, val, seconds)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -404,12 +759,69 @@ func (s *service) Set(key, val string, seconds int64) error {
 	return nil
 }
 
-func (s *service) Del(key ...string) error {
+// SetNX sets a key only if it does not already exist
+//
+// SetNX returns true if the key was set.
+func (s *service) SetNX(ctx context.Context, key, val string, seconds int64) (bool, error) {
+	if s.mem != nil {
+		return s.mem.SetNX(ctx, key, val, seconds)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	ok, err := client.SetNX(key, val, time.Duration(seconds)*time.Second).Result()
+	if err != nil {
+		return false, governor.ErrWithKind(err, ErrClient{}, "Failed to set key")
+	}
+	return ok, nil
+}
+
+var (
+	compareAndSetScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+if tonumber(ARGV[3]) > 0 then
+  redis.call("SET", KEYS[1], ARGV[2], "EX", ARGV[3])
+else
+  redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+)
+
+// CompareAndSet sets a key to val only if its current value is old
+//
+// The comparison is performed atomically on the server. A key that does not
+// exist never matches old. CompareAndSet returns true if the key was set.
+func (s *service) CompareAndSet(ctx context.Context, key, old, val string, seconds int64) (bool, error) {
+	if s.mem != nil {
+		return s.mem.CompareAndSet(ctx, key, old, val, seconds)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	v, err := compareAndSetScript.Run(client, []string{key}, old, val, seconds).Int64()
+	if err != nil {
+		return false, governor.ErrWithKind(err, ErrClient{}, "Failed to compare and set key")
+	}
+	return v == 1, nil
+}
+
+func (s *service) Del(ctx context.Context, key ...string) error {
+	if s.mem != nil {
+		return s.mem.Del(ctx, key...)
+	}
+
 	if len(key) == 0 {
 		return nil
 	}
 
-	client, err := s.getClient()
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -420,8 +832,12 @@ func (s *service) Del(key ...string) error {
 	return nil
 }
 
-func (s *service) Incr(key string, delta int64) (int64, error) {
-	client, err := s.getClient()
+func (s *service) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if s.mem != nil {
+		return s.mem.Incr(ctx, key, delta)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -432,8 +848,12 @@ func (s *service) Incr(key string, delta int64) (int64, error) {
 	return val, nil
 }
 
-func (s *service) Expire(key string, seconds int64) error {
-	client, err := s.getClient()
+func (s *service) Expire(ctx context.Context, key string, seconds int64) error {
+	if s.mem != nil {
+		return s.mem.Expire(ctx, key, seconds)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -443,6 +863,24 @@ func (s *service) Expire(key string, seconds int64) error {
 	return nil
 }
 
+// TTL returns the remaining time to live of a key in seconds
+//
+// The remaining time is rounded down, and is -1 if the key does not expire.
+func (s *service) TTL(ctx context.Context, key string) (int64, error) {
+	if s.mem != nil {
+		return s.mem.TTL(ctx, key)
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	r := &ttlResulter{
+		res: client.PTTL(key),
+	}
+	return r.Result()
+}
+
 func (s *service) Subkey(keypath ...string) string {
 	if len(keypath) == 0 {
 		return ""
@@ -450,26 +888,108 @@ func (s *service) Subkey(keypath ...string) string {
 	return strings.Join(keypath, kvpathSeparator)
 }
 
-func (s *service) Multi() (Multi, error) {
-	client, err := s.getClient()
+func (s *service) Multi(ctx context.Context) (Multi, error) {
+	if s.mem != nil {
+		return s.mem.Multi(ctx)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return &baseMulti{
 		base: client.Pipeline(),
+		keys: txKeys{
+			hashTags: s.hashTags,
+		},
 	}, nil
 }
 
-func (s *service) Tx() (Multi, error) {
-	client, err := s.getClient()
+// Tx returns a Multi whose commands are executed atomically
+//
+// All keys of a Tx must be directly in the same subtree, so that they map to
+// the same slot in cluster mode. Exec fails otherwise.
+func (s *service) Tx(ctx context.Context) (Multi, error) {
+	if s.mem != nil {
+		return s.mem.Tx(ctx)
+	}
+
+	client, err := s.getClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return &baseMulti{
 		base: client.TxPipeline(),
+		keys: txKeys{
+			tx:       true,
+			hashTags: s.hashTags,
+		},
 	}, nil
 }
 
+// Watch runs fn in an optimistic transaction over keys
+//
+// Commands queued on the Watcher's Tx are executed only if none of keys were
+// modified since the watch began, otherwise fn is retried. ErrConflict is
+// returned if the watch keeps conflicting. In cluster mode all keys must
+// belong to the same slot, e.g. by being in the same subtree.
+func (s *service) Watch(ctx context.Context, keys []string, fn func(tx Watcher) error) error {
+	if s.mem != nil {
+		return s.mem.Watch(ctx, keys, fn)
+	}
+
+	if len(keys) == 0 {
+		return governor.ErrWithKind(nil, ErrVal{}, "Watch requires at least one key")
+	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	var fnerr error
+	txfn := func(tx *redis.Tx) error {
+		w := &baseWatcher{
+			tx: tx,
+			multi: &baseMulti{
+				base: tx.TxPipeline(),
+				keys: txKeys{
+					tx:       true,
+					hashTags: s.hashTags,
+				},
+			},
+		}
+		if err := fn(w); err != nil {
+			fnerr = err
+			return err
+		}
+		if w.multi.keys.err != nil {
+			fnerr = w.multi.keys.err
+			return w.multi.keys.err
+		}
+		if _, err := w.multi.base.ExecContext(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		return nil
+	}
+	for i := 0; i < watchMaxRetries; i++ {
+		err := client.Watch(txfn, keys...)
+		if err == nil {
+			return nil
+		}
+		if fnerr != nil {
+			return fnerr
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to execute watch")
+		}
+		select {
+		case <-ctx.Done():
+			return governor.ErrWithKind(ctx.Err(), ErrConflict{}, "Context cancelled")
+		default:
+		}
+	}
+	return governor.ErrWithKind(nil, ErrConflict{}, "Watched keys modified")
+}
+
 func (s *service) Subtree(prefix string) KVStore {
 	return &tree{
 		prefix: prefix,
@@ -478,46 +998,165 @@ func (s *service) Subtree(prefix string) KVStore {
 }
 
 type (
+	baseWatcher struct {
+		tx    *redis.Tx
+		multi *baseMulti
+	}
+
+	watcher struct {
+		prefix   string
+		hashTags bool
+		base     Watcher
+	}
+)
+
+func (w *baseWatcher) Get(ctx context.Context, key string) (string, error) {
+	val, err := w.tx.Get(key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", governor.ErrWithKind(err, ErrNotFound{}, "Key not found")
+		}
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	return val, nil
+}
+
+func (w *baseWatcher) GetInt(ctx context.Context, key string) (int64, error) {
+	val, err := w.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	num, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrVal{}, "Invalid int value")
+	}
+	return num, nil
+}
+
+func (w *baseWatcher) Tx() Multi {
+	return w.multi
+}
+
+func (w *baseWatcher) Subkey(keypath ...string) string {
+	if len(keypath) == 0 {
+		return ""
+	}
+	return strings.Join(keypath, kvpathSeparator)
+}
+
+func (w *baseWatcher) Subtree(prefix string) Watcher {
+	return &watcher{
+		prefix:   prefix,
+		hashTags: w.multi.keys.hashTags,
+		base:     w,
+	}
+}
+
+func (w *watcher) key(key string) string {
+	return subtreeKey(w.hashTags, w.prefix, key)
+}
+
+func (w *watcher) Get(ctx context.Context, key string) (string, error) {
+	return w.base.Get(ctx, w.key(key))
+}
+
+func (w *watcher) GetInt(ctx context.Context, key string) (int64, error) {
+	return w.base.GetInt(ctx, w.key(key))
+}
+
+func (w *watcher) Tx() Multi {
+	return w.base.Tx().Subtree(w.prefix)
+}
+
+func (w *watcher) Subkey(keypath ...string) string {
+	if len(keypath) == 0 {
+		return ""
+	}
+	return strings.Join(keypath, kvpathSeparator)
+}
+
+func (w *watcher) Subtree(prefix string) Watcher {
+	return &watcher{
+		prefix:   w.prefix + kvpathSeparator + prefix,
+		hashTags: w.hashTags,
+		base:     w.base,
+	}
+}
+
+type (
+	// baseMulti queues commands in a pipeline
 	baseMulti struct {
 		base redis.Pipeliner
+		keys txKeys
+	}
+
+	// txKeys checks the keys of a tx
+	//
+	// The commands of a tx are executed atomically, and in cluster mode must
+	// all be on the same slot. Since each subtree is its own hash tag in cluster
+	// mode, a tx may only have keys directly in a single subtree, and a tx with
+	// keys of different subtrees fails on Exec regardless of mode or backend.
+	// Keys outside of a subtree are only checked in cluster mode, where they
+	// must share a hash tag.
+	txKeys struct {
+		tx       bool
+		hashTags bool
+		tag      string
+		err      error
 	}
 
 	multi struct {
 		prefix string
-		base   *baseMulti
+		base   Multi
+		keys   *txKeys
 	}
 )
 
 func (t *baseMulti) Get(key string) Resulter {
+	t.keys.check(key)
 	return &resulter{
 		res: t.base.Get(key),
 	}
 }
 
 func (t *baseMulti) GetInt(key string) IntResulter {
+	t.keys.check(key)
 	return &intResulter{
 		res: t.base.Get(key),
 	}
 }
 
 func (t *baseMulti) Set(key, val string, seconds int64) {
+	t.keys.check(key)
 	t.base.Set(key, val, time.Duration(seconds)*time.Second)
 }
 
 func (t *baseMulti) Del(key ...string) {
+	for _, i := range key {
+		t.keys.check(i)
+	}
 	t.base.Del(key...)
 }
 
 func (t *baseMulti) Incr(key string, delta int64) IntResulter {
+	t.keys.check(key)
 	return &intCmdResulter{
 		res: t.base.IncrBy(key, delta),
 	}
 }
 
 func (t *baseMulti) Expire(key string, seconds int64) {
+	t.keys.check(key)
 	t.base.Expire(key, time.Duration(seconds)*time.Second)
 }
 
+func (t *baseMulti) TTL(key string) IntResulter {
+	t.keys.check(key)
+	return &ttlResulter{
+		res: t.base.PTTL(key),
+	}
+}
+
 func (t *baseMulti) Subkey(keypath ...string) string {
 	if len(keypath) == 0 {
 		return ""
@@ -529,11 +1168,44 @@ func (t *baseMulti) Subtree(prefix string) Multi {
 	return &multi{
 		prefix: prefix,
 		base:   t,
+		keys:   &t.keys,
+	}
+}
+
+// check records an error if a key of a tx is in a different slot from the
+// previous keys of the tx in cluster mode
+func (k *txKeys) check(key string) {
+	if !k.hashTags {
+		return
+	}
+	k.checkTag(hashTag(key))
+}
+
+// checkSubtree records an error if a subtree of a tx is different from that
+// of the previous keys of the tx
+func (k *txKeys) checkSubtree(prefix string) {
+	k.checkTag(prefix)
+}
+
+func (k *txKeys) checkTag(tag string) {
+	if !k.tx || k.err != nil {
+		return
+	}
+	if k.tag == "" {
+		k.tag = tag
+		return
+	}
+	if tag != k.tag {
+		k.err = governor.ErrWithKind(nil, ErrVal{}, "Tx keys must be in the same subtree")
 	}
 }
 
-func (t *baseMulti) Exec() error {
-	if _, err := t.base.Exec(); err != nil {
+func (t *baseMulti) Exec(ctx context.Context) error {
+	if t.keys.err != nil {
+		t.base.Discard()
+		return t.keys.err
+	}
+	if _, err := t.base.ExecContext(ctx); err != nil {
 		if !errors.Is(err, redis.Nil) {
 			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to execute multi")
 		}
@@ -541,36 +1213,45 @@ func (t *baseMulti) Exec() error {
 	return nil
 }
 
+func (t *multi) key(key string) string {
+	t.keys.checkSubtree(t.prefix)
+	return subtreeKey(t.keys.hashTags, t.prefix, key)
+}
+
 func (t *multi) Get(key string) Resulter {
-	return t.base.Get(t.prefix + kvpathSeparator + key)
+	return t.base.Get(t.key(key))
 }
 
 func (t *multi) GetInt(key string) IntResulter {
-	return t.base.GetInt(t.prefix + kvpathSeparator + key)
+	return t.base.GetInt(t.key(key))
 }
 
 func (t *multi) Set(key, val string, seconds int64) {
-	t.base.Set(t.prefix+kvpathSeparator+key, val, seconds)
+	t.base.Set(t.key(key), val, seconds)
 }
 
 func (t *multi) Del(key ...string) {
 	args := make([]string, 0, len(key))
 	for _, i := range key {
-		args = append(args, t.prefix+kvpathSeparator+i)
+		args = append(args, t.key(i))
 	}
 	t.base.Del(args...)
 }
 
 func (t *multi) Incr(key string, delta int64) IntResulter {
-	return t.base.Incr(t.prefix+kvpathSeparator+key, delta)
+	return t.base.Incr(t.key(key), delta)
 }
 
 func (t *multi) Expire(key string, seconds int64) {
-	t.base.Expire(key, seconds)
+	t.base.Expire(t.key(key), seconds)
+}
+
+func (t *multi) TTL(key string) IntResulter {
+	return t.base.TTL(t.key(key))
 }
 
-func (t *multi) Exec() error {
-	return t.base.Exec()
+func (t *multi) Exec(ctx context.Context) error {
+	return t.base.Exec(ctx)
 }
 
 func (t *multi) Subkey(keypath ...string) string {
@@ -584,6 +1265,7 @@ func (t *multi) Subtree(prefix string) Multi {
 	return &multi{
 		prefix: t.prefix + kvpathSeparator + prefix,
 		base:   t.base,
+		keys:   t.keys,
 	}
 }
 
@@ -594,32 +1276,71 @@ type (
 	}
 )
 
-func (t *tree) Get(key string) (string, error) {
-	return t.base.Get(t.prefix + kvpathSeparator + key)
+// subtreeKey returns the key of an entry in a subtree
+//
+// In cluster mode the subtree prefix is wrapped in a hash tag so that all keys
+// directly in a subtree, such as those used together in a Multi or Tx, map to
+// the same slot. Other modes and the mem backend have a single keyspace, and
+// keep the plain prefix, so that their existing keys remain in place.
+func subtreeKey(hashTags bool, prefix, key string) string {
+	if hashTags {
+		return "{" + prefix + "}" + kvpathSeparator + key
+	}
+	return prefix + kvpathSeparator + key
+}
+
+// hashTag returns the part of a key that determines its cluster slot
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+func (t *tree) key(key string) string {
+	return subtreeKey(t.base.hashTags, t.prefix, key)
+}
+
+func (t *tree) Get(ctx context.Context, key string) (string, error) {
+	return t.base.Get(ctx, t.key(key))
+}
+
+func (t *tree) GetInt(ctx context.Context, key string) (int64, error) {
+	return t.base.GetInt(ctx, t.key(key))
 }
 
-func (t *tree) GetInt(key string) (int64, error) {
-	return t.base.GetInt(t.prefix + kvpathSeparator + key)
+func (t *tree) Set(ctx context.Context, key, val string, seconds int64) error {
+	return t.base.Set(ctx, t.key(key), val, seconds)
 }
 
-func (t *tree) Set(key, val string, seconds int64) error {
-	return t.base.Set(t.prefix+kvpathSeparator+key, val, seconds)
+func (t *tree) SetNX(ctx context.Context, key, val string, seconds int64) (bool, error) {
+	return t.base.SetNX(ctx, t.key(key), val, seconds)
 }
 
-func (t *tree) Del(key ...string) error {
+func (t *tree) CompareAndSet(ctx context.Context, key, old, val string, seconds int64) (bool, error) {
+	return t.base.CompareAndSet(ctx, t.key(key), old, val, seconds)
+}
+
+func (t *tree) Del(ctx context.Context, key ...string) error {
 	args := make([]string, 0, len(key))
 	for _, i := range key {
-		args = append(args, t.prefix+kvpathSeparator+i)
+		args = append(args, t.key(i))
 	}
-	return t.base.Del(args...)
+	return t.base.Del(ctx, args...)
 }
 
-func (t *tree) Incr(key string, delta int64) (int64, error) {
-	return t.base.Incr(t.prefix+kvpathSeparator+key, delta)
+func (t *tree) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return t.base.Incr(ctx, t.key(key), delta)
 }
 
-func (t *tree) Expire(key string, seconds int64) error {
-	return t.base.Expire(t.prefix+kvpathSeparator+key, seconds)
+func (t *tree) Expire(ctx context.Context, key string, seconds int64) error {
+	return t.base.Expire(ctx, t.key(key), seconds)
+}
+
+func (t *tree) TTL(ctx context.Context, key string) (int64, error) {
+	return t.base.TTL(ctx, t.key(key))
 }
 
 func (t *tree) Subkey(keypath ...string) string {
@@ -629,22 +1350,32 @@ func (t *tree) Subkey(keypath ...string) string {
 	return strings.Join(keypath, kvpathSeparator)
 }
 
-func (t *tree) Multi() (Multi, error) {
-	tx, err := t.base.Multi()
+func (t *tree) Multi(ctx context.Context) (Multi, error) {
+	tx, err := t.base.Multi(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return tx.Subtree(t.prefix), nil
 }
 
-func (t *tree) Tx() (Multi, error) {
-	tx, err := t.base.Tx()
+func (t *tree) Tx(ctx context.Context) (Multi, error) {
+	tx, err := t.base.Tx(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return tx.Subtree(t.prefix), nil
 }
 
+func (t *tree) Watch(ctx context.Context, keys []string, fn func(tx Watcher) error) error {
+	args := make([]string, 0, len(keys))
+	for _, i := range keys {
+		args = append(args, t.key(i))
+	}
+	return t.base.Watch(ctx, args, func(tx Watcher) error {
+		return fn(tx.Subtree(t.prefix))
+	})
+}
+
 func (t *tree) Subtree(prefix string) KVStore {
 	return &tree{
 		prefix: t.prefix + kvpathSeparator + prefix,
@@ -706,3 +1437,24 @@ func (r *intResulter) Result() (int64, error) {
 	}
 	return num, nil
 }
+
+type (
+	ttlResulter struct {
+		res *redis.DurationCmd
+	}
+)
+
+func (r *ttlResulter) Result() (int64, error) {
+	val, err := r.res.Result()
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to get key ttl")
+	}
+	// the reply of a key without a ttl is not scaled by the command precision
+	switch val {
+	case -2:
+		return 0, governor.ErrWithKind(nil, ErrNotFound{}, "Key not found")
+	case -1:
+		return -1, nil
+	}
+	return int64(val / time.Second), nil
+}
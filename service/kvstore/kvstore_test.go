@@ -0,0 +1,322 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKV returns a kvstore service backed by an in process redis server
+func newTestKV(t *testing.T) *service {
+	t.Helper()
+
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: m.Addr(),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s := New().(*service)
+	// the test server does not report memory usage
+	s.nomemusage = true
+	s.done = done
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case op := <-s.ops:
+				op.res <- getClientRes{
+					client: client,
+				}
+				close(op.res)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		if err := client.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+// newTestClusterKeysKV returns a kvstore service backed by an in process redis
+// server, with the hash tagged keys of cluster mode
+func newTestClusterKeysKV(t *testing.T) *service {
+	t.Helper()
+
+	s := newTestKV(t)
+	s.hashTags = true
+	return s
+}
+
+// newTestMemKV returns a kvstore service with the mem backend, whose clock is
+// stopped so that ttls do not elapse
+func newTestMemKV(t *testing.T) *service {
+	t.Helper()
+
+	s := New().(*service)
+	s.backend = backendMem
+	s.mem = newMemStore()
+	now := time.Now().Round(0)
+	s.mem.clock = func() time.Time {
+		return now
+	}
+	s.ready = true
+	return s
+}
+
+var (
+	testBackends = []struct {
+		Name string
+		New  func(t *testing.T) *service
+	}{
+		{
+			Name: "redis",
+			New:  newTestKV,
+		},
+		{
+			Name: "redis cluster keys",
+			New:  newTestClusterKeysKV,
+		},
+		{
+			Name: "mem",
+			New:  newTestMemKV,
+		},
+	}
+)
+
+func TestDatatypes(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range testBackends {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			ctx := context.Background()
+			kv := tc.New(t).Subtree("test")
+
+			assert.NoError(kv.HSet(ctx, "hash", map[string]string{
+				"a": "1",
+				"b": "2",
+			}))
+			v, err := kv.HGet(ctx, "hash", "a")
+			assert.NoError(err)
+			assert.Equal("1", v)
+			assert.NoError(kv.HDel(ctx, "hash", "a"))
+			_, err = kv.HGet(ctx, "hash", "a")
+			assert.True(errors.Is(err, ErrNotFound{}))
+			fields, err := kv.HGetAll(ctx, "hash")
+			assert.NoError(err)
+			assert.Equal(map[string]string{"b": "2"}, fields)
+
+			assert.NoError(kv.SAdd(ctx, "set", "a", "b", "c"))
+			assert.NoError(kv.SRem(ctx, "set", "b"))
+			ok, err := kv.SIsMember(ctx, "set", "a")
+			assert.NoError(err)
+			assert.True(ok)
+			ok, err = kv.SIsMember(ctx, "set", "b")
+			assert.NoError(err)
+			assert.False(ok)
+			members, err := kv.SMembers(ctx, "set")
+			assert.NoError(err)
+			sort.Strings(members)
+			assert.Equal([]string{"a", "c"}, members)
+
+			assert.NoError(kv.ZAdd(ctx, "zset", ZMember{Score: 1, Member: "a"}, ZMember{Score: 2, Member: "b"}, ZMember{Score: 3, Member: "c"}))
+			zmembers, err := kv.ZRangeByScore(ctx, "zset", 2, math.Inf(1), 0, 0)
+			assert.NoError(err)
+			assert.Equal([]ZMember{{Score: 2, Member: "b"}, {Score: 3, Member: "c"}}, zmembers)
+			zmembers, err = kv.ZRangeByScore(ctx, "zset", math.Inf(-1), math.Inf(1), 1, 1)
+			assert.NoError(err)
+			assert.Equal([]ZMember{{Score: 2, Member: "b"}}, zmembers)
+			score, err := kv.ZIncrBy(ctx, "zset", 5, "a")
+			assert.NoError(err)
+			assert.Equal(float64(6), score)
+			rank, err := kv.ZRank(ctx, "zset", "a")
+			assert.NoError(err)
+			assert.Equal(int64(2), rank)
+			_, err = kv.ZRank(ctx, "zset", "bogus")
+			assert.True(errors.Is(err, ErrNotFound{}))
+
+			assert.NoError(kv.LPush(ctx, "list", "a", "b", "c"))
+			vals, err := kv.LRange(ctx, "list", 0, -1)
+			assert.NoError(err)
+			assert.Equal([]string{"c", "b", "a"}, vals)
+			v, err = kv.RPop(ctx, "list")
+			assert.NoError(err)
+			assert.Equal("a", v)
+			assert.NoError(kv.LTrim(ctx, "list", 0, 0))
+			vals, err = kv.LRange(ctx, "list", 0, -1)
+			assert.NoError(err)
+			assert.Equal([]string{"c"}, vals)
+
+			m, err := kv.Multi(ctx)
+			assert.NoError(err)
+			m.HSet("mhash", map[string]string{"a": "1"})
+			hres := m.HGetAll("mhash")
+			m.SAdd("mset", "a")
+			sres := m.SIsMember("mset", "a")
+			lres := m.LRange("list", 0, -1)
+			assert.NoError(m.Exec(ctx))
+			fields, err = hres.Result()
+			assert.NoError(err)
+			assert.Equal(map[string]string{"a": "1"}, fields)
+			ok, err = sres.Result()
+			assert.NoError(err)
+			assert.True(ok)
+			vals, err = lres.Result()
+			assert.NoError(err)
+			assert.Equal([]string{"c"}, vals)
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range testBackends {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			ctx := context.Background()
+			kv := tc.New(t).Subtree("test")
+
+			assert.NoError(kv.Set(ctx, "a", "1", 30))
+			assert.NoError(kv.Set(ctx, "b", "1", 0))
+			ttl, err := kv.TTL(ctx, "a")
+			assert.NoError(err)
+			assert.Equal(int64(30), ttl)
+			ttl, err = kv.TTL(ctx, "b")
+			assert.NoError(err)
+			assert.Equal(int64(-1), ttl)
+			_, err = kv.TTL(ctx, "c")
+			assert.True(errors.Is(err, ErrNotFound{}))
+
+			m, err := kv.Multi(ctx)
+			assert.NoError(err)
+			ares := m.TTL("a")
+			cres := m.TTL("c")
+			assert.NoError(m.Exec(ctx))
+			ttl, err = ares.Result()
+			assert.NoError(err)
+			assert.Equal(int64(30), ttl)
+			_, err = cres.Result()
+			assert.True(errors.Is(err, ErrNotFound{}))
+		})
+	}
+}
+
+func TestSubtreeKeys(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	assert.Equal("test:a", subtreeKey(false, "test", "a"), "Should keep the plain prefix outside of cluster mode")
+	assert.Equal("{test}:a", subtreeKey(true, "test", "a"))
+	assert.Equal("test", hashTag(subtreeKey(true, "test", "a")))
+	assert.Equal("test:nested", hashTag(subtreeKey(true, "test:nested", "a:b")))
+	assert.Equal("a", hashTag("a"))
+	assert.Equal("{}a", hashTag("{}a"), "Should use the whole key for an empty hash tag")
+
+	for _, tc := range testBackends {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			ctx := context.Background()
+			kv := tc.New(t).Subtree("test")
+
+			tx, err := kv.Tx(ctx)
+			assert.NoError(err)
+			tx.Set("a", "1", 0)
+			tx.Set("b", "1", 0)
+			assert.NoError(tx.Exec(ctx))
+			v, err := kv.Get(ctx, "b")
+			assert.NoError(err)
+			assert.Equal("1", v)
+
+			tx, err = kv.Tx(ctx)
+			assert.NoError(err)
+			tx.Set("c", "1", 0)
+			tx.Subtree("nested").Set("d", "1", 0)
+			err = tx.Exec(ctx)
+			assert.True(errors.Is(err, ErrVal{}), "Should reject a tx with keys in different subtrees")
+			_, err = kv.Get(ctx, "c")
+			assert.True(errors.Is(err, ErrNotFound{}))
+
+			m, err := kv.Multi(ctx)
+			assert.NoError(err)
+			m.Set("c", "1", 0)
+			m.Subtree("nested").Set("d", "1", 0)
+			assert.NoError(m.Exec(ctx), "Should allow a multi with keys in different subtrees")
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range testBackends {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			ctx := context.Background()
+			kv := tc.New(t).Subtree("test")
+
+			assert.NoError(kv.Set(ctx, "a", "1", 0))
+			attempts := 0
+			assert.NoError(kv.Watch(ctx, []string{"a"}, func(tx Watcher) error {
+				attempts++
+				v, err := tx.GetInt(ctx, "a")
+				if err != nil {
+					return err
+				}
+				if attempts == 1 {
+					// modify the watched key to conflict with the first attempt
+					if _, err := kv.Incr(ctx, "a", 1); err != nil {
+						return err
+					}
+				}
+				tx.Tx().Set("a", strconv.FormatInt(v*10, 10), 0)
+				return nil
+			}))
+			assert.Equal(2, attempts, "Should retry a conflicting watch")
+			v, err := kv.GetInt(ctx, "a")
+			assert.NoError(err)
+			assert.Equal(int64(20), v)
+
+			err = kv.Watch(ctx, []string{"a"}, func(tx Watcher) error {
+				tx.Tx().Set("b", "1", 0)
+				return errors.New("test error")
+			})
+			assert.Error(err)
+			assert.False(errors.Is(err, ErrConflict{}))
+			_, err = kv.Get(ctx, "b")
+			assert.True(errors.Is(err, ErrNotFound{}), "Should not execute the tx of a failed watch")
+		})
+	}
+}
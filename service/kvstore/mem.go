@@ -0,0 +1,1386 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xorkevin.dev/governor"
+)
+
+const (
+	// memSweepInterval is how often expired keys of the mem backend are
+	// removed
+	memSweepInterval = time.Minute
+)
+
+const (
+	memKindStr = iota
+	memKindHash
+	memKindSet
+	memKindZSet
+	memKindList
+)
+
+var (
+	// errMemWrongKind is returned for a command on a key holding a different
+	// kind of value
+	errMemWrongKind = errors.New("Key holds the wrong kind of value")
+)
+
+type (
+	// memStore is the in memory kvstore of the mem backend
+	//
+	// Each command, and each multi and tx as a whole, runs under a single lock
+	// and is atomic. Expired keys are removed when they are accessed, and by a
+	// periodic sweep. Keys are not persisted and are lost when the process
+	// exits. Values are never evicted, so keys which are not bounded in number
+	// should be set with a ttl.
+	memStore struct {
+		mu      *sync.Mutex
+		entries map[string]*memEntry
+		clock   func() time.Time
+	}
+
+	// memEntry is the value of a key
+	//
+	// Each write to an entry increments its version, and an entry which is
+	// replaced or deleted is never reused, so that a watch detects a modified
+	// key by its entry and version.
+	memEntry struct {
+		kind    int
+		str     string
+		hash    map[string]string
+		set     map[string]struct{}
+		zset    map[string]float64
+		list    []string
+		expires time.Time
+		version uint64
+	}
+
+	// memVersion is the state of a watched key when the watch began
+	memVersion struct {
+		entry   *memEntry
+		version uint64
+	}
+)
+
+func newMemStore() *memStore {
+	return &memStore{
+		mu:      &sync.Mutex{},
+		entries: map[string]*memEntry{},
+		clock:   time.Now,
+	}
+}
+
+// startMem starts the in memory kvstore of the mem backend
+//
+// The mem backend does not connect to a server, and is a single node with a
+// single db.
+func (s *service) startMem(ctx context.Context) {
+	s.mem = newMemStore()
+	s.ready = true
+	done := make(chan struct{})
+	go s.memSweep(ctx, done)
+	s.memdone = done
+}
+
+// memSweep periodically removes expired keys of the mem backend
+func (s *service) memSweep(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(memSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if count := s.mem.sweep(); count > 0 {
+				s.logger.Debug("removed expired keys", map[string]string{
+					"actiontype": "sweepmemkv",
+					"count":      strconv.Itoa(count),
+				})
+			}
+		}
+	}
+}
+
+func (e *memEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (e *memEntry) empty() bool {
+	switch e.kind {
+	case memKindHash:
+		return len(e.hash) == 0
+	case memKindSet:
+		return len(e.set) == 0
+	case memKindZSet:
+		return len(e.zset) == 0
+	case memKindList:
+		return len(e.list) == 0
+	default:
+		return false
+	}
+}
+
+func memExpires(now time.Time, seconds int64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(seconds) * time.Second)
+}
+
+// lookup returns the entry of a key, or nil if the key does not exist
+func (m *memStore) lookup(key string, now time.Time) *memEntry {
+	e, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	if e.expired(now) {
+		delete(m.entries, key)
+		return nil
+	}
+	return e
+}
+
+// lookupKind returns the entry of a key which holds a kind of value, or nil if
+// the key does not exist
+func (m *memStore) lookupKind(key string, kind int, now time.Time) (*memEntry, error) {
+	e := m.lookup(key, now)
+	if e == nil {
+		return nil, nil
+	}
+	if e.kind != kind {
+		return nil, errMemWrongKind
+	}
+	return e, nil
+}
+
+// lookupOrCreate returns the entry of a key which holds a kind of value, and
+// creates it if the key does not exist
+func (m *memStore) lookupOrCreate(key string, kind int, now time.Time) (*memEntry, error) {
+	e, err := m.lookupKind(key, kind, now)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		e = m.create(key, kind)
+	}
+	return e, nil
+}
+
+// create sets a new empty entry for a key
+func (m *memStore) create(key string, kind int) *memEntry {
+	e := &memEntry{
+		kind: kind,
+	}
+	switch kind {
+	case memKindHash:
+		e.hash = map[string]string{}
+	case memKindSet:
+		e.set = map[string]struct{}{}
+	case memKindZSet:
+		e.zset = map[string]float64{}
+	}
+	m.entries[key] = e
+	return e
+}
+
+// touch records a write to an entry, and deletes it if it is an empty
+// collection
+func (m *memStore) touch(key string, e *memEntry) {
+	e.version++
+	if e.empty() {
+		delete(m.entries, key)
+	}
+}
+
+// do runs fn under the lock
+func (m *memStore) do(fn func(now time.Time)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(m.clock())
+}
+
+// sweep removes all expired keys and returns the number of removed keys
+func (m *memStore) sweep() int {
+	count := 0
+	m.do(func(now time.Time) {
+		for k, v := range m.entries {
+			if v.expired(now) {
+				delete(m.entries, k)
+				count++
+			}
+		}
+	})
+	return count
+}
+
+// matchKeys returns the sorted keys matching a glob pattern
+func (m *memStore) matchKeys(match string, now time.Time) []string {
+	var keys []string
+	for k, v := range m.entries {
+		if v.expired(now) {
+			delete(m.entries, k)
+			continue
+		}
+		if globMatch(match, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type (
+	// memScanIterator iterates over the keys matched when the scan began
+	memScanIterator struct {
+		keys   []string
+		key    string
+		prefix string
+	}
+)
+
+func (i *memScanIterator) Next() bool {
+	if len(i.keys) == 0 {
+		return false
+	}
+	i.key = i.keys[0]
+	i.keys = i.keys[1:]
+	return true
+}
+
+func (i *memScanIterator) Key() string {
+	return strings.TrimPrefix(i.key, i.prefix)
+}
+
+func (i *memScanIterator) Err() error {
+	return nil
+}
+
+// scan returns an iterator over keys matching a pattern, with prefix trimmed
+// from each returned key
+func (m *memStore) scan(match string, prefix string) ScanIterator {
+	var keys []string
+	m.do(func(now time.Time) {
+		keys = m.matchKeys(match, now)
+	})
+	return &memScanIterator{
+		keys:   keys,
+		prefix: prefix,
+	}
+}
+
+// delPattern deletes all keys matching a pattern
+func (m *memStore) delPattern(match string) {
+	m.do(func(now time.Time) {
+		m.del(m.matchKeys(match, now)...)
+	})
+}
+
+// stats returns the total key count and the stats of subtrees
+//
+// The mem backend does not report memory usage. Each subtree is counted up to
+// limit keys, and a limit less than 1 disables the limit.
+func (m *memStore) stats(prefixes []string, limit int) *Stats {
+	matches := make([]string, 0, len(prefixes))
+	subtrees := make([]SubtreeStats, 0, len(prefixes))
+	for _, i := range prefixes {
+		matches = append(matches, subtreePattern(false, i))
+		subtrees = append(subtrees, SubtreeStats{
+			Prefix: i,
+		})
+	}
+	var total int64
+	m.do(func(now time.Time) {
+		for k, v := range m.entries {
+			if v.expired(now) {
+				delete(m.entries, k)
+				continue
+			}
+			total++
+			for n, i := range matches {
+				if !globMatch(i, k) {
+					continue
+				}
+				if limit > 0 && subtrees[n].Keys >= int64(limit) {
+					subtrees[n].Truncated = true
+					continue
+				}
+				subtrees[n].Keys++
+			}
+		}
+	})
+	return &Stats{
+		Keys:     total,
+		Subtrees: subtrees,
+	}
+}
+
+// globMatch returns whether s matches a glob pattern with the same syntax as
+// the redis scan match pattern
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) > 1 {
+					pattern = pattern[1:]
+					if pattern[0] == s[0] {
+						match = true
+					}
+				} else if len(pattern) > 2 && pattern[1] == '-' {
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					if s[0] >= start && s[0] <= end {
+						match = true
+					}
+					pattern = pattern[2:]
+				} else if pattern[0] == s[0] {
+					match = true
+				}
+				pattern = pattern[1:]
+			}
+			if match == not {
+				return false
+			}
+			s = s[1:]
+			if len(pattern) == 0 {
+				// an unterminated class ends the pattern
+				return len(s) == 0
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		pattern = pattern[1:]
+	}
+	return len(s) == 0
+}
+
+func (m *memStore) get(key string, now time.Time) (string, error) {
+	e, err := m.lookupKind(key, memKindStr, now)
+	if err != nil {
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to get key")
+	}
+	if e == nil {
+		return "", governor.ErrWithKind(nil, ErrNotFound{}, "Key not found")
+	}
+	return e.str, nil
+}
+
+func (m *memStore) getInt(key string, now time.Time) (int64, error) {
+	val, err := m.get(key, now)
+	if err != nil {
+		return 0, err
+	}
+	num, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrVal{}, "Invalid int value")
+	}
+	return num, nil
+}
+
+func (m *memStore) set(key, val string, seconds int64, now time.Time) {
+	e := m.create(key, memKindStr)
+	e.str = val
+	e.expires = memExpires(now, seconds)
+}
+
+func (m *memStore) setNX(key, val string, seconds int64, now time.Time) bool {
+	if m.lookup(key, now) != nil {
+		return false
+	}
+	m.set(key, val, seconds, now)
+	return true
+}
+
+func (m *memStore) compareAndSet(key, old, val string, seconds int64, now time.Time) (bool, error) {
+	e, err := m.lookupKind(key, memKindStr, now)
+	if err != nil {
+		return false, governor.ErrWithKind(err, ErrClient{}, "Failed to compare and set key")
+	}
+	if e == nil || e.str != old {
+		return false, nil
+	}
+	m.set(key, val, seconds, now)
+	return true, nil
+}
+
+func (m *memStore) del(key ...string) {
+	for _, i := range key {
+		delete(m.entries, i)
+	}
+}
+
+func (m *memStore) incr(key string, delta int64, now time.Time) (int64, error) {
+	e, err := m.lookupKind(key, memKindStr, now)
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to incr key")
+	}
+	var num int64
+	if e != nil {
+		num, err = strconv.ParseInt(e.str, 10, 64)
+		if err != nil {
+			return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to incr key")
+		}
+	}
+	if (delta > 0 && num > math.MaxInt64-delta) || (delta < 0 && num < math.MinInt64-delta) {
+		return 0, governor.ErrWithKind(nil, ErrClient{}, "Incr overflows int value")
+	}
+	num += delta
+	if e == nil {
+		e = m.create(key, memKindStr)
+	}
+	e.str = strconv.FormatInt(num, 10)
+	m.touch(key, e)
+	return num, nil
+}
+
+func (m *memStore) expire(key string, seconds int64, now time.Time) {
+	e := m.lookup(key, now)
+	if e == nil {
+		return
+	}
+	if seconds <= 0 {
+		delete(m.entries, key)
+		return
+	}
+	e.expires = memExpires(now, seconds)
+	m.touch(key, e)
+}
+
+func (m *memStore) ttl(key string, now time.Time) (int64, error) {
+	e := m.lookup(key, now)
+	if e == nil {
+		return 0, governor.ErrWithKind(nil, ErrNotFound{}, "Key not found")
+	}
+	if e.expires.IsZero() {
+		return -1, nil
+	}
+	return int64(e.expires.Sub(now) / time.Second), nil
+}
+
+func (m *memStore) hGet(key, field string, now time.Time) (string, error) {
+	e, err := m.lookupKind(key, memKindHash, now)
+	if err != nil {
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to get hash field")
+	}
+	if e == nil {
+		return "", governor.ErrWithKind(nil, ErrNotFound{}, "Field not found")
+	}
+	val, ok := e.hash[field]
+	if !ok {
+		return "", governor.ErrWithKind(nil, ErrNotFound{}, "Field not found")
+	}
+	return val, nil
+}
+
+func (m *memStore) hSet(key string, fields map[string]string, now time.Time) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	e, err := m.lookupOrCreate(key, memKindHash, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to set hash fields")
+	}
+	for k, v := range fields {
+		e.hash[k] = v
+	}
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) hDel(key string, field []string, now time.Time) error {
+	e, err := m.lookupKind(key, memKindHash, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to delete hash fields")
+	}
+	if e == nil || len(field) == 0 {
+		return nil
+	}
+	for _, i := range field {
+		delete(e.hash, i)
+	}
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) hGetAll(key string, now time.Time) (map[string]string, error) {
+	e, err := m.lookupKind(key, memKindHash, now)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get hash")
+	}
+	res := map[string]string{}
+	if e != nil {
+		for k, v := range e.hash {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+func (m *memStore) sAdd(key string, member []string, now time.Time) error {
+	if len(member) == 0 {
+		return nil
+	}
+	e, err := m.lookupOrCreate(key, memKindSet, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to add set members")
+	}
+	for _, i := range member {
+		e.set[i] = struct{}{}
+	}
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) sRem(key string, member []string, now time.Time) error {
+	e, err := m.lookupKind(key, memKindSet, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove set members")
+	}
+	if e == nil || len(member) == 0 {
+		return nil
+	}
+	for _, i := range member {
+		delete(e.set, i)
+	}
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) sIsMember(key, member string, now time.Time) (bool, error) {
+	e, err := m.lookupKind(key, memKindSet, now)
+	if err != nil {
+		return false, governor.ErrWithKind(err, ErrClient{}, "Failed to check set member")
+	}
+	if e == nil {
+		return false, nil
+	}
+	_, ok := e.set[member]
+	return ok, nil
+}
+
+func (m *memStore) sMembers(key string, now time.Time) ([]string, error) {
+	e, err := m.lookupKind(key, memKindSet, now)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get set members")
+	}
+	if e == nil {
+		return []string{}, nil
+	}
+	res := make([]string, 0, len(e.set))
+	for k := range e.set {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// zMembers returns the members of a sorted set ordered by score, and then by
+// member
+func (e *memEntry) zMembers() []ZMember {
+	res := make([]ZMember, 0, len(e.zset))
+	for k, v := range e.zset {
+		res = append(res, ZMember{
+			Score:  v,
+			Member: k,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score < res[j].Score
+		}
+		return res[i].Member < res[j].Member
+	})
+	return res
+}
+
+func (m *memStore) zAdd(key string, member []ZMember, now time.Time) error {
+	if len(member) == 0 {
+		return nil
+	}
+	for _, i := range member {
+		if math.IsNaN(i.Score) {
+			return governor.ErrWithKind(nil, ErrClient{}, "Sorted set score is not a number")
+		}
+	}
+	e, err := m.lookupOrCreate(key, memKindZSet, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to add sorted set members")
+	}
+	for _, i := range member {
+		e.zset[i.Member] = i.Score
+	}
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) zRangeByScore(key string, min, max float64, limit, offset int, now time.Time) ([]ZMember, error) {
+	e, err := m.lookupKind(key, memKindZSet, now)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get sorted set range")
+	}
+	res := []ZMember{}
+	if e == nil || offset < 0 {
+		return res, nil
+	}
+	for _, i := range e.zMembers() {
+		if i.Score < min || i.Score > max {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		res = append(res, i)
+		if limit > 0 && len(res) >= limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (m *memStore) zRank(key, member string, now time.Time) (int64, error) {
+	e, err := m.lookupKind(key, memKindZSet, now)
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to get sorted set rank")
+	}
+	if e != nil {
+		for n, i := range e.zMembers() {
+			if i.Member == member {
+				return int64(n), nil
+			}
+		}
+	}
+	return 0, governor.ErrWithKind(nil, ErrNotFound{}, "Member not found")
+}
+
+func (m *memStore) zIncrBy(key string, delta float64, member string, now time.Time) (float64, error) {
+	e, err := m.lookupKind(key, memKindZSet, now)
+	if err != nil {
+		return 0, governor.ErrWithKind(err, ErrClient{}, "Failed to incr sorted set member")
+	}
+	var score float64
+	if e != nil {
+		score = e.zset[member]
+	}
+	score += delta
+	if math.IsNaN(score) {
+		return 0, governor.ErrWithKind(nil, ErrClient{}, "Sorted set score is not a number")
+	}
+	if e == nil {
+		e = m.create(key, memKindZSet)
+	}
+	e.zset[member] = score
+	m.touch(key, e)
+	return score, nil
+}
+
+func (m *memStore) lPush(key string, val []string, now time.Time) error {
+	if len(val) == 0 {
+		return nil
+	}
+	e, err := m.lookupOrCreate(key, memKindList, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to push list values")
+	}
+	// each value is pushed to the head of the list in turn
+	list := make([]string, 0, len(val)+len(e.list))
+	for i := len(val) - 1; i >= 0; i-- {
+		list = append(list, val[i])
+	}
+	e.list = append(list, e.list...)
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) rPop(key string, now time.Time) (string, error) {
+	e, err := m.lookupKind(key, memKindList, now)
+	if err != nil {
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to pop list value")
+	}
+	if e == nil {
+		return "", governor.ErrWithKind(nil, ErrNotFound{}, "List empty")
+	}
+	last := len(e.list) - 1
+	val := e.list[last]
+	e.list = e.list[:last]
+	m.touch(key, e)
+	return val, nil
+}
+
+// listRange returns the bounds of the list range from start to stop inclusive,
+// where negative indices count from the end of the list
+func listRange(size int, start, stop int64) (int, int, bool) {
+	n := int64(size)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	return int(start), int(stop) + 1, true
+}
+
+func (m *memStore) lRange(key string, start, stop int64, now time.Time) ([]string, error) {
+	e, err := m.lookupKind(key, memKindList, now)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get list range")
+	}
+	if e == nil {
+		return []string{}, nil
+	}
+	begin, end, ok := listRange(len(e.list), start, stop)
+	if !ok {
+		return []string{}, nil
+	}
+	res := make([]string, end-begin)
+	copy(res, e.list[begin:end])
+	return res, nil
+}
+
+func (m *memStore) lTrim(key string, start, stop int64, now time.Time) error {
+	e, err := m.lookupKind(key, memKindList, now)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to trim list")
+	}
+	if e == nil {
+		return nil
+	}
+	begin, end, ok := listRange(len(e.list), start, stop)
+	if !ok {
+		e.list = nil
+	} else {
+		list := make([]string, end-begin)
+		copy(list, e.list[begin:end])
+		e.list = list
+	}
+	m.touch(key, e)
+	return nil
+}
+
+func (m *memStore) Get(ctx context.Context, key string) (val string, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.get(key, now)
+	})
+	return
+}
+
+func (m *memStore) GetInt(ctx context.Context, key string) (num int64, err error) {
+	m.do(func(now time.Time) {
+		num, err = m.getInt(key, now)
+	})
+	return
+}
+
+func (m *memStore) Set(ctx context.Context, key, val string, seconds int64) error {
+	m.do(func(now time.Time) {
+		m.set(key, val, seconds, now)
+	})
+	return nil
+}
+
+func (m *memStore) SetNX(ctx context.Context, key, val string, seconds int64) (ok bool, err error) {
+	m.do(func(now time.Time) {
+		ok = m.setNX(key, val, seconds, now)
+	})
+	return
+}
+
+func (m *memStore) CompareAndSet(ctx context.Context, key, old, val string, seconds int64) (ok bool, err error) {
+	m.do(func(now time.Time) {
+		ok, err = m.compareAndSet(key, old, val, seconds, now)
+	})
+	return
+}
+
+func (m *memStore) Del(ctx context.Context, key ...string) error {
+	m.do(func(now time.Time) {
+		m.del(key...)
+	})
+	return nil
+}
+
+func (m *memStore) Incr(ctx context.Context, key string, delta int64) (num int64, err error) {
+	m.do(func(now time.Time) {
+		num, err = m.incr(key, delta, now)
+	})
+	return
+}
+
+func (m *memStore) Expire(ctx context.Context, key string, seconds int64) error {
+	m.do(func(now time.Time) {
+		m.expire(key, seconds, now)
+	})
+	return nil
+}
+
+func (m *memStore) TTL(ctx context.Context, key string) (ttl int64, err error) {
+	m.do(func(now time.Time) {
+		ttl, err = m.ttl(key, now)
+	})
+	return
+}
+
+func (m *memStore) HGet(ctx context.Context, key, field string) (val string, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.hGet(key, field, now)
+	})
+	return
+}
+
+func (m *memStore) HSet(ctx context.Context, key string, fields map[string]string) (err error) {
+	m.do(func(now time.Time) {
+		err = m.hSet(key, fields, now)
+	})
+	return
+}
+
+func (m *memStore) HDel(ctx context.Context, key string, field ...string) (err error) {
+	m.do(func(now time.Time) {
+		err = m.hDel(key, field, now)
+	})
+	return
+}
+
+func (m *memStore) HGetAll(ctx context.Context, key string) (val map[string]string, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.hGetAll(key, now)
+	})
+	return
+}
+
+func (m *memStore) SAdd(ctx context.Context, key string, member ...string) (err error) {
+	m.do(func(now time.Time) {
+		err = m.sAdd(key, member, now)
+	})
+	return
+}
+
+func (m *memStore) SRem(ctx context.Context, key string, member ...string) (err error) {
+	m.do(func(now time.Time) {
+		err = m.sRem(key, member, now)
+	})
+	return
+}
+
+func (m *memStore) SIsMember(ctx context.Context, key, member string) (ok bool, err error) {
+	m.do(func(now time.Time) {
+		ok, err = m.sIsMember(key, member, now)
+	})
+	return
+}
+
+func (m *memStore) SMembers(ctx context.Context, key string) (val []string, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.sMembers(key, now)
+	})
+	return
+}
+
+func (m *memStore) ZAdd(ctx context.Context, key string, member ...ZMember) (err error) {
+	m.do(func(now time.Time) {
+		err = m.zAdd(key, member, now)
+	})
+	return
+}
+
+func (m *memStore) ZRangeByScore(ctx context.Context, key string, min, max float64, limit, offset int) (val []ZMember, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.zRangeByScore(key, min, max, limit, offset, now)
+	})
+	return
+}
+
+func (m *memStore) ZRank(ctx context.Context, key, member string) (rank int64, err error) {
+	m.do(func(now time.Time) {
+		rank, err = m.zRank(key, member, now)
+	})
+	return
+}
+
+func (m *memStore) ZIncrBy(ctx context.Context, key string, delta float64, member string) (score float64, err error) {
+	m.do(func(now time.Time) {
+		score, err = m.zIncrBy(key, delta, member, now)
+	})
+	return
+}
+
+func (m *memStore) LPush(ctx context.Context, key string, val ...string) (err error) {
+	m.do(func(now time.Time) {
+		err = m.lPush(key, val, now)
+	})
+	return
+}
+
+func (m *memStore) RPop(ctx context.Context, key string) (val string, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.rPop(key, now)
+	})
+	return
+}
+
+func (m *memStore) LRange(ctx context.Context, key string, start, stop int64) (val []string, err error) {
+	m.do(func(now time.Time) {
+		val, err = m.lRange(key, start, stop, now)
+	})
+	return
+}
+
+func (m *memStore) LTrim(ctx context.Context, key string, start, stop int64) (err error) {
+	m.do(func(now time.Time) {
+		err = m.lTrim(key, start, stop, now)
+	})
+	return
+}
+
+func (m *memStore) Multi(ctx context.Context) (Multi, error) {
+	return &memMulti{
+		store: m,
+	}, nil
+}
+
+func (m *memStore) Tx(ctx context.Context) (Multi, error) {
+	return &memMulti{
+		store: m,
+		keys: txKeys{
+			tx: true,
+		},
+	}, nil
+}
+
+// versions returns the state of watched keys
+func (m *memStore) versions(keys []string) map[string]memVersion {
+	versions := make(map[string]memVersion, len(keys))
+	m.do(func(now time.Time) {
+		for _, i := range keys {
+			v := memVersion{
+				entry: m.lookup(i, now),
+			}
+			if v.entry != nil {
+				v.version = v.entry.version
+			}
+			versions[i] = v
+		}
+	})
+	return versions
+}
+
+// execWatched runs ops only if no watched key has been modified, and returns
+// whether they were run
+func (m *memStore) execWatched(versions map[string]memVersion, ops []func(now time.Time)) bool {
+	ok := true
+	m.do(func(now time.Time) {
+		for k, v := range versions {
+			e := m.lookup(k, now)
+			if e != v.entry || e != nil && e.version != v.version {
+				ok = false
+				return
+			}
+		}
+		for _, i := range ops {
+			i(now)
+		}
+	})
+	return ok
+}
+
+func (m *memStore) Watch(ctx context.Context, keys []string, fn func(tx Watcher) error) error {
+	if len(keys) == 0 {
+		return governor.ErrWithKind(nil, ErrVal{}, "Watch requires at least one key")
+	}
+	for i := 0; i < watchMaxRetries; i++ {
+		versions := m.versions(keys)
+		w := &memWatcher{
+			store: m,
+			multi: &memMulti{
+				store: m,
+				keys: txKeys{
+					tx: true,
+				},
+			},
+		}
+		if err := fn(w); err != nil {
+			return err
+		}
+		if w.multi.keys.err != nil {
+			return w.multi.keys.err
+		}
+		if m.execWatched(versions, w.multi.ops) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return governor.ErrWithKind(ctx.Err(), ErrConflict{}, "Context cancelled")
+		default:
+		}
+	}
+	return governor.ErrWithKind(nil, ErrConflict{}, "Watched keys modified")
+}
+
+type (
+	memWatcher struct {
+		store *memStore
+		multi *memMulti
+	}
+)
+
+func (w *memWatcher) Get(ctx context.Context, key string) (string, error) {
+	return w.store.Get(ctx, key)
+}
+
+func (w *memWatcher) GetInt(ctx context.Context, key string) (int64, error) {
+	return w.store.GetInt(ctx, key)
+}
+
+func (w *memWatcher) Tx() Multi {
+	return w.multi
+}
+
+func (w *memWatcher) Subkey(keypath ...string) string {
+	if len(keypath) == 0 {
+		return ""
+	}
+	return strings.Join(keypath, kvpathSeparator)
+}
+
+func (w *memWatcher) Subtree(prefix string) Watcher {
+	return &watcher{
+		prefix: prefix,
+		base:   w,
+	}
+}
+
+type (
+	// memMulti queues commands which are run together under the lock of the
+	// store on Exec
+	memMulti struct {
+		store *memStore
+		ops   []func(now time.Time)
+		keys  txKeys
+	}
+
+	memResulter struct {
+		val string
+		err error
+	}
+
+	memIntResulter struct {
+		val int64
+		err error
+	}
+
+	memFloatResulter struct {
+		val float64
+		err error
+	}
+
+	memBoolResulter struct {
+		val bool
+		err error
+	}
+
+	memStrSliceResulter struct {
+		val []string
+		err error
+	}
+
+	memStrMapResulter struct {
+		val map[string]string
+		err error
+	}
+
+	memZMemberResulter struct {
+		val []ZMember
+		err error
+	}
+)
+
+func (r *memResulter) Result() (string, error) {
+	return r.val, r.err
+}
+
+func (r *memIntResulter) Result() (int64, error) {
+	return r.val, r.err
+}
+
+func (r *memFloatResulter) Result() (float64, error) {
+	return r.val, r.err
+}
+
+func (r *memBoolResulter) Result() (bool, error) {
+	return r.val, r.err
+}
+
+func (r *memStrSliceResulter) Result() ([]string, error) {
+	return r.val, r.err
+}
+
+func (r *memStrMapResulter) Result() (map[string]string, error) {
+	return r.val, r.err
+}
+
+func (r *memZMemberResulter) Result() ([]ZMember, error) {
+	return r.val, r.err
+}
+
+func (t *memMulti) queue(key string, op func(now time.Time)) {
+	t.keys.check(key)
+	t.ops = append(t.ops, op)
+}
+
+func (t *memMulti) Get(key string) Resulter {
+	r := &memResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.get(key, now)
+	})
+	return r
+}
+
+func (t *memMulti) GetInt(key string) IntResulter {
+	r := &memIntResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.getInt(key, now)
+	})
+	return r
+}
+
+func (t *memMulti) Set(key, val string, seconds int64) {
+	t.queue(key, func(now time.Time) {
+		t.store.set(key, val, seconds, now)
+	})
+}
+
+func (t *memMulti) Del(key ...string) {
+	for _, i := range key {
+		t.keys.check(i)
+	}
+	t.ops = append(t.ops, func(now time.Time) {
+		t.store.del(key...)
+	})
+}
+
+func (t *memMulti) Incr(key string, delta int64) IntResulter {
+	r := &memIntResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.incr(key, delta, now)
+	})
+	return r
+}
+
+func (t *memMulti) Expire(key string, seconds int64) {
+	t.queue(key, func(now time.Time) {
+		t.store.expire(key, seconds, now)
+	})
+}
+
+func (t *memMulti) TTL(key string) IntResulter {
+	r := &memIntResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.ttl(key, now)
+	})
+	return r
+}
+
+func (t *memMulti) HGet(key, field string) Resulter {
+	r := &memResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.hGet(key, field, now)
+	})
+	return r
+}
+
+func (t *memMulti) HSet(key string, fields map[string]string) {
+	t.queue(key, func(now time.Time) {
+		t.store.hSet(key, fields, now)
+	})
+}
+
+func (t *memMulti) HDel(key string, field ...string) {
+	t.queue(key, func(now time.Time) {
+		t.store.hDel(key, field, now)
+	})
+}
+
+func (t *memMulti) HGetAll(key string) StrMapResulter {
+	r := &memStrMapResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.hGetAll(key, now)
+	})
+	return r
+}
+
+func (t *memMulti) SAdd(key string, member ...string) {
+	t.queue(key, func(now time.Time) {
+		t.store.sAdd(key, member, now)
+	})
+}
+
+func (t *memMulti) SRem(key string, member ...string) {
+	t.queue(key, func(now time.Time) {
+		t.store.sRem(key, member, now)
+	})
+}
+
+func (t *memMulti) SIsMember(key, member string) BoolResulter {
+	r := &memBoolResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.sIsMember(key, member, now)
+	})
+	return r
+}
+
+func (t *memMulti) SMembers(key string) StrSliceResulter {
+	r := &memStrSliceResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.sMembers(key, now)
+	})
+	return r
+}
+
+func (t *memMulti) ZAdd(key string, member ...ZMember) {
+	t.queue(key, func(now time.Time) {
+		t.store.zAdd(key, member, now)
+	})
+}
+
+func (t *memMulti) ZRangeByScore(key string, min, max float64, limit, offset int) ZMemberResulter {
+	r := &memZMemberResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.zRangeByScore(key, min, max, limit, offset, now)
+	})
+	return r
+}
+
+func (t *memMulti) ZRank(key, member string) IntResulter {
+	r := &memIntResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.zRank(key, member, now)
+	})
+	return r
+}
+
+func (t *memMulti) ZIncrBy(key string, delta float64, member string) FloatResulter {
+	r := &memFloatResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.zIncrBy(key, delta, member, now)
+	})
+	return r
+}
+
+func (t *memMulti) LPush(key string, val ...string) {
+	t.queue(key, func(now time.Time) {
+		t.store.lPush(key, val, now)
+	})
+}
+
+func (t *memMulti) RPop(key string) Resulter {
+	r := &memResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.rPop(key, now)
+	})
+	return r
+}
+
+func (t *memMulti) LRange(key string, start, stop int64) StrSliceResulter {
+	r := &memStrSliceResulter{}
+	t.queue(key, func(now time.Time) {
+		r.val, r.err = t.store.lRange(key, start, stop, now)
+	})
+	return r
+}
+
+func (t *memMulti) LTrim(key string, start, stop int64) {
+	t.queue(key, func(now time.Time) {
+		t.store.lTrim(key, start, stop, now)
+	})
+}
+
+func (t *memMulti) Subkey(keypath ...string) string {
+	if len(keypath) == 0 {
+		return ""
+	}
+	return strings.Join(keypath, kvpathSeparator)
+}
+
+func (t *memMulti) Subtree(prefix string) Multi {
+	return &multi{
+		prefix: prefix,
+		base:   t,
+		keys:   &t.keys,
+	}
+}
+
+// Exec runs the queued commands atomically
+//
+// As with a redis pipeline, errors of individual commands are returned by
+// their results rather than by Exec.
+func (t *memMulti) Exec(ctx context.Context) error {
+	ops := t.ops
+	t.ops = nil
+	if t.keys.err != nil {
+		return t.keys.err
+	}
+	t.store.do(func(now time.Time) {
+		for _, i := range ops {
+			i(now)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,175 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobMatch(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Test    string
+		Pattern string
+		Key     string
+		Match   bool
+	}{
+		{
+			Test:    "literal",
+			Pattern: "abc",
+			Key:     "abc",
+			Match:   true,
+		},
+		{
+			Test:    "star",
+			Pattern: "a*c",
+			Key:     "abbbc",
+			Match:   true,
+		},
+		{
+			Test:    "star matches empty",
+			Pattern: "a*",
+			Key:     "a",
+			Match:   true,
+		},
+		{
+			Test:    "question",
+			Pattern: "a?c",
+			Key:     "ac",
+			Match:   false,
+		},
+		{
+			Test:    "class",
+			Pattern: "a[bc]d",
+			Key:     "acd",
+			Match:   true,
+		},
+		{
+			Test:    "negated class",
+			Pattern: "a[^bc]d",
+			Key:     "acd",
+			Match:   false,
+		},
+		{
+			Test:    "range",
+			Pattern: "a[0-9]",
+			Key:     "a5",
+			Match:   true,
+		},
+		{
+			Test:    "escaped",
+			Pattern: EscapeGlob("c*[x]") + "*",
+			Key:     "c*[x]d",
+			Match:   true,
+		},
+		{
+			Test:    "escaped literal star",
+			Pattern: EscapeGlob("c*"),
+			Key:     "cd",
+			Match:   false,
+		},
+		{
+			Test:    "subtree",
+			Pattern: subtreePattern(false, "test"),
+			Key:     subtreeKey(false, "test:nested", "a"),
+			Match:   true,
+		},
+		{
+			Test:    "other subtree",
+			Pattern: subtreePattern(false, "test"),
+			Key:     subtreeKey(false, "tester", "a"),
+			Match:   false,
+		},
+		{
+			Test:    "hash tagged subtree",
+			Pattern: subtreePattern(true, "test"),
+			Key:     subtreeKey(true, "test:nested", "a"),
+			Match:   true,
+		},
+		{
+			Test:    "other hash tagged subtree",
+			Pattern: subtreePattern(true, "test"),
+			Key:     subtreeKey(true, "tester", "a"),
+			Match:   false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			assert.Equal(tc.Match, globMatch(tc.Pattern, tc.Key))
+		})
+	}
+}
+
+func TestMemExpiry(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ctx := context.Background()
+	m := newMemStore()
+	now := time.Now().Round(0)
+	m.clock = func() time.Time {
+		return now
+	}
+
+	assert.NoError(m.Set(ctx, "a", "1", 2))
+	assert.NoError(m.Set(ctx, "b", "1", 0))
+	assert.NoError(m.HSet(ctx, "c", map[string]string{"a": "1"}))
+	assert.NoError(m.Expire(ctx, "c", 1))
+	now = now.Add(1500 * time.Millisecond)
+	ttl, err := m.TTL(ctx, "a")
+	assert.NoError(err)
+	assert.Equal(int64(0), ttl, "Should round the ttl down")
+	_, err = m.HGet(ctx, "c", "a")
+	assert.True(errors.Is(err, ErrNotFound{}), "Should not return expired keys")
+
+	now = now.Add(time.Second)
+	assert.Equal(1, m.sweep(), "Should remove expired keys")
+	_, err = m.Get(ctx, "a")
+	assert.True(errors.Is(err, ErrNotFound{}))
+	v, err := m.Get(ctx, "b")
+	assert.NoError(err)
+	assert.Equal("1", v)
+
+	ok, err := m.SetNX(ctx, "a", "2", 0)
+	assert.NoError(err)
+	assert.True(ok, "Should set expired keys")
+	ok, err = m.CompareAndSet(ctx, "a", "1", "3", 0)
+	assert.NoError(err)
+	assert.False(ok)
+	ok, err = m.CompareAndSet(ctx, "a", "2", "3", 0)
+	assert.NoError(err)
+	assert.True(ok)
+}
+
+func TestMemKinds(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ctx := context.Background()
+	m := newMemStore()
+
+	assert.NoError(m.Set(ctx, "a", "x", 0))
+	_, err := m.Incr(ctx, "a", 1)
+	assert.True(errors.Is(err, ErrClient{}), "Should reject incr of a non int value")
+	err = m.SAdd(ctx, "a", "1")
+	assert.True(errors.Is(err, ErrClient{}), "Should reject commands on the wrong kind of value")
+	_, err = m.HGetAll(ctx, "a")
+	assert.True(errors.Is(err, ErrClient{}))
+
+	assert.NoError(m.LPush(ctx, "list", "a"))
+	_, err = m.RPop(ctx, "list")
+	assert.NoError(err)
+	_, err = m.RPop(ctx, "list")
+	assert.True(errors.Is(err, ErrNotFound{}), "Should remove empty lists")
+	assert.NoError(m.SAdd(ctx, "list", "a"), "Should allow a removed key to be reused")
+}
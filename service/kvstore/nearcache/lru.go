@@ -0,0 +1,210 @@
+package nearcache
+
+import (
+	"container/list"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	genStripes = 64
+)
+
+type (
+	lruEntry struct {
+		key    string
+		val    string
+		expire int64
+	}
+
+	// lru is a bounded in memory cache evicting the least recently used entry
+	//
+	// Writes to a key increment the generation of its stripe, so that a fill
+	// of a value read before the write is discarded.
+	lru struct {
+		size          int
+		ll            *list.List
+		items         map[string]*list.Element
+		gens          [genStripes]uint64
+		mu            *sync.Mutex
+		hits          int64
+		misses        int64
+		evictions     int64
+		invalidations int64
+	}
+
+	lruStats struct {
+		size          int
+		hits          int64
+		misses        int64
+		evictions     int64
+		invalidations int64
+	}
+)
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+		mu:    &sync.Mutex{},
+	}
+}
+
+func (c *lru) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+	entry := e.Value.(*lruEntry)
+	if entry.expire < time.Now().Round(0).UnixNano() {
+		c.removeElement(e)
+		c.misses++
+		return "", false
+	}
+	c.ll.MoveToFront(e)
+	c.hits++
+	return entry.val, true
+}
+
+func genStripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % genStripes)
+}
+
+func (c *lru) bumpKey(key string) {
+	c.gens[genStripe(key)]++
+}
+
+func (c *lru) bumpAll() {
+	for i := range c.gens {
+		c.gens[i]++
+	}
+}
+
+// gen returns the generation of a key to be passed to fill
+func (c *lru) gen(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gens[genStripe(key)]
+}
+
+// set writes a value to the cache
+func (c *lru) set(key, val string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.bumpKey(key)
+	c.setLocked(key, val, ttl)
+}
+
+// fill writes a value read from the kvstore to the cache only if the key has
+// not been written since gen
+func (c *lru) fill(key, val string, ttl time.Duration, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gens[genStripe(key)] != gen {
+		return
+	}
+	c.setLocked(key, val, ttl)
+}
+
+func (c *lru) setLocked(key, val string, ttl time.Duration) {
+	if c.size < 1 || ttl <= 0 {
+		if e, ok := c.items[key]; ok {
+			c.removeElement(e)
+		}
+		return
+	}
+
+	expire := time.Now().Round(0).Add(ttl).UnixNano()
+	if e, ok := c.items[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.val = val
+		entry.expire = expire
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{
+		key:    key,
+		val:    val,
+		expire: expire,
+	})
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+}
+
+func (c *lru) del(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, i := range keys {
+		c.bumpKey(i)
+		if e, ok := c.items[i]; ok {
+			c.removeElement(e)
+		}
+	}
+}
+
+func (c *lru) delPrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.bumpAll()
+	for k, v := range c.items {
+		if strings.HasPrefix(k, prefix) {
+			c.removeElement(v)
+		}
+	}
+}
+
+func (c *lru) invalidate(keys []string, prefixes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidations++
+	for _, i := range keys {
+		c.bumpKey(i)
+		if e, ok := c.items[i]; ok {
+			c.removeElement(e)
+		}
+	}
+	if len(prefixes) > 0 {
+		c.bumpAll()
+	}
+	for _, i := range prefixes {
+		for k, v := range c.items {
+			if strings.HasPrefix(k, i) {
+				c.removeElement(v)
+			}
+		}
+	}
+}
+
+func (c *lru) removeElement(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*lruEntry).key)
+}
+
+func (c *lru) stats() lruStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return lruStats{
+		size:          c.ll.Len(),
+		hits:          c.hits,
+		misses:        c.misses,
+		evictions:     c.evictions,
+		invalidations: c.invalidations,
+	}
+}
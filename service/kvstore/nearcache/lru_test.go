@@ -0,0 +1,77 @@
+package nearcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	{
+		c := newLRU(2)
+		c.set("a", "1", time.Minute)
+		c.set("b", "2", time.Minute)
+		v, ok := c.get("a")
+		assert.True(ok, "Should find a set key")
+		assert.Equal("1", v, "Should return the set value")
+		c.set("c", "3", time.Minute)
+		_, ok = c.get("b")
+		assert.False(ok, "Should evict the least recently used key")
+		_, ok = c.get("a")
+		assert.True(ok, "Should keep recently used keys")
+		k := c.stats()
+		assert.Equal(2, k.size, "Should not exceed capacity")
+		assert.Equal(int64(1), k.evictions, "Should count evictions")
+		assert.Equal(int64(2), k.hits, "Should count hits")
+		assert.Equal(int64(1), k.misses, "Should count misses")
+	}
+	{
+		c := newLRU(4)
+		c.set("a", "1", time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		_, ok := c.get("a")
+		assert.False(ok, "Should not return expired keys")
+		assert.Equal(0, c.stats().size, "Should remove expired keys")
+	}
+	{
+		c := newLRU(4)
+		c.set("user:a", "1", time.Minute)
+		c.set("user:b", "2", time.Minute)
+		c.set("other", "3", time.Minute)
+		c.invalidate([]string{"other"}, []string{"user:"})
+		assert.Equal(0, c.stats().size, "Should remove invalidated keys and prefixes")
+		assert.Equal(int64(1), c.stats().invalidations, "Should count invalidations")
+	}
+	{
+		c := newLRU(4)
+		gen := c.gen("a")
+		c.set("a", "2", time.Minute)
+		c.fill("a", "1", time.Minute, gen)
+		v, ok := c.get("a")
+		assert.True(ok, "Should find a set key")
+		assert.Equal("2", v, "Should not fill a value read before a write")
+		gen = c.gen("b")
+		c.del([]string{"b"})
+		c.fill("b", "1", time.Minute, gen)
+		_, ok = c.get("b")
+		assert.False(ok, "Should not fill a value read before a delete")
+		gen = c.gen("user:c")
+		c.invalidate(nil, []string{"user:"})
+		c.fill("user:c", "1", time.Minute, gen)
+		_, ok = c.get("user:c")
+		assert.False(ok, "Should not fill a value read before an invalidation")
+		gen = c.gen("d")
+		c.fill("d", "1", time.Minute, gen)
+		v, ok = c.get("d")
+		assert.True(ok, "Should fill a value")
+		assert.Equal("1", v, "Should return the filled value")
+		c.fill("d", "2", 0, c.gen("d"))
+		_, ok = c.get("d")
+		assert.False(ok, "Should remove a value filled without a ttl")
+	}
+}
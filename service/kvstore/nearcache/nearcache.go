@@ -0,0 +1,557 @@
+package nearcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/util/uid"
+)
+
+const (
+	kvpathSeparator = ":"
+
+	invalidateChannelPrefix = "DEV_XORKEVIN_GOV_NEARCACHE"
+
+	scanBatch = 256
+)
+
+type (
+	// Cache is a kvstore with an in memory near cache
+	//
+	// Values are read from a bounded local LRU before falling back to the
+	// kvstore. Writes and deletes broadcast invalidations to all other
+	// instances of the cache.
+	Cache interface {
+		Get(ctx context.Context, key string) (string, error)
+		GetMulti(ctx context.Context, key ...string) (map[string]string, error)
+		Set(ctx context.Context, key, val string, seconds int64) error
+		SetMulti(ctx context.Context, vals map[string]string, seconds int64) error
+		Del(ctx context.Context, key ...string) error
+		DelSubtree(ctx context.Context) error
+		Subkey(keypath ...string) string
+		Subtree(prefix string) Cache
+	}
+
+	// Stats are the hit and miss stats of a near cache
+	Stats struct {
+		Name          string `json:"name"`
+		Size          int    `json:"size"`
+		Capacity      int    `json:"capacity"`
+		Hits          int64  `json:"hits"`
+		Misses        int64  `json:"misses"`
+		Evictions     int64  `json:"evictions"`
+		Invalidations int64  `json:"invalidations"`
+	}
+
+	// Statser reports stats on all registered near caches
+	Statser interface {
+		Stats() []Stats
+	}
+
+	// Service is a Cache and governor.Service
+	Service interface {
+		governor.Service
+		Cache
+	}
+
+	service struct {
+		kv      kvstore.KVStore
+		events  events.Events
+		cache   *lru
+		name    string
+		id      string
+		channel string
+		maxttl  time.Duration
+		sub     events.Subscription
+		logger  governor.Logger
+	}
+
+	tree struct {
+		prefix string
+		base   *service
+	}
+
+	registry struct {
+		caches []*service
+		mu     *sync.RWMutex
+	}
+
+	invalidateMsg struct {
+		Origin   string   `json:"origin"`
+		Keys     []string `json:"keys,omitempty"`
+		Prefixes []string `json:"prefixes,omitempty"`
+	}
+
+	ctxKeyCache struct{}
+
+	ctxKeyRegistry struct{}
+)
+
+// GetCtxCache returns a Cache from the context
+func GetCtxCache(inj governor.Injector) Cache {
+	v := inj.Get(ctxKeyCache{})
+	if v == nil {
+		return nil
+	}
+	return v.(Cache)
+}
+
+// setCtxCache sets a Cache in the context
+func setCtxCache(inj governor.Injector, c Cache) {
+	inj.Set(ctxKeyCache{}, c)
+}
+
+// getCtxRegistry returns a near cache registry from the context
+func getCtxRegistry(inj governor.Injector) *registry {
+	v := inj.Get(ctxKeyRegistry{})
+	if v == nil {
+		return nil
+	}
+	return v.(*registry)
+}
+
+// setCtxRegistry sets a near cache registry in the context
+func setCtxRegistry(inj governor.Injector, r *registry) {
+	inj.Set(ctxKeyRegistry{}, r)
+}
+
+// GetCtxStatser returns a Statser for all registered near caches from the context
+func GetCtxStatser(inj governor.Injector) Statser {
+	v := getCtxRegistry(inj)
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
+// NewInCtx creates a new near cache in front of the context KVStore, sets it
+// in the context, and returns it to be registered
+func NewInCtx(inj governor.Injector) Service {
+	kv := kvstore.GetCtxKVStore(inj)
+	ev := events.GetCtxEvents(inj)
+	s := New(kv, ev)
+	setCtxCache(inj, s)
+	return s
+}
+
+// New creates a new near cache
+func New(kv kvstore.KVStore, ev events.Events) Service {
+	return &service{
+		kv:     kv,
+		events: ev,
+	}
+}
+
+func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
+	reg := getCtxRegistry(inj)
+	if reg == nil {
+		reg = &registry{
+			mu: &sync.RWMutex{},
+		}
+		setCtxRegistry(inj, reg)
+	}
+	reg.add(s)
+
+	r.SetDefault("size", 1024)
+	r.SetDefault("ttl", "30s")
+}
+
+func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
+	s.logger = l
+	l = s.logger.WithData(map[string]string{
+		"phase": "init",
+	})
+
+	s.name = r.Name()
+	s.channel = invalidateChannelPrefix + "." + s.name
+	s.cache = newLRU(r.GetInt("size"))
+	if t, err := time.ParseDuration(r.GetStr("ttl")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse near cache ttl")
+	} else {
+		s.maxttl = t
+	}
+	u, err := uid.New(8)
+	if err != nil {
+		return governor.ErrWithMsg(err, "Failed to create near cache instance id")
+	}
+	s.id = u.Base64()
+
+	l.Info("loaded config", map[string]string{
+		"size":    strconv.Itoa(s.cache.size),
+		"ttl (s)": strconv.FormatInt(int64(s.maxttl/time.Second), 10),
+		"channel": s.channel,
+	})
+
+	return nil
+}
+
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) Start(ctx context.Context) error {
+	l := s.logger.WithData(map[string]string{
+		"phase": "start",
+	})
+
+	sub, err := s.events.Subscribe(s.channel, "", s.invalidateSubscriber)
+	if err != nil {
+		return governor.ErrWithMsg(err, "Failed to subscribe to near cache invalidations")
+	}
+	s.sub = sub
+	l.Info("Subscribed to near cache invalidations", nil)
+	return nil
+}
+
+func (s *service) Stop(ctx context.Context) {
+	if s.sub == nil {
+		return
+	}
+	if err := s.sub.Close(); err != nil {
+		s.logger.Error("Failed to close near cache invalidation subscription", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "closesub",
+		})
+	}
+}
+
+func (s *service) Health() error {
+	return nil
+}
+
+func (s *service) invalidateSubscriber(msgdata []byte) {
+	m := invalidateMsg{}
+	if err := json.Unmarshal(msgdata, &m); err != nil {
+		s.logger.Error("Failed to decode near cache invalidation", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "decodeinvalidation",
+		})
+		return
+	}
+	if m.Origin == s.id {
+		return
+	}
+	s.cache.invalidate(m.Keys, m.Prefixes)
+}
+
+func (s *service) broadcast(ctx context.Context, keys []string, prefixes []string) {
+	b, err := json.Marshal(invalidateMsg{
+		Origin:   s.id,
+		Keys:     keys,
+		Prefixes: prefixes,
+	})
+	if err != nil {
+		s.logger.Error("Failed to encode near cache invalidation", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "encodeinvalidation",
+		})
+		return
+	}
+	if err := s.events.Publish(ctx, s.channel, b); err != nil {
+		s.logger.Error("Failed to publish near cache invalidation", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "publishinvalidation",
+		})
+	}
+}
+
+func (s *service) localTTL(seconds int64) time.Duration {
+	if seconds <= 0 {
+		return s.maxttl
+	}
+	if t := time.Duration(seconds) * time.Second; t < s.maxttl {
+		return t
+	}
+	return s.maxttl
+}
+
+// fillTTL returns the local ttl of a value read from the kvstore with the ttl
+// result of its key
+//
+// A value whose ttl cannot be read is not cached locally.
+func (s *service) fillTTL(ttl kvstore.IntResulter) time.Duration {
+	seconds, err := ttl.Result()
+	if err != nil {
+		return 0
+	}
+	if seconds == 0 {
+		// the key expires in less than a second
+		return 0
+	}
+	return s.localTTL(seconds)
+}
+
+// Get returns the value of a key
+//
+// A value read from the kvstore is cached locally for at most the remaining
+// ttl of its key, and only if the key has not been written or invalidated
+// while it was being read.
+func (s *service) Get(ctx context.Context, key string) (string, error) {
+	if v, ok := s.cache.get(key); ok {
+		return v, nil
+	}
+	gen := s.cache.gen(key)
+	m, err := s.kv.Multi(ctx)
+	if err != nil {
+		return "", err
+	}
+	res := m.Get(key)
+	ttl := m.TTL(key)
+	if err := m.Exec(ctx); err != nil {
+		return "", err
+	}
+	v, err := res.Result()
+	if err != nil {
+		return "", err
+	}
+	s.cache.fill(key, v, s.fillTTL(ttl), gen)
+	return v, nil
+}
+
+func (s *service) GetMulti(ctx context.Context, key ...string) (map[string]string, error) {
+	res := make(map[string]string, len(key))
+	uncached := make([]string, 0, len(key))
+	for _, i := range key {
+		if v, ok := s.cache.get(i); ok {
+			res[i] = v
+		} else {
+			uncached = append(uncached, i)
+		}
+	}
+	if len(uncached) == 0 {
+		return res, nil
+	}
+
+	gens := make(map[string]uint64, len(uncached))
+	for _, i := range uncached {
+		gens[i] = s.cache.gen(i)
+	}
+	multiget, err := s.kv.Multi(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resget := make(map[string]kvstore.Resulter, len(uncached))
+	resttl := make(map[string]kvstore.IntResulter, len(uncached))
+	for _, i := range uncached {
+		resget[i] = multiget.Get(i)
+		resttl[i] = multiget.TTL(i)
+	}
+	if err := multiget.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for k, r := range resget {
+		v, err := r.Result()
+		if err != nil {
+			if errors.Is(err, kvstore.ErrNotFound{}) {
+				continue
+			}
+			return nil, err
+		}
+		res[k] = v
+		s.cache.fill(k, v, s.fillTTL(resttl[k]), gens[k])
+	}
+	return res, nil
+}
+
+func (s *service) Set(ctx context.Context, key, val string, seconds int64) error {
+	if err := s.kv.Set(ctx, key, val, seconds); err != nil {
+		return err
+	}
+	s.cache.set(key, val, s.localTTL(seconds))
+	s.broadcast(ctx, []string{key}, nil)
+	return nil
+}
+
+func (s *service) SetMulti(ctx context.Context, vals map[string]string, seconds int64) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	multiset, err := s.kv.Multi(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(vals))
+	for k, v := range vals {
+		multiset.Set(k, v, seconds)
+		keys = append(keys, k)
+	}
+	if err := multiset.Exec(ctx); err != nil {
+		return err
+	}
+	ttl := s.localTTL(seconds)
+	for k, v := range vals {
+		s.cache.set(k, v, ttl)
+	}
+	sort.Strings(keys)
+	s.broadcast(ctx, keys, nil)
+	return nil
+}
+
+func (s *service) Del(ctx context.Context, key ...string) error {
+	if len(key) == 0 {
+		return nil
+	}
+	s.cache.del(key)
+	if err := s.kv.Del(ctx, key...); err != nil {
+		return err
+	}
+	s.broadcast(ctx, key, nil)
+	return nil
+}
+
+func (s *service) DelSubtree(ctx context.Context) error {
+	return s.delPrefix(ctx, "")
+}
+
+func (s *service) delPrefix(ctx context.Context, prefix string) error {
+	s.cache.delPrefix(prefix)
+	if prefix == "" {
+		if err := s.kv.DelSubtree(ctx); err != nil {
+			return err
+		}
+	} else {
+		// keys of a near cache subtree are stored directly in the kvstore
+		// subtree, and are removed by scanning for their prefix
+		iter, err := s.kv.Scan(ctx, kvstore.EscapeGlob(prefix)+"*", scanBatch)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, scanBatch)
+		for iter.Next() {
+			keys = append(keys, iter.Key())
+			if len(keys) >= scanBatch {
+				if err := s.kv.Del(ctx, keys...); err != nil {
+					return err
+				}
+				keys = keys[:0]
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		if err := s.kv.Del(ctx, keys...); err != nil {
+			return err
+		}
+	}
+	s.broadcast(ctx, nil, []string{prefix})
+	return nil
+}
+
+func (s *service) Subkey(keypath ...string) string {
+	if len(keypath) == 0 {
+		return ""
+	}
+	return strings.Join(keypath, kvpathSeparator)
+}
+
+func (s *service) Subtree(prefix string) Cache {
+	return &tree{
+		prefix: prefix,
+		base:   s,
+	}
+}
+
+func (s *service) stats() Stats {
+	k := s.cache.stats()
+	return Stats{
+		Name:          s.name,
+		Size:          k.size,
+		Capacity:      s.cache.size,
+		Hits:          k.hits,
+		Misses:        k.misses,
+		Evictions:     k.evictions,
+		Invalidations: k.invalidations,
+	}
+}
+
+func (t *tree) Get(ctx context.Context, key string) (string, error) {
+	return t.base.Get(ctx, t.prefix+kvpathSeparator+key)
+}
+
+func (t *tree) GetMulti(ctx context.Context, key ...string) (map[string]string, error) {
+	args := make([]string, 0, len(key))
+	for _, i := range key {
+		args = append(args, t.prefix+kvpathSeparator+i)
+	}
+	m, err := t.base.GetMulti(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[strings.TrimPrefix(k, t.prefix+kvpathSeparator)] = v
+	}
+	return res, nil
+}
+
+func (t *tree) Set(ctx context.Context, key, val string, seconds int64) error {
+	return t.base.Set(ctx, t.prefix+kvpathSeparator+key, val, seconds)
+}
+
+func (t *tree) SetMulti(ctx context.Context, vals map[string]string, seconds int64) error {
+	args := make(map[string]string, len(vals))
+	for k, v := range vals {
+		args[t.prefix+kvpathSeparator+k] = v
+	}
+	return t.base.SetMulti(ctx, args, seconds)
+}
+
+func (t *tree) Del(ctx context.Context, key ...string) error {
+	args := make([]string, 0, len(key))
+	for _, i := range key {
+		args = append(args, t.prefix+kvpathSeparator+i)
+	}
+	return t.base.Del(ctx, args...)
+}
+
+func (t *tree) DelSubtree(ctx context.Context) error {
+	return t.base.delPrefix(ctx, t.prefix+kvpathSeparator)
+}
+
+func (t *tree) Subkey(keypath ...string) string {
+	if len(keypath) == 0 {
+		return ""
+	}
+	return strings.Join(keypath, kvpathSeparator)
+}
+
+func (t *tree) Subtree(prefix string) Cache {
+	return &tree{
+		prefix: t.prefix + kvpathSeparator + prefix,
+		base:   t.base,
+	}
+}
+
+func (r *registry) add(s *service) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches = append(r.caches, s)
+}
+
+// Stats returns the stats of all registered near caches
+func (r *registry) Stats() []Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]Stats, 0, len(r.caches))
+	for _, i := range r.caches {
+		if i.cache == nil {
+			// cache has not been initialized
+			continue
+		}
+		res = append(res, i.stats())
+	}
+	return res
+}
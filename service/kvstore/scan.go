@@ -0,0 +1,332 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v7"
+	"xorkevin.dev/governor"
+)
+
+const (
+	defaultScanBatch = 256
+)
+
+var (
+	globReplacer = strings.NewReplacer(
+		`\`, `\\`,
+		`*`, `\*`,
+		`?`, `\?`,
+		`[`, `\[`,
+		`]`, `\]`,
+	)
+)
+
+// EscapeGlob escapes a string for literal use in a scan match pattern
+func EscapeGlob(s string) string {
+	return globReplacer.Replace(s)
+}
+
+// subtreePattern returns a scan pattern matching all keys of a subtree and
+// its nested subtrees
+func subtreePattern(hashTags bool, prefix string) string {
+	if hashTags {
+		return "{" + EscapeGlob(prefix) + "[:}]*"
+	}
+	return EscapeGlob(prefix) + kvpathSeparator + "*"
+}
+
+type (
+	// scanIterator scans over each node in turn
+	scanIterator struct {
+		nodes  []*redis.Client
+		match  string
+		count  int64
+		prefix string
+		iter   *redis.ScanIterator
+	}
+)
+
+func (i *scanIterator) Next() bool {
+	for {
+		if i.iter == nil {
+			if len(i.nodes) == 0 {
+				return false
+			}
+			i.iter = i.nodes[0].Scan(0, i.match, i.count).Iterator()
+			i.nodes = i.nodes[1:]
+		}
+		if i.iter.Next() {
+			return true
+		}
+		if i.iter.Err() != nil {
+			return false
+		}
+		i.iter = nil
+	}
+}
+
+func (i *scanIterator) Key() string {
+	return strings.TrimPrefix(i.iter.Val(), i.prefix)
+}
+
+func (i *scanIterator) Err() error {
+	if i.iter == nil {
+		return nil
+	}
+	if err := i.iter.Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to scan keys")
+	}
+	return nil
+}
+
+// scanNodes returns the nodes that must be scanned to visit every key
+func scanNodes(ctx context.Context, client redis.UniversalClient) ([]*redis.Client, error) {
+	switch c := client.(type) {
+	case *redis.Client:
+		return []*redis.Client{c}, nil
+	case *redis.ClusterClient:
+		var nodes []*redis.Client
+		mu := &sync.Mutex{}
+		if err := c.ForEachMaster(func(node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			nodes = append(nodes, node.WithContext(ctx))
+			return nil
+		}); err != nil {
+			return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get cluster nodes")
+		}
+		return nodes, nil
+	default:
+		return nil, governor.ErrWithKind(nil, ErrClient{}, "Unsupported client type")
+	}
+}
+
+// scan returns an iterator over keys matching a pattern, with prefix trimmed
+// from each returned key
+func (s *service) scan(ctx context.Context, match string, prefix string, batch int) (ScanIterator, error) {
+	if s.mem != nil {
+		return s.mem.scan(match, prefix), nil
+	}
+	if batch < 1 {
+		batch = defaultScanBatch
+	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodes(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return &scanIterator{
+		nodes:  nodes,
+		match:  match,
+		count:  int64(batch),
+		prefix: prefix,
+	}, nil
+}
+
+// Scan iterates over all keys matching a glob pattern
+func (s *service) Scan(ctx context.Context, pattern string, batch int) (ScanIterator, error) {
+	return s.scan(ctx, pattern, "", batch)
+}
+
+// delPattern unlinks all keys matching a pattern in batches
+//
+// Keys are found with SCAN and removed with UNLINK so neither blocks the
+// server for large subtrees. Each key is unlinked individually in a pipeline
+// since matched keys may span cluster slots.
+func (s *service) delPattern(ctx context.Context, match string) error {
+	if s.mem != nil {
+		s.mem.delPattern(match)
+		return nil
+	}
+	iter, err := s.scan(ctx, match, "", defaultScanBatch)
+	if err != nil {
+		return err
+	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, defaultScanBatch)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		pipe := client.Pipeline()
+		for _, i := range keys {
+			pipe.Unlink(i)
+		}
+		if _, err := pipe.ExecContext(ctx); err != nil {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to delete keys")
+		}
+		keys = keys[:0]
+		return nil
+	}
+	for iter.Next() {
+		keys = append(keys, iter.Key())
+		if len(keys) >= defaultScanBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// DelSubtree is not permitted on the root KVStore
+func (s *service) DelSubtree(ctx context.Context) error {
+	return governor.ErrWithKind(nil, ErrVal{}, "May not delete root kvstore")
+}
+
+func (s *service) registerSubtree(prefix string) {
+	s.submu.Lock()
+	defer s.submu.Unlock()
+	s.subtrees[prefix] = struct{}{}
+}
+
+func (s *service) registeredSubtrees() []string {
+	s.submu.RLock()
+	defer s.submu.RUnlock()
+	prefixes := make([]string, 0, len(s.subtrees))
+	for k := range s.subtrees {
+		prefixes = append(prefixes, k)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// subtreeStats returns the key count and memory usage of a subtree
+//
+// Each node is scanned for at most limit keys, so that the stats of a large
+// subtree do not require scanning the entire keyspace. A limit less than 1
+// disables the limit.
+func (s *service) subtreeStats(ctx context.Context, nodes []*redis.Client, prefix string, limit int) (*SubtreeStats, error) {
+	match := subtreePattern(s.hashTags, prefix)
+	stats := &SubtreeStats{
+		Prefix: prefix,
+	}
+	for _, node := range nodes {
+		var cursor uint64
+		scanned := 0
+		for {
+			keys, next, err := node.Scan(cursor, match, defaultScanBatch).Result()
+			if err != nil {
+				return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to scan keys")
+			}
+			if limit > 0 && scanned+len(keys) > limit {
+				keys = keys[:limit-scanned]
+				stats.Truncated = true
+			}
+			if err := s.addKeyStats(ctx, node, keys, stats); err != nil {
+				return nil, err
+			}
+			// the count of a scan is the number of slots examined rather than keys
+			// matched, and bounds the work done by the server for each call
+			if len(keys) > defaultScanBatch {
+				scanned += len(keys)
+			} else {
+				scanned += defaultScanBatch
+			}
+			cursor = next
+			if cursor == 0 || stats.Truncated {
+				break
+			}
+			if limit > 0 && scanned >= limit {
+				stats.Truncated = true
+				break
+			}
+		}
+	}
+	return stats, nil
+}
+
+// addKeyStats adds the memory usage of keys to the stats of a subtree
+func (s *service) addKeyStats(ctx context.Context, node *redis.Client, keys []string, stats *SubtreeStats) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if s.nomemusage {
+		stats.Keys += int64(len(keys))
+		return nil
+	}
+	pipe := node.Pipeline()
+	sizes := make([]*redis.IntCmd, 0, len(keys))
+	for _, i := range keys {
+		sizes = append(sizes, pipe.MemoryUsage(i))
+	}
+	if _, err := pipe.ExecContext(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to get key memory usage")
+	}
+	for _, i := range sizes {
+		// keys may expire between the scan and memory usage calls
+		if v, err := i.Result(); err == nil {
+			stats.Keys++
+			stats.Memory += v
+		}
+	}
+	return nil
+}
+
+// Stats returns the total key count and the stats of registered subtrees
+//
+// The total key count is reported by the server without a scan.
+func (s *service) Stats(ctx context.Context) (*Stats, error) {
+	if s.mem != nil {
+		return s.mem.stats(s.registeredSubtrees(), s.statslimit), nil
+	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodes(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, i := range nodes {
+		n, err := i.DBSize().Result()
+		if err != nil {
+			return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get key count")
+		}
+		total += n
+	}
+	prefixes := s.registeredSubtrees()
+	subtrees := make([]SubtreeStats, 0, len(prefixes))
+	for _, i := range prefixes {
+		stats, err := s.subtreeStats(ctx, nodes, i, s.statslimit)
+		if err != nil {
+			return nil, err
+		}
+		subtrees = append(subtrees, *stats)
+	}
+	return &Stats{
+		Keys:     total,
+		Subtrees: subtrees,
+	}, nil
+}
+
+// Scan iterates over keys directly in the subtree matching a glob pattern
+//
+// In cluster mode keys of nested subtrees are not matched, since each subtree
+// has its own hash tag. In other modes they share the prefix of the subtree,
+// and are matched with the nested subtree prefix as part of their key. Keys
+// made with Subkey are directly in the subtree, and may contain separators.
+// Returned keys are relative to the subtree.
+func (t *tree) Scan(ctx context.Context, pattern string, batch int) (ScanIterator, error) {
+	prefix := t.key("")
+	return t.base.scan(ctx, EscapeGlob(prefix)+pattern, prefix, batch)
+}
+
+// DelSubtree deletes all keys in the subtree and its nested subtrees
+func (t *tree) DelSubtree(ctx context.Context) error {
+	return t.base.delPattern(ctx, subtreePattern(t.base.hashTags, t.prefix))
+}
@@ -0,0 +1,88 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range testBackends {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			ctx := context.Background()
+			s := tc.New(t)
+			s.registerSubtree("test")
+			s.registerSubtree("other")
+			kv := s.Subtree("test")
+			nested := kv.Subtree("nested")
+			other := s.Subtree("other")
+
+			for _, i := range []string{"a", "b", "c*"} {
+				assert.NoError(kv.Set(ctx, i, "1", 0))
+			}
+			assert.NoError(kv.Set(ctx, kv.Subkey("e", "f"), "1", 0))
+			assert.NoError(nested.Set(ctx, "d", "1", 0))
+			assert.NoError(other.Set(ctx, "g", "1", 0))
+
+			iter, err := kv.Scan(ctx, "*", 0)
+			assert.NoError(err)
+			var keys []string
+			for iter.Next() {
+				keys = append(keys, iter.Key())
+			}
+			assert.NoError(iter.Err())
+			sort.Strings(keys)
+			if s.hashTags {
+				assert.Equal([]string{"a", "b", "c*", "e:f"}, keys, "Should exclude keys of nested subtrees")
+			} else {
+				assert.Equal([]string{"a", "b", "c*", "e:f", "nested:d"}, keys, "Should include keys of nested subtrees by their prefix")
+			}
+
+			iter, err = kv.Scan(ctx, EscapeGlob("c*"), 0)
+			assert.NoError(err)
+			keys = nil
+			for iter.Next() {
+				keys = append(keys, iter.Key())
+			}
+			assert.NoError(iter.Err())
+			assert.Equal([]string{"c*"}, keys)
+
+			stats, err := s.Stats(ctx)
+			assert.NoError(err)
+			assert.Equal(int64(6), stats.Keys)
+			assert.Equal([]SubtreeStats{
+				{Prefix: "other", Keys: 1},
+				{Prefix: "test", Keys: 5},
+			}, stats.Subtrees)
+
+			s.statslimit = 2
+			stats, err = s.Stats(ctx)
+			assert.NoError(err)
+			assert.Equal(int64(6), stats.Keys)
+			assert.Equal([]SubtreeStats{
+				{Prefix: "other", Keys: 1},
+				{Prefix: "test", Keys: 2, Truncated: true},
+			}, stats.Subtrees)
+
+			assert.NoError(kv.DelSubtree(ctx))
+			_, err = kv.Get(ctx, "a")
+			assert.True(errors.Is(err, ErrNotFound{}))
+			_, err = nested.Get(ctx, "d")
+			assert.True(errors.Is(err, ErrNotFound{}))
+			v, err := other.Get(ctx, "g")
+			assert.NoError(err)
+			assert.Equal("1", v)
+			assert.Error(s.DelSubtree(ctx))
+		})
+	}
+}
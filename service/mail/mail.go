@@ -26,12 +26,20 @@ const (
 	eventStreamChannels = eventStream + ".*"
 	mailChannel         = eventStream + ".mail"
 	mailWorker          = eventStream + "_WORKER"
+	eventSource         = "mail"
+)
+
+var (
+	mailSchema = events.Schema{
+		Type:    "mail.send",
+		Version: 1,
+	}
 )
 
 type (
 	// Mailer is a service wrapper around a mailer instance
 	Mailer interface {
-		Send(from, fromname string, to []string, tpl string, emdata interface{}) error
+		Send(ctx context.Context, from, fromname string, to []string, tpl string, emdata interface{}) error
 	}
 
 	// Service is a Mailer and governor.Service
@@ -149,11 +157,11 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
-	if err := s.events.InitStream(eventStream, []string{eventStreamChannels}, events.StreamOpts{
+	if err := s.events.InitStream(ctx, eventStream, []string{eventStreamChannels}, events.StreamOpts{
 		Replicas:   1,
 		MaxAge:     30 * 24 * time.Hour,
 		MaxBytes:   s.streamsize,
@@ -165,7 +173,7 @@ func (s *service) Setup(req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -174,11 +182,15 @@ func (s *service) Start(ctx context.Context) error {
 		"phase": "start",
 	})
 
-	if _, err := s.events.StreamSubscribe(eventStream, mailChannel, mailWorker, s.mailSubscriber, events.StreamConsumerOpts{
+	if _, err := s.events.StreamSubscribe(eventStream, mailChannel, mailWorker, events.EventWorker(s.mailSubscriber), events.StreamConsumerOpts{
 		AckWait:     30 * time.Second,
 		MaxDeliver:  30,
 		MaxPending:  1024,
 		MaxRequests: 32,
+		BatchSize:   16,
+		Concurrency: 8,
+		RetryBase:   time.Second,
+		RetryMax:    time.Minute,
 	}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to subscribe to mail queue")
 	}
@@ -272,14 +284,14 @@ func (e ErrBuildMail) Error() string {
 	return "Error building email"
 }
 
-func (s *service) mailSubscriber(pinger events.Pinger, msgdata []byte) error {
+func (s *service) mailSubscriber(ctx context.Context, pinger events.Pinger, env *events.Envelope) error {
 	emmsg := &mailmsg{}
-	if err := json.Unmarshal(msgdata, emmsg); err != nil {
+	if err := mailSchema.Decode(env, emmsg); err != nil {
 		return governor.ErrWithKind(err, ErrMailMsg{}, "Failed to decode mail message")
 	}
 	emdata := map[string]string{}
 	if err := json.Unmarshal([]byte(emmsg.Emdata), &emdata); err != nil {
-		return governor.ErrWithKind(err, ErrMailMsg{}, "Failed to decode mail data")
+		return events.WorkerTerm(governor.ErrWithKind(err, ErrMailMsg{}, "Failed to decode mail data"))
 	}
 
 	subject, err := s.tpl.Execute(emmsg.Subjecttpl, emdata)
@@ -443,7 +455,7 @@ func (b *msgbuilder) build() (*bytes.Buffer, error) {
 }
 
 // Send creates and enqueues a new message to be sent
-func (s *service) Send(from, fromname string, to []string, tpl string, emdata interface{}) error {
+func (s *service) Send(ctx context.Context, from, fromname string, to []string, tpl string, emdata interface{}) error {
 	if len(to) == 0 {
 		return governor.ErrWithKind(nil, ErrInvalidMail{}, "Email must have at least one recipient")
 	}
@@ -468,11 +480,12 @@ func (s *service) Send(from, fromname string, to []string, tpl string, emdata in
 		msg.FromName = s.fromName
 	}
 
-	b, err := json.Marshal(msg)
-	if err != nil {
-		return governor.ErrWithKind(err, ErrMailMsg{}, "Failed to encode email to json")
-	}
-	if err := s.events.StreamPublish(mailChannel, b); err != nil {
+	if err := events.StreamPublishEvent(ctx, s.events, mailChannel, events.JSONCodec, events.Event{
+		Type:    mailSchema.Type,
+		Version: mailSchema.Version,
+		Source:  eventSource,
+		Data:    msg,
+	}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to publish new email to message queue")
 	}
 	return nil
@@ -0,0 +1,493 @@
+package objstore
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"xorkevin.dev/governor"
+)
+
+const (
+	fsObjDir = "obj"
+	fsTmpDir = "tmp"
+	// fsMetaLenSize is the size of the metadata length at the end of an object
+	// file
+	fsMetaLenSize = 4
+	fsMetaMaxSize = 1 << 20
+)
+
+type (
+	// fsBucket is a bucket stored on the local filesystem
+	//
+	// Objects are stored at obj/name within the bucket directory. Each object
+	// file holds the object data followed by its json metadata and the length
+	// of the metadata, so that data and metadata are replaced together. Writes
+	// are staged in tmp and renamed into place so that readers never see
+	// partial objects.
+	fsBucket struct {
+		s    *service
+		name string
+	}
+
+	fsMeta struct {
+		ContentType string            `json:"contenttype"`
+		ETag        string            `json:"etag"`
+		Meta        map[string]string `json:"meta,omitempty"`
+		Tags        map[string]string `json:"tags,omitempty"`
+		Expires     int64             `json:"expires,omitempty"`
+	}
+)
+
+func (s *service) initFS(r governor.ConfigReader, l governor.Logger) error {
+	root, err := filepath.Abs(r.GetStr("fs.root"))
+	if err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Invalid fs root")
+	}
+	s.fsroot = root
+	if err := os.MkdirAll(s.fsroot, 0700); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to create fs root")
+	}
+
+	l.Info("loaded config", map[string]string{
+		"backend": s.backend,
+		"fsroot":  s.fsroot,
+	})
+
+	done := make(chan struct{})
+	close(done)
+	s.done = done
+	s.ready = true
+	return nil
+}
+
+// delFSBucket deletes an empty fs bucket if it exists
+func (s *service) delFSBucket(ctx context.Context, name string) error {
+	b := &fsBucket{
+		s:    s,
+		name: name,
+	}
+	if _, err := os.Stat(b.dir()); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to get bucket")
+		}
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to get bucket")
+	}
+	objects, err := b.List(ctx, "", "", 1)
+	if err != nil {
+		return err
+	}
+	if len(objects) != 0 {
+		return governor.ErrWithKind(nil, ErrClient{}, "Bucket is not empty")
+	}
+	if err := os.RemoveAll(b.dir()); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove bucket")
+	}
+	return nil
+}
+
+func (b *fsBucket) dir() string {
+	return filepath.Join(b.s.fsroot, b.name)
+}
+
+// objPath returns the file path of an object
+//
+// Names must be clean relative paths without .. segments, so that objects
+// always remain within the bucket.
+func (b *fsBucket) objPath(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || path.Clean(name) != name {
+		return "", governor.ErrWithKind(nil, ErrClient{}, "Invalid object name")
+	}
+	for _, i := range strings.Split(name, "/") {
+		if i == ".." {
+			return "", governor.ErrWithKind(nil, ErrClient{}, "Invalid object name")
+		}
+	}
+	objdir := filepath.Join(b.dir(), fsObjDir)
+	p := filepath.Join(objdir, filepath.FromSlash(name))
+	if !strings.HasPrefix(p, objdir+string(filepath.Separator)) {
+		return "", governor.ErrWithKind(nil, ErrClient{}, "Invalid object name")
+	}
+	return p, nil
+}
+
+// Init creates the bucket if it does not exist
+func (b *fsBucket) Init(ctx context.Context) error {
+	for _, i := range []string{fsObjDir, fsTmpDir} {
+		if err := os.MkdirAll(filepath.Join(b.dir(), i), 0700); err != nil {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to create bucket")
+		}
+	}
+	return nil
+}
+
+// readMeta reads the metadata of an object file, and returns it along with the
+// size of the object data
+func (b *fsBucket) readMeta(f *os.File) (*fsMeta, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, governor.ErrWithKind(err, ErrClient{}, "Failed to stat object")
+	}
+	if info.IsDir() {
+		return nil, 0, governor.ErrWithKind(nil, ErrNotFound{}, "Failed to find object")
+	}
+	size := info.Size()
+	if size < fsMetaLenSize {
+		return nil, 0, governor.ErrWithKind(nil, ErrClient{}, "Invalid object metadata")
+	}
+	var lenb [fsMetaLenSize]byte
+	if _, err := f.ReadAt(lenb[:], size-fsMetaLenSize); err != nil {
+		return nil, 0, governor.ErrWithKind(err, ErrClient{}, "Failed to read object metadata")
+	}
+	metalen := int64(binary.BigEndian.Uint32(lenb[:]))
+	if metalen > fsMetaMaxSize || metalen > size-fsMetaLenSize {
+		return nil, 0, governor.ErrWithKind(nil, ErrClient{}, "Invalid object metadata")
+	}
+	datasize := size - fsMetaLenSize - metalen
+	metab := make([]byte, metalen)
+	if _, err := f.ReadAt(metab, datasize); err != nil {
+		return nil, 0, governor.ErrWithKind(err, ErrClient{}, "Failed to read object metadata")
+	}
+	m := &fsMeta{}
+	if err := json.Unmarshal(metab, m); err != nil {
+		return nil, 0, governor.ErrWithKind(err, ErrClient{}, "Invalid object metadata")
+	}
+	return m, datasize, nil
+}
+
+func (b *fsBucket) openObject(objpath string) (*os.File, error) {
+	f, err := os.Open(objpath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
+		}
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get object")
+	}
+	return f, nil
+}
+
+func (b *fsBucket) closeObject(f *os.File) {
+	if err := f.Close(); err != nil {
+		b.s.logger.Error("failed to close object", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "fsobjectclose",
+		})
+	}
+}
+
+func (b *fsBucket) objectInfo(name string, f *os.File) (*ObjectInfo, error) {
+	meta, size, err := b.readMeta(f)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to stat object")
+	}
+	userMeta := make(map[string]string, len(meta.Meta))
+	for k, v := range meta.Meta {
+		userMeta[k] = v
+	}
+	return &ObjectInfo{
+		Name:         name,
+		Size:         size,
+		ContentType:  meta.ContentType,
+		ETag:         meta.ETag,
+		LastModified: info.ModTime().Unix(),
+		Meta:         userMeta,
+		Expires:      meta.Expires,
+	}, nil
+}
+
+// statObject returns metadata of an object at a path
+func (b *fsBucket) statObject(name string, objpath string) (*ObjectInfo, error) {
+	f, err := b.openObject(objpath)
+	if err != nil {
+		return nil, err
+	}
+	defer b.closeObject(f)
+	return b.objectInfo(name, f)
+}
+
+// Stat returns metadata of an object from the bucket
+func (b *fsBucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	objpath, err := b.objPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return b.statObject(name, objpath)
+}
+
+type (
+	// fsObject reads and seeks within the data of an object file
+	fsObject struct {
+		*io.SectionReader
+		f *os.File
+	}
+)
+
+func (o *fsObject) Close() error {
+	return o.f.Close()
+}
+
+// Get gets an object from the bucket
+func (b *fsBucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	objpath, err := b.objPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := b.openObject(objpath)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := b.objectInfo(name, f)
+	if err != nil {
+		b.closeObject(f)
+		return nil, nil, err
+	}
+	return &fsObject{
+		SectionReader: io.NewSectionReader(f, 0, info.Size),
+		f:             f,
+	}, info, nil
+}
+
+// writeTmp writes data followed by a trailer to a new synced temporary file
+// in the bucket
+func (b *fsBucket) writeTmp(data io.Reader, trailer func(n int64) ([]byte, error)) (_ string, retErr error) {
+	f, err := os.CreateTemp(filepath.Join(b.dir(), fsTmpDir), "put-")
+	if err != nil {
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to create temp file")
+	}
+	defer func() {
+		if retErr != nil {
+			if err := os.Remove(f.Name()); err != nil {
+				b.s.logger.Error("failed to remove temp file", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "fsputobjectcleanup",
+				})
+			}
+		}
+	}()
+	n, err := io.Copy(f, data)
+	if err != nil {
+		_ = f.Close()
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to write object")
+	}
+	t, err := trailer(n)
+	if err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if _, err := f.Write(t); err != nil {
+		_ = f.Close()
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to write object")
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to write object")
+	}
+	if err := f.Close(); err != nil {
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to write object")
+	}
+	return f.Name(), nil
+}
+
+// Put puts a new object into the bucket
+func (b *fsBucket) Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts putOpts) error {
+	objpath, err := b.objPath(name)
+	if err != nil {
+		return err
+	}
+	h := md5.New()
+	objtmp, err := b.writeTmp(io.TeeReader(object, h), func(n int64) ([]byte, error) {
+		if size >= 0 && n != size {
+			return nil, governor.ErrWithKind(nil, ErrClient{}, "Object size does not match")
+		}
+		metab, err := json.Marshal(fsMeta{
+			ContentType: contentType,
+			ETag:        hex.EncodeToString(h.Sum(nil)),
+			Meta:        opts.meta,
+			Tags:        opts.tags,
+			Expires:     opts.expires,
+		})
+		if err != nil {
+			return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to encode object metadata")
+		}
+		if len(metab) > fsMetaMaxSize {
+			return nil, governor.ErrWithKind(nil, ErrClient{}, "Object metadata too large")
+		}
+		var lenb [fsMetaLenSize]byte
+		binary.BigEndian.PutUint32(lenb[:], uint32(len(metab)))
+		return append(metab, lenb[:]...), nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(objpath), 0700); err != nil {
+		_ = os.Remove(objtmp)
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to create object dir")
+	}
+	if err := os.Rename(objtmp, objpath); err != nil {
+		_ = os.Remove(objtmp)
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to save object to bucket")
+	}
+	return nil
+}
+
+// Del removes an object from the bucket
+func (b *fsBucket) Del(ctx context.Context, name string) error {
+	objpath, err := b.objPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(objpath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
+		}
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove object")
+	}
+	return nil
+}
+
+// PresignGet is not supported by the fs backend
+func (b *fsBucket) PresignGet(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	return "", governor.ErrWithKind(nil, ErrClient{}, "Presigned urls are not supported by the fs backend")
+}
+
+// PresignPut is not supported by the fs backend
+func (b *fsBucket) PresignPut(ctx context.Context, name string, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error) {
+	return nil, governor.ErrWithKind(nil, ErrClient{}, "Presigned urls are not supported by the fs backend")
+}
+
+// listNames returns the sorted names of all objects beginning with prefix
+func (b *fsBucket) listNames(ctx context.Context, prefix string) ([]string, error) {
+	objdir := filepath.Join(b.dir(), fsObjDir)
+	var names []string
+	if err := filepath.WalkDir(objdir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(objdir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	}); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to list objects")
+	}
+	// walk order is not lexicographic by full name across directories
+	sort.Strings(names)
+	return names, nil
+}
+
+// List returns a page of objects with names beginning with prefix
+func (b *fsBucket) List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, error) {
+	names, err := b.listNames(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	start := sort.Search(len(names), func(i int) bool {
+		return names[i] > cursor
+	})
+	names = names[start:]
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+	objects := make([]ObjectInfo, 0, len(names))
+	for _, i := range names {
+		objpath, err := b.objPath(i)
+		if err != nil {
+			return nil, err
+		}
+		info, err := b.statObject(i, objpath)
+		if err != nil {
+			if errors.Is(err, ErrNotFound{}) {
+				// object was removed after listing
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, *info)
+	}
+	return objects, nil
+}
+
+// delExpired removes all objects which expire at or before now
+func (b *fsBucket) delExpired(ctx context.Context, now int64) (int, error) {
+	names, err := b.listNames(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, i := range names {
+		objpath, err := b.objPath(i)
+		if err != nil {
+			return count, err
+		}
+		info, err := b.statObject(i, objpath)
+		if err != nil {
+			if errors.Is(err, ErrNotFound{}) {
+				continue
+			}
+			return count, err
+		}
+		if info.Expires <= 0 || info.Expires > now {
+			continue
+		}
+		if err := b.Del(ctx, i); err != nil {
+			if errors.Is(err, ErrNotFound{}) {
+				continue
+			}
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// delPrefix removes all objects with names beginning with prefix
+func (b *fsBucket) delPrefix(ctx context.Context, prefix string) error {
+	names, err := b.listNames(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, i := range names {
+		if err := b.Del(ctx, i); err != nil && !errors.Is(err, ErrNotFound{}) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTo is not performed by the fs backend, and objects are instead streamed
+func (b *fsBucket) copyTo(ctx context.Context, name string, dst storeBucket, dstName string) (bool, error) {
+	return false, nil
+}
+
+func (b *fsBucket) logger() governor.Logger {
+	return b.s.logger
+}
@@ -0,0 +1,144 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+func TestFSBucket(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ctx := context.Background()
+	s := New().(*service)
+	s.backend = backendFS
+	s.fsroot = t.TempDir()
+	b := s.GetBucket("test")
+	assert.NoError(b.Init(ctx))
+
+	d := b.Subdir("dir")
+	for _, i := range []string{"b", "a", "c/d"} {
+		assert.NoError(d.Put(ctx, i, "text/plain", int64(len(i)), bytes.NewReader([]byte(i))))
+	}
+	assert.Error(d.Put(ctx, "../escape", "text/plain", 1, bytes.NewReader([]byte("x"))), "Should reject names outside the bucket")
+	for _, i := range []string{"../x", "../../x", "/x", "..", "x/"} {
+		assert.Error(b.Put(ctx, i, "text/plain", 1, bytes.NewReader([]byte("x"))), "Should reject names outside the bucket")
+	}
+
+	info, err := d.Stat(ctx, "a")
+	assert.NoError(err)
+	assert.Equal("a", info.Name, "Should return the name relative to the dir")
+	assert.Equal(int64(1), info.Size)
+	assert.Equal("text/plain", info.ContentType, "Should store the content type as metadata")
+	assert.Equal("0cc175b9c0f1b6a831c399e269772661", info.ETag, "Should store the md5 as the etag")
+
+	assert.NoError(d.Put(ctx, "a", "application/json", 2, bytes.NewReader([]byte("{}"))))
+	info, err = d.Stat(ctx, "a")
+	assert.NoError(err)
+	assert.Equal(int64(2), info.Size, "Should replace data and metadata together")
+	assert.Equal("application/json", info.ContentType)
+	assert.Equal("99914b932bd37a50b983c5e7c90ae93b", info.ETag)
+	assert.NoError(d.Put(ctx, "a", "text/plain", 1, bytes.NewReader([]byte("a"))))
+
+	obj, _, err := d.Get(ctx, "c/d")
+	assert.NoError(err)
+	v, err := io.ReadAll(obj)
+	assert.NoError(err)
+	assert.NoError(obj.Close())
+	assert.Equal("c/d", string(v))
+
+	obj, info, err = d.Get(ctx, "c/d")
+	assert.NoError(err)
+	_, ok := obj.(io.ReadSeeker)
+	assert.True(ok, "Should return seekable objects")
+	req := httptest.NewRequest(http.MethodGet, "/obj", nil)
+	req.Header.Set("Range", "bytes=1-")
+	rec := httptest.NewRecorder()
+	governor.NewContext(rec, req, nil).ServeObject(info.ServeInfo(), obj)
+	assert.NoError(obj.Close())
+	assert.Equal(http.StatusPartialContent, rec.Code, "Should serve byte ranges of objects")
+	assert.Equal("/d", rec.Body.String(), "Should not serve object metadata")
+
+	objects, err := d.List(ctx, "", "", 2)
+	assert.NoError(err)
+	assert.Len(objects, 2)
+	assert.Equal("a", objects[0].Name, "Should list in lexicographic order")
+	assert.Equal("b", objects[1].Name)
+	objects, err = d.List(ctx, "", objects[1].Name, 2)
+	assert.NoError(err)
+	assert.Len(objects, 1, "Should list after the cursor")
+	assert.Equal("c/d", objects[0].Name)
+
+	other := b.Subdir("other")
+	assert.NoError(d.Copy(ctx, "a", other, "a"))
+	assert.NoError(d.Move(ctx, "b", other, "b"))
+	_, err = d.Stat(ctx, "b")
+	assert.True(errors.Is(err, ErrNotFound{}), "Should remove moved objects")
+	info, err = other.Stat(ctx, "b")
+	assert.NoError(err)
+	assert.Equal("text/plain", info.ContentType, "Should keep the content type of copied objects")
+
+	assert.NoError(d.DelAll(ctx))
+	objects, err = d.List(ctx, "", "", 0)
+	assert.NoError(err)
+	assert.Len(objects, 0, "Should delete all objects in the dir")
+	objects, err = b.List(ctx, "", "", 0)
+	assert.NoError(err)
+	assert.Len(objects, 2, "Should not delete objects outside the dir")
+}
+
+func TestFSBucketLifecycle(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ctx := context.Background()
+	s := New().(*service)
+	s.backend = backendFS
+	s.fsroot = t.TempDir()
+	b := s.GetBucket("test")
+	assert.NoError(b.Init(ctx))
+
+	now := time.Now().Round(0)
+	assert.NoError(b.Put(ctx, "a", "text/plain", 1, bytes.NewReader([]byte("a")), PutOptMeta(map[string]string{"Owner": "user"}), PutOptExpires(now.Add(-time.Minute))))
+	assert.NoError(b.Put(ctx, "b", "text/plain", 1, bytes.NewReader([]byte("b")), PutOptExpires(now.Add(time.Hour))))
+	assert.Error(b.Put(ctx, "c", "text/plain", 1, bytes.NewReader([]byte("c")), PutOptMeta(map[string]string{metaKeyExpires: "1"})), "Should reject reserved metadata keys")
+
+	info, err := b.Stat(ctx, "a")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"owner": "user"}, info.Meta, "Should return metadata with lower case keys")
+	assert.Equal(now.Add(-time.Minute).Unix(), info.Expires)
+
+	count, err := b.(*bucket).store().delExpired(ctx, now.Unix())
+	assert.NoError(err)
+	assert.Equal(1, count, "Should remove only expired objects")
+	_, err = b.Stat(ctx, "a")
+	assert.True(errors.Is(err, ErrNotFound{}))
+
+	q := NewQuota(b.Subdir("quota"), 4)
+	d := q.Dir("user")
+	assert.NoError(d.Put(ctx, "x", "text/plain", 3, bytes.NewReader([]byte("xxx"))))
+	err = d.Subdir("sub").Put(ctx, "y", "text/plain", 2, bytes.NewReader([]byte("yy")))
+	assert.True(errors.Is(err, ErrQuota{}), "Should reject puts over the quota")
+	assert.NoError(q.Dir("other").Put(ctx, "y", "text/plain", 2, bytes.NewReader([]byte("yy"))), "Should limit each owner separately")
+	assert.NoError(d.Put(ctx, "x", "text/plain", 4, bytes.NewReader([]byte("xxxx"))), "Should not count replaced objects")
+	u, err := DirUsage(ctx, b.Subdir("quota").Subdir("user"))
+	assert.NoError(err)
+	assert.Equal(1, u.Objects)
+	assert.Equal(int64(4), u.Bytes)
+	assert.NoError(d.Del(ctx, "x"))
+	assert.NoError(d.Subdir("sub").Put(ctx, "y", "text/plain", 2, bytes.NewReader([]byte("yy"))), "Should not count deleted objects")
+	used, err := q.Usage(ctx, "user")
+	assert.NoError(err)
+	assert.Equal(int64(2), used)
+}
@@ -0,0 +1,363 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xorkevin.dev/governor"
+)
+
+const (
+	// metaKeyExpires is the reserved metadata key storing the object expiry
+	metaKeyExpires = "governor-expires"
+	// usageListBatch is the page size used when totaling usage
+	usageListBatch = 1000
+	// quotaUsageTime is the time after which quota usage is relisted
+	quotaUsageTime = 5 * time.Minute
+	// quotaSweepSize is the number of owners past which unused quota usage is
+	// removed
+	quotaSweepSize = 1024
+)
+
+type (
+	// PutOpt is an object put option
+	PutOpt = func(o *putOpts)
+
+	putOpts struct {
+		meta    map[string]string
+		tags    map[string]string
+		expires int64
+	}
+)
+
+// PutOptMeta sets custom metadata on the object
+//
+// Keys are case insensitive.
+func PutOptMeta(meta map[string]string) PutOpt {
+	return func(o *putOpts) {
+		for k, v := range meta {
+			o.meta[strings.ToLower(k)] = v
+		}
+	}
+}
+
+// PutOptTags sets tags on the object
+//
+// Tags may be matched by bucket lifecycle rules and policies of the minio
+// backend, and have no effect on the fs backend.
+func PutOptTags(tags map[string]string) PutOpt {
+	return func(o *putOpts) {
+		for k, v := range tags {
+			o.tags[k] = v
+		}
+	}
+}
+
+// PutOptExpires sets the time after which the object is removed by the
+// objstore expire job
+func PutOptExpires(t time.Time) PutOpt {
+	return func(o *putOpts) {
+		o.expires = t.Unix()
+	}
+}
+
+func newPutOpts(opts []PutOpt) (*putOpts, error) {
+	o := &putOpts{
+		meta: map[string]string{},
+		tags: map[string]string{},
+	}
+	for _, i := range opts {
+		i(o)
+	}
+	if _, ok := o.meta[metaKeyExpires]; ok {
+		return nil, governor.ErrWithKind(nil, ErrClient{}, "Reserved object metadata key")
+	}
+	return o, nil
+}
+
+// userMeta returns the metadata to store for an object, with the expiry
+// stored under its reserved key
+func (o putOpts) userMeta() map[string]string {
+	meta := make(map[string]string, len(o.meta)+1)
+	for k, v := range o.meta {
+		meta[k] = v
+	}
+	if o.expires > 0 {
+		meta[metaKeyExpires] = strconv.FormatInt(o.expires, 10)
+	}
+	return meta
+}
+
+// splitMeta separates stored metadata into the custom metadata and expiry of
+// an object
+func splitMeta(stored map[string]string) (map[string]string, int64) {
+	var expires int64
+	meta := map[string]string{}
+	for k, v := range stored {
+		if k == metaKeyExpires {
+			if t, err := strconv.ParseInt(v, 10, 64); err == nil {
+				expires = t
+			}
+			continue
+		}
+		meta[k] = v
+	}
+	return meta, expires
+}
+
+func (s *service) registeredBuckets() []string {
+	s.bucketmu.RLock()
+	defer s.bucketmu.RUnlock()
+	names := make([]string, 0, len(s.buckets))
+	for k := range s.buckets {
+		names = append(names, k)
+	}
+	return names
+}
+
+// expire periodically removes expired objects from all buckets
+func (s *service) expire(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.expireTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.delExpired(ctx)
+		}
+	}
+}
+
+func (s *service) delExpired(ctx context.Context) {
+	now := time.Now().Round(0).Unix()
+	for _, i := range s.registeredBuckets() {
+		b := &bucket{
+			s:    s,
+			name: i,
+		}
+		count, err := b.store().delExpired(ctx, now)
+		if err != nil {
+			s.logger.Error("failed to remove expired objects", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "delexpiredobjects",
+				"bucket":     i,
+			})
+		}
+		if count > 0 {
+			s.logger.Info("removed expired objects", map[string]string{
+				"actiontype": "delexpiredobjects",
+				"bucket":     i,
+				"count":      strconv.Itoa(count),
+			})
+		}
+	}
+}
+
+type (
+	// Usage is the storage used by objects in a Dir
+	Usage struct {
+		Objects int
+		Bytes   int64
+	}
+)
+
+// DirUsage returns the total storage used by objects in a Dir
+//
+// Objects are stored per owner by using a Subdir for each userid or orgid, so
+// that the usage of an owner is the usage of its Subdir.
+func DirUsage(ctx context.Context, d Dir) (*Usage, error) {
+	u := &Usage{}
+	cursor := ""
+	for {
+		objects, err := d.List(ctx, "", cursor, usageListBatch)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to list objects")
+		}
+		for _, i := range objects {
+			u.Objects++
+			u.Bytes += i.Size
+		}
+		if len(objects) < usageListBatch {
+			return u, nil
+		}
+		cursor = objects[len(objects)-1].Name
+	}
+}
+
+type (
+	// Quota limits the total storage used by each owner of a Dir
+	//
+	// Objects of an owner are stored in the Subdir of d named by the owner.
+	// Puts and deletes of an owner made through the same Quota are serialized,
+	// and its usage is tracked incrementally and relisted after
+	// quotaUsageTime. Quotas are not shared between instances, so concurrent
+	// puts to one owner from separate instances may together exceed the
+	// quota, and objects removed by other means, such as by expiry, are
+	// counted until the usage is relisted.
+	Quota struct {
+		dir      Dir
+		maxBytes int64
+		mu       *sync.Mutex
+		owners   map[string]*ownerUsage
+	}
+
+	ownerUsage struct {
+		mu      *sync.Mutex
+		refs    int
+		valid   bool
+		bytes   int64
+		expires time.Time
+	}
+
+	// quotaDir is a Dir whose total storage is limited
+	quotaDir struct {
+		Dir
+		quota *Quota
+		owner string
+	}
+)
+
+// NewQuota creates a new Quota which rejects any Put that would increase the
+// total size of objects of an owner past maxBytes with ErrQuota
+//
+// Quotas are enforced only for puts made through Dirs returned by the Quota.
+// Objects uploaded with PresignPut are not checked.
+func NewQuota(d Dir, maxBytes int64) *Quota {
+	return &Quota{
+		dir:      d,
+		maxBytes: maxBytes,
+		mu:       &sync.Mutex{},
+		owners:   map[string]*ownerUsage{},
+	}
+}
+
+// Dir returns the Dir of an owner
+func (q *Quota) Dir(owner string) Dir {
+	return &quotaDir{
+		Dir:   q.dir.Subdir(owner),
+		quota: q,
+		owner: owner,
+	}
+}
+
+// Usage returns the total storage used by an owner
+//
+// Services which stage presigned uploads of an owner may add the usage of the
+// staged uploads to check them against the quota before presigning.
+func (q *Quota) Usage(ctx context.Context, owner string) (int64, error) {
+	u := q.acquire(owner)
+	defer q.release(owner, u)
+	return q.usage(ctx, q.dir.Subdir(owner), u)
+}
+
+// acquire returns the locked usage of an owner
+func (q *Quota) acquire(owner string) *ownerUsage {
+	q.mu.Lock()
+	if len(q.owners) >= quotaSweepSize {
+		now := time.Now()
+		for k, v := range q.owners {
+			if v.refs == 0 && !now.Before(v.expires) {
+				delete(q.owners, k)
+			}
+		}
+	}
+	u, ok := q.owners[owner]
+	if !ok {
+		u = &ownerUsage{
+			mu: &sync.Mutex{},
+		}
+		q.owners[owner] = u
+	}
+	u.refs++
+	q.mu.Unlock()
+	u.mu.Lock()
+	return u
+}
+
+// release unlocks the usage of an owner
+func (q *Quota) release(owner string, u *ownerUsage) {
+	u.mu.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u.refs--
+	if u.refs == 0 && !u.valid {
+		delete(q.owners, owner)
+	}
+}
+
+// usage returns the usage of an owner, listing its Dir if the usage is not
+// known
+func (q *Quota) usage(ctx context.Context, d Dir, u *ownerUsage) (int64, error) {
+	if u.valid && time.Now().Before(u.expires) {
+		return u.bytes, nil
+	}
+	k, err := DirUsage(ctx, d)
+	if err != nil {
+		u.valid = false
+		return 0, err
+	}
+	u.valid = true
+	u.bytes = k.Bytes
+	u.expires = time.Now().Add(quotaUsageTime)
+	return u.bytes, nil
+}
+
+func (d *quotaDir) Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts ...PutOpt) error {
+	if size < 0 {
+		return governor.ErrWithKind(nil, ErrQuota{}, "Object size is required to check quota")
+	}
+	u := d.quota.acquire(d.owner)
+	defer d.quota.release(d.owner, u)
+	usage, err := d.quota.usage(ctx, d.quota.dir.Subdir(d.owner), u)
+	if err != nil {
+		return err
+	}
+	total := usage + size
+	if info, err := d.Dir.Stat(ctx, name); err != nil {
+		if !errors.Is(err, ErrNotFound{}) {
+			return governor.ErrWithMsg(err, "Failed to get existing object")
+		}
+	} else {
+		total -= info.Size
+	}
+	if total > d.quota.maxBytes {
+		return governor.ErrWithKind(nil, ErrQuota{}, "Storage quota exceeded")
+	}
+	if err := d.Dir.Put(ctx, name, contentType, size, object, opts...); err != nil {
+		// a failed put may have partially replaced the object
+		u.valid = false
+		return err
+	}
+	u.bytes = total
+	return nil
+}
+
+func (d *quotaDir) Del(ctx context.Context, name string) error {
+	u := d.quota.acquire(d.owner)
+	defer d.quota.release(d.owner, u)
+	info, err := d.Dir.Stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := d.Dir.Del(ctx, name); err != nil {
+		u.valid = false
+		return err
+	}
+	u.bytes -= info.Size
+	return nil
+}
+
+func (d *quotaDir) Subdir(name string) Dir {
+	return &quotaDir{
+		Dir:   d.Dir.Subdir(name),
+		quota: d.quota,
+		owner: d.owner,
+	}
+}
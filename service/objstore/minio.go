@@ -0,0 +1,335 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6"
+	"xorkevin.dev/governor"
+)
+
+const (
+	minioMetaPrefix = "x-amz-meta-"
+)
+
+type (
+	// minioBucket is a bucket stored in minio
+	minioBucket struct {
+		s    *service
+		name string
+	}
+)
+
+// minioUserMeta returns user metadata with lower case keys and without the
+// amz meta prefix
+func minioUserMeta(stored map[string]string) map[string]string {
+	meta := make(map[string]string, len(stored))
+	for k, v := range stored {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, minioMetaPrefix) {
+			continue
+		}
+		meta[strings.TrimPrefix(k, minioMetaPrefix)] = v
+	}
+	return meta
+}
+
+func minioObjectInfo(name string, info minio.ObjectInfo) *ObjectInfo {
+	stored := make(map[string]string, len(info.Metadata))
+	for k := range info.Metadata {
+		stored[k] = info.Metadata.Get(k)
+	}
+	meta, expires := splitMeta(minioUserMeta(stored))
+	return &ObjectInfo{
+		Name:         name,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified.Unix(),
+		Meta:         meta,
+		Expires:      expires,
+	}
+}
+
+// Init creates the bucket if it does not exist
+func (b *minioBucket) Init(ctx context.Context) error {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := client.BucketExistsWithContext(ctx, b.name)
+	if err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to get bucket")
+	}
+	if !exists {
+		if err := client.MakeBucketWithContext(ctx, b.name, b.s.location); err != nil {
+			return governor.ErrWithKind(err, ErrClient{}, "Failed to create bucket")
+		}
+	}
+	return nil
+}
+
+// Stat returns metadata of an object from the bucket
+func (b *minioBucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.StatObjectWithContext(ctx, b.name, name, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
+		}
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to stat object")
+	}
+	return minioObjectInfo(name, info), nil
+}
+
+// Get gets an object from the bucket
+func (b *minioBucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := client.GetObjectWithContext(ctx, b.name, name, minio.GetObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, nil, governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
+		}
+		return nil, nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get object")
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		return nil, nil, governor.ErrWithKind(err, ErrClient{}, "Failed to stat object")
+	}
+	return obj, minioObjectInfo(name, info), nil
+}
+
+// Put puts a new object into the bucket
+func (b *minioBucket) Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts putOpts) error {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := client.PutObjectWithContext(ctx, b.name, name, object, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: opts.userMeta(),
+		UserTags:     opts.tags,
+	}); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to save object to bucket")
+	}
+	return nil
+}
+
+// Del removes an object from the bucket
+func (b *minioBucket) Del(ctx context.Context, name string) error {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.RemoveObject(b.name, name); err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
+		}
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove object")
+	}
+	return nil
+}
+
+// PresignGet returns a url which may be used to get an object until the ttl
+// expires
+func (b *minioBucket) PresignGet(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	client, err := b.s.getPresigner(ctx, ttl)
+	if err != nil {
+		return "", err
+	}
+	u, err := client.PresignedGetObject(b.name, name, ttl, nil)
+	if err != nil {
+		return "", governor.ErrWithKind(err, ErrClient{}, "Failed to presign get object")
+	}
+	return u.String(), nil
+}
+
+// PresignPut returns a form upload which may be used to put an object until
+// the ttl expires
+//
+// The content type and max size of the object are enforced by minio. The
+// uploaded object expires one ttl after the upload window closes, so that
+// staged uploads which are never confirmed are removed by the expire job.
+func (b *minioBucket) PresignPut(ctx context.Context, name string, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error) {
+	client, err := b.s.getPresigner(ctx, ttl)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Round(0).Add(ttl)
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(b.name); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Invalid bucket name")
+	}
+	if err := policy.SetKey(name); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Invalid object name")
+	}
+	if err := policy.SetExpires(expires); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Invalid presign ttl")
+	}
+	if err := policy.SetContentType(contentType); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Invalid content type")
+	}
+	if err := policy.SetContentLengthRange(1, maxSize); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Invalid max size")
+	}
+	if err := policy.SetUserMetadata(metaKeyExpires, strconv.FormatInt(expires.Add(ttl).Unix(), 10)); err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Invalid upload expiry")
+	}
+	u, formData, err := client.PresignedPostPolicy(policy)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to presign put object")
+	}
+	return &PresignedUpload{
+		URL:      u.String(),
+		FormData: formData,
+		Expires:  expires.Unix(),
+	}, nil
+}
+
+// List returns a page of objects with names beginning with prefix
+func (b *minioBucket) List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	core := minio.Core{Client: client}
+	res, err := core.ListObjectsV2(b.name, prefix, "", false, "", limit, cursor)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to list objects")
+	}
+	objects := make([]ObjectInfo, 0, len(res.Contents))
+	for _, i := range res.Contents {
+		objects = append(objects, ObjectInfo{
+			Name:         i.Key,
+			Size:         i.Size,
+			ETag:         i.ETag,
+			LastModified: i.LastModified.Unix(),
+		})
+	}
+	return objects, nil
+}
+
+// delExpired removes all objects which expire at or before now
+//
+// Expiry is read from the object metadata returned by the minio list with
+// metadata extension.
+func (b *minioBucket) delExpired(ctx context.Context, now int64) (int, error) {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	count := 0
+	for i := range client.ListObjectsV2WithMetadata(b.name, "", true, done) {
+		if i.Err != nil {
+			return count, governor.ErrWithKind(i.Err, ErrClient{}, "Failed to list objects")
+		}
+		if _, expires := splitMeta(minioUserMeta(i.UserMetadata)); expires <= 0 || expires > now {
+			continue
+		}
+		if err := b.Del(ctx, i.Key); err != nil {
+			if errors.Is(err, ErrNotFound{}) {
+				continue
+			}
+			return count, err
+		}
+		count++
+	}
+	if err := ctx.Err(); err != nil {
+		return count, governor.ErrWithKind(err, ErrClient{}, "Context cancelled")
+	}
+	return count, nil
+}
+
+// delPrefix removes all objects with names beginning with prefix
+func (b *minioBucket) delPrefix(ctx context.Context, prefix string) error {
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	names := make(chan string)
+	var listErr error
+	go func() {
+		defer close(names)
+		for i := range client.ListObjectsV2(b.name, prefix, true, done) {
+			if i.Err != nil {
+				listErr = i.Err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case names <- i.Key:
+			}
+		}
+	}()
+	var rmErr error
+	for i := range client.RemoveObjectsWithContext(ctx, b.name, names) {
+		if rmErr == nil {
+			rmErr = i.Err
+		}
+	}
+	if listErr != nil {
+		return governor.ErrWithKind(listErr, ErrClient{}, "Failed to list objects")
+	}
+	if rmErr != nil {
+		return governor.ErrWithKind(rmErr, ErrClient{}, "Failed to remove objects")
+	}
+	if err := ctx.Err(); err != nil {
+		return governor.ErrWithKind(err, ErrClient{}, "Context cancelled")
+	}
+	return nil
+}
+
+// copyTo copies an object within minio
+func (b *minioBucket) copyTo(ctx context.Context, name string, dst storeBucket, dstName string) (bool, error) {
+	d, ok := dst.(*minioBucket)
+	if !ok || d.s != b.s {
+		return false, nil
+	}
+	client, err := b.s.getClient(ctx)
+	if err != nil {
+		return true, err
+	}
+	core := minio.Core{Client: client}
+	if _, err := core.CopyObjectWithContext(ctx, b.name, name, d.name, dstName, nil); err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return true, governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
+		}
+		return true, governor.ErrWithKind(err, ErrClient{}, "Failed to copy object")
+	}
+	return true, nil
+}
+
+func (b *minioBucket) logger() governor.Logger {
+	return b.s.logger
+}
+
+// delMinioBucket deletes a minio bucket if it exists
+func (s *service) delMinioBucket(ctx context.Context, name string) error {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.RemoveBucket(name); err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to get bucket")
+		}
+		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove bucket")
+	}
+	return nil
+}
@@ -4,19 +4,32 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/minio/minio-go/v6"
 	"xorkevin.dev/governor"
 )
 
+const (
+	// drainTimeout is how long the credentials of a replaced client remain
+	// valid for requests in flight on it
+	drainTimeout = time.Minute
+)
+
+const (
+	backendMinio = "minio"
+	backendFS    = "fs"
+)
+
 type (
 	// Objstore is a service wrapper around a object storage client
 	Objstore interface {
 		GetBucket(name string) Bucket
-		DelBucket(name string) error
+		DelBucket(ctx context.Context, name string) error
 	}
 
 	// Service is an Objstore and governor.Service
@@ -31,28 +44,44 @@ type (
 	}
 
 	getClientRes struct {
-		client *minio.Client
-		err    error
+		client    *minio.Client
+		presigner *minio.Client
+		err       error
 	}
 
 	getOp struct {
 		res chan<- getClientRes
+		// presignUntil is when urls presigned with the client expire
+		presignUntil time.Time
 	}
 
 	service struct {
-		client     *minio.Client
-		auth       minioauth
-		addr       string
-		sslmode    bool
-		location   string
-		config     governor.SecretReader
-		logger     governor.Logger
-		ops        chan getOp
-		ready      bool
-		hbfailed   int
-		hbinterval int
-		hbmaxfail  int
-		done       <-chan struct{}
+		client         *minio.Client
+		presigner      *minio.Client
+		auth           minioauth
+		secret         map[string]interface{}
+		drainsecret    map[string]interface{}
+		drainUntil     time.Time
+		presignUntil   time.Time
+		addr           string
+		sslmode        bool
+		presignaddr    string
+		presignsslmode bool
+		location       string
+		backend        string
+		fsroot         string
+		expireTime     time.Duration
+		buckets        map[string]struct{}
+		bucketmu       *sync.RWMutex
+		config         governor.SecretReader
+		logger         governor.Logger
+		ops            chan getOp
+		ready          bool
+		hbfailed       int
+		hbinterval     int
+		hbmaxfail      int
+		done           <-chan struct{}
+		expiredone     <-chan struct{}
 	}
 
 	ctxKeyObjstore struct{}
@@ -98,6 +127,8 @@ func NewBucketInCtx(inj governor.Injector, name string) {
 func New() Service {
 	return &service{
 		ops:      make(chan getOp),
+		buckets:  map[string]struct{}{},
+		bucketmu: &sync.RWMutex{},
 		ready:    false,
 		hbfailed: 0,
 	}
@@ -106,11 +137,17 @@ func New() Service {
 func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
 	setCtxObjstore(inj, s)
 
+	r.SetDefault("backend", backendMinio)
+	r.SetDefault("fs.root", "objstore")
 	r.SetDefault("auth", "")
 	r.SetDefault("host", "localhost")
 	r.SetDefault("port", "9000")
 	r.SetDefault("sslmode", false)
+	r.SetDefault("presign.host", "")
+	r.SetDefault("presign.port", "")
+	r.SetDefault("presign.sslmode", false)
 	r.SetDefault("location", "us-east-1")
+	r.SetDefault("expiretime", "1h")
 	r.SetDefault("hbinterval", 5)
 	r.SetDefault("hbmaxfail", 5)
 }
@@ -122,6 +159,10 @@ type (
 	ErrClient struct{}
 	// ErrNotFound is returned when an object is not found
 	ErrNotFound struct{}
+	// ErrInvalidUpload is returned when an uploaded object does not match expectations
+	ErrInvalidUpload struct{}
+	// ErrQuota is returned when a put would exceed a storage quota
+	ErrQuota struct{}
 )
 
 func (e ErrConn) Error() string {
@@ -136,6 +177,14 @@ func (e ErrNotFound) Error() string {
 	return "Object not found"
 }
 
+func (e ErrInvalidUpload) Error() string {
+	return "Invalid upload"
+}
+
+func (e ErrQuota) Error() string {
+	return "Storage quota exceeded"
+}
+
 func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
 	s.logger = l
 	l = s.logger.WithData(map[string]string{
@@ -144,25 +193,56 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 
 	s.config = r
 
+	if t, err := time.ParseDuration(r.GetStr("expiretime")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse expire time")
+	} else {
+		s.expireTime = t
+	}
+
+	s.backend = r.GetStr("backend")
+	switch s.backend {
+	case backendMinio:
+	case backendFS:
+		return s.initFS(r, l)
+	default:
+		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Invalid objstore backend")
+	}
+
 	s.addr = fmt.Sprintf("%s:%s", r.GetStr("host"), r.GetStr("port"))
 	s.sslmode = r.GetBool("sslmode")
+	// presigned urls are signed for the host clients reach the objstore at,
+	// which may differ from the internal addr
+	if presignhost := r.GetStr("presign.host"); presignhost != "" {
+		s.presignaddr = presignhost
+		if presignport := r.GetStr("presign.port"); presignport != "" {
+			s.presignaddr = fmt.Sprintf("%s:%s", presignhost, presignport)
+		}
+		s.presignsslmode = r.GetBool("presign.sslmode")
+	} else {
+		s.presignaddr = s.addr
+		s.presignsslmode = s.sslmode
+	}
 	s.location = r.GetStr("location")
 	s.hbinterval = r.GetInt("hbinterval")
 	s.hbmaxfail = r.GetInt("hbmaxfail")
 
 	l.Info("loaded config", map[string]string{
-		"addr":       s.addr,
-		"sslmode":    strconv.FormatBool(s.sslmode),
-		"location":   s.location,
-		"hbinterval": strconv.Itoa(s.hbinterval),
-		"hbmaxfail":  strconv.Itoa(s.hbmaxfail),
+		"backend":        s.backend,
+		"addr":           s.addr,
+		"sslmode":        strconv.FormatBool(s.sslmode),
+		"presignaddr":    s.presignaddr,
+		"presignsslmode": strconv.FormatBool(s.presignsslmode),
+		"location":       s.location,
+		"expiretime":     s.expireTime.String(),
+		"hbinterval":     strconv.Itoa(s.hbinterval),
+		"hbmaxfail":      strconv.Itoa(s.hbmaxfail),
 	})
 
 	done := make(chan struct{})
 	go s.execute(ctx, done)
 	s.done = done
 
-	if _, err := s.getClient(); err != nil {
+	if _, err := s.getClient(ctx); err != nil {
 		return err
 	}
 	return nil
@@ -181,37 +261,45 @@ func (s *service) execute(ctx context.Context, done chan<- struct{}) {
 			s.handlePing()
 		case op := <-s.ops:
 			client, err := s.handleGetClient()
+			if err == nil && op.presignUntil.After(s.presignUntil) {
+				s.presignUntil = op.presignUntil
+			}
 			op.res <- getClientRes{
-				client: client,
-				err:    err,
+				client:    client,
+				presigner: s.presigner,
+				err:       err,
 			}
 			close(op.res)
 		}
 	}
 }
 
+// handlePing checks the health of the client
+//
+// The credentials of a healthy client are also checked on each heartbeat so
+// that they are rotated before they expire.
 func (s *service) handlePing() {
+	s.handleDraining()
 	if s.client != nil {
-		_, err := s.client.ListBuckets()
-		if err == nil {
+		if _, err := s.client.ListBuckets(); err != nil {
+			s.hbfailed++
+			if s.hbfailed < s.hbmaxfail {
+				s.logger.Warn("failed to ping objstore", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "pingobj",
+					"address":    s.addr,
+					"username":   s.auth.username,
+				})
+				return
+			}
+			s.ready = false
+			s.hbfailed = 0
+			s.auth = minioauth{}
+			s.config.InvalidateSecret("auth")
+		} else {
 			s.ready = true
 			s.hbfailed = 0
-			return
 		}
-		s.hbfailed++
-		if s.hbfailed < s.hbmaxfail {
-			s.logger.Warn("failed to ping objstore", map[string]string{
-				"error":      err.Error(),
-				"actiontype": "pingobj",
-				"address":    s.addr,
-				"username":   s.auth.username,
-			})
-			return
-		}
-		s.ready = false
-		s.hbfailed = 0
-		s.auth = minioauth{}
-		s.config.InvalidateSecret("auth")
 	}
 	if _, err := s.handleGetClient(); err != nil {
 		s.logger.Error("failed to create objstore client", map[string]string{
@@ -238,39 +326,111 @@ func (s *service) handleGetClient() (*minio.Client, error) {
 		return s.client, nil
 	}
 
-	s.closeClient()
-
-	client, err := minio.New(s.addr, auth.username, auth.password, s.sslmode)
+	client, presigner, err := s.newClients(auth)
 	if err != nil {
-		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to create objstore client")
-	}
-	if _, err := client.ListBuckets(); err != nil {
-		s.config.InvalidateSecret("auth")
-		return nil, governor.ErrWithKind(err, ErrConn{}, "Failed to ping objstore")
+		if s.client != nil && s.ready {
+			// the previous credentials remain valid until they expire
+			s.logger.Warn("failed to rotate objstore credentials", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "rotateobjauth",
+				"address":    s.addr,
+				"username":   s.auth.username,
+			})
+			return s.client, nil
+		}
+		s.closeClient()
+		return nil, err
 	}
 
+	if s.client != nil {
+		// minio clients hold no connections that must be closed, but requests in
+		// flight on the previous client and urls presigned with it are allowed to
+		// finish before its credentials are revoked
+		s.closeDraining()
+		s.drainsecret = s.secret
+		s.drainUntil = time.Now().Add(drainTimeout)
+		if s.presignUntil.After(s.drainUntil) {
+			s.drainUntil = s.presignUntil
+		}
+	}
+	s.presignUntil = time.Time{}
 	s.client = client
+	s.presigner = presigner
 	s.auth = auth
-	s.ready = false
+	s.secret = authsecret
+	s.ready = true
 	s.hbfailed = 0
 	s.logger.Info(fmt.Sprintf("established connection to %s with key %s", s.addr, s.auth.username), nil)
 	return s.client, nil
 }
 
+// newClients creates and pings a new client and presign client
+func (s *service) newClients(auth minioauth) (*minio.Client, *minio.Client, error) {
+	client, err := minio.New(s.addr, auth.username, auth.password, s.sslmode)
+	if err != nil {
+		return nil, nil, governor.ErrWithKind(err, ErrClient{}, "Failed to create objstore client")
+	}
+	if _, err := client.ListBuckets(); err != nil {
+		s.config.InvalidateSecret("auth")
+		return nil, nil, governor.ErrWithKind(err, ErrConn{}, "Failed to ping objstore")
+	}
+	// the region is provided so that presigning does not make any requests to
+	// the public addr
+	presigner, err := minio.NewWithRegion(s.presignaddr, auth.username, auth.password, s.presignsslmode, s.location)
+	if err != nil {
+		return nil, nil, governor.ErrWithKind(err, ErrClient{}, "Failed to create objstore presign client")
+	}
+	return client, presigner, nil
+}
+
 func (s *service) closeClient() {
+	s.closeDraining()
 	s.client = nil
+	s.presigner = nil
 	s.auth = minioauth{}
+	s.secret = nil
+}
+
+// handleDraining revokes the credentials of the replaced client after they are
+// no longer in use
+func (s *service) handleDraining() {
+	if s.drainsecret == nil {
+		return
+	}
+	if time.Now().Before(s.drainUntil) {
+		return
+	}
+	s.closeDraining()
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+// closeDraining revokes the credentials of the replaced client
+func (s *service) closeDraining() {
+	if s.drainsecret == nil {
+		return
+	}
+	if err := s.config.RevokeSecret("auth", s.drainsecret); err != nil {
+		s.logger.Error("failed to revoke objstore credentials", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "revokeobjauth",
+		})
+	}
+	s.drainsecret = nil
+}
+
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
 func (s *service) Start(ctx context.Context) error {
+	if s.expireTime > 0 {
+		done := make(chan struct{})
+		go s.expire(ctx, done)
+		s.expiredone = done
+	}
 	return nil
 }
 
@@ -278,11 +438,16 @@ func (s *service) Stop(ctx context.Context) {
 	l := s.logger.WithData(map[string]string{
 		"phase": "stop",
 	})
-	select {
-	case <-s.done:
-		return
-	case <-ctx.Done():
-		l.Warn("failed to stop", nil)
+	for _, i := range []<-chan struct{}{s.done, s.expiredone} {
+		if i == nil {
+			continue
+		}
+		select {
+		case <-i:
+		case <-ctx.Done():
+			l.Warn("failed to stop", nil)
+			return
+		}
 	}
 }
 
@@ -293,185 +458,380 @@ func (s *service) Health() error {
 	return nil
 }
 
-func (s *service) getClient() (*minio.Client, error) {
+func (s *service) getClients(ctx context.Context, presignUntil time.Time) (*getClientRes, error) {
 	res := make(chan getClientRes)
 	op := getOp{
-		res: res,
+		res:          res,
+		presignUntil: presignUntil,
 	}
 	select {
 	case <-s.done:
 		return nil, governor.ErrWithKind(nil, ErrConn{}, "Objstore service shutdown")
+	case <-ctx.Done():
+		return nil, governor.ErrWithKind(ctx.Err(), ErrConn{}, "Context cancelled")
 	case s.ops <- op:
 		v := <-res
-		return v.client, v.err
+		if v.err != nil {
+			return nil, v.err
+		}
+		return &v, nil
 	}
 }
 
+func (s *service) getClient(ctx context.Context) (*minio.Client, error) {
+	v, err := s.getClients(ctx, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return v.client, nil
+}
+
+// getPresigner returns the presign client for urls which expire after the ttl
+func (s *service) getPresigner(ctx context.Context, ttl time.Duration) (*minio.Client, error) {
+	v, err := s.getClients(ctx, time.Now().Add(ttl))
+	if err != nil {
+		return nil, err
+	}
+	return v.presigner, nil
+}
+
 // GetBucket returns the bucket of the given name
 func (s *service) GetBucket(name string) Bucket {
+	s.bucketmu.Lock()
+	s.buckets[name] = struct{}{}
+	s.bucketmu.Unlock()
 	return &bucket{
-		s:        s,
-		name:     name,
-		location: s.location,
+		s:    s,
+		name: name,
 	}
 }
 
 // DelBucket deletes the bucket if it exists
-func (s *service) DelBucket(name string) error {
-	client, err := s.getClient()
-	if err != nil {
-		return err
+func (s *service) DelBucket(ctx context.Context, name string) error {
+	if s.backend == backendFS {
+		return s.delFSBucket(ctx, name)
 	}
-	if err := client.RemoveBucket(name); err != nil {
-		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
-			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to get bucket")
-		}
-		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove bucket")
-	}
-	return nil
+	return s.delMinioBucket(ctx, name)
 }
 
 type (
 	// ObjectInfo is stored object metadata
+	//
+	// Name is relative to the Dir the object info is retrieved from.
+	// ContentType, Meta, and Expires may not be returned by List. Meta keys are
+	// returned in lower case. Expires is a unix timestamp in seconds, and is 0
+	// for objects that do not expire.
 	ObjectInfo struct {
+		Name         string
 		Size         int64
 		ContentType  string
 		ETag         string
 		LastModified int64
+		Meta         map[string]string
+		Expires      int64
+	}
+
+	// PresignedUpload is a presigned form upload
+	//
+	// The object is uploaded by a multipart form POST to URL with all of
+	// FormData as fields, followed by the object as the file field.
+	PresignedUpload struct {
+		URL      string
+		FormData map[string]string
+		Expires  int64
 	}
 
 	// Dir is a collection of objects in the store at a specified directory
 	Dir interface {
-		Stat(name string) (*ObjectInfo, error)
-		Get(name string) (io.ReadCloser, *ObjectInfo, error)
-		Put(name string, contentType string, size int64, object io.Reader) error
-		Del(name string) error
+		Stat(ctx context.Context, name string) (*ObjectInfo, error)
+		Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error)
+		Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts ...PutOpt) error
+		Del(ctx context.Context, name string) error
+		PresignGet(ctx context.Context, name string, ttl time.Duration) (string, error)
+		PresignPut(ctx context.Context, name string, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error)
+		List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, error)
+		DelAll(ctx context.Context) error
+		Copy(ctx context.Context, name string, dst Dir, dstName string) error
+		Move(ctx context.Context, name string, dst Dir, dstName string) error
 		Subdir(name string) Dir
 	}
 
 	// Bucket is a collection of items of the object store service
 	Bucket interface {
 		Dir
-		Init() error
+		Init(ctx context.Context) error
+	}
+
+	// storeBucket is a bucket of an objstore backend
+	storeBucket interface {
+		Init(ctx context.Context) error
+		Stat(ctx context.Context, name string) (*ObjectInfo, error)
+		Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error)
+		Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts putOpts) error
+		Del(ctx context.Context, name string) error
+		PresignGet(ctx context.Context, name string, ttl time.Duration) (string, error)
+		PresignPut(ctx context.Context, name string, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error)
+		List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, error)
+		// delExpired removes all objects which expire at or before now, and
+		// returns the number of removed objects
+		delExpired(ctx context.Context, now int64) (int, error)
+		delPrefix(ctx context.Context, prefix string) error
+		// copyTo copies an object within the backend, returning false if dst is
+		// not of the same backend
+		copyTo(ctx context.Context, name string, dst storeBucket, dstName string) (bool, error)
+		logger() governor.Logger
+	}
+
+	// bucket is a Bucket of the configured backend
+	//
+	// The backend is resolved on use, since buckets are created before the
+	// objstore service is initialized.
+	bucket struct {
+		s    *service
+		name string
 	}
 
-	bucket struct {
-		s        *service
-		name     string
-		location string
+	// locator resolves a name in a Dir to its backend bucket and full object
+	// name
+	locator interface {
+		Dir
+		locate(name string) (storeBucket, string)
 	}
 
 	dir struct {
-		parent Dir
+		parent locator
 		name   string
 	}
 )
 
+func (b *bucket) store() storeBucket {
+	if b.s.backend == backendFS {
+		return &fsBucket{
+			s:    b.s,
+			name: b.name,
+		}
+	}
+	return &minioBucket{
+		s:    b.s,
+		name: b.name,
+	}
+}
+
 // Init creates the bucket if it does not exist
-func (b *bucket) Init() error {
-	client, err := b.s.getClient()
+func (b *bucket) Init(ctx context.Context) error {
+	return b.store().Init(ctx)
+}
+
+// Stat returns metadata of an object from the bucket
+func (b *bucket) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	return b.store().Stat(ctx, name)
+}
+
+// Get gets an object from the bucket
+func (b *bucket) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	return b.store().Get(ctx, name)
+}
+
+// Put puts a new object into the bucket
+func (b *bucket) Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts ...PutOpt) error {
+	o, err := newPutOpts(opts)
 	if err != nil {
 		return err
 	}
-	exists, err := client.BucketExists(b.name)
+	return b.store().Put(ctx, name, contentType, size, object, *o)
+}
+
+// Del removes an object from the bucket
+func (b *bucket) Del(ctx context.Context, name string) error {
+	return b.store().Del(ctx, name)
+}
+
+// PresignGet returns a url which may be used to get an object until the ttl
+// expires
+func (b *bucket) PresignGet(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	return b.store().PresignGet(ctx, name, ttl)
+}
+
+// PresignPut returns a form upload which may be used to put an object until
+// the ttl expires
+//
+// Uploads should be checked with ConfirmUpload before being used. Uploaded
+// objects are staged, and expire one ttl after the upload window closes, so
+// services should put a confirmed upload to its final object rather than
+// moving it.
+func (b *bucket) PresignPut(ctx context.Context, name string, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error) {
+	return b.store().PresignPut(ctx, name, contentType, maxSize, ttl)
+}
+
+// List returns objects with names beginning with prefix
+//
+// Objects are returned in lexicographic order of their names, beginning after
+// the cursor. The name of the last returned object is the cursor of the next
+// page.
+func (b *bucket) List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, error) {
+	return b.store().List(ctx, prefix, cursor, limit)
+}
+
+// DelAll removes all objects in the bucket
+func (b *bucket) DelAll(ctx context.Context) error {
+	return b.store().delPrefix(ctx, "")
+}
+
+// Copy copies an object to a destination Dir, which may be in another bucket
+//
+// The object is copied by the backend when the destination is of the same
+// backend, and is otherwise streamed through the service. Tags are not preserved when the object is streamed.
+func (b *bucket) Copy(ctx context.Context, name string, dst Dir, dstName string) error {
+	return copyObject(ctx, b, name, dst, dstName)
+}
+
+// Move moves an object to a destination Dir, which may be in another bucket
+func (b *bucket) Move(ctx context.Context, name string, dst Dir, dstName string) error {
+	return moveObject(ctx, b, name, dst, dstName)
+}
+
+func (b *bucket) locate(name string) (storeBucket, string) {
+	return b.store(), name
+}
+
+func copyObject(ctx context.Context, src locator, name string, dst Dir, dstName string) error {
+	srcBucket, srcName := src.locate(name)
+	if d, ok := dst.(locator); ok {
+		dstBucket, dstObjName := d.locate(dstName)
+		if ok, err := srcBucket.copyTo(ctx, srcName, dstBucket, dstObjName); ok {
+			return err
+		}
+	}
+	obj, info, err := src.Get(ctx, name)
 	if err != nil {
-		return governor.ErrWithKind(err, ErrClient{}, "Failed to get bucket")
+		return err
 	}
-	if !exists {
-		if err := client.MakeBucket(b.name, b.location); err != nil {
-			return governor.ErrWithKind(err, ErrClient{}, "Failed to create bucket")
+	defer func() {
+		if err := obj.Close(); err != nil {
+			srcBucket.logger().Error("failed to close object", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "copyobjectclose",
+			})
 		}
+	}()
+	var opts []PutOpt
+	if len(info.Meta) != 0 {
+		opts = append(opts, PutOptMeta(info.Meta))
+	}
+	if info.Expires > 0 {
+		opts = append(opts, PutOptExpires(time.Unix(info.Expires, 0)))
+	}
+	if err := dst.Put(ctx, dstName, info.ContentType, info.Size, obj, opts...); err != nil {
+		return governor.ErrWithMsg(err, "Failed to copy object")
 	}
 	return nil
 }
 
-// Stat returns metadata of an object from the bucket
-func (b *bucket) Stat(name string) (*ObjectInfo, error) {
-	client, err := b.s.getClient()
-	if err != nil {
-		return nil, err
+func moveObject(ctx context.Context, src locator, name string, dst Dir, dstName string) error {
+	if err := copyObject(ctx, src, name, dst, dstName); err != nil {
+		return err
 	}
-	info, err := client.StatObject(b.name, name, minio.StatObjectOptions{})
-	if err != nil {
-		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
-			return nil, governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
-		}
-		return nil, governor.ErrWithKind(err, ErrClient{}, "Failed to stat object")
+	if err := src.Del(ctx, name); err != nil {
+		return governor.ErrWithMsg(err, "Failed to remove moved object")
 	}
-	return &ObjectInfo{
-		Size:         info.Size,
-		ContentType:  info.ContentType,
-		ETag:         info.ETag,
-		LastModified: info.LastModified.Unix(),
-	}, nil
+	return nil
 }
 
-// Get gets an object from the bucket
-func (b *bucket) Get(name string) (io.ReadCloser, *ObjectInfo, error) {
-	client, err := b.s.getClient()
-	if err != nil {
-		return nil, nil, err
+// ServeInfo returns the info needed to serve the object with
+// governor.Context.ServeObject
+func (o ObjectInfo) ServeInfo() governor.ServeInfo {
+	return governor.ServeInfo{
+		ContentType:  o.ContentType,
+		Size:         o.Size,
+		ETag:         o.ETag,
+		LastModified: o.LastModified,
+		Filename:     path.Base(o.Name),
 	}
-	obj, err := client.GetObject(b.name, name, minio.GetObjectOptions{})
+}
+
+// ConfirmUpload checks that an uploaded object has the expected content type
+// and does not exceed the max size
+//
+// Services should confirm objects uploaded with a presigned upload before
+// committing any change referencing the object.
+func ConfirmUpload(ctx context.Context, d Dir, name string, contentType string, maxSize int64) (*ObjectInfo, error) {
+	info, err := d.Stat(ctx, name)
 	if err != nil {
-		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
-			return nil, nil, governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
-		}
-		return nil, nil, governor.ErrWithKind(err, ErrClient{}, "Failed to get object")
+		return nil, err
 	}
-	info, err := obj.Stat()
-	if err != nil {
-		return nil, nil, governor.ErrWithKind(err, ErrClient{}, "Failed to stat object")
+	if info.ContentType != contentType {
+		return nil, governor.ErrWithKind(nil, ErrInvalidUpload{}, "Invalid object content type")
 	}
-	return obj, &ObjectInfo{
-		Size:         info.Size,
-		ContentType:  info.ContentType,
-		ETag:         info.ETag,
-		LastModified: info.LastModified.Unix(),
-	}, nil
+	if info.Size > maxSize {
+		return nil, governor.ErrWithKind(nil, ErrInvalidUpload{}, "Object exceeds max size")
+	}
+	return info, nil
 }
 
-// Put puts a new object into the bucket
-func (b *bucket) Put(name string, contentType string, size int64, object io.Reader) error {
-	client, err := b.s.getClient()
+func (d *dir) Stat(ctx context.Context, name string) (*ObjectInfo, error) {
+	info, err := d.parent.Stat(ctx, d.name+"/"+name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if _, err := client.PutObject(b.name, name, object, size, minio.PutObjectOptions{ContentType: contentType}); err != nil {
-		return governor.ErrWithKind(err, ErrClient{}, "Failed to save object to bucket")
+	info.Name = name
+	return info, nil
+}
+
+func (d *dir) Get(ctx context.Context, name string) (io.ReadCloser, *ObjectInfo, error) {
+	obj, info, err := d.parent.Get(ctx, d.name+"/"+name)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	info.Name = name
+	return obj, info, nil
 }
 
-// Del removes an object from the bucket
-func (b *bucket) Del(name string) error {
-	client, err := b.s.getClient()
+func (d *dir) Put(ctx context.Context, name string, contentType string, size int64, object io.Reader, opts ...PutOpt) error {
+	return d.parent.Put(ctx, d.name+"/"+name, contentType, size, object, opts...)
+}
+
+func (d *dir) Del(ctx context.Context, name string) error {
+	return d.parent.Del(ctx, d.name+"/"+name)
+}
+
+func (d *dir) PresignGet(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	return d.parent.PresignGet(ctx, d.name+"/"+name, ttl)
+}
+
+func (d *dir) PresignPut(ctx context.Context, name string, contentType string, maxSize int64, ttl time.Duration) (*PresignedUpload, error) {
+	return d.parent.PresignPut(ctx, d.name+"/"+name, contentType, maxSize, ttl)
+}
+
+func (d *dir) List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, error) {
+	dirPrefix := d.name + "/"
+	if cursor != "" {
+		cursor = dirPrefix + cursor
+	}
+	objects, err := d.parent.List(ctx, dirPrefix+prefix, cursor, limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := client.RemoveObject(b.name, name); err != nil {
-		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
-			return governor.ErrWithKind(err, ErrNotFound{}, "Failed to find object")
-		}
-		return governor.ErrWithKind(err, ErrClient{}, "Failed to remove object")
+	for n, i := range objects {
+		objects[n].Name = strings.TrimPrefix(i.Name, dirPrefix)
 	}
-	return nil
+	return objects, nil
 }
 
-func (d *dir) Stat(name string) (*ObjectInfo, error) {
-	return d.parent.Stat(d.name + "/" + name)
+func (d *dir) DelAll(ctx context.Context) error {
+	b, prefix := d.locate("")
+	return b.delPrefix(ctx, prefix)
 }
 
-func (d *dir) Get(name string) (io.ReadCloser, *ObjectInfo, error) {
-	return d.parent.Get(d.name + "/" + name)
+func (d *dir) Copy(ctx context.Context, name string, dst Dir, dstName string) error {
+	return copyObject(ctx, d, name, dst, dstName)
 }
 
-func (d *dir) Put(name string, contentType string, size int64, object io.Reader) error {
-	return d.parent.Put(d.name+"/"+name, contentType, size, object)
+func (d *dir) Move(ctx context.Context, name string, dst Dir, dstName string) error {
+	return moveObject(ctx, d, name, dst, dstName)
 }
 
-func (d *dir) Del(name string) error {
-	return d.parent.Del(d.name + "/" + name)
+func (d *dir) locate(name string) (storeBucket, string) {
+	return d.parent.locate(d.name + "/" + name)
 }
 
 func (d *dir) Subdir(name string) Dir {
@@ -0,0 +1,221 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/util/uid"
+)
+
+const (
+	uidSize = 16
+)
+
+type (
+	// Repo is an outbox repository
+	Repo interface {
+		New(channel string, msgdata []byte) (*Model, error)
+		ClaimPending(ctx context.Context, tx db.SQLExecutor, now int64, limit int) ([]Model, error)
+		Insert(ctx context.Context, tx db.SQLExecutor, m *Model) error
+		Lease(ctx context.Context, tx db.SQLExecutor, msgids []string, leaseTime int64) error
+		Release(ctx context.Context, msgids []string) error
+		UpdateState(ctx context.Context, m *Model) error
+		DeletePublished(ctx context.Context, before int64) error
+		Setup(ctx context.Context) error
+	}
+
+	repo struct {
+		db db.Database
+	}
+
+	// Model is the db outbox model
+	//
+	// MsgID is used by the stream to deduplicate messages published more than
+	// once by the relay. Seq and the id of the inserting transaction are
+	// assigned by the db on insert, and messages are ordered by transaction id
+	// and then by Seq. A message which has failed to publish Attempts times is
+	// retried at NextAttempt, and is no longer retried once it is Dead. A
+	// message is leased to a relay until LeaseTime.
+	Model struct {
+		MsgID         string
+		Seq           int64
+		Channel       string
+		Msgdata       []byte
+		CreationTime  int64
+		Published     bool
+		PublishedTime int64
+		Attempts      int
+		NextAttempt   int64
+		Dead          bool
+		LeaseTime     int64
+	}
+
+	ctxKeyRepo struct{}
+)
+
+// GetCtxRepo returns a Repo from the context
+func GetCtxRepo(inj governor.Injector) Repo {
+	v := inj.Get(ctxKeyRepo{})
+	if v == nil {
+		return nil
+	}
+	return v.(Repo)
+}
+
+// SetCtxRepo sets a Repo in the context
+func SetCtxRepo(inj governor.Injector, r Repo) {
+	inj.Set(ctxKeyRepo{}, r)
+}
+
+// NewInCtx creates a new outbox repo from a context and sets it in the context
+func NewInCtx(inj governor.Injector) {
+	SetCtxRepo(inj, NewCtx(inj))
+}
+
+// NewCtx creates a new outbox repo from a context
+func NewCtx(inj governor.Injector) Repo {
+	dbService := db.GetCtxDB(inj)
+	return New(dbService)
+}
+
+// New creates a new outbox repo
+func New(database db.Database) Repo {
+	return &repo{
+		db: database,
+	}
+}
+
+// New creates a new outbox model
+func (r *repo) New(channel string, msgdata []byte) (*Model, error) {
+	u, err := uid.New(uidSize)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to create new uid")
+	}
+	return &Model{
+		MsgID:        u.Base64(),
+		Channel:      channel,
+		Msgdata:      msgdata,
+		CreationTime: time.Now().Round(0).Unix(),
+	}, nil
+}
+
+// ClaimPending locks the oldest messages which are neither published nor
+// dead nor leased nor waiting to be retried at now in a transaction, in
+// message order
+//
+// Messages are held back until every transaction which began before theirs
+// has ended, so that a message which is later in order than a claimed
+// message may not become visible afterwards. Messages of a channel are not
+// returned if an earlier message of the channel is waiting to be retried,
+// locked by another transaction, or leased, so that the messages of each
+// channel are relayed in order by one relay at a time, and a message which
+// fails to publish holds back only the messages of its own channel.
+func (r *repo) ClaimPending(ctx context.Context, tx db.SQLExecutor, now int64, limit int) ([]Model, error) {
+	m, err := outboxModelClaimPending(ctx, tx, now, limit)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, db.ErrClient{}, "Failed to claim pending messages")
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	channels := make([]string, 0, len(m))
+	firsts := make(map[string]string, len(m))
+	for _, i := range m {
+		if _, ok := firsts[i.Channel]; !ok {
+			firsts[i.Channel] = i.MsgID
+			channels = append(channels, i.Channel)
+		}
+	}
+	heads, err := outboxModelGetPendingHeads(ctx, tx, channels)
+	if err != nil {
+		return nil, governor.ErrWithKind(err, db.ErrClient{}, "Failed to get oldest pending messages")
+	}
+	res := make([]Model, 0, len(m))
+	for _, i := range m {
+		// messages skipped by the claim query as locked by another
+		// transaction precede the claimed messages of their channel
+		if heads[i.Channel] != firsts[i.Channel] {
+			continue
+		}
+		res = append(res, i)
+	}
+	return res, nil
+}
+
+// Lease leases messages to a relay until a time in the transaction which
+// claimed them
+func (r *repo) Lease(ctx context.Context, tx db.SQLExecutor, msgids []string, leaseTime int64) error {
+	if len(msgids) == 0 {
+		return nil
+	}
+	if err := outboxModelUpdLeaseHasMsgID(ctx, tx, leaseTime, msgids); err != nil {
+		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to lease messages")
+	}
+	return nil
+}
+
+// Release releases the leases of messages
+func (r *repo) Release(ctx context.Context, msgids []string) error {
+	if len(msgids) == 0 {
+		return nil
+	}
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return err
+	}
+	if err := outboxModelUpdLeaseHasMsgID(ctx, d, 0, msgids); err != nil {
+		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to release messages")
+	}
+	return nil
+}
+
+// Insert inserts the model into the db in a transaction
+func (r *repo) Insert(ctx context.Context, tx db.SQLExecutor, m *Model) error {
+	if code, err := outboxModelInsert(ctx, tx, m); err != nil {
+		if code == 3 {
+			return governor.ErrWithKind(err, db.ErrUnique{}, "Message id must be unique")
+		}
+		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to insert message")
+	}
+	return nil
+}
+
+// UpdateState updates the publish state and lease of a message
+func (r *repo) UpdateState(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return err
+	}
+	if err := outboxModelUpdState(ctx, d, m); err != nil {
+		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to update message state")
+	}
+	return nil
+}
+
+// DeletePublished deletes messages published at or before a time
+func (r *repo) DeletePublished(ctx context.Context, before int64) error {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return err
+	}
+	if err := outboxModelDelEqPublishedLeqPublishedTime(ctx, d, true, before); err != nil {
+		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to delete published messages")
+	}
+	return nil
+}
+
+// Setup creates a new outbox table
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return err
+	}
+	if code, err := outboxModelSetup(ctx, d); err != nil {
+		if code != 5 {
+			return governor.ErrWithKind(err, db.ErrClient{}, "Failed to setup outbox model")
+		}
+	}
+	return nil
+}
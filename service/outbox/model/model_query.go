@@ -0,0 +1,149 @@
+// Queries of the outbox model
+//
+// The outbox claims messages with row locks, and assigns message sequence
+// numbers and transaction ids in the db, none of which are supported by forge,
+// and its queries are maintained by hand.
+//
+// Transaction ids are XID8 values assigned by pg_current_xact_id, and messages
+// are claimed only once their transaction is older than pg_snapshot_xmin of
+// the current snapshot, which require Postgres 13 or later. As a result, a
+// long running transaction holds back the relay of all messages inserted by
+// transactions which began after it.
+
+package model
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"xorkevin.dev/governor/service/db"
+)
+
+const (
+	outboxModelTableName = "outbox"
+)
+
+func outboxModelSetup(ctx context.Context, d db.SQLExecutor) (int, error) {
+	_, err := d.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS outbox (msgid VARCHAR(31) PRIMARY KEY, seq BIGSERIAL NOT NULL UNIQUE, txid XID8 NOT NULL DEFAULT pg_current_xact_id(), channel VARCHAR(255) NOT NULL, msgdata BYTEA NOT NULL, creation_time BIGINT NOT NULL, published BOOLEAN NOT NULL, published_time BIGINT NOT NULL, attempts INT NOT NULL, next_attempt BIGINT NOT NULL, dead BOOLEAN NOT NULL, lease_time BIGINT NOT NULL);")
+	if err != nil {
+		return 0, err
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS outbox_published_dead_txid_seq_index ON outbox (published, dead, txid, seq);")
+	if err != nil {
+		if postgresErr, ok := err.(*pq.Error); ok {
+			switch postgresErr.Code {
+			case "42501": // insufficient_privilege
+				return 5, err
+			default:
+				return 0, err
+			}
+		}
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS outbox_channel_published_dead_txid_seq_index ON outbox (channel, published, dead, txid, seq);")
+	if err != nil {
+		if postgresErr, ok := err.(*pq.Error); ok {
+			switch postgresErr.Code {
+			case "42501": // insufficient_privilege
+				return 5, err
+			default:
+				return 0, err
+			}
+		}
+	}
+	_, err = d.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS outbox_published_time_index ON outbox (published_time);")
+	if err != nil {
+		if postgresErr, ok := err.(*pq.Error); ok {
+			switch postgresErr.Code {
+			case "42501": // insufficient_privilege
+				return 5, err
+			default:
+				return 0, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+func outboxModelInsert(ctx context.Context, d db.SQLExecutor, m *Model) (int, error) {
+	_, err := d.ExecContext(ctx, "INSERT INTO outbox (msgid, channel, msgdata, creation_time, published, published_time, attempts, next_attempt, dead, lease_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);", m.MsgID, m.Channel, m.Msgdata, m.CreationTime, m.Published, m.PublishedTime, m.Attempts, m.NextAttempt, m.Dead, m.LeaseTime)
+	if err != nil {
+		if postgresErr, ok := err.(*pq.Error); ok {
+			switch postgresErr.Code {
+			case "23505": // unique_violation
+				return 3, err
+			default:
+				return 0, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+// outboxModelClaimPending locks the oldest pending messages which are neither
+// locked by another transaction, leased, nor waiting to be retried, whose
+// transaction is older than every transaction in progress, and which follow no
+// earlier pending message of their channel which is leased or waiting to be
+// retried
+func outboxModelClaimPending(ctx context.Context, d db.SQLExecutor, now int64, limit int) ([]Model, error) {
+	res := make([]Model, 0, limit)
+	rows, err := d.QueryContext(ctx, "SELECT msgid, seq, channel, msgdata, creation_time, published, published_time, attempts, next_attempt, dead, lease_time FROM outbox o WHERE published = false AND dead = false AND lease_time <= $1 AND next_attempt <= $1 AND txid < pg_snapshot_xmin(pg_current_snapshot()) AND NOT EXISTS (SELECT 1 FROM outbox p WHERE p.channel = o.channel AND p.published = false AND p.dead = false AND (p.txid, p.seq) < (o.txid, o.seq) AND (p.lease_time > $1 OR p.next_attempt > $1)) ORDER BY txid ASC, seq ASC LIMIT $2 FOR UPDATE SKIP LOCKED;", now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+		}
+	}()
+	for rows.Next() {
+		m := Model{}
+		if err := rows.Scan(&m.MsgID, &m.Seq, &m.Channel, &m.Msgdata, &m.CreationTime, &m.Published, &m.PublishedTime, &m.Attempts, &m.NextAttempt, &m.Dead, &m.LeaseTime); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// outboxModelGetPendingHeads returns the message ids of the oldest pending
+// message of each channel, including those locked by other transactions or
+// leased
+func outboxModelGetPendingHeads(ctx context.Context, d db.SQLExecutor, channels []string) (map[string]string, error) {
+	res := make(map[string]string, len(channels))
+	rows, err := d.QueryContext(ctx, "SELECT DISTINCT ON (channel) channel, msgid FROM outbox WHERE channel = ANY($1) AND published = false AND dead = false ORDER BY channel ASC, txid ASC, seq ASC;", pq.Array(channels))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+		}
+	}()
+	for rows.Next() {
+		var channel, msgid string
+		if err := rows.Scan(&channel, &msgid); err != nil {
+			return nil, err
+		}
+		res[channel] = msgid
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func outboxModelUpdLeaseHasMsgID(ctx context.Context, d db.SQLExecutor, leasetime int64, msgids []string) error {
+	_, err := d.ExecContext(ctx, "UPDATE outbox SET lease_time = $1 WHERE msgid = ANY($2);", leasetime, pq.Array(msgids))
+	return err
+}
+
+func outboxModelUpdState(ctx context.Context, d db.SQLExecutor, m *Model) error {
+	_, err := d.ExecContext(ctx, "UPDATE outbox SET (published, published_time, attempts, next_attempt, dead, lease_time) = ROW($1, $2, $3, $4, $5, $6) WHERE msgid = $7;", m.Published, m.PublishedTime, m.Attempts, m.NextAttempt, m.Dead, m.LeaseTime, m.MsgID)
+	return err
+}
+
+func outboxModelDelEqPublishedLeqPublishedTime(ctx context.Context, d db.SQLExecutor, published bool, publishedtime int64) error {
+	_, err := d.ExecContext(ctx, "DELETE FROM outbox WHERE published = $1 AND published_time <= $2;", published, publishedtime)
+	return err
+}
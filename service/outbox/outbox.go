@@ -0,0 +1,405 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/outbox/model"
+)
+
+type (
+	// Outbox is a service which publishes stream messages recorded in the same
+	// db transaction as the state change they describe
+	//
+	// Messages are relayed to the event stream after their transaction
+	// commits, and are published at least once unless they repeatedly fail to
+	// publish and are marked dead. Messages of a channel are relayed in order:
+	// messages of a transaction in the order they were inserted, and after
+	// messages of any transaction which committed before it began. Messages of
+	// concurrent transactions are relayed in the order of their transaction
+	// ids. A message which fails to publish holds back only the later messages
+	// of its channel until it is published or marked dead. Each message is
+	// published with a message id, so that a message relayed more than once
+	// within the duplicate window of its stream is deduplicated.
+	//
+	// The outbox requires Postgres 13 or later, and messages are relayed only
+	// once every transaction which began before theirs has ended, so a long
+	// running transaction holds back the relay of all messages.
+	Outbox interface {
+		Insert(ctx context.Context, tx db.SQLExecutor, channel string, msgdata []byte) error
+	}
+
+	// Service is an Outbox and governor.Service
+	Service interface {
+		governor.Service
+		Outbox
+	}
+
+	service struct {
+		repo        model.Repo
+		database    db.Database
+		events      events.Events
+		logger      governor.Logger
+		relayTime   time.Duration
+		batchSize   int
+		maxAttempts int
+		maxBackoff  time.Duration
+		leaseTime   time.Duration
+		pruneTime   time.Duration
+		pruneAge    int64
+		done        <-chan struct{}
+	}
+
+	ctxKeyOutbox struct{}
+)
+
+// GetCtxOutbox returns an Outbox from the context
+func GetCtxOutbox(inj governor.Injector) Outbox {
+	v := inj.Get(ctxKeyOutbox{})
+	if v == nil {
+		return nil
+	}
+	return v.(Outbox)
+}
+
+// setCtxOutbox sets an Outbox in the context
+func setCtxOutbox(inj governor.Injector, o Outbox) {
+	inj.Set(ctxKeyOutbox{}, o)
+}
+
+// NewCtx creates a new Outbox service from a context
+func NewCtx(inj governor.Injector) Service {
+	repo := model.GetCtxRepo(inj)
+	database := db.GetCtxDB(inj)
+	ev := events.GetCtxEvents(inj)
+	return New(repo, database, ev)
+}
+
+// New creates a new Outbox service
+func New(repo model.Repo, database db.Database, ev events.Events) Service {
+	return &service{
+		repo:     repo,
+		database: database,
+		events:   ev,
+	}
+}
+
+func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr governor.JobRegistrar) {
+	setCtxOutbox(inj, s)
+
+	r.SetDefault("relaytime", "1s")
+	r.SetDefault("batchsize", 256)
+	r.SetDefault("maxattempts", 16)
+	r.SetDefault("maxbackoff", "1h")
+	r.SetDefault("leasetime", "1m")
+	r.SetDefault("prunetime", "1h")
+	r.SetDefault("pruneage", "24h")
+}
+
+func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
+	s.logger = l
+	l = s.logger.WithData(map[string]string{
+		"phase": "init",
+	})
+
+	if t, err := time.ParseDuration(r.GetStr("relaytime")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse relay time")
+	} else {
+		s.relayTime = t
+	}
+	s.batchSize = r.GetInt("batchsize")
+	if s.batchSize < 1 {
+		return governor.ErrWithMsg(nil, "Invalid batch size")
+	}
+	s.maxAttempts = r.GetInt("maxattempts")
+	if s.maxAttempts < 1 {
+		return governor.ErrWithMsg(nil, "Invalid max attempts")
+	}
+	if t, err := time.ParseDuration(r.GetStr("maxbackoff")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse max backoff")
+	} else {
+		s.maxBackoff = t
+	}
+	if t, err := time.ParseDuration(r.GetStr("leasetime")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse lease time")
+	} else {
+		s.leaseTime = t
+	}
+	if t, err := time.ParseDuration(r.GetStr("prunetime")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse prune time")
+	} else {
+		s.pruneTime = t
+	}
+	if t, err := time.ParseDuration(r.GetStr("pruneage")); err != nil {
+		return governor.ErrWithMsg(err, "Failed to parse prune age")
+	} else {
+		s.pruneAge = int64(t / time.Second)
+	}
+
+	l.Info("loaded config", map[string]string{
+		"relaytime":   s.relayTime.String(),
+		"batchsize":   strconv.Itoa(s.batchSize),
+		"maxattempts": strconv.Itoa(s.maxAttempts),
+		"maxbackoff":  s.maxBackoff.String(),
+		"leasetime":   s.leaseTime.String(),
+		"prunetime":   s.pruneTime.String(),
+		"pruneage":    strconv.FormatInt(s.pruneAge, 10),
+	})
+	return nil
+}
+
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
+	l := s.logger.WithData(map[string]string{
+		"phase": "setup",
+	})
+	if err := s.repo.Setup(ctx); err != nil {
+		return err
+	}
+	l.Info("Created outbox table", nil)
+	return nil
+}
+
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
+	return nil
+}
+
+func (s *service) Start(ctx context.Context) error {
+	done := make(chan struct{})
+	go s.execute(ctx, done)
+	s.done = done
+	return nil
+}
+
+// execute periodically relays unpublished messages and prunes published
+// messages
+func (s *service) execute(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	relayTicker := time.NewTicker(s.relayTime)
+	defer relayTicker.Stop()
+	pruneTicker := time.NewTicker(s.pruneTime)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-relayTicker.C:
+			count, err := s.relay(ctx)
+			if err != nil {
+				s.logger.Error("failed to relay outbox messages", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "relayoutbox",
+				})
+			}
+			if count > 0 {
+				s.logger.Debug("relayed outbox messages", map[string]string{
+					"actiontype": "relayoutbox",
+					"count":      strconv.Itoa(count),
+				})
+			}
+		case <-pruneTicker.C:
+			before := time.Now().Round(0).Unix() - s.pruneAge
+			if err := s.repo.DeletePublished(ctx, before); err != nil {
+				s.logger.Error("failed to prune outbox messages", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "pruneoutbox",
+				})
+			}
+		}
+	}
+}
+
+// relay publishes pending messages until none remain
+//
+// Messages are claimed and leased in a short transaction so that relays of
+// other instances do not publish the same messages concurrently, and are
+// published after it commits. A message which fails to publish is retried with
+// backoff, and the later messages of its channel are not relayed until then,
+// so that messages of a channel are not published out of order. A message
+// which fails to publish maxattempts times is marked dead and skipped, and
+// remains in the outbox to be inspected. Messages whose lease expires before
+// they are published may be relayed again by another relay, and are
+// deduplicated by the stream.
+func (s *service) relay(ctx context.Context) (int, error) {
+	count := 0
+	for {
+		n, more, err := s.relayBatch(ctx)
+		count += n
+		if err != nil {
+			return count, err
+		}
+		if !more {
+			return count, nil
+		}
+	}
+}
+
+// relayBatch publishes a batch of pending messages, and returns whether more
+// messages may be pending
+func (s *service) relayBatch(ctx context.Context) (int, bool, error) {
+	msgs, more, err := s.claimBatch(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	count := 0
+	// channels with a message which failed to publish in this batch
+	failed := map[string]struct{}{}
+	var held []model.Model
+	defer func() {
+		s.releaseBatch(ctx, held)
+	}()
+	for n := range msgs {
+		i := &msgs[n]
+		if _, ok := failed[i.Channel]; ok {
+			held = append(held, *i)
+			continue
+		}
+		now := time.Now().Round(0)
+		if now.Unix() >= i.LeaseTime {
+			// messages may now be claimed by another relay
+			return count, true, nil
+		}
+		i.LeaseTime = 0
+		if err := s.events.StreamPublish(ctx, i.Channel, i.Msgdata, events.StreamPublishOptMsgID(i.MsgID)); err != nil {
+			s.failMessage(i, now)
+			if err := s.repo.UpdateState(ctx, i); err != nil {
+				held = append(held, msgs[n:]...)
+				return count, false, governor.ErrWithMsg(err, "Failed to update message state")
+			}
+			if !i.Dead {
+				failed[i.Channel] = struct{}{}
+				s.logger.Error("failed to publish outbox message", map[string]string{
+					"error":      err.Error(),
+					"actiontype": "relayoutboxretry",
+					"msgid":      i.MsgID,
+					"channel":    i.Channel,
+					"attempts":   strconv.Itoa(i.Attempts),
+				})
+				continue
+			}
+			s.logger.Error("failed to publish outbox message, marked dead", map[string]string{
+				"error":      err.Error(),
+				"actiontype": "relayoutboxdead",
+				"msgid":      i.MsgID,
+				"channel":    i.Channel,
+			})
+			continue
+		}
+		i.Published = true
+		i.PublishedTime = now.Unix()
+		if err := s.repo.UpdateState(ctx, i); err != nil {
+			held = append(held, msgs[n:]...)
+			return count, false, governor.ErrWithMsg(err, "Failed to update message state")
+		}
+		count++
+	}
+	return count, more, nil
+}
+
+// claimBatch claims and leases a batch of pending messages, and returns
+// whether more messages may be pending
+func (s *service) claimBatch(ctx context.Context) ([]model.Model, bool, error) {
+	var msgs []model.Model
+	var more bool
+	if err := s.database.WithTx(ctx, nil, func(tx db.SQLExecutor) error {
+		now := time.Now().Round(0)
+		m, err := s.repo.ClaimPending(ctx, tx, now.Unix(), s.batchSize)
+		if err != nil {
+			return err
+		}
+		more = len(m) == s.batchSize
+		leaseTime := now.Add(s.leaseTime).Unix()
+		msgids := make([]string, 0, len(m))
+		for n := range m {
+			m[n].LeaseTime = leaseTime
+			msgids = append(msgids, m[n].MsgID)
+		}
+		if err := s.repo.Lease(ctx, tx, msgids, leaseTime); err != nil {
+			return err
+		}
+		msgs = m
+		return nil
+	}); err != nil {
+		return nil, false, governor.ErrWithMsg(err, "Failed to claim messages")
+	}
+	return msgs, more, nil
+}
+
+// releaseBatch releases the leases of messages which were not published, so
+// that they may be relayed without waiting for their leases to expire
+func (s *service) releaseBatch(ctx context.Context, msgs []model.Model) {
+	msgids := make([]string, 0, len(msgs))
+	for _, i := range msgs {
+		msgids = append(msgids, i.MsgID)
+	}
+	if err := s.repo.Release(ctx, msgids); err != nil {
+		s.logger.Error("failed to release outbox messages", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "releaseoutbox",
+		})
+	}
+}
+
+// failMessage records a failed publish of a message, and schedules its retry
+// with exponential backoff or marks it dead
+func (s *service) failMessage(m *model.Model, now time.Time) {
+	m.Attempts++
+	if m.Attempts >= s.maxAttempts {
+		m.Dead = true
+		return
+	}
+	backoff := s.maxBackoff
+	if m.Attempts < 32 {
+		if k := s.relayTime << m.Attempts; k > 0 && k < backoff {
+			backoff = k
+		}
+	}
+	m.NextAttempt = now.Add(backoff).Unix()
+}
+
+func (s *service) Stop(ctx context.Context) {
+	if s.done == nil {
+		return
+	}
+	l := s.logger.WithData(map[string]string{
+		"phase": "stop",
+	})
+	select {
+	case <-s.done:
+		return
+	case <-ctx.Done():
+		l.Warn("failed to stop", nil)
+	}
+}
+
+func (s *service) Health() error {
+	return nil
+}
+
+// InsertEvent records an event in a new envelope to be published to a channel
+// once tx commits
+func InsertEvent(ctx context.Context, ob Outbox, tx db.SQLExecutor, channel string, codec events.Codec, e events.Event) error {
+	b, err := events.EncodeEvent(codec, e)
+	if err != nil {
+		return err
+	}
+	if err := ob.Insert(ctx, tx, channel, b); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Insert records a message to be published to a channel once tx commits
+func (s *service) Insert(ctx context.Context, tx db.SQLExecutor, channel string, msgdata []byte) error {
+	m, err := s.repo.New(channel, msgdata)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Insert(ctx, tx, m); err != nil {
+		return governor.ErrWithMsg(err, "Failed to insert outbox message")
+	}
+	return nil
+}
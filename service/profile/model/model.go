@@ -1,22 +1,23 @@
 package model
 
 import (
+	"context"
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
 )
 
-//go:generate forge model -m Model -t profiles -p profile -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t profiles -p profile -o model_gen.go Model
 
 type (
 	// Repo is a profile repository
 	Repo interface {
 		New(userid, email, bio string) *Model
-		GetByID(userid string) (*Model, error)
-		GetBulk(userids []string) ([]Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(m *Model) error
-		Setup() error
+		GetByID(ctx context.Context, userid string) (*Model, error)
+		GetBulk(ctx context.Context, userids []string) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -77,12 +78,12 @@ func (r *repo) New(userid, email, bio string) *Model {
 }
 
 // GetByID returns a profile model with the given base64 id
-func (r *repo) GetByID(userid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := profileModelGetModelEqUserid(d, userid)
+	m, code, err := profileModelGetModelEqUserid(ctx, d, userid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No profile found with that id")
@@ -92,12 +93,12 @@ func (r *repo) GetByID(userid string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) GetBulk(userids []string) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetBulk(ctx context.Context, userids []string) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := profileModelGetModelHasUseridOrdUserid(d, userids, true, len(userids), 0)
+	m, err := profileModelGetModelHasUseridOrdUserid(ctx, d, userids, true, len(userids), 0)
 	if err != nil {
 		return nil, governor.ErrWithKind(err, db.ErrClient{}, "Failed to get profiles of userids")
 	}
@@ -105,12 +106,12 @@ func (r *repo) GetBulk(userids []string) ([]Model, error) {
 }
 
 // Insert inserts the model into the db
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := profileModelInsert(d, m); err != nil {
+	if code, err := profileModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Profile id must be unique")
 		}
@@ -120,36 +121,36 @@ func (r *repo) Insert(m *Model) error {
 }
 
 // Update updates the model in the db
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := profileModelUpdModelEqUserid(d, m, m.Userid); err != nil {
+	if _, err := profileModelUpdModelEqUserid(ctx, d, m, m.Userid); err != nil {
 		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to update profile")
 	}
 	return nil
 }
 
 // Delete deletes the model in the db
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := profileModelDelEqUserid(d, m.Userid); err != nil {
+	if err := profileModelDelEqUserid(ctx, d, m.Userid); err != nil {
 		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to delete profile")
 	}
 	return nil
 }
 
 // Setup creates a new Profile table
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := profileModelSetup(d); err != nil {
+	if code, err := profileModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithKind(err, db.ErrClient{}, "Failed to setup profile model")
 		}
@@ -100,23 +100,23 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
-	if err := s.profiles.Setup(); err != nil {
+	if err := s.profiles.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("Created profile table", nil)
 
-	if err := s.profileBucket.Init(); err != nil {
+	if err := s.profileBucket.Init(ctx); err != nil {
 		return governor.ErrWithMsg(err, "Failed to init profile image bucket")
 	}
 	l.Info("Created profile bucket", nil)
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -125,19 +125,27 @@ func (s *service) Start(ctx context.Context) error {
 		"phase": "start",
 	})
 
-	if _, err := s.events.StreamSubscribe(user.EventStream, user.CreateChannel, govworkercreate, s.UserCreateHook, events.StreamConsumerOpts{
+	if _, err := s.events.StreamSubscribe(user.EventStream, user.CreateChannel, govworkercreate, events.EventWorker(s.UserCreateHook), events.StreamConsumerOpts{
 		AckWait:     15 * time.Second,
 		MaxDeliver:  30,
 		MaxPending:  1024,
 		MaxRequests: 32,
+		BatchSize:   16,
+		Concurrency: 4,
+		RetryBase:   time.Second,
+		RetryMax:    time.Minute,
 	}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to subscribe to user create queue")
 	}
-	if _, err := s.events.StreamSubscribe(user.EventStream, user.DeleteChannel, govworkerdelete, s.UserDeleteHook, events.StreamConsumerOpts{
+	if _, err := s.events.StreamSubscribe(user.EventStream, user.DeleteChannel, govworkerdelete, events.EventWorker(s.UserDeleteHook), events.StreamConsumerOpts{
 		AckWait:     15 * time.Second,
 		MaxDeliver:  30,
 		MaxPending:  1024,
 		MaxRequests: 32,
+		BatchSize:   16,
+		Concurrency: 4,
+		RetryBase:   time.Second,
+		RetryMax:    time.Minute,
 	}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to subscribe to user delete queue")
 	}
@@ -153,24 +161,24 @@ func (s *service) Health() error {
 }
 
 // UserCreateHook creates a new profile for a new user
-func (s *service) UserCreateHook(pinger events.Pinger, msgdata []byte) error {
-	props, err := user.DecodeNewUserProps(msgdata)
+func (s *service) UserCreateHook(ctx context.Context, pinger events.Pinger, env *events.Envelope) error {
+	props, err := user.DecodeNewUserProps(env)
 	if err != nil {
 		return err
 	}
-	if _, err := s.CreateProfile(props.Userid, "", ""); err != nil {
+	if _, err := s.CreateProfile(ctx, props.Userid, "", ""); err != nil {
 		return err
 	}
 	return nil
 }
 
 // UserDeleteHook deletes the profile of a deleted user
-func (s *service) UserDeleteHook(pinger events.Pinger, msgdata []byte) error {
-	props, err := user.DecodeDeleteUserProps(msgdata)
+func (s *service) UserDeleteHook(ctx context.Context, pinger events.Pinger, env *events.Envelope) error {
+	props, err := user.DecodeDeleteUserProps(env)
 	if err != nil {
 		return err
 	}
-	if err := s.DeleteProfile(props.Userid); err != nil {
+	if err := s.DeleteProfile(ctx, props.Userid); err != nil {
 		return err
 	}
 	return nil
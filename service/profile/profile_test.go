@@ -0,0 +1,106 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/profile/model"
+	"xorkevin.dev/governor/service/user"
+)
+
+type (
+	testRepo struct {
+		model.Repo
+		profiles map[string]model.Model
+	}
+)
+
+func (r *testRepo) New(userid, email, bio string) *model.Model {
+	return &model.Model{
+		Userid: userid,
+		Email:  email,
+		Bio:    bio,
+	}
+}
+
+func (r *testRepo) Insert(ctx context.Context, m *model.Model) error {
+	r.profiles[m.Userid] = *m
+	return nil
+}
+
+func TestUserCreateHook(t *testing.T) {
+	t.Parallel()
+
+	type (
+		newUserPropsV1 struct {
+			Userid       string `json:"userid"`
+			Username     string `json:"username"`
+			Email        string `json:"email"`
+			FirstName    string `json:"first_name"`
+			LastName     string `json:"last_name"`
+			CreationTime int64  `json:"creation_time"`
+		}
+	)
+
+	for _, tc := range []struct {
+		Test    string
+		Version int
+		Data    interface{}
+		Userid  string
+	}{
+		{
+			Test:    "upcasts version 1 props",
+			Version: 1,
+			Data: newUserPropsV1{
+				Userid:       "userv1",
+				Username:     "userv1",
+				Email:        "userv1@example.com",
+				FirstName:    "First",
+				LastName:     "Last",
+				CreationTime: 1,
+			},
+			Userid: "userv1",
+		},
+		{
+			Test:    "decodes version 2 props",
+			Version: 2,
+			Data: user.NewUserProps{
+				Userid:       "userv2",
+				Username:     "userv2",
+				Email:        "userv2@example.com",
+				FirstName:    "First",
+				LastName:     "Last",
+				CreationTime: 1,
+				Admin:        true,
+			},
+			Userid: "userv2",
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			repo := &testRepo{
+				profiles: map[string]model.Model{},
+			}
+			s := &service{
+				profiles: repo,
+			}
+			env, err := events.NewEnvelope(events.JSONCodec, events.Event{
+				Type:    "user.create",
+				Version: tc.Version,
+				Source:  "user",
+				Data:    tc.Data,
+			})
+			assert.NoError(err)
+			assert.NoError(s.UserCreateHook(context.Background(), nil, env))
+			m, ok := repo.profiles[tc.Userid]
+			assert.True(ok, "Should create a profile for the user")
+			assert.Equal(tc.Userid, m.Userid)
+		})
+	}
+}
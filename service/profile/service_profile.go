@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -28,10 +29,10 @@ type (
 	}
 )
 
-func (s *service) CreateProfile(userid, email, bio string) (*resProfileUpdate, error) {
+func (s *service) CreateProfile(ctx context.Context, userid, email, bio string) (*resProfileUpdate, error) {
 	m := s.profiles.New(userid, email, bio)
 
-	if err := s.profiles.Insert(m); err != nil {
+	if err := s.profiles.Insert(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusBadRequest,
@@ -46,8 +47,8 @@ func (s *service) CreateProfile(userid, email, bio string) (*resProfileUpdate, e
 	}, nil
 }
 
-func (s *service) UpdateProfile(userid, email, bio string) error {
-	m, err := s.profiles.GetByID(userid)
+func (s *service) UpdateProfile(ctx context.Context, userid, email, bio string) error {
+	m, err := s.profiles.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -61,7 +62,7 @@ func (s *service) UpdateProfile(userid, email, bio string) error {
 	m.Email = email
 	m.Bio = bio
 
-	if err := s.profiles.Update(m); err != nil {
+	if err := s.profiles.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update profile")
 	}
 	return nil
@@ -74,8 +75,8 @@ const (
 	thumbQuality = 0
 )
 
-func (s *service) UpdateImage(userid string, img image.Image) error {
-	m, err := s.profiles.GetByID(userid)
+func (s *service) UpdateImage(ctx context.Context, userid string, img image.Image) error {
+	m, err := s.profiles.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -98,19 +99,19 @@ func (s *service) UpdateImage(userid string, img image.Image) error {
 		return governor.ErrWithMsg(err, "Failed to encode image")
 	}
 
-	if err := s.profileDir.Put(userid, image.MediaTypeJpeg, int64(imgJpeg.Len()), imgJpeg); err != nil {
+	if err := s.profileDir.Put(ctx, userid, image.MediaTypeJpeg, int64(imgJpeg.Len()), imgJpeg); err != nil {
 		return governor.ErrWithMsg(err, "Failed to save profile picture")
 	}
 
 	m.Image = thumb64
-	if err := s.profiles.Update(m); err != nil {
+	if err := s.profiles.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update profile")
 	}
 	return nil
 }
 
-func (s *service) DeleteProfile(userid string) error {
-	m, err := s.profiles.GetByID(userid)
+func (s *service) DeleteProfile(ctx context.Context, userid string) error {
+	m, err := s.profiles.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -121,20 +122,20 @@ func (s *service) DeleteProfile(userid string) error {
 		return governor.ErrWithMsg(err, "Failed to get profile")
 	}
 
-	if err := s.profileDir.Del(userid); err != nil {
+	if err := s.profileDir.Del(ctx, userid); err != nil {
 		if !errors.Is(err, objstore.ErrNotFound{}) {
 			return governor.ErrWithMsg(err, "Failed to delete profile picture")
 		}
 	}
 
-	if err := s.profiles.Delete(m); err != nil {
+	if err := s.profiles.Delete(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete profile")
 	}
 	return nil
 }
 
-func (s *service) GetProfile(userid string) (*resProfileModel, error) {
-	m, err := s.profiles.GetByID(userid)
+func (s *service) GetProfile(ctx context.Context, userid string) (*resProfileModel, error) {
+	m, err := s.profiles.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -152,8 +153,8 @@ func (s *service) GetProfile(userid string) (*resProfileModel, error) {
 	}, nil
 }
 
-func (s *service) StatProfileImage(userid string) (*objstore.ObjectInfo, error) {
-	objinfo, err := s.profileDir.Stat(userid)
+func (s *service) StatProfileImage(ctx context.Context, userid string) (*objstore.ObjectInfo, error) {
+	objinfo, err := s.profileDir.Stat(ctx, userid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -166,22 +167,22 @@ func (s *service) StatProfileImage(userid string) (*objstore.ObjectInfo, error)
 	return objinfo, nil
 }
 
-func (s *service) GetProfileImage(userid string) (io.ReadCloser, string, error) {
-	obj, objinfo, err := s.profileDir.Get(userid)
+func (s *service) GetProfileImage(ctx context.Context, userid string) (io.ReadCloser, *objstore.ObjectInfo, error) {
+	obj, objinfo, err := s.profileDir.Get(ctx, userid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
-			return nil, "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			return nil, nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
 				Message: "Profile image not found",
 			}), governor.ErrOptInner(err))
 		}
-		return nil, "", governor.ErrWithMsg(err, "Failed to get profile image")
+		return nil, nil, governor.ErrWithMsg(err, "Failed to get profile image")
 	}
-	return obj, objinfo.ContentType, nil
+	return obj, objinfo, nil
 }
 
-func (s *service) GetProfilesBulk(userids []string) (*resProfiles, error) {
-	m, err := s.profiles.GetBulk(userids)
+func (s *service) GetProfilesBulk(ctx context.Context, userids []string) (*resProfiles, error) {
+	m, err := s.profiles.GetBulk(ctx, userids)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get profiles")
 	}
@@ -80,11 +80,11 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -117,7 +117,7 @@ func (s *service) Ratelimit(tagger Tagger) governor.Middleware {
 			now := time.Now().Round(0).Unix()
 			tags := tagger(c)
 			if len(tags) > 0 {
-				multiget, err := s.tags.Multi()
+				multiget, err := s.tags.Multi(c.Ctx())
 				if err != nil {
 					s.logger.Error("Failed to create kvstore multi", map[string]string{
 						"error": err.Error(),
@@ -134,17 +134,20 @@ func (s *service) Ratelimit(tagger Tagger) governor.Middleware {
 					}
 					t := now / i.Period
 					k := divroundup(i.Expiration, i.Period)
+					// each tag is its own subtree so that tags are spread across
+					// slots in cluster mode
+					tagkv := multiget.Subtree(multiget.Subkey(i.Key, i.Value))
 					periods := make([]kvstore.IntResulter, 0, k)
-					periods = append(periods, multiget.Incr(multiget.Subkey(i.Key, i.Value, strconv.FormatInt(t, 32)), 1))
+					periods = append(periods, tagkv.Incr(strconv.FormatInt(t, 32), 1))
 					for j := int64(1); j < k; j++ {
-						periods = append(periods, multiget.GetInt(multiget.Subkey(i.Key, i.Value, strconv.FormatInt(t-j, 32))))
+						periods = append(periods, tagkv.GetInt(strconv.FormatInt(t-j, 32)))
 					}
 					sums = append(sums, tagSum{
 						limit:   i.Limit,
 						periods: periods,
 					})
 				}
-				if err := multiget.Exec(); err != nil {
+				if err := multiget.Exec(c.Ctx()); err != nil {
 					s.logger.Error("Failed to get tags from cache", map[string]string{
 						"error":      err.Error(),
 						"actiontype": "getratelimittags",
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -9,7 +10,7 @@ import (
 	"xorkevin.dev/governor/service/state"
 )
 
-//go:generate forge model -m Model -t govstate -p state -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t govstate -p state -o model_gen.go Model
 
 const (
 	configID = 0
@@ -49,12 +50,12 @@ func (r *repo) New(version string, vhash string) *Model {
 }
 
 // GetModel returns the state model
-func (r *repo) GetModel() (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetModel(ctx context.Context) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := stateModelGetModelEqconfig(d, configID)
+	m, code, err := stateModelGetModelEqconfig(ctx, d, configID)
 	if err != nil {
 		switch code {
 		case 2:
@@ -69,13 +70,13 @@ func (r *repo) GetModel() (*Model, error) {
 }
 
 // Insert inserts the model into the db
-func (r *repo) Insert(m *Model) error {
+func (r *repo) Insert(ctx context.Context, m *Model) error {
 	m.config = configID
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := stateModelInsert(d, m); err != nil {
+	if code, err := stateModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Failed to insert state")
 		}
@@ -85,21 +86,21 @@ func (r *repo) Insert(m *Model) error {
 }
 
 // Update updates the model in the db
-func (r *repo) Update(m *Model) error {
+func (r *repo) Update(ctx context.Context, m *Model) error {
 	m.config = configID
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := stateModelUpdModelEqconfig(d, m, configID); err != nil {
+	if _, err := stateModelUpdModelEqconfig(ctx, d, m, configID); err != nil {
 		return governor.ErrWithKind(err, db.ErrClient{}, "Failed to update state")
 	}
 	return nil
 }
 
 // Get retrieves the current server state
-func (r *repo) Get() (*state.Model, error) {
-	m, err := r.GetModel()
+func (r *repo) Get(ctx context.Context) (*state.Model, error) {
+	m, err := r.GetModel(ctx)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return &state.Model{
@@ -117,8 +118,8 @@ func (r *repo) Get() (*state.Model, error) {
 }
 
 // Set updates the server state entry
-func (r *repo) Set(m *state.Model) error {
-	return r.Update(&Model{
+func (r *repo) Set(ctx context.Context, m *state.Model) error {
+	return r.Update(ctx, &Model{
 		Setup:        m.Setup,
 		Version:      m.Version,
 		VHash:        m.VHash,
@@ -128,19 +129,19 @@ func (r *repo) Set(m *state.Model) error {
 
 // Setup creates a new State table if it does not exist and updates the server
 // state entry
-func (r *repo) Setup(req state.ReqSetup) error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context, req state.ReqSetup) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := stateModelSetup(d); err != nil {
+	if code, err := stateModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithKind(err, db.ErrClient{}, "Failed to setup state model")
 		}
 	}
 	k := r.New(req.Version, req.VHash)
 	k.Setup = true
-	if err := r.Insert(k); err != nil {
+	if err := r.Insert(ctx, k); err != nil {
 		return err
 	}
 	return nil
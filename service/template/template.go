@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	htmlTemplate "html/template"
+	"mime"
 	"os"
 	"strings"
 	textTemplate "text/template"
@@ -17,6 +18,8 @@ type (
 	Template interface {
 		Execute(templateName string, data interface{}) ([]byte, error)
 		ExecuteHTML(filename string, data interface{}) ([]byte, error)
+		ExecuteHTMLNonce(templateName string, nonce string, data interface{}) ([]byte, error)
+		WriteHTML(c governor.Context, status int, templateName string, data interface{})
 	}
 
 	// Service is a Template and governor.Service
@@ -28,6 +31,7 @@ type (
 	service struct {
 		tt     *textTemplate.Template
 		ht     *htmlTemplate.Template
+		htbase *htmlTemplate.Template
 		logger governor.Logger
 	}
 
@@ -48,6 +52,18 @@ func setCtxTemplate(inj governor.Injector, t Template) {
 	inj.Set(ctxKeyTemplate{}, t)
 }
 
+const (
+	cspNonceFunc = "cspnonce"
+)
+
+func cspNonceFuncMap(nonce string) htmlTemplate.FuncMap {
+	return htmlTemplate.FuncMap{
+		cspNonceFunc: func() string {
+			return nonce
+		},
+	}
+}
+
 // New creates a new Template service
 func New() Service {
 	return &service{}
@@ -77,16 +93,23 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 		}
 	}
 	s.tt = tt
-	ht, err := htmlTemplate.ParseFS(templateDir, r.GetStr("htmlglob"))
+	ht, err := htmlTemplate.New("default").Funcs(cspNonceFuncMap("")).ParseFS(templateDir, r.GetStr("htmlglob"))
 	if err != nil {
 		if err.Error() == fmt.Sprintf("html/template: pattern matches no files: %#q", r.GetStr("dir")+"/*.html") {
 			l.Warn("no templates loaded", nil)
-			ht = htmlTemplate.New("default")
+			ht = htmlTemplate.New("default").Funcs(cspNonceFuncMap(""))
 		} else {
 			return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Failed to load templates")
 		}
 	}
+	// htbase is never executed, since html templates may not be cloned after
+	// execution
+	htbase, err := ht.Clone()
+	if err != nil {
+		return governor.ErrWithKind(err, governor.ErrInvalidConfig{}, "Failed to clone templates")
+	}
 	s.ht = ht
+	s.htbase = htbase
 
 	if k := tt.DefinedTemplates(); k != "" {
 		l.Info("loaded text templates", map[string]string{
@@ -101,11 +124,11 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -146,3 +169,35 @@ func (s *service) ExecuteHTML(templateName string, data interface{}) ([]byte, er
 	}
 	return b.Bytes(), nil
 }
+
+// ExecuteHTMLNonce executes an html template with a csp nonce and returns the
+// templated string
+//
+// The nonce is available to templates with the cspnonce function, e.g.
+// <script nonce="{{cspnonce}}">.
+func (s *service) ExecuteHTMLNonce(templateName string, nonce string, data interface{}) ([]byte, error) {
+	t, err := s.htbase.Clone()
+	if err != nil {
+		return nil, governor.ErrWithKind(err, ErrExecute{}, "Failed cloning html template")
+	}
+	b := &bytes.Buffer{}
+	if err := t.Funcs(cspNonceFuncMap(nonce)).ExecuteTemplate(b, templateName, data); err != nil {
+		return nil, governor.ErrWithKind(err, ErrExecute{}, "Failed executing html template")
+	}
+	return b.Bytes(), nil
+}
+
+// WriteHTML executes an html template with the csp nonce of the request and
+// writes it as the response
+//
+// The nonce is the one set in the security headers of the response by the
+// server, so that inline scripts and styles of the page are allowed by its
+// content security policy.
+func (s *service) WriteHTML(c governor.Context, status int, templateName string, data interface{}) {
+	b, err := s.ExecuteHTMLNonce(templateName, c.CSPNonce(), data)
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+	c.WriteFile(status, mime.FormatMediaType("text/html", map[string]string{"charset": "utf-8"}), bytes.NewReader(b))
+}
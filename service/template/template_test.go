@@ -0,0 +1,52 @@
+package template
+
+import (
+	htmlTemplate "html/template"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+)
+
+func TestWriteHTML(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	ht, err := htmlTemplate.New("default").Funcs(cspNonceFuncMap("")).Parse(`{{define "page.html"}}<script nonce="{{cspnonce}}">{{.}}</script>{{end}}`)
+	assert.NoError(err)
+	htbase, err := ht.Clone()
+	assert.NoError(err)
+	s := &service{
+		ht:     ht,
+		htbase: htbase,
+	}
+
+	h := governor.SecHeaders(map[string]string{
+		governor.HeaderCSP: "default-src 'self'; script-src 'nonce-" + governor.CSPNoncePlaceholder + "'",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.WriteHTML(governor.NewContext(w, r, nil), http.StatusOK, "page.html", "main()")
+	}))
+
+	headerNonce := regexp.MustCompile(`'nonce-([A-Za-z0-9_-]+)'`)
+	bodyNonce := regexp.MustCompile(`<script nonce="([A-Za-z0-9_-]+)">`)
+
+	nonces := map[string]struct{}{}
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(http.StatusOK, rec.Code)
+		assert.Equal("text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		hm := headerNonce.FindStringSubmatch(rec.Header().Get(governor.HeaderCSP))
+		assert.Len(hm, 2, "Should set a csp nonce in the header")
+		bm := bodyNonce.FindStringSubmatch(rec.Body.String())
+		assert.Len(bm, 2, "Should render the csp nonce in the page")
+		assert.Equal(hm[1], bm[1], "Should render the csp nonce of the header")
+		nonces[hm[1]] = struct{}{}
+	}
+	assert.Len(nonces, 2, "Should generate a nonce per request")
+}
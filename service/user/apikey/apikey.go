@@ -6,7 +6,8 @@ import (
 	"time"
 
 	"xorkevin.dev/governor"
-	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/kvstore/nearcache"
 	"xorkevin.dev/governor/service/user/apikey/model"
 )
 
@@ -17,13 +18,15 @@ const (
 type (
 	// Apikeys manages apikeys
 	Apikeys interface {
-		GetUserKeys(userid string, limit, offset int) ([]model.Model, error)
-		CheckKey(keyid, key string) (string, string, error)
-		Insert(userid string, scope string, name, desc string) (*ResApikeyModel, error)
-		RotateKey(keyid string) (*ResApikeyModel, error)
-		UpdateKey(keyid string, scope string, name, desc string) error
-		DeleteKey(keyid string) error
-		DeleteUserKeys(userid string) error
+		GetUserKeys(ctx context.Context, userid string, limit, offset int) ([]model.Model, error)
+		CheckKey(ctx context.Context, keyid, key string) (string, string, error)
+		Insert(ctx context.Context, userid string, scope string, name, desc string) (*ResApikeyModel, error)
+		RotateKey(ctx context.Context, keyid string) (*ResApikeyModel, error)
+		UpdateKey(ctx context.Context, keyid string, scope string, name, desc string) error
+		DeleteKey(ctx context.Context, keyid string) error
+		DeleteUserKeys(ctx context.Context, userid string) error
+		DeleteUserKeysTx(ctx context.Context, tx db.SQLExecutor, userid string) ([]string, error)
+		ClearCache(ctx context.Context, keyids ...string)
 	}
 
 	// Service is an Apikeys and governor.Service
@@ -34,7 +37,7 @@ type (
 
 	service struct {
 		apikeys        model.Repo
-		kvkey          kvstore.KVStore
+		kvkey          nearcache.Cache
 		logger         governor.Logger
 		scopeCacheTime int64
 	}
@@ -59,12 +62,12 @@ func setCtxApikeys(inj governor.Injector, a Apikeys) {
 // NewCtx returns a new Apikeys service from a context
 func NewCtx(inj governor.Injector) Service {
 	apikeys := model.GetCtxRepo(inj)
-	kv := kvstore.GetCtxKVStore(inj)
+	kv := nearcache.GetCtxCache(inj)
 	return New(apikeys, kv)
 }
 
 // New returns a new Apikeys service
-func New(apikeys model.Repo, kv kvstore.KVStore) Service {
+func New(apikeys model.Repo, kv nearcache.Cache) Service {
 	return &service{
 		apikeys:        apikeys,
 		kvkey:          kv.Subtree("key"),
@@ -97,12 +100,12 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
 
-	if err := s.apikeys.Setup(); err != nil {
+	if err := s.apikeys.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created userapikeys table", nil)
@@ -110,7 +113,7 @@ func (s *service) Setup(req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
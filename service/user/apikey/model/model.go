@@ -1,6 +1,8 @@
 package model
 
 import (
+	"context"
+	"database/sql"
 	"strings"
 	"time"
 
@@ -10,7 +12,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t userapikeys -p apikey -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t userapikeys -p apikey -o model_gen.go Model qID
 
 const (
 	uidSize = 8
@@ -21,19 +23,24 @@ const (
 	keySeparator = "|"
 )
 
+const (
+	userKeysBatchSize = 256
+)
+
 type (
 	// Repo is an apikey repository
 	Repo interface {
 		New(userid string, scope string, name, desc string) (*Model, string, error)
 		ValidateKey(key string, m *Model) (bool, error)
 		RehashKey(m *Model) (string, error)
-		GetByID(keyid string) (*Model, error)
-		GetUserKeys(userid string, limit, offset int) ([]Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(m *Model) error
-		DeleteUserKeys(userid string) error
-		Setup() error
+		GetByID(ctx context.Context, keyid string) (*Model, error)
+		GetUserKeys(ctx context.Context, userid string, limit, offset int) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		DeleteUserKeys(ctx context.Context, userid string) ([]string, error)
+		DeleteUserKeysTx(ctx context.Context, tx db.SQLExecutor, userid string) ([]string, error)
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -53,6 +60,10 @@ type (
 		Time    int64  `model:"time,BIGINT NOT NULL;index" query:"time,getgroupeq,userid"`
 	}
 
+	qID struct {
+		Keyid string `query:"keyid,getgroupeq,userid"`
+	}
+
 	ctxKeyRepo struct{}
 )
 
@@ -153,12 +164,12 @@ func (r *repo) RehashKey(m *Model) (string, error) {
 	return keystr, nil
 }
 
-func (r *repo) GetByID(keyid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, keyid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := apikeyModelGetModelEqKeyid(d, keyid)
+	m, code, err := apikeyModelGetModelEqKeyid(ctx, d, keyid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No apikey found with that id")
@@ -168,24 +179,24 @@ func (r *repo) GetByID(keyid string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) GetUserKeys(userid string, limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetUserKeys(ctx context.Context, userid string, limit, offset int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := apikeyModelGetModelEqUseridOrdTime(d, userid, false, limit, offset)
+	m, err := apikeyModelGetModelEqUseridOrdTime(ctx, d, userid, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user apikeys")
 	}
 	return m, nil
 }
 
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := apikeyModelInsert(d, m); err != nil {
+	if code, err := apikeyModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Keyid must be unique")
 		}
@@ -194,45 +205,73 @@ func (r *repo) Insert(m *Model) error {
 	return nil
 }
 
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := apikeyModelUpdModelEqKeyid(d, m, m.Keyid); err != nil {
+	if _, err := apikeyModelUpdModelEqKeyid(ctx, d, m, m.Keyid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update apikey")
 	}
 	return nil
 }
 
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := apikeyModelDelEqKeyid(d, m.Keyid); err != nil {
+	if err := apikeyModelDelEqKeyid(ctx, d, m.Keyid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete apikey")
 	}
 	return nil
 }
 
-func (r *repo) DeleteUserKeys(userid string) error {
-	d, err := r.db.DB()
-	if err != nil {
+// DeleteUserKeys deletes all the apikeys of a user and returns their keyids
+func (r *repo) DeleteUserKeys(ctx context.Context, userid string) ([]string, error) {
+	var keyids []string
+	if err := r.db.WithTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead}, func(tx db.SQLExecutor) error {
+		var err error
+		keyids, err = r.DeleteUserKeysTx(ctx, tx, userid)
 		return err
+	}); err != nil {
+		return nil, err
 	}
-	if err := apikeyModelDelEqUserid(d, userid); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user apikeys")
+	return keyids, nil
+}
+
+// DeleteUserKeysTx deletes all the apikeys of a user in a transaction and
+// returns their keyids
+//
+// The keyids are read in batches before the keys are deleted, so the
+// transaction should be at least repeatable read for the returned keyids to
+// be those which are deleted.
+func (r *repo) DeleteUserKeysTx(ctx context.Context, tx db.SQLExecutor, userid string) ([]string, error) {
+	var keyids []string
+	for offset := 0; ; offset += userKeysBatchSize {
+		m, err := apikeyModelGetqIDEqUseridOrdKeyid(ctx, tx, userid, true, userKeysBatchSize, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get user apikeys")
+		}
+		for _, i := range m {
+			keyids = append(keyids, i.Keyid)
+		}
+		if len(m) < userKeysBatchSize {
+			break
+		}
 	}
-	return nil
+	if err := apikeyModelDelEqUserid(ctx, tx, userid); err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to delete user apikeys")
+	}
+	return keyids, nil
 }
 
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := apikeyModelSetup(d); err != nil {
+	if code, err := apikeyModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup user apikeys model")
 		}
@@ -1,6 +1,7 @@
 package apikey
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 
@@ -36,16 +37,16 @@ type (
 	}
 )
 
-func (s *service) GetUserKeys(userid string, limit, offset int) ([]model.Model, error) {
-	m, err := s.apikeys.GetUserKeys(userid, limit, offset)
+func (s *service) GetUserKeys(ctx context.Context, userid string, limit, offset int) ([]model.Model, error) {
+	m, err := s.apikeys.GetUserKeys(ctx, userid, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get apikeys")
 	}
 	return m, nil
 }
 
-func (s *service) getKeyHash(keyid string) (string, string, error) {
-	if result, err := s.kvkey.Get(keyid); err != nil {
+func (s *service) getKeyHash(ctx context.Context, keyid string) (string, string, error) {
+	if result, err := s.kvkey.Get(ctx, keyid); err != nil {
 		if !errors.Is(err, kvstore.ErrNotFound{}) {
 			s.logger.Error("Failed to get apikey key from cache", map[string]string{
 				"error":      err.Error(),
@@ -66,10 +67,10 @@ func (s *service) getKeyHash(keyid string) (string, string, error) {
 		}
 	}
 
-	m, err := s.apikeys.GetByID(keyid)
+	m, err := s.apikeys.GetByID(ctx, keyid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
-			if err := s.kvkey.Set(keyid, cacheValTombstone, s.scopeCacheTime); err != nil {
+			if err := s.kvkey.Set(ctx, keyid, cacheValTombstone, s.scopeCacheTime); err != nil {
 				s.logger.Error("Failed to set apikey key in cache", map[string]string{
 					"error":      err.Error(),
 					"actiontype": "setcacheapikey",
@@ -88,7 +89,7 @@ func (s *service) getKeyHash(keyid string) (string, string, error) {
 			"error":      err.Error(),
 			"actiontype": "cacheapikeyencode",
 		})
-	} else if err := s.kvkey.Set(keyid, string(kvVal), s.scopeCacheTime); err != nil {
+	} else if err := s.kvkey.Set(ctx, keyid, string(kvVal), s.scopeCacheTime); err != nil {
 		s.logger.Error("Failed to set apikey key in cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "setcacheapikey",
@@ -98,13 +99,13 @@ func (s *service) getKeyHash(keyid string) (string, string, error) {
 	return m.KeyHash, m.Scope, nil
 }
 
-func (s *service) CheckKey(keyid, key string) (string, string, error) {
+func (s *service) CheckKey(ctx context.Context, keyid, key string) (string, string, error) {
 	userid, err := model.ParseIDUserid(keyid)
 	if err != nil {
 		return "", "", governor.ErrWithKind(err, ErrInvalidKey{}, "Invalid key")
 	}
 
-	keyhash, keyscope, err := s.getKeyHash(keyid)
+	keyhash, keyscope, err := s.getKeyHash(ctx, keyid)
 	if err != nil {
 		return "", "", err
 	}
@@ -128,23 +129,23 @@ type (
 	}
 )
 
-func (s *service) Insert(userid string, scope string, name, desc string) (*ResApikeyModel, error) {
+func (s *service) Insert(ctx context.Context, userid string, scope string, name, desc string) (*ResApikeyModel, error) {
 	m, key, err := s.apikeys.New(userid, scope, name, desc)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to create apikey keys")
 	}
-	if err := s.apikeys.Insert(m); err != nil {
+	if err := s.apikeys.Insert(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to create apikey")
 	}
-	s.clearCache(m.Keyid)
+	s.ClearCache(ctx, m.Keyid)
 	return &ResApikeyModel{
 		Keyid: m.Keyid,
 		Key:   key,
 	}, nil
 }
 
-func (s *service) RotateKey(keyid string) (*ResApikeyModel, error) {
-	m, err := s.apikeys.GetByID(keyid)
+func (s *service) RotateKey(ctx context.Context, keyid string) (*ResApikeyModel, error) {
+	m, err := s.apikeys.GetByID(ctx, keyid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.ErrWithKind(err, ErrNotFound{}, "Apikey not found")
@@ -155,18 +156,18 @@ func (s *service) RotateKey(keyid string) (*ResApikeyModel, error) {
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to rotate apikey")
 	}
-	if err := s.apikeys.Update(m); err != nil {
+	if err := s.apikeys.Update(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to update apikey")
 	}
-	s.clearCache(m.Keyid)
+	s.ClearCache(ctx, m.Keyid)
 	return &ResApikeyModel{
 		Keyid: m.Keyid,
 		Key:   key,
 	}, nil
 }
 
-func (s *service) UpdateKey(keyid string, scope string, name, desc string) error {
-	m, err := s.apikeys.GetByID(keyid)
+func (s *service) UpdateKey(ctx context.Context, keyid string, scope string, name, desc string) error {
+	m, err := s.apikeys.GetByID(ctx, keyid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.ErrWithKind(err, ErrNotFound{}, "Apikey not found")
@@ -176,51 +177,56 @@ func (s *service) UpdateKey(keyid string, scope string, name, desc string) error
 	m.Scope = scope
 	m.Name = name
 	m.Desc = desc
-	if err := s.apikeys.Update(m); err != nil {
+	if err := s.apikeys.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update apikey")
 	}
-	s.clearCache(m.Keyid)
+	s.ClearCache(ctx, m.Keyid)
 	return nil
 }
 
-func (s *service) DeleteKey(keyid string) error {
-	m, err := s.apikeys.GetByID(keyid)
+func (s *service) DeleteKey(ctx context.Context, keyid string) error {
+	m, err := s.apikeys.GetByID(ctx, keyid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.ErrWithKind(err, ErrNotFound{}, "Apikey not found")
 		}
 		return governor.ErrWithMsg(err, "Failed to get apikey")
 	}
-	if err := s.apikeys.Delete(m); err != nil {
+	if err := s.apikeys.Delete(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete apikey")
 	}
-	s.clearCache(m.Keyid)
+	s.ClearCache(ctx, m.Keyid)
 	return nil
 }
 
-func (s *service) DeleteUserKeys(userid string) error {
-	keys, err := s.GetUserKeys(userid, 65536, 0)
+func (s *service) DeleteUserKeys(ctx context.Context, userid string) error {
+	keyids, err := s.apikeys.DeleteUserKeys(ctx, userid)
 	if err != nil {
-		return governor.ErrWithMsg(err, "Failed to get user keys")
-	}
-	if err := s.apikeys.DeleteUserKeys(userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user keys")
 	}
-
-	if len(keys) == 0 {
+	if len(keyids) == 0 {
 		return nil
 	}
+	s.ClearCache(ctx, keyids...)
+	return nil
+}
 
-	keyids := make([]string, 0, len(keys))
-	for _, i := range keys {
-		keyids = append(keyids, i.Keyid)
+// DeleteUserKeysTx deletes all the apikeys of a user in a transaction and
+// returns their keyids
+//
+// The deleted keys are not cleared from the cache, and ClearCache should be
+// called with the returned keyids after the transaction is committed.
+func (s *service) DeleteUserKeysTx(ctx context.Context, tx db.SQLExecutor, userid string) ([]string, error) {
+	keyids, err := s.apikeys.DeleteUserKeysTx(ctx, tx, userid)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to delete user keys")
 	}
-	s.clearCache(keyids...)
-	return nil
+	return keyids, nil
 }
 
-func (s *service) clearCache(keyids ...string) {
-	if err := s.kvkey.Del(keyids...); err != nil {
+// ClearCache clears cached apikeys
+func (s *service) ClearCache(ctx context.Context, keyids ...string) {
+	if err := s.kvkey.Del(ctx, keyids...); err != nil {
 		s.logger.Error("Failed to clear keys from cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "clearcacheapikey",
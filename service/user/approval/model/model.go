@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -10,7 +11,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t userapprovals -p approval -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t userapprovals -p approval -o model_gen.go Model
 
 const (
 	keySize = 16
@@ -23,12 +24,12 @@ type (
 		ToUserModel(m *Model) *usermodel.Model
 		ValidateCode(code string, m *Model) (bool, error)
 		RehashCode(m *Model) (string, error)
-		GetByID(userid string) (*Model, error)
-		GetGroup(limit, offset int) ([]Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(m *Model) error
-		Setup() error
+		GetByID(ctx context.Context, userid string) (*Model, error)
+		GetGroup(ctx context.Context, limit, offset int) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -144,12 +145,12 @@ func (r *repo) RehashCode(m *Model) (string, error) {
 	return codestr, nil
 }
 
-func (r *repo) GetByID(userid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := approvalModelGetModelEqUserid(d, userid)
+	m, code, err := approvalModelGetModelEqUserid(ctx, d, userid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No user found with that id")
@@ -159,24 +160,24 @@ func (r *repo) GetByID(userid string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) GetGroup(limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetGroup(ctx context.Context, limit, offset int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := approvalModelGetModelOrdCreationTime(d, true, limit, offset)
+	m, err := approvalModelGetModelOrdCreationTime(ctx, d, true, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user approvals")
 	}
 	return m, nil
 }
 
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := approvalModelInsert(d, m); err != nil {
+	if code, err := approvalModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Userid must be unique")
 		}
@@ -185,34 +186,34 @@ func (r *repo) Insert(m *Model) error {
 	return nil
 }
 
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := approvalModelUpdModelEqUserid(d, m, m.Userid); err != nil {
+	if _, err := approvalModelUpdModelEqUserid(ctx, d, m, m.Userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update user approval")
 	}
 	return nil
 }
 
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := approvalModelDelEqUserid(d, m.Userid); err != nil {
+	if err := approvalModelDelEqUserid(ctx, d, m.Userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user approval")
 	}
 	return nil
 }
 
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := approvalModelSetup(d); err != nil {
+	if code, err := approvalModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup user approval model")
 		}
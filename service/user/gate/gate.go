@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"xorkevin.dev/governor"
@@ -49,6 +51,7 @@ type (
 	// Gate creates new authenticating middleware
 	Gate interface {
 		Authenticate(v Validator, scope string) governor.Middleware
+		CheckCSRF(c governor.Context, sessionID string) error
 	}
 
 	// Service is a Gate and governor.Service
@@ -63,6 +66,8 @@ type (
 		tokenizer token.Tokenizer
 		baseurl   string
 		realm     string
+		csrf      bool
+		origins   []string
 		logger    governor.Logger
 	}
 
@@ -122,6 +127,7 @@ func (s *service) Register(inj governor.Injector, r governor.ConfigRegistrar, jr
 	setCtxGate(inj, s)
 
 	r.SetDefault("realm", "governor")
+	r.SetDefault("csrf", true)
 }
 
 func (s *service) Init(ctx context.Context, c governor.Config, r governor.ConfigReader, l governor.Logger, m governor.Router) error {
@@ -131,17 +137,20 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	})
 	s.baseurl = c.BaseURL
 	s.realm = r.GetStr("realm")
+	s.csrf = r.GetBool("csrf")
+	s.origins = c.AllowOrigins()
 	l.Info("loaded config", map[string]string{
 		"realm": s.realm,
+		"csrf":  strconv.FormatBool(s.csrf),
 	})
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -196,6 +205,92 @@ func getAuthHeader(c governor.Context) (string, error) {
 	return token, nil
 }
 
+const (
+	csrfHeader = "X-CSRF-Token"
+)
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" {
+		return true
+	}
+	pattern = strings.ToLower(pattern)
+	if k := strings.SplitN(pattern, "*", 2); len(k) == 2 {
+		return len(origin) >= len(k[0])+len(k[1]) && strings.HasPrefix(origin, k[0]) && strings.HasSuffix(origin, k[1])
+	}
+	return origin == pattern
+}
+
+// checkOrigin returns whether the request originates from the server itself or
+// an allowed origin
+//
+// The Referer header is checked when the Origin header is absent. Requests with
+// neither header are allowed, and instead rely on the csrf token.
+func (s *service) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	origin = strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, i := range s.origins {
+		if matchOrigin(i, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCSRF validates a cookie authenticated request against csrf attacks
+//
+// Routes that authenticate with cookies outside of Authenticate, such as
+// refresh token routes, must call CheckCSRF before changing state.
+func (s *service) CheckCSRF(c governor.Context, sessionID string) error {
+	return s.checkCSRF(c.Req(), sessionID)
+}
+
+// checkCSRF validates a cookie authenticated request against csrf attacks
+func (s *service) checkCSRF(r *http.Request, sessionID string) error {
+	if !s.csrf || isSafeMethod(r.Method) {
+		return nil
+	}
+	if !s.checkOrigin(r) {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusForbidden,
+			Message: "Invalid request origin",
+		}))
+	}
+	if !s.tokenizer.ValidateCSRF(sessionID, r.Header.Get(csrfHeader)) {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusForbidden,
+			Message: "Invalid CSRF token",
+		}))
+	}
+	return nil
+}
+
 func (r *intersector) Userid() string {
 	return r.userid
 }
@@ -205,7 +300,7 @@ func (r *intersector) Ctx() governor.Context {
 }
 
 func (r *intersector) Intersect(roles rank.Rank) (rank.Rank, bool) {
-	k, err := r.s.roles.IntersectRoles(r.userid, roles)
+	k, err := r.s.roles.IntersectRoles(r.ctx.Ctx(), r.userid, roles)
 	if err != nil {
 		r.s.logger.Error("Failed to get user roles", map[string]string{
 			"error":      err.Error(),
@@ -241,7 +336,7 @@ func (s *service) Authenticate(v Validator, scope string) governor.Middleware {
 			c := governor.NewContext(w, r, s.logger)
 			keyid, password, ok := r.BasicAuth()
 			if ok {
-				userid, keyscope, err := s.apikeys.CheckKey(keyid, password)
+				userid, keyscope, err := s.apikeys.CheckKey(c.Ctx(), keyid, password)
 				if err != nil {
 					if !errors.Is(err, apikey.ErrInvalidKey{}) && !errors.Is(err, apikey.ErrNotFound{}) {
 						c.WriteError(governor.ErrWithMsg(err, "Failed to get apikey"))
@@ -347,6 +442,12 @@ func (s *service) Authenticate(v Validator, scope string) governor.Middleware {
 					})))
 					return
 				}
+				if !isBearer {
+					if err := s.checkCSRF(r, claims.ID); err != nil {
+						c.WriteError(err)
+						return
+					}
+				}
 				if !token.HasScope(claims.Scope, scope) {
 					if isBearer {
 						c.SetHeader(
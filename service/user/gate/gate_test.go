@@ -0,0 +1,142 @@
+package gate
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/token"
+)
+
+type (
+	csrfTokenizer struct {
+		token.Tokenizer
+	}
+)
+
+func (t csrfTokenizer) ValidateCSRF(sessionID string, csrfToken string) bool {
+	return sessionID != "" && csrfToken == "csrf-"+sessionID
+}
+
+func TestCheckCSRF(t *testing.T) {
+	t.Parallel()
+
+	s := &service{
+		tokenizer: csrfTokenizer{},
+		csrf:      true,
+		origins:   []string{"https://*.example.com"},
+	}
+
+	for _, tc := range []struct {
+		Test   string
+		Method string
+		Header map[string]string
+		CSRF   bool
+		Valid  bool
+	}{
+		{
+			Test:   "safe method",
+			Method: http.MethodGet,
+			CSRF:   true,
+			Valid:  true,
+		},
+		{
+			Test:   "missing token",
+			Method: http.MethodPost,
+			CSRF:   true,
+			Valid:  false,
+		},
+		{
+			Test:   "invalid token",
+			Method: http.MethodPost,
+			Header: map[string]string{
+				csrfHeader: "csrf-other",
+			},
+			CSRF:  true,
+			Valid: false,
+		},
+		{
+			Test:   "valid token",
+			Method: http.MethodPost,
+			Header: map[string]string{
+				csrfHeader: "csrf-session",
+			},
+			CSRF:  true,
+			Valid: true,
+		},
+		{
+			Test:   "allowed origin",
+			Method: http.MethodDelete,
+			Header: map[string]string{
+				csrfHeader: "csrf-session",
+				"Origin":   "https://app.example.com",
+			},
+			CSRF:  true,
+			Valid: true,
+		},
+		{
+			Test:   "invalid origin",
+			Method: http.MethodPost,
+			Header: map[string]string{
+				csrfHeader: "csrf-session",
+				"Origin":   "https://evil.com",
+			},
+			CSRF:  true,
+			Valid: false,
+		},
+		{
+			Test:   "invalid referer",
+			Method: http.MethodPut,
+			Header: map[string]string{
+				csrfHeader: "csrf-session",
+				"Referer":  "https://evil.com/page",
+			},
+			CSRF:  true,
+			Valid: false,
+		},
+		{
+			Test:   "disabled",
+			Method: http.MethodPost,
+			CSRF:   false,
+			Valid:  true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			r := httptest.NewRequest(tc.Method, "http://localhost:8080/api/u/auth/refresh", nil)
+			for k, v := range tc.Header {
+				r.Header.Set(k, v)
+			}
+			k := *s
+			k.csrf = tc.CSRF
+			err := k.checkCSRF(r, "session")
+			if tc.Valid {
+				assert.NoError(err)
+				return
+			}
+			assert.Error(err)
+			var gerr *governor.Error
+			assert.True(errors.As(err, &gerr))
+			assert.Equal(http.StatusForbidden, gerr.Status)
+		})
+	}
+}
+
+func TestMatchOrigin(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	assert.True(matchOrigin("*", "https://evil.com"))
+	assert.True(matchOrigin("https://example.com", "https://example.com"))
+	assert.True(matchOrigin("https://*.example.com", "https://app.example.com"))
+	assert.False(matchOrigin("https://*.example.com", "https://example.com.evil.com"))
+	assert.False(matchOrigin("https://example.com", "http://example.com"))
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"crypto/hmac"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t users -p user -o model_gen.go Model Info
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t users -p user -o model_gen.go Model Info
 
 const (
 	uidSize       = 16
@@ -29,15 +30,18 @@ type (
 		ValidateOTPCode(decrypter *hunter2.Decrypter, m *Model, code string) (bool, error)
 		ValidateOTPBackup(decrypter *hunter2.Decrypter, m *Model, backup string) (bool, error)
 		GenerateOTPSecret(cipher hunter2.Cipher, m *Model, issuer string, alg string, digits int) (string, string, error)
-		GetGroup(limit, offset int) ([]Info, error)
-		GetBulk(userids []string) ([]Info, error)
-		GetByID(userid string) (*Model, error)
-		GetByUsername(username string) (*Model, error)
-		GetByEmail(email string) (*Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(m *Model) error
-		Setup() error
+		GetGroup(ctx context.Context, limit, offset int) ([]Info, error)
+		GetGroupAfter(ctx context.Context, cursor *db.Cursor, limit int) ([]Info, error)
+		GetBulk(ctx context.Context, userids []string) ([]Info, error)
+		GetByID(ctx context.Context, userid string) (*Model, error)
+		GetByUsername(ctx context.Context, username string) (*Model, error)
+		GetByEmail(ctx context.Context, email string) (*Model, error)
+		Insert(ctx context.Context, m *Model) error
+		InsertTx(ctx context.Context, tx db.SQLExecutor, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		DeleteTx(ctx context.Context, tx db.SQLExecutor, m *Model) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -64,7 +68,7 @@ type (
 
 	// Info is the metadata of a user
 	Info struct {
-		Userid    string `query:"userid,getgroup;getgroupeq,userid|arr"`
+		Userid    string `query:"userid,getgroup;getgroupeq,userid|arr;getpage"`
 		Username  string `query:"username"`
 		Email     string `query:"email"`
 		FirstName string `query:"first_name"`
@@ -209,12 +213,31 @@ func (r *repo) GenerateOTPSecret(cipher hunter2.Cipher, m *Model, issuer string,
 }
 
 // GetGroup gets information from each user
-func (r *repo) GetGroup(limit, offset int) ([]Info, error) {
-	d, err := r.db.DB()
+func (r *repo) GetGroup(ctx context.Context, limit, offset int) ([]Info, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
 	if err != nil {
 		return nil, err
 	}
-	m, err := userModelGetInfoOrdUserid(d, true, limit, offset)
+	m, err := userModelGetInfoOrdUserid(ctx, d, true, limit, offset)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get user info")
+	}
+	return m, nil
+}
+
+// GetGroupAfter gets information from each user after a cursor
+//
+// The first page is returned if cursor is nil.
+func (r *repo) GetGroupAfter(ctx context.Context, cursor *db.Cursor, limit int) ([]Info, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		c := db.FirstCursor(true)
+		cursor = &c
+	}
+	m, err := userModelGetInfoPageUserid(ctx, d, true, cursor.Key, limit)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user info")
 	}
@@ -222,12 +245,12 @@ func (r *repo) GetGroup(limit, offset int) ([]Info, error) {
 }
 
 // GetBulk gets information from users
-func (r *repo) GetBulk(userids []string) ([]Info, error) {
-	d, err := r.db.DB()
+func (r *repo) GetBulk(ctx context.Context, userids []string) ([]Info, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
 	if err != nil {
 		return nil, err
 	}
-	m, err := userModelGetInfoHasUseridOrdUserid(d, userids, true, len(userids), 0)
+	m, err := userModelGetInfoHasUseridOrdUserid(ctx, d, userids, true, len(userids), 0)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user info of userids")
 	}
@@ -235,12 +258,12 @@ func (r *repo) GetBulk(userids []string) ([]Info, error) {
 }
 
 // GetByID returns a user model with the given id
-func (r *repo) GetByID(userid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := userModelGetModelEqUserid(d, userid)
+	m, code, err := userModelGetModelEqUserid(ctx, d, userid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No user found with that id")
@@ -251,12 +274,12 @@ func (r *repo) GetByID(userid string) (*Model, error) {
 }
 
 // GetByUsername returns a user model with the given username
-func (r *repo) GetByUsername(username string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByUsername(ctx context.Context, username string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := userModelGetModelEqUsername(d, username)
+	m, code, err := userModelGetModelEqUsername(ctx, d, username)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No user found with that username")
@@ -267,12 +290,12 @@ func (r *repo) GetByUsername(username string) (*Model, error) {
 }
 
 // GetByEmail returns a user model with the given email
-func (r *repo) GetByEmail(email string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByEmail(ctx context.Context, email string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := userModelGetModelEqEmail(d, email)
+	m, code, err := userModelGetModelEqEmail(ctx, d, email)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No user found with that email")
@@ -283,12 +306,17 @@ func (r *repo) GetByEmail(email string) (*Model, error) {
 }
 
 // Insert inserts the model into the db
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := userModelInsert(d, m); err != nil {
+	return r.InsertTx(ctx, d, m)
+}
+
+// InsertTx inserts the model into the db in a transaction
+func (r *repo) InsertTx(ctx context.Context, tx db.SQLExecutor, m *Model) error {
+	if code, err := userModelInsert(ctx, tx, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Username and email must be unique")
 		}
@@ -298,12 +326,12 @@ func (r *repo) Insert(m *Model) error {
 }
 
 // Update updates the model in the db
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := userModelUpdModelEqUserid(d, m, m.Userid); err != nil {
+	if code, err := userModelUpdModelEqUserid(ctx, d, m, m.Userid); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Username and email must be unique")
 		}
@@ -313,24 +341,29 @@ func (r *repo) Update(m *Model) error {
 }
 
 // Delete deletes the model in the db
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := userModelDelEqUserid(d, m.Userid); err != nil {
+	return r.DeleteTx(ctx, d, m)
+}
+
+// DeleteTx deletes the model in the db in a transaction
+func (r *repo) DeleteTx(ctx context.Context, tx db.SQLExecutor, m *Model) error {
+	if err := userModelDelEqUserid(ctx, tx, m.Userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user")
 	}
 	return nil
 }
 
 // Setup creates a new User table
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := userModelSetup(d); err != nil {
+	if code, err := userModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup user model")
 		}
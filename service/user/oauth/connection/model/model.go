@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -9,7 +10,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t oauthconnections -p connection -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t oauthconnections -p connection -o model_gen.go Model
 
 const (
 	keySize = 32
@@ -23,13 +24,14 @@ type (
 		RehashCode(m *Model) (string, error)
 		ValidateKey(key string, m *Model) (bool, error)
 		RehashKey(m *Model) (string, error)
-		GetByID(userid, clientid string) (*Model, error)
-		GetUserConnections(userid string, limit, offset int) ([]Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(userid string, clientids []string) error
-		DeleteUserConnections(userid string) error
-		Setup() error
+		GetByID(ctx context.Context, userid, clientid string) (*Model, error)
+		GetUserConnections(ctx context.Context, userid string, limit, offset int) ([]Model, error)
+		GetUserConnectionsAfter(ctx context.Context, userid string, cursor *db.Cursor, limit int) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, userid string, clientids []string) error
+		DeleteUserConnections(ctx context.Context, userid string) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -49,7 +51,7 @@ type (
 		CodeHash        string `model:"codehash,VARCHAR(255) NOT NULL" query:"codehash"`
 		AuthTime        int64  `model:"auth_time,BIGINT NOT NULL" query:"auth_time"`
 		CodeTime        int64  `model:"code_time,BIGINT NOT NULL" query:"code_time"`
-		AccessTime      int64  `model:"access_time,BIGINT NOT NULL;index" query:"access_time,getgroupeq,userid"`
+		AccessTime      int64  `model:"access_time,BIGINT NOT NULL;index" query:"access_time,getgroupeq,userid;getpageeq,userid,clientid|tie"`
 		CreationTime    int64  `model:"creation_time,BIGINT NOT NULL" query:"creation_time"`
 		KeyHash         string `model:"keyhash,VARCHAR(255) NOT NULL" query:"keyhash"`
 	}
@@ -172,12 +174,12 @@ func (r *repo) RehashKey(m *Model) (string, error) {
 	return keystr, nil
 }
 
-func (r *repo) GetByID(userid, clientid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid, clientid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := connectionModelGetModelEqUseridEqClientID(d, userid, clientid)
+	m, code, err := connectionModelGetModelEqUseridEqClientID(ctx, d, userid, clientid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No connected OAuth app found with that id")
@@ -187,24 +189,40 @@ func (r *repo) GetByID(userid, clientid string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) GetUserConnections(userid string, limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetUserConnections(ctx context.Context, userid string, limit, offset int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := connectionModelGetModelEqUseridOrdAccessTime(d, userid, false, limit, offset)
+	m, err := connectionModelGetModelEqUseridOrdAccessTime(ctx, d, userid, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get connected OAuth apps")
 	}
 	return m, nil
 }
 
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) GetUserConnectionsAfter(ctx context.Context, userid string, cursor *db.Cursor, limit int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		c := db.FirstCursor(false)
+		cursor = &c
+	}
+	m, err := connectionModelGetModelEqUseridPageAccessTimeClientID(ctx, d, userid, false, cursor.Time, cursor.Key, limit)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get connected OAuth apps")
+	}
+	return m, nil
+}
+
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := connectionModelInsert(d, m); err != nil {
+	if code, err := connectionModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "OAuth app already connected")
 		}
@@ -213,45 +231,45 @@ func (r *repo) Insert(m *Model) error {
 	return nil
 }
 
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := connectionModelUpdModelEqUseridEqClientID(d, m, m.Userid, m.ClientID); err != nil {
+	if _, err := connectionModelUpdModelEqUseridEqClientID(ctx, d, m, m.Userid, m.ClientID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update connected OAuth app")
 	}
 	return nil
 }
 
-func (r *repo) Delete(userid string, clientids []string) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, userid string, clientids []string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := connectionModelDelEqUseridHasClientID(d, userid, clientids); err != nil {
+	if err := connectionModelDelEqUseridHasClientID(ctx, d, userid, clientids); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete connected OAuth app")
 	}
 	return nil
 }
 
-func (r *repo) DeleteUserConnections(userid string) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteUserConnections(ctx context.Context, userid string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := connectionModelDelEqUserid(d, userid); err != nil {
+	if err := connectionModelDelEqUserid(ctx, d, userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete connected OAuth apps")
 	}
 	return nil
 }
 
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := connectionModelSetup(d); err != nil {
+	if code, err := connectionModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup OAuth connection model")
 		}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -9,7 +10,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t oauthapps -p oauthapp -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t oauthapps -p oauthapp -o model_gen.go Model
 
 const (
 	uidSize = 16
@@ -22,14 +23,15 @@ type (
 		New(name, url, redirectURI, creatorID string) (*Model, string, error)
 		ValidateKey(key string, m *Model) (bool, error)
 		RehashKey(m *Model) (string, error)
-		GetByID(clientid string) (*Model, error)
-		GetApps(limit, offset int, creatorid string) ([]Model, error)
-		GetBulk(clientids []string) ([]Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		DeleteCreatorApps(creatorid string) error
-		Delete(m *Model) error
-		Setup() error
+		GetByID(ctx context.Context, clientid string) (*Model, error)
+		GetApps(ctx context.Context, limit, offset int, creatorid string) ([]Model, error)
+		GetAppsAfter(ctx context.Context, cursor *db.Cursor, limit int, creatorid string) ([]Model, error)
+		GetBulk(ctx context.Context, clientids []string) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		DeleteCreatorApps(ctx context.Context, creatorid string) error
+		Delete(ctx context.Context, m *Model) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -47,7 +49,7 @@ type (
 		Logo         string `model:"logo,VARCHAR(4095)" query:"logo"`
 		KeyHash      string `model:"keyhash,VARCHAR(255) NOT NULL" query:"keyhash"`
 		Time         int64  `model:"time,BIGINT NOT NULL;index" query:"time,getgroup;getgroupeq,creator_id"`
-		CreationTime int64  `model:"creation_time,BIGINT NOT NULL" query:"creation_time"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL;index" query:"creation_time,getpage,clientid|tie;getpageeq,creator_id,clientid|tie"`
 		CreatorID    string `model:"creator_id,VARCHAR(31);index" query:"creator_id,deleq,creator_id"`
 	}
 
@@ -146,12 +148,12 @@ func (r *repo) RehashKey(m *Model) (string, error) {
 	return keystr, nil
 }
 
-func (r *repo) GetByID(clientid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, clientid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := oauthappModelGetModelEqClientID(d, clientid)
+	m, code, err := oauthappModelGetModelEqClientID(ctx, d, clientid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No OAuth app found with that id")
@@ -161,43 +163,66 @@ func (r *repo) GetByID(clientid string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) GetApps(limit, offset int, creatorid string) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetApps(ctx context.Context, limit, offset int, creatorid string) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
 	if creatorid == "" {
-		m, err := oauthappModelGetModelOrdTime(d, false, limit, offset)
+		m, err := oauthappModelGetModelOrdTime(ctx, d, false, limit, offset)
 		if err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to get OAuth apps")
 		}
 		return m, nil
 	}
-	m, err := oauthappModelGetModelEqCreatorIDOrdTime(d, creatorid, false, limit, offset)
+	m, err := oauthappModelGetModelEqCreatorIDOrdTime(ctx, d, creatorid, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get OAuth apps")
 	}
 	return m, nil
 }
 
-func (r *repo) GetBulk(clientids []string) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetAppsAfter(ctx context.Context, cursor *db.Cursor, limit int, creatorid string) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := oauthappModelGetModelHasClientIDOrdClientID(d, clientids, true, len(clientids), 0)
+	if cursor == nil {
+		c := db.FirstCursor(false)
+		cursor = &c
+	}
+	if creatorid == "" {
+		m, err := oauthappModelGetModelPageCreationTimeClientID(ctx, d, false, cursor.Time, cursor.Key, limit)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get OAuth apps")
+		}
+		return m, nil
+	}
+	m, err := oauthappModelGetModelEqCreatorIDPageCreationTimeClientID(ctx, d, creatorid, false, cursor.Time, cursor.Key, limit)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get OAuth apps")
+	}
+	return m, nil
+}
+
+func (r *repo) GetBulk(ctx context.Context, clientids []string) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := oauthappModelGetModelHasClientIDOrdClientID(ctx, d, clientids, true, len(clientids), 0)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get OAuth apps")
 	}
 	return m, nil
 }
 
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := oauthappModelInsert(d, m); err != nil {
+	if code, err := oauthappModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Clientid must be unique")
 		}
@@ -206,45 +231,45 @@ func (r *repo) Insert(m *Model) error {
 	return nil
 }
 
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := oauthappModelUpdModelEqClientID(d, m, m.ClientID); err != nil {
+	if _, err := oauthappModelUpdModelEqClientID(ctx, d, m, m.ClientID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update OAuth app config")
 	}
 	return nil
 }
 
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := oauthappModelDelEqClientID(d, m.ClientID); err != nil {
+	if err := oauthappModelDelEqClientID(ctx, d, m.ClientID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete OAuth app")
 	}
 	return nil
 }
 
-func (r *repo) DeleteCreatorApps(creatorid string) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteCreatorApps(ctx context.Context, creatorid string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := oauthappModelDelEqCreatorID(d, creatorid); err != nil {
+	if err := oauthappModelDelEqCreatorID(ctx, d, creatorid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete OAuth apps")
 	}
 	return nil
 }
 
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := oauthappModelSetup(d); err != nil {
+	if code, err := oauthappModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup OAuth app model")
 		}
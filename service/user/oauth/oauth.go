@@ -7,7 +7,7 @@ import (
 	"time"
 
 	"xorkevin.dev/governor"
-	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/kvstore/nearcache"
 	"xorkevin.dev/governor/service/objstore"
 	"xorkevin.dev/governor/service/user"
 	"xorkevin.dev/governor/service/user/gate"
@@ -44,7 +44,7 @@ type (
 		apps         model.Repo
 		connections  connmodel.Repo
 		tokenizer    token.Tokenizer
-		kvclient     kvstore.KVStore
+		kvclient     nearcache.Cache
 		oauthBucket  objstore.Bucket
 		logoImgDir   objstore.Dir
 		users        user.Users
@@ -90,7 +90,7 @@ func NewCtx(inj governor.Injector) Service {
 	apps := model.GetCtxRepo(inj)
 	connections := connmodel.GetCtxRepo(inj)
 	tokenizer := token.GetCtxTokenizer(inj)
-	kv := kvstore.GetCtxKVStore(inj)
+	kv := nearcache.GetCtxCache(inj)
 	obj := objstore.GetCtxBucket(inj)
 	users := user.GetCtxUsers(inj)
 	g := gate.GetCtxGate(inj)
@@ -98,7 +98,7 @@ func NewCtx(inj governor.Injector) Service {
 }
 
 // New returns a new Apikey
-func New(apps model.Repo, connections connmodel.Repo, tokenizer token.Tokenizer, kv kvstore.KVStore, obj objstore.Bucket, users user.Users, g gate.Gate) Service {
+func New(apps model.Repo, connections connmodel.Repo, tokenizer token.Tokenizer, kv nearcache.Cache, obj objstore.Bucket, users user.Users, g gate.Gate) Service {
 	return &service{
 		apps:         apps,
 		connections:  connections,
@@ -205,29 +205,29 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
 
-	if err := s.apps.Setup(); err != nil {
+	if err := s.apps.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("Created oauthapps table", nil)
 
-	if err := s.connections.Setup(); err != nil {
+	if err := s.connections.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("Created oauthconnections table", nil)
 
-	if err := s.oauthBucket.Init(); err != nil {
+	if err := s.oauthBucket.Init(ctx); err != nil {
 		return governor.ErrWithMsg(err, "Failed to init oauth bucket")
 	}
 	l.Info("Created oauth bucket", nil)
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -27,7 +27,7 @@ func (m *router) getApp(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	res, err := m.s.GetApp(req.ClientID)
+	res, err := m.s.GetApp(c.Ctx(), req.ClientID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -44,7 +44,7 @@ func (m *router) getAppLogo(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	img, contentType, err := m.s.GetLogo(req.ClientID)
+	img, objinfo, err := m.s.GetLogo(c.Ctx(), req.ClientID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -57,14 +57,15 @@ func (m *router) getAppLogo(w http.ResponseWriter, r *http.Request) {
 			})
 		}
 	}()
-	c.WriteFile(http.StatusOK, contentType, img)
+	c.ServeObject(objinfo.ServeInfo(), img)
 }
 
 type (
 	reqGetAppGroup struct {
 		CreatorID string `valid:"userid,opt" json:"-"`
 		Amount    int    `valid:"amount" json:"-"`
-		Offset    int    `valid:"offset" json:"-"`
+		Offset    int    `valid:"offset,opt" json:"-"`
+		Cursor    string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -74,13 +75,14 @@ func (m *router) getAppGroup(w http.ResponseWriter, r *http.Request) {
 		CreatorID: c.Query("creatorid"),
 		Amount:    c.QueryInt("amount", -1),
 		Offset:    c.QueryInt("offset", -1),
+		Cursor:    c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetApps(req.Amount, req.Offset, req.CreatorID)
+	res, err := m.s.GetApps(c.Ctx(), req.Amount, req.Offset, req.Cursor, req.CreatorID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -104,7 +106,7 @@ func (m *router) getAppBulk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetAppsBulk(strings.Split(req.ClientIDs, ","))
+	res, err := m.s.GetAppsBulk(c.Ctx(), strings.Split(req.ClientIDs, ","))
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -134,7 +136,7 @@ func (m *router) createApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.CreateApp(req.Name, req.URL, req.RedirectURI, req.CreatorID)
+	res, err := m.s.CreateApp(c.Ctx(), req.Name, req.URL, req.RedirectURI, req.CreatorID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -164,7 +166,7 @@ func (m *router) updateApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.UpdateApp(req.ClientID, req.Name, req.URL, req.RedirectURI); err != nil {
+	if err := m.s.UpdateApp(c.Ctx(), req.ClientID, req.Name, req.URL, req.RedirectURI); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -187,7 +189,7 @@ func (m *router) updateAppLogo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.UpdateLogo(req.ClientID, img); err != nil {
+	if err := m.s.UpdateLogo(c.Ctx(), req.ClientID, img); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -204,7 +206,7 @@ func (m *router) rotateAppKey(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	res, err := m.s.RotateAppKey(req.ClientID)
+	res, err := m.s.RotateAppKey(c.Ctx(), req.ClientID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -222,7 +224,7 @@ func (m *router) deleteApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.Delete(req.ClientID); err != nil {
+	if err := m.s.Delete(c.Ctx(), req.ClientID); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -238,7 +240,7 @@ func (m *router) getAppLogoCC(c governor.Context) (string, error) {
 		return "", err
 	}
 
-	objinfo, err := m.s.StatLogo(req.ClientID)
+	objinfo, err := m.s.StatLogo(c.Ctx(), req.ClientID)
 	if err != nil {
 		return "", err
 	}
@@ -253,7 +255,7 @@ const (
 
 func (m *router) mountAppRoutes(r governor.Router) {
 	r.Get("/id/{clientid}", m.getApp)
-	r.Get("/id/{clientid}/image", m.getAppLogo, cachecontrol.Control(m.s.logger, true, nil, 60, m.getAppLogoCC))
+	r.Get("/id/{clientid}/image", m.getAppLogo, governor.SecHeadersSandbox(), cachecontrol.Control(m.s.logger, true, nil, 60, m.getAppLogoCC))
 	r.Get("", m.getAppGroup, gate.Member(m.s.gate, "gov.oauth", scopeAppRead))
 	r.Get("/ids", m.getAppBulk)
 	r.Post("", m.createApp, gate.Member(m.s.gate, "gov.oauth", scopeAppWrite))
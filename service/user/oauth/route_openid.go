@@ -83,7 +83,7 @@ func (m *router) authCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.AuthCode(req.Userid, req.ClientID, req.Scope, req.Nonce, req.CodeChallenge, req.CodeChallengeMethod, claims.AuthTime)
+	res, err := m.s.AuthCode(c.Ctx(), req.Userid, req.ClientID, req.Scope, req.Nonce, req.CodeChallenge, req.CodeChallengeMethod, claims.AuthTime)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -195,7 +195,7 @@ func (m *router) authToken(w http.ResponseWriter, r *http.Request) {
 			m.writeOAuthTokenError(c, err)
 			return
 		}
-		res, err := m.s.AuthTokenCode(req.ClientID, req.ClientSecret, req.RedirectURI, req.Userid, req.Code, req.CodeVerifier)
+		res, err := m.s.AuthTokenCode(c.Ctx(), req.ClientID, req.ClientSecret, req.RedirectURI, req.Userid, req.Code, req.CodeVerifier)
 		if err != nil {
 			m.writeOAuthTokenError(c, err)
 			return
@@ -218,7 +218,7 @@ func (m *router) userinfo(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(governor.ErrWithMsg(nil, "No access token claims"))
 		return
 	}
-	res, err := m.s.Userinfo(claims.Subject, claims.Scope)
+	res, err := m.s.Userinfo(c.Ctx(), claims.Subject, claims.Scope)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -230,7 +230,8 @@ type (
 	reqGetConnectionGroup struct {
 		Userid string `valid:"userid,has" json:"-"`
 		Amount int    `valid:"amount" json:"-"`
-		Offset int    `valid:"offset" json:"-"`
+		Offset int    `valid:"offset,opt" json:"-"`
+		Cursor string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -240,13 +241,14 @@ func (m *router) getConnections(w http.ResponseWriter, r *http.Request) {
 		Userid: gate.GetCtxUserid(c),
 		Amount: c.QueryInt("amount", -1),
 		Offset: c.QueryInt("offset", -1),
+		Cursor: c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetConnections(req.Userid, req.Amount, req.Offset)
+	res, err := m.s.GetConnections(c.Ctx(), req.Userid, req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -272,7 +274,7 @@ func (m *router) getConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetConnection(req.Userid, req.ClientID)
+	res, err := m.s.GetConnection(c.Ctx(), req.Userid, req.ClientID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -291,7 +293,7 @@ func (m *router) delConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.DelConnection(req.Userid, req.ClientID); err != nil {
+	if err := m.s.DelConnection(c.Ctx(), req.Userid, req.ClientID); err != nil {
 		c.WriteError(err)
 		return
 	}
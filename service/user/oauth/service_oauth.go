@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -39,14 +40,42 @@ type (
 	}
 
 	resApps struct {
-		Apps []resApp `json:"apps"`
+		Apps       []resApp `json:"apps"`
+		NextCursor string   `json:"next_cursor,omitempty"`
 	}
 )
 
-func (s *service) GetApps(limit, offset int, creatorid string) (*resApps, error) {
-	m, err := s.apps.GetApps(limit, offset, creatorid)
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to get oauth apps")
+// GetApps returns a group of oauth apps
+//
+// Apps are paged by cursor if a cursor is provided, and by offset otherwise.
+// The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetApps(ctx context.Context, limit, offset int, cursor string, creatorid string) (*resApps, error) {
+	paged := cursor != ""
+	var m []model.Model
+	if paged {
+		after, err := db.ParseCursor(cursor)
+		if err != nil {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}), governor.ErrOptInner(err))
+		}
+		m, err = s.apps.GetAppsAfter(ctx, after, limit, creatorid)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get oauth apps")
+		}
+	} else {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		var err error
+		m, err = s.apps.GetApps(ctx, limit, offset, creatorid)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get oauth apps")
+		}
 	}
 	res := make([]resApp, 0, len(m))
 	for _, i := range m {
@@ -60,13 +89,22 @@ func (s *service) GetApps(limit, offset int, creatorid string) (*resApps, error)
 			CreationTime: i.CreationTime,
 		})
 	}
+	var next string
+	if paged && len(m) > 0 && len(m) == limit {
+		last := m[len(m)-1]
+		next = db.Cursor{
+			Time: last.CreationTime,
+			Key:  last.ClientID,
+		}.Encode()
+	}
 	return &resApps{
-		Apps: res,
+		Apps:       res,
+		NextCursor: next,
 	}, nil
 }
 
-func (s *service) GetAppsBulk(clientids []string) (*resApps, error) {
-	m, err := s.apps.GetBulk(clientids)
+func (s *service) GetAppsBulk(ctx context.Context, clientids []string) (*resApps, error) {
+	m, err := s.apps.GetBulk(ctx, clientids)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get oauth apps")
 	}
@@ -87,8 +125,8 @@ func (s *service) GetAppsBulk(clientids []string) (*resApps, error) {
 	}, nil
 }
 
-func (s *service) getCachedClient(clientid string) (*model.Model, error) {
-	if clientstr, err := s.kvclient.Get(clientid); err != nil {
+func (s *service) getCachedClient(ctx context.Context, clientid string) (*model.Model, error) {
+	if clientstr, err := s.kvclient.Get(ctx, clientid); err != nil {
 		if !errors.Is(err, kvstore.ErrNotFound{}) {
 			s.logger.Error("Failed to get oauth client from cache", map[string]string{
 				"error":      err.Error(),
@@ -109,10 +147,10 @@ func (s *service) getCachedClient(clientid string) (*model.Model, error) {
 		}
 	}
 
-	m, err := s.apps.GetByID(clientid)
+	m, err := s.apps.GetByID(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
-			if err := s.kvclient.Set(clientid, cacheValTombstone, s.keyCacheTime); err != nil {
+			if err := s.kvclient.Set(ctx, clientid, cacheValTombstone, s.keyCacheTime); err != nil {
 				s.logger.Error("Failed to set oauth client in cache", map[string]string{
 					"error":      err.Error(),
 					"actiontype": "setcacheclient",
@@ -128,7 +166,7 @@ func (s *service) getCachedClient(clientid string) (*model.Model, error) {
 			"error":      err.Error(),
 			"actiontype": "marshalclientjson",
 		})
-	} else if err := s.kvclient.Set(clientid, string(clientbytes), s.keyCacheTime); err != nil {
+	} else if err := s.kvclient.Set(ctx, clientid, string(clientbytes), s.keyCacheTime); err != nil {
 		s.logger.Error("Failed to set oauth client in cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "setcacheclient",
@@ -145,12 +183,12 @@ type (
 	}
 )
 
-func (s *service) CreateApp(name, url, redirectURI, creatorID string) (*resCreate, error) {
+func (s *service) CreateApp(ctx context.Context, name, url, redirectURI, creatorID string) (*resCreate, error) {
 	m, key, err := s.apps.New(name, url, redirectURI, creatorID)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to create oauth app")
 	}
-	if err := s.apps.Insert(m); err != nil {
+	if err := s.apps.Insert(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to insert oauth app")
 	}
 	return &resCreate{
@@ -159,8 +197,8 @@ func (s *service) CreateApp(name, url, redirectURI, creatorID string) (*resCreat
 	}, nil
 }
 
-func (s *service) RotateAppKey(clientid string) (*resCreate, error) {
-	m, err := s.apps.GetByID(clientid)
+func (s *service) RotateAppKey(ctx context.Context, clientid string) (*resCreate, error) {
+	m, err := s.apps.GetByID(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -174,18 +212,18 @@ func (s *service) RotateAppKey(clientid string) (*resCreate, error) {
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to rotate client key")
 	}
-	if err := s.apps.Update(m); err != nil {
+	if err := s.apps.Update(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to update oauth app")
 	}
-	s.clearCache(clientid)
+	s.clearCache(ctx, clientid)
 	return &resCreate{
 		ClientID: clientid,
 		Key:      key,
 	}, nil
 }
 
-func (s *service) UpdateApp(clientid string, name, url, redirectURI string) error {
-	m, err := s.apps.GetByID(clientid)
+func (s *service) UpdateApp(ctx context.Context, clientid string, name, url, redirectURI string) error {
+	m, err := s.apps.GetByID(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -198,10 +236,10 @@ func (s *service) UpdateApp(clientid string, name, url, redirectURI string) erro
 	m.Name = name
 	m.URL = url
 	m.RedirectURI = redirectURI
-	if err := s.apps.Update(m); err != nil {
+	if err := s.apps.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update oauth app")
 	}
-	s.clearCache(clientid)
+	s.clearCache(ctx, clientid)
 	return nil
 }
 
@@ -211,8 +249,8 @@ const (
 	thumbQuality = 0
 )
 
-func (s *service) UpdateLogo(clientid string, img image.Image) error {
-	m, err := s.apps.GetByID(clientid)
+func (s *service) UpdateLogo(ctx context.Context, clientid string, img image.Image) error {
+	m, err := s.apps.GetByID(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -234,20 +272,20 @@ func (s *service) UpdateLogo(clientid string, img image.Image) error {
 	if err != nil {
 		return governor.ErrWithMsg(err, "Failed to encode image to png")
 	}
-	if err := s.logoImgDir.Put(m.ClientID, image.MediaTypePng, int64(imgpng.Len()), imgpng); err != nil {
+	if err := s.logoImgDir.Put(ctx, m.ClientID, image.MediaTypePng, int64(imgpng.Len()), imgpng); err != nil {
 		return governor.ErrWithMsg(err, "Failed to save app logo")
 	}
 
 	m.Logo = thumb64
-	if err := s.apps.Update(m); err != nil {
+	if err := s.apps.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update oauth app")
 	}
-	s.clearCache(clientid)
+	s.clearCache(ctx, clientid)
 	return nil
 }
 
-func (s *service) Delete(clientid string) error {
-	m, err := s.apps.GetByID(clientid)
+func (s *service) Delete(ctx context.Context, clientid string) error {
+	m, err := s.apps.GetByID(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -258,21 +296,21 @@ func (s *service) Delete(clientid string) error {
 		return governor.ErrWithMsg(err, "Failed to get oauth app")
 	}
 
-	if err := s.logoImgDir.Del(clientid); err != nil {
+	if err := s.logoImgDir.Del(ctx, clientid); err != nil {
 		if !errors.Is(err, objstore.ErrNotFound{}) {
 			return governor.ErrWithMsg(err, "Unable to delete app logo")
 		}
 	}
 
-	if err := s.apps.Delete(m); err != nil {
+	if err := s.apps.Delete(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete oauth app")
 	}
-	s.clearCache(clientid)
+	s.clearCache(ctx, clientid)
 	return nil
 }
 
-func (s *service) GetApp(clientid string) (*resApp, error) {
-	m, err := s.getCachedClient(clientid)
+func (s *service) GetApp(ctx context.Context, clientid string) (*resApp, error) {
+	m, err := s.getCachedClient(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -293,8 +331,8 @@ func (s *service) GetApp(clientid string) (*resApp, error) {
 	}, nil
 }
 
-func (s *service) StatLogo(clientid string) (*objstore.ObjectInfo, error) {
-	objinfo, err := s.logoImgDir.Stat(clientid)
+func (s *service) StatLogo(ctx context.Context, clientid string) (*objstore.ObjectInfo, error) {
+	objinfo, err := s.logoImgDir.Stat(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -307,22 +345,22 @@ func (s *service) StatLogo(clientid string) (*objstore.ObjectInfo, error) {
 	return objinfo, nil
 }
 
-func (s *service) GetLogo(clientid string) (io.ReadCloser, string, error) {
-	obj, objinfo, err := s.logoImgDir.Get(clientid)
+func (s *service) GetLogo(ctx context.Context, clientid string) (io.ReadCloser, *objstore.ObjectInfo, error) {
+	obj, objinfo, err := s.logoImgDir.Get(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, objstore.ErrNotFound{}) {
-			return nil, "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			return nil, nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
 				Message: "OAuth app logo not found",
 			}), governor.ErrOptInner(err))
 		}
-		return nil, "", governor.ErrWithMsg(err, "Failed to get app logo")
+		return nil, nil, governor.ErrWithMsg(err, "Failed to get app logo")
 	}
-	return obj, objinfo.ContentType, nil
+	return obj, objinfo, nil
 }
 
-func (s *service) clearCache(clientid string) {
-	if err := s.kvclient.Del(clientid); err != nil {
+func (s *service) clearCache(ctx context.Context, clientid string) {
+	if err := s.kvclient.Del(ctx, clientid); err != nil {
 		s.logger.Error("Failed to clear oauth client from cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "clearcacheclient",
@@ -2,6 +2,7 @@ package oauth
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
@@ -14,6 +15,7 @@ import (
 	"gopkg.in/square/go-jose.v2"
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
+	connmodel "xorkevin.dev/governor/service/user/oauth/connection/model"
 	"xorkevin.dev/governor/service/user/token"
 )
 
@@ -141,7 +143,7 @@ type (
 	}
 )
 
-func (s *service) AuthCode(userid, clientid, scope, nonce, challenge, method string, authTime int64) (*resAuthCode, error) {
+func (s *service) AuthCode(ctx context.Context, userid, clientid, scope, nonce, challenge, method string, authTime int64) (*resAuthCode, error) {
 	// sort and filter unknown scopes
 	scope = dedupSSV(scope, map[string]struct{}{
 		oidScopeOpenid:  {},
@@ -150,7 +152,7 @@ func (s *service) AuthCode(userid, clientid, scope, nonce, challenge, method str
 		oidScopeOffline: {},
 	})
 
-	if _, err := s.getCachedClient(clientid); err != nil {
+	if _, err := s.getCachedClient(ctx, clientid); err != nil {
 		if errors.Is(err, ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
@@ -160,7 +162,7 @@ func (s *service) AuthCode(userid, clientid, scope, nonce, challenge, method str
 		return nil, governor.ErrWithMsg(err, "Failed to get oauth app")
 	}
 
-	m, err := s.connections.GetByID(userid, clientid)
+	m, err := s.connections.GetByID(ctx, userid, clientid)
 	if err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.ErrWithMsg(err, "Failed to get oauth app connection")
@@ -169,7 +171,7 @@ func (s *service) AuthCode(userid, clientid, scope, nonce, challenge, method str
 		if err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to create oauth app connection")
 		}
-		if err := s.connections.Insert(m); err != nil {
+		if err := s.connections.Insert(ctx, m); err != nil {
 			if errors.Is(err, db.ErrUnique{}) {
 				return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 					Status:  http.StatusBadRequest,
@@ -196,7 +198,7 @@ func (s *service) AuthCode(userid, clientid, scope, nonce, challenge, method str
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to generate auth code")
 	}
-	if err := s.connections.Update(m); err != nil {
+	if err := s.connections.Update(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to update oauth app connection")
 	}
 	return &resAuthCode{
@@ -252,9 +254,9 @@ func ssvSet(s string) map[string]struct{} {
 	return scopes
 }
 
-func (s *service) getUserinfoClaims(userid string, scopes map[string]struct{}) (*UserinfoClaims, error) {
+func (s *service) getUserinfoClaims(ctx context.Context, userid string, scopes map[string]struct{}) (*UserinfoClaims, error) {
 	claims := &UserinfoClaims{}
-	user, err := s.users.GetByID(userid)
+	user, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "User not found")
 	}
@@ -285,8 +287,8 @@ func (s *service) getUserinfoClaims(userid string, scopes map[string]struct{}) (
 	return claims, nil
 }
 
-func (s *service) checkClientKey(clientid, key, redirect string) error {
-	m, err := s.getCachedClient(clientid)
+func (s *service) checkClientKey(ctx context.Context, clientid, key, redirect string) error {
+	m, err := s.getCachedClient(ctx, clientid)
 	if err != nil {
 		if errors.Is(err, ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -316,11 +318,11 @@ func (s *service) checkClientKey(clientid, key, redirect string) error {
 	return nil
 }
 
-func (s *service) AuthTokenCode(clientid, secret, redirect, userid, code, verifier string) (*resAuthToken, error) {
-	if err := s.checkClientKey(clientid, secret, redirect); err != nil {
+func (s *service) AuthTokenCode(ctx context.Context, clientid, secret, redirect, userid, code, verifier string) (*resAuthToken, error) {
+	if err := s.checkClientKey(ctx, clientid, secret, redirect); err != nil {
 		return nil, err
 	}
-	m, err := s.connections.GetByID(userid, clientid)
+	m, err := s.connections.GetByID(ctx, userid, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -399,7 +401,7 @@ func (s *service) AuthTokenCode(clientid, secret, redirect, userid, code, verifi
 		m.KeyHash = ""
 	}
 
-	if err := s.connections.Update(m); err != nil {
+	if err := s.connections.Update(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to update oauth session")
 	}
 
@@ -416,7 +418,7 @@ func (s *service) AuthTokenCode(clientid, secret, redirect, userid, code, verifi
 		}
 	}
 
-	userClaims, err := s.getUserinfoClaims(userid, scopes)
+	userClaims, err := s.getUserinfoClaims(ctx, userid, scopes)
 	if err != nil {
 		return nil, err
 	}
@@ -441,8 +443,8 @@ func (s *service) AuthTokenCode(clientid, secret, redirect, userid, code, verifi
 	}, nil
 }
 
-func (s *service) Userinfo(userid string, scope string) (*resUserinfo, error) {
-	userClaims, err := s.getUserinfoClaims(userid, ssvSet(scope))
+func (s *service) Userinfo(ctx context.Context, userid string, scope string) (*resUserinfo, error) {
+	userClaims, err := s.getUserinfoClaims(ctx, userid, ssvSet(scope))
 	if err != nil {
 		return nil, err
 	}
@@ -464,13 +466,41 @@ type (
 
 	resConnections struct {
 		Connections []resConnection `json:"connections"`
+		NextCursor  string          `json:"next_cursor"`
 	}
 )
 
-func (s *service) GetConnections(userid string, amount, offset int) (*resConnections, error) {
-	m, err := s.connections.GetUserConnections(userid, amount, offset)
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to get oauth app connections")
+// GetConnections returns the oauth app connections of a user
+//
+// Connections are paged by cursor if a cursor is provided, and by offset
+// otherwise. The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetConnections(ctx context.Context, userid string, amount, offset int, cursor string) (*resConnections, error) {
+	paged := cursor != ""
+	var m []connmodel.Model
+	if paged {
+		after, err := db.ParseCursor(cursor)
+		if err != nil {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}), governor.ErrOptInner(err))
+		}
+		m, err = s.connections.GetUserConnectionsAfter(ctx, userid, after, amount)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get oauth app connections")
+		}
+	} else {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		var err error
+		m, err = s.connections.GetUserConnections(ctx, userid, amount, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get oauth app connections")
+		}
 	}
 	res := make([]resConnection, 0, len(m))
 	for _, i := range m {
@@ -482,13 +512,22 @@ func (s *service) GetConnections(userid string, amount, offset int) (*resConnect
 			CreationTime: i.CreationTime,
 		})
 	}
+	var next string
+	if paged && len(m) > 0 && len(m) == amount {
+		last := m[len(m)-1]
+		next = db.Cursor{
+			Time: last.AccessTime,
+			Key:  last.ClientID,
+		}.Encode()
+	}
 	return &resConnections{
 		Connections: res,
+		NextCursor:  next,
 	}, nil
 }
 
-func (s *service) GetConnection(userid string, clientid string) (*resConnection, error) {
-	m, err := s.connections.GetByID(userid, clientid)
+func (s *service) GetConnection(ctx context.Context, userid string, clientid string) (*resConnection, error) {
+	m, err := s.connections.GetByID(ctx, userid, clientid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -507,8 +546,8 @@ func (s *service) GetConnection(userid string, clientid string) (*resConnection,
 	}, nil
 }
 
-func (s *service) DelConnection(userid string, clientid string) error {
-	if _, err := s.connections.GetByID(userid, clientid); err != nil {
+func (s *service) DelConnection(ctx context.Context, userid string, clientid string) error {
+	if _, err := s.connections.GetByID(ctx, userid, clientid); err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
@@ -517,7 +556,7 @@ func (s *service) DelConnection(userid string, clientid string) error {
 		}
 		return governor.ErrWithMsg(err, "Failed to get oauth app connection")
 	}
-	if err := s.connections.Delete(userid, []string{clientid}); err != nil {
+	if err := s.connections.Delete(ctx, userid, []string{clientid}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete oauth app connection")
 	}
 	return nil
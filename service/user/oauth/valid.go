@@ -5,6 +5,7 @@ import (
 	"net/url"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
 )
 
 const (
@@ -12,6 +13,7 @@ const (
 	lengthCapUserid   = 31
 	lengthCap         = 127
 	amountCap         = 1024
+	lengthCapCursor   = 255
 	lengthCapURL      = 512
 	lengthCapRedirect = 512
 	lengthCapLarge    = 4095
@@ -101,6 +103,32 @@ func validOffset(offset int) error {
 	return nil
 }
 
+func validoptOffset(offset int) error {
+	if offset == -1 {
+		return nil
+	}
+	return validOffset(offset)
+}
+
+func validoptCursor(cursor string) error {
+	if len(cursor) == 0 {
+		return nil
+	}
+	if len(cursor) > lengthCapCursor {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Cursor must be shorter than 256 characters",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	if _, err := db.ParseCursor(cursor); err != nil {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Cursor is invalid",
+			Status:  http.StatusBadRequest,
+		}), governor.ErrOptInner(err))
+	}
+	return nil
+}
+
 func validName(name string) error {
 	if len(name) == 0 {
 		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -8,7 +9,7 @@ import (
 	"xorkevin.dev/governor/util/uid"
 )
 
-//go:generate forge model -m Model -t userorgs -p org -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t userorgs -p org -o model_gen.go Model
 
 const (
 	uidSize = 16
@@ -18,14 +19,15 @@ type (
 	// Repo is a user org repository
 	Repo interface {
 		New(displayName, desc string) (*Model, error)
-		GetByID(orgid string) (*Model, error)
-		GetByName(orgname string) (*Model, error)
-		GetAllOrgs(limit, offset int) ([]Model, error)
-		GetOrgs(orgids []string) ([]Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(m *Model) error
-		Setup() error
+		GetByID(ctx context.Context, orgid string) (*Model, error)
+		GetByName(ctx context.Context, orgname string) (*Model, error)
+		GetAllOrgs(ctx context.Context, limit, offset int) ([]Model, error)
+		GetAllOrgsAfter(ctx context.Context, cursor *db.Cursor, limit int) ([]Model, error)
+		GetOrgs(ctx context.Context, orgids []string) ([]Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -38,7 +40,7 @@ type (
 		Name         string `model:"name,VARCHAR(255) NOT NULL UNIQUE" query:"name,getoneeq,name"`
 		DisplayName  string `model:"display_name,VARCHAR(255) NOT NULL" query:"display_name"`
 		Desc         string `model:"description,VARCHAR(255) NOT NULL" query:"description"`
-		CreationTime int64  `model:"creation_time,BIGINT NOT NULL;index" query:"creation_time,getgroup"`
+		CreationTime int64  `model:"creation_time,BIGINT NOT NULL;index" query:"creation_time,getgroup;getpage,orgid|tie"`
 	}
 
 	ctxKeyRepo struct{}
@@ -93,12 +95,12 @@ func (r *repo) New(displayName, desc string) (*Model, error) {
 	}, nil
 }
 
-func (r *repo) GetByID(orgid string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, orgid string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := orgModelGetModelEqOrgID(d, orgid)
+	m, code, err := orgModelGetModelEqOrgID(ctx, d, orgid)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No org found with that id")
@@ -108,12 +110,12 @@ func (r *repo) GetByID(orgid string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) GetByName(orgname string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByName(ctx context.Context, orgname string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := orgModelGetModelEqName(d, orgname)
+	m, code, err := orgModelGetModelEqName(ctx, d, orgname)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No org found with that name")
@@ -122,36 +124,52 @@ func (r *repo) GetByName(orgname string) (*Model, error) {
 	}
 	return m, nil
 }
-func (r *repo) GetAllOrgs(limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetAllOrgs(ctx context.Context, limit, offset int) ([]Model, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
 	if err != nil {
 		return nil, err
 	}
-	m, err := orgModelGetModelOrdCreationTime(d, false, limit, offset)
+	m, err := orgModelGetModelOrdCreationTime(ctx, d, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get orgs")
 	}
 	return m, nil
 }
 
-func (r *repo) GetOrgs(orgids []string) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetAllOrgsAfter(ctx context.Context, cursor *db.Cursor, limit int) ([]Model, error) {
+	d, err := r.db.ReadExecutor(ctx, db.ReplicaStaleness)
 	if err != nil {
 		return nil, err
 	}
-	m, err := orgModelGetModelHasOrgIDOrdOrgID(d, orgids, true, len(orgids), 0)
+	if cursor == nil {
+		c := db.FirstCursor(false)
+		cursor = &c
+	}
+	m, err := orgModelGetModelPageCreationTimeOrgID(ctx, d, false, cursor.Time, cursor.Key, limit)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get orgs")
+	}
+	return m, nil
+}
+
+func (r *repo) GetOrgs(ctx context.Context, orgids []string) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := orgModelGetModelHasOrgIDOrdOrgID(ctx, d, orgids, true, len(orgids), 0)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get orgs")
 	}
 	return m, nil
 }
 
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := orgModelInsert(d, m); err != nil {
+	if code, err := orgModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Org name must be unique")
 		}
@@ -160,12 +178,12 @@ func (r *repo) Insert(m *Model) error {
 	return nil
 }
 
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := orgModelUpdModelEqOrgID(d, m, m.OrgID); err != nil {
+	if code, err := orgModelUpdModelEqOrgID(ctx, d, m, m.OrgID); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Org name must be unique")
 		}
@@ -174,23 +192,23 @@ func (r *repo) Update(m *Model) error {
 	return nil
 }
 
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := orgModelDelEqOrgID(d, m.OrgID); err != nil {
+	if err := orgModelDelEqOrgID(ctx, d, m.OrgID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete org")
 	}
 	return nil
 }
 
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := orgModelSetup(d); err != nil {
+	if code, err := orgModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup org model")
 		}
@@ -88,12 +88,12 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
 
-	if err := s.orgs.Setup(); err != nil {
+	if err := s.orgs.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created userorgs table", nil)
@@ -101,7 +101,7 @@ func (s *service) Setup(req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -25,8 +25,9 @@ type (
 	}
 
 	reqOrgsGetBulk struct {
-		Amount int `valid:"amount" json:"-"`
-		Offset int `valid:"offset" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset,opt" json:"-"`
+		Cursor string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -40,7 +41,7 @@ func (m *router) getOrg(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByID(req.OrgID)
+	res, err := m.s.GetByID(c.Ctx(), req.OrgID)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -58,7 +59,7 @@ func (m *router) getOrgByName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByName(req.Name)
+	res, err := m.s.GetByName(c.Ctx(), req.Name)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -76,7 +77,7 @@ func (m *router) getOrgs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetOrgs(strings.Split(req.OrgIDs, ","))
+	res, err := m.s.GetOrgs(c.Ctx(), strings.Split(req.OrgIDs, ","))
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -89,13 +90,14 @@ func (m *router) getAllOrgs(w http.ResponseWriter, r *http.Request) {
 	req := reqOrgsGetBulk{
 		Amount: c.QueryInt("amount", -1),
 		Offset: c.QueryInt("offset", -1),
+		Cursor: c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetAllOrgs(req.Amount, req.Offset)
+	res, err := m.s.GetAllOrgs(c.Ctx(), req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -124,7 +126,7 @@ func (m *router) createOrg(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.CreateOrg(req.Userid, req.Display, req.Desc)
+	res, err := m.s.CreateOrg(c.Ctx(), req.Userid, req.Display, req.Desc)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -154,7 +156,7 @@ func (m *router) updateOrg(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.UpdateOrg(req.OrgID, req.Name, req.Display, req.Desc); err != nil {
+	if err := m.s.UpdateOrg(c.Ctx(), req.OrgID, req.Name, req.Display, req.Desc); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -171,7 +173,7 @@ func (m *router) deleteOrg(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.DeleteOrg(req.OrgID); err != nil {
+	if err := m.s.DeleteOrg(c.Ctx(), req.OrgID); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -1,11 +1,13 @@
 package org
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/user/org/model"
 	"xorkevin.dev/governor/util/rank"
 )
 
@@ -19,12 +21,13 @@ type (
 	}
 
 	resOrgs struct {
-		Orgs []resOrg `json:"orgs"`
+		Orgs       []resOrg `json:"orgs"`
+		NextCursor string   `json:"next_cursor,omitempty"`
 	}
 )
 
-func (s *service) GetByID(orgid string) (*resOrg, error) {
-	m, err := s.orgs.GetByID(orgid)
+func (s *service) GetByID(ctx context.Context, orgid string) (*resOrg, error) {
+	m, err := s.orgs.GetByID(ctx, orgid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -43,8 +46,8 @@ func (s *service) GetByID(orgid string) (*resOrg, error) {
 	}, nil
 }
 
-func (s *service) GetByName(name string) (*resOrg, error) {
-	m, err := s.orgs.GetByName(name)
+func (s *service) GetByName(ctx context.Context, name string) (*resOrg, error) {
+	m, err := s.orgs.GetByName(ctx, name)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -63,8 +66,8 @@ func (s *service) GetByName(name string) (*resOrg, error) {
 	}, nil
 }
 
-func (s *service) GetOrgs(orgids []string) (*resOrgs, error) {
-	m, err := s.orgs.GetOrgs(orgids)
+func (s *service) GetOrgs(ctx context.Context, orgids []string) (*resOrgs, error) {
+	m, err := s.orgs.GetOrgs(ctx, orgids)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get orgs")
 	}
@@ -83,10 +86,37 @@ func (s *service) GetOrgs(orgids []string) (*resOrgs, error) {
 	}, nil
 }
 
-func (s *service) GetAllOrgs(limit, offset int) (*resOrgs, error) {
-	m, err := s.orgs.GetAllOrgs(limit, offset)
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to get orgs")
+// GetAllOrgs returns a group of all orgs
+//
+// Orgs are paged by cursor if a cursor is provided, and by offset otherwise.
+// The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetAllOrgs(ctx context.Context, limit, offset int, cursor string) (*resOrgs, error) {
+	paged := cursor != ""
+	var m []model.Model
+	if paged {
+		after, err := db.ParseCursor(cursor)
+		if err != nil {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}), governor.ErrOptInner(err))
+		}
+		m, err = s.orgs.GetAllOrgsAfter(ctx, after, limit)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get orgs")
+		}
+	} else {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		var err error
+		m, err = s.orgs.GetAllOrgs(ctx, limit, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get orgs")
+		}
 	}
 	orgs := make([]resOrg, 0, len(m))
 	for _, i := range m {
@@ -98,17 +128,26 @@ func (s *service) GetAllOrgs(limit, offset int) (*resOrgs, error) {
 			CreationTime: i.CreationTime,
 		})
 	}
+	var next string
+	if paged && len(m) > 0 && len(m) == limit {
+		last := m[len(m)-1]
+		next = db.Cursor{
+			Time: last.CreationTime,
+			Key:  last.OrgID,
+		}.Encode()
+	}
 	return &resOrgs{
-		Orgs: orgs,
+		Orgs:       orgs,
+		NextCursor: next,
 	}, nil
 }
 
-func (s *service) CreateOrg(userid, displayName, desc string) (*resOrg, error) {
+func (s *service) CreateOrg(ctx context.Context, userid, displayName, desc string) (*resOrg, error) {
 	m, err := s.orgs.New(displayName, desc)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to create org")
 	}
-	if err := s.orgs.Insert(m); err != nil {
+	if err := s.orgs.Insert(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusBadRequest,
@@ -118,7 +157,7 @@ func (s *service) CreateOrg(userid, displayName, desc string) (*resOrg, error) {
 		return nil, governor.ErrWithMsg(err, "Failed to insert org")
 	}
 	orgrole := rank.ToOrgName(m.OrgID)
-	if err := s.roles.InsertRoles(userid, rank.Rank{}.AddMod(orgrole).AddUsr(orgrole)); err != nil {
+	if err := s.roles.InsertRoles(ctx, userid, rank.Rank{}.AddMod(orgrole).AddUsr(orgrole)); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to add mod roles to user")
 	}
 	return &resOrg{
@@ -130,8 +169,8 @@ func (s *service) CreateOrg(userid, displayName, desc string) (*resOrg, error) {
 	}, nil
 }
 
-func (s *service) UpdateOrg(orgid, name, displayName, desc string) error {
-	m, err := s.orgs.GetByID(orgid)
+func (s *service) UpdateOrg(ctx context.Context, orgid, name, displayName, desc string) error {
+	m, err := s.orgs.GetByID(ctx, orgid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -144,7 +183,7 @@ func (s *service) UpdateOrg(orgid, name, displayName, desc string) error {
 	m.Name = name
 	m.DisplayName = displayName
 	m.Desc = desc
-	if err := s.orgs.Update(m); err != nil {
+	if err := s.orgs.Update(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusBadRequest,
@@ -156,8 +195,8 @@ func (s *service) UpdateOrg(orgid, name, displayName, desc string) error {
 	return nil
 }
 
-func (s *service) DeleteOrg(orgid string) error {
-	m, err := s.orgs.GetByID(orgid)
+func (s *service) DeleteOrg(ctx context.Context, orgid string) error {
+	m, err := s.orgs.GetByID(ctx, orgid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -168,13 +207,13 @@ func (s *service) DeleteOrg(orgid string) error {
 		return governor.ErrWithMsg(err, "Failed to get org")
 	}
 	orgrole := rank.ToOrgName(orgid)
-	if err := s.roles.DeleteByRole(rank.ToUsrName(orgrole)); err != nil {
+	if err := s.roles.DeleteByRole(ctx, rank.ToUsrName(orgrole)); err != nil {
 		return governor.ErrWithMsg(err, "Failed to remove org users")
 	}
-	if err := s.roles.DeleteByRole(rank.ToModName(orgrole)); err != nil {
+	if err := s.roles.DeleteByRole(ctx, rank.ToModName(orgrole)); err != nil {
 		return governor.ErrWithMsg(err, "Failed to remove org mods")
 	}
-	if err := s.orgs.Delete(m); err != nil {
+	if err := s.orgs.Delete(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete org")
 	}
 	return nil
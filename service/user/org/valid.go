@@ -5,6 +5,7 @@ import (
 	"regexp"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
 )
 
 const (
@@ -13,6 +14,7 @@ const (
 	lengthCap       = 127
 	lengthCapLarge  = 4095
 	amountCap       = 1024
+	lengthCapCursor = 255
 )
 
 var (
@@ -156,3 +158,29 @@ func validOffset(offset int) error {
 	}
 	return nil
 }
+
+func validoptOffset(offset int) error {
+	if offset == -1 {
+		return nil
+	}
+	return validOffset(offset)
+}
+
+func validoptCursor(cursor string) error {
+	if len(cursor) == 0 {
+		return nil
+	}
+	if len(cursor) > lengthCapCursor {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Cursor must be shorter than 256 characters",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	if _, err := db.ParseCursor(cursor); err != nil {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Cursor is invalid",
+			Status:  http.StatusBadRequest,
+		}), governor.ErrOptInner(err))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -9,7 +10,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t userresets -p reset -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t userresets -p reset -o model_gen.go Model
 
 const (
 	keySize = 16
@@ -21,12 +22,13 @@ type (
 		New(userid, kind string) *Model
 		ValidateCode(code string, m *Model) (bool, error)
 		RehashCode(m *Model) (string, error)
-		GetByID(userid, kind string) (*Model, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(userid, kind string) error
-		DeleteByUserid(userid string) error
-		Setup() error
+		GetByID(ctx context.Context, userid, kind string) (*Model, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, userid, kind string) error
+		DeleteByUserid(ctx context.Context, userid string) error
+		DeleteByUseridTx(ctx context.Context, tx db.SQLExecutor, userid string) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -119,12 +121,12 @@ func (r *repo) RehashCode(m *Model) (string, error) {
 	return codestr, nil
 }
 
-func (r *repo) GetByID(userid, kind string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid, kind string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := resetModelGetModelEqUseridEqKind(d, userid, kind)
+	m, code, err := resetModelGetModelEqUseridEqKind(ctx, d, userid, kind)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "Code does not exist")
@@ -134,12 +136,12 @@ func (r *repo) GetByID(userid, kind string) (*Model, error) {
 	return m, nil
 }
 
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := resetModelInsert(d, m); err != nil {
+	if code, err := resetModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Reset code already exists")
 		}
@@ -148,45 +150,50 @@ func (r *repo) Insert(m *Model) error {
 	return nil
 }
 
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := resetModelUpdModelEqUseridEqKind(d, m, m.Userid, m.Kind); err != nil {
+	if _, err := resetModelUpdModelEqUseridEqKind(ctx, d, m, m.Userid, m.Kind); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update reset code")
 	}
 	return nil
 }
 
-func (r *repo) Delete(userid, kind string) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, userid, kind string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := resetModelDelEqUseridEqKind(d, userid, kind); err != nil {
+	if err := resetModelDelEqUseridEqKind(ctx, d, userid, kind); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete reset code")
 	}
 	return nil
 }
 
-func (r *repo) DeleteByUserid(userid string) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteByUserid(ctx context.Context, userid string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := resetModelDelEqUserid(d, userid); err != nil {
+	return r.DeleteByUseridTx(ctx, d, userid)
+}
+
+// DeleteByUseridTx deletes all the reset codes of a user in a transaction
+func (r *repo) DeleteByUseridTx(ctx context.Context, tx db.SQLExecutor, userid string) error {
+	if err := resetModelDelEqUserid(ctx, tx, userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete reset codes")
 	}
 	return nil
 }
 
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := resetModelSetup(d); err != nil {
+	if code, err := resetModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup user reset code model")
 		}
@@ -1,24 +1,25 @@
 package model
 
 import (
+	"context"
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
 	"xorkevin.dev/governor/util/rank"
 )
 
-//go:generate forge model -m Model -t userroleinvitations -p inv -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t userroleinvitations -p inv -o model_gen.go Model
 
 type (
 	// Repo is a role invitation repository
 	Repo interface {
-		GetByID(userid, role string, after int64) (*Model, error)
-		GetByUser(userid string, after int64, limit, offset int) ([]Model, error)
-		GetByRole(role string, after int64, limit, offset int) ([]Model, error)
-		Insert(userid string, roles rank.Rank, by string, at int64) error
-		DeleteByID(userid, role string) error
-		DeleteByRoles(userid string, roles rank.Rank) error
-		DeleteBefore(before int64) error
-		Setup() error
+		GetByID(ctx context.Context, userid, role string, after int64) (*Model, error)
+		GetByUser(ctx context.Context, userid string, after int64, limit, offset int) ([]Model, error)
+		GetByRole(ctx context.Context, role string, after int64, limit, offset int) ([]Model, error)
+		Insert(ctx context.Context, userid string, roles rank.Rank, by string, at int64) error
+		DeleteByID(ctx context.Context, userid, role string) error
+		DeleteByRoles(ctx context.Context, userid string, roles rank.Rank) error
+		DeleteBefore(ctx context.Context, before int64) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -68,12 +69,12 @@ func New(database db.Database) Repo {
 	}
 }
 
-func (r *repo) GetByID(userid, role string, after int64) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid, role string, after int64) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := invModelGetModelEqUseridEqRoleGtCreationTime(d, userid, role, after)
+	m, code, err := invModelGetModelEqUseridEqRoleGtCreationTime(ctx, d, userid, role, after)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "Invitation not found")
@@ -84,12 +85,12 @@ func (r *repo) GetByID(userid, role string, after int64) (*Model, error) {
 }
 
 // GetByUser returns a user's invitations
-func (r *repo) GetByUser(userid string, after int64, limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByUser(ctx context.Context, userid string, after int64, limit, offset int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := invModelGetModelEqUseridGtCreationTimeOrdCreationTime(d, userid, after, false, limit, offset)
+	m, err := invModelGetModelEqUseridGtCreationTimeOrdCreationTime(ctx, d, userid, after, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get invitations")
 	}
@@ -97,12 +98,12 @@ func (r *repo) GetByUser(userid string, after int64, limit, offset int) ([]Model
 }
 
 // GetByRole returns a role's invitations
-func (r *repo) GetByRole(role string, after int64, limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByRole(ctx context.Context, role string, after int64, limit, offset int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := invModelGetModelEqRoleGtCreationTimeOrdCreationTime(d, role, after, false, limit, offset)
+	m, err := invModelGetModelEqRoleGtCreationTimeOrdCreationTime(ctx, d, role, after, false, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get invitations")
 	}
@@ -110,7 +111,7 @@ func (r *repo) GetByRole(role string, after int64, limit, offset int) ([]Model,
 }
 
 // Insert inserts invitations into the db
-func (r *repo) Insert(userid string, roles rank.Rank, by string, at int64) error {
+func (r *repo) Insert(ctx context.Context, userid string, roles rank.Rank, by string, at int64) error {
 	if len(roles) == 0 {
 		return nil
 	}
@@ -124,62 +125,62 @@ func (r *repo) Insert(userid string, roles rank.Rank, by string, at int64) error
 			CreationTime: at,
 		})
 	}
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := invModelInsertBulk(d, m, true); err != nil {
+	if _, err := invModelInsertBulk(ctx, d, m, true); err != nil {
 		return governor.ErrWithMsg(err, "Failed to insert invitations")
 	}
 	return nil
 }
 
 // DeleteByID deletes an invitation by userid and role
-func (r *repo) DeleteByID(userid, role string) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteByID(ctx context.Context, userid, role string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := invModelDelEqUseridEqRole(d, userid, role); err != nil {
+	if err := invModelDelEqUseridEqRole(ctx, d, userid, role); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete invitation")
 	}
 	return nil
 }
 
 // DeleteByRoles deletes invitations by userid and roles
-func (r *repo) DeleteByRoles(userid string, roles rank.Rank) error {
+func (r *repo) DeleteByRoles(ctx context.Context, userid string, roles rank.Rank) error {
 	if len(roles) == 0 {
 		return nil
 	}
 
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := invModelDelEqUseridHasRole(d, userid, roles.ToSlice()); err != nil {
+	if err := invModelDelEqUseridHasRole(ctx, d, userid, roles.ToSlice()); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete invitations")
 	}
 	return nil
 }
 
-func (r *repo) DeleteBefore(before int64) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteBefore(ctx context.Context, before int64) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := invModelDelLeqCreationTime(d, before); err != nil {
+	if err := invModelDelLeqCreationTime(ctx, d, before); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete invitations")
 	}
 	return nil
 }
 
 // Setup creates a new role invitation table
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := invModelSetup(d); err != nil {
+	if code, err := invModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup role invitation model")
 		}
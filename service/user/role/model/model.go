@@ -1,27 +1,37 @@
 package model
 
 import (
+	"context"
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
 	"xorkevin.dev/governor/util/rank"
 )
 
-//go:generate forge model -m Model -t userroles -p role -o model_gen.go Model
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t userroles -p role -o model_gen.go Model
+
+const (
+	// userRolesMax is the max number of roles of a user that are read at once
+	userRolesMax = 65536
+)
 
 type (
 	// Repo is a user role repository
 	Repo interface {
 		New(userid, role string) *Model
-		GetByID(userid, role string) (*Model, error)
-		IntersectRoles(userid string, roles rank.Rank) (rank.Rank, error)
-		GetByRole(role string, limit, offset int) ([]string, error)
-		GetRoles(userid string, limit, offset int) (rank.Rank, error)
-		GetRolesPrefix(userid string, prefix string, limit, offset int) (rank.Rank, error)
-		InsertRoles(userid string, roles rank.Rank) error
-		DeleteRoles(userid string, roles rank.Rank) error
-		DeleteByRole(role string) error
-		DeleteUserRoles(userid string) error
-		Setup() error
+		GetByID(ctx context.Context, userid, role string) (*Model, error)
+		IntersectRoles(ctx context.Context, userid string, roles rank.Rank) (rank.Rank, error)
+		GetByRole(ctx context.Context, role string, limit, offset int) ([]string, error)
+		GetRoles(ctx context.Context, userid string, limit, offset int) (rank.Rank, error)
+		GetRolesPrefix(ctx context.Context, userid string, prefix string, limit, offset int) (rank.Rank, error)
+		GetRolesAfter(ctx context.Context, userid string, cursor *db.Cursor, limit int) (rank.Rank, error)
+		GetRolesPrefixAfter(ctx context.Context, userid string, prefix string, cursor *db.Cursor, limit int) (rank.Rank, error)
+		InsertRoles(ctx context.Context, userid string, roles rank.Rank) error
+		InsertRolesTx(ctx context.Context, tx db.SQLExecutor, userid string, roles rank.Rank) error
+		DeleteRoles(ctx context.Context, userid string, roles rank.Rank) error
+		DeleteByRole(ctx context.Context, role string) error
+		DeleteUserRoles(ctx context.Context, userid string) (rank.Rank, error)
+		DeleteUserRolesTx(ctx context.Context, tx db.SQLExecutor, userid string) (rank.Rank, error)
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -31,7 +41,7 @@ type (
 	// Model is the db User role model
 	Model struct {
 		Userid string `model:"userid,VARCHAR(31);index" query:"userid,getgroupeq,role;deleq,userid"`
-		Role   string `model:"role,VARCHAR(255), PRIMARY KEY (userid, role);index" query:"role,getoneeq,userid,role;getgroupeq,userid;getgroupeq,userid,role|arr;getgroupeq,userid,role|like;deleq,role;deleq,userid,role;deleq,userid,role|arr"`
+		Role   string `model:"role,VARCHAR(255), PRIMARY KEY (userid, role);index" query:"role,getoneeq,userid,role;getgroupeq,userid;getgroupeq,userid,role|arr;getgroupeq,userid,role|like;getpageeq,userid;getpageeq,userid,role|like;deleq,role;deleq,userid,role;deleq,userid,role|arr"`
 	}
 
 	ctxKeyRepo struct{}
@@ -78,12 +88,12 @@ func (r *repo) New(userid, role string) *Model {
 }
 
 // GetByID returns a user role model with the given id
-func (r *repo) GetByID(userid, role string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, userid, role string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := roleModelGetModelEqUseridEqRole(d, userid, role)
+	m, code, err := roleModelGetModelEqUseridEqRole(ctx, d, userid, role)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "Role not found for user")
@@ -94,16 +104,16 @@ func (r *repo) GetByID(userid, role string) (*Model, error) {
 }
 
 // IntersectRoles gets the intersection of user roles and the input roles
-func (r *repo) IntersectRoles(userid string, roles rank.Rank) (rank.Rank, error) {
+func (r *repo) IntersectRoles(ctx context.Context, userid string, roles rank.Rank) (rank.Rank, error) {
 	if len(roles) == 0 {
 		return rank.Rank{}, nil
 	}
 
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := roleModelGetModelEqUseridHasRoleOrdRole(d, userid, roles.ToSlice(), true, len(roles), 0)
+	m, err := roleModelGetModelEqUseridHasRoleOrdRole(ctx, d, userid, roles.ToSlice(), true, len(roles), 0)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user roles")
 	}
@@ -115,12 +125,12 @@ func (r *repo) IntersectRoles(userid string, roles rank.Rank) (rank.Rank, error)
 }
 
 // GetByRole returns a list of userids with the given role
-func (r *repo) GetByRole(role string, limit, offset int) ([]string, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByRole(ctx context.Context, role string, limit, offset int) ([]string, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := roleModelGetModelEqRoleOrdUserid(d, role, true, limit, offset)
+	m, err := roleModelGetModelEqRoleOrdUserid(ctx, d, role, true, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get userids of role")
 	}
@@ -132,12 +142,12 @@ func (r *repo) GetByRole(role string, limit, offset int) ([]string, error) {
 }
 
 // GetRoles returns a list of a user's roles
-func (r *repo) GetRoles(userid string, limit, offset int) (rank.Rank, error) {
-	d, err := r.db.DB()
+func (r *repo) GetRoles(ctx context.Context, userid string, limit, offset int) (rank.Rank, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := roleModelGetModelEqUseridOrdRole(d, userid, true, limit, offset)
+	m, err := roleModelGetModelEqUseridOrdRole(ctx, d, userid, true, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get roles of userid")
 	}
@@ -149,12 +159,59 @@ func (r *repo) GetRoles(userid string, limit, offset int) (rank.Rank, error) {
 }
 
 // GetRolesPrefix returns a list of a user's roles with a prefix
-func (r *repo) GetRolesPrefix(userid string, prefix string, limit, offset int) (rank.Rank, error) {
-	d, err := r.db.DB()
+func (r *repo) GetRolesPrefix(ctx context.Context, userid string, prefix string, limit, offset int) (rank.Rank, error) {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := roleModelGetModelEqUseridLikeRoleOrdRole(ctx, d, userid, prefix+"%", true, limit, offset)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get roles of userid")
+	}
+	roles := make(rank.Rank, len(m))
+	for _, i := range m {
+		roles[i.Role] = struct{}{}
+	}
+	return roles, nil
+}
+
+// GetRolesAfter returns a page of a user's roles after a cursor
+//
+// The first page is returned if cursor is nil.
+func (r *repo) GetRolesAfter(ctx context.Context, userid string, cursor *db.Cursor, limit int) (rank.Rank, error) {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		c := db.FirstCursor(true)
+		cursor = &c
+	}
+	m, err := roleModelGetModelEqUseridPageRole(ctx, d, userid, true, cursor.Key, limit)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get roles of userid")
+	}
+	roles := make(rank.Rank, len(m))
+	for _, i := range m {
+		roles[i.Role] = struct{}{}
+	}
+	return roles, nil
+}
+
+// GetRolesPrefixAfter returns a page of a user's roles with a prefix after a
+// cursor
+//
+// The first page is returned if cursor is nil.
+func (r *repo) GetRolesPrefixAfter(ctx context.Context, userid string, prefix string, cursor *db.Cursor, limit int) (rank.Rank, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := roleModelGetModelEqUseridLikeRoleOrdRole(d, userid, prefix+"%", true, limit, offset)
+	if cursor == nil {
+		c := db.FirstCursor(true)
+		cursor = &c
+	}
+	m, err := roleModelGetModelEqUseridLikeRolePageRole(ctx, d, userid, prefix+"%", true, cursor.Key, limit)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get roles of userid")
 	}
@@ -166,12 +223,12 @@ func (r *repo) GetRolesPrefix(userid string, prefix string, limit, offset int) (
 }
 
 // Insert inserts the model into the db
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := roleModelInsert(d, m); err != nil {
+	if code, err := roleModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Role id must be unique")
 		}
@@ -181,7 +238,16 @@ func (r *repo) Insert(m *Model) error {
 }
 
 // InsertRoles inserts roles for a user into the db
-func (r *repo) InsertRoles(userid string, roles rank.Rank) error {
+func (r *repo) InsertRoles(ctx context.Context, userid string, roles rank.Rank) error {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return err
+	}
+	return r.InsertRolesTx(ctx, d, userid, roles)
+}
+
+// InsertRolesTx inserts roles for a user into the db in a transaction
+func (r *repo) InsertRolesTx(ctx context.Context, tx db.SQLExecutor, userid string, roles rank.Rank) error {
 	if len(roles) == 0 {
 		return nil
 	}
@@ -193,75 +259,89 @@ func (r *repo) InsertRoles(userid string, roles rank.Rank) error {
 			Role:   i,
 		})
 	}
-	d, err := r.db.DB()
-	if err != nil {
-		return err
-	}
-	if _, err := roleModelInsertBulk(d, m, true); err != nil {
+	if _, err := roleModelInsertBulk(ctx, tx, m, true); err != nil {
 		return governor.ErrWithMsg(err, "Failed to insert roles")
 	}
 	return nil
 }
 
 // Delete deletes the model in the db
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := roleModelDelEqUseridEqRole(d, m.Userid, m.Role); err != nil {
+	if err := roleModelDelEqUseridEqRole(ctx, d, m.Userid, m.Role); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete role")
 	}
 	return nil
 }
 
 // DeleteRoles deletes multiple roles from the db of a userid
-func (r *repo) DeleteRoles(userid string, roles rank.Rank) error {
+func (r *repo) DeleteRoles(ctx context.Context, userid string, roles rank.Rank) error {
 	if len(roles) == 0 {
 		return nil
 	}
 
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := roleModelDelEqUseridHasRole(d, userid, roles.ToSlice()); err != nil {
+	if err := roleModelDelEqUseridHasRole(ctx, d, userid, roles.ToSlice()); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete roles")
 	}
 	return nil
 }
 
 // DeleteByRole deletes by role name
-func (r *repo) DeleteByRole(role string) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteByRole(ctx context.Context, role string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := roleModelDelEqRole(d, role); err != nil {
+	if err := roleModelDelEqRole(ctx, d, role); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete roles")
 	}
 	return nil
 }
 
-// DeleteUserRoles deletes all the roles of a user
-func (r *repo) DeleteUserRoles(userid string) error {
-	d, err := r.db.DB()
-	if err != nil {
+// DeleteUserRoles deletes all the roles of a user and returns them
+func (r *repo) DeleteUserRoles(ctx context.Context, userid string) (rank.Rank, error) {
+	var roles rank.Rank
+	if err := r.db.WithTx(ctx, nil, func(tx db.SQLExecutor) error {
+		var err error
+		roles, err = r.DeleteUserRolesTx(ctx, tx, userid)
 		return err
+	}); err != nil {
+		return nil, err
 	}
-	if err := roleModelDelEqUserid(d, userid); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user roles")
+	return roles, nil
+}
+
+// DeleteUserRolesTx deletes all the roles of a user in a transaction and
+// returns them
+func (r *repo) DeleteUserRolesTx(ctx context.Context, tx db.SQLExecutor, userid string) (rank.Rank, error) {
+	m, err := roleModelGetModelEqUseridOrdRole(ctx, tx, userid, true, userRolesMax, 0)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get roles of userid")
 	}
-	return nil
+	if err := roleModelDelEqUserid(ctx, tx, userid); err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to delete user roles")
+	}
+	roles := make(rank.Rank, len(m))
+	for _, i := range m {
+		roles[i.Role] = struct{}{}
+	}
+	return roles, nil
 }
 
 // Setup creates a new User role table
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := roleModelSetup(d); err != nil {
+	if code, err := roleModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup role model")
 		}
@@ -6,7 +6,8 @@ import (
 	"time"
 
 	"xorkevin.dev/governor"
-	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/kvstore/nearcache"
 	"xorkevin.dev/governor/service/user/role/model"
 	"xorkevin.dev/governor/util/rank"
 )
@@ -18,13 +19,17 @@ const (
 type (
 	// Roles manages user roles
 	Roles interface {
-		IntersectRoles(userid string, roles rank.Rank) (rank.Rank, error)
-		InsertRoles(userid string, roles rank.Rank) error
-		DeleteRoles(userid string, roles rank.Rank) error
-		DeleteAllRoles(userid string) error
-		GetRoles(userid string, prefix string, amount, offset int) (rank.Rank, error)
-		GetByRole(roleName string, amount, offset int) ([]string, error)
-		DeleteByRole(roleName string) error
+		IntersectRoles(ctx context.Context, userid string, roles rank.Rank) (rank.Rank, error)
+		InsertRoles(ctx context.Context, userid string, roles rank.Rank) error
+		InsertRolesTx(ctx context.Context, tx db.SQLExecutor, userid string, roles rank.Rank) error
+		DeleteRoles(ctx context.Context, userid string, roles rank.Rank) error
+		DeleteAllRoles(ctx context.Context, userid string) error
+		DeleteAllRolesTx(ctx context.Context, tx db.SQLExecutor, userid string) (rank.Rank, error)
+		ClearUserCache(ctx context.Context, userid string, roles rank.Rank)
+		GetRoles(ctx context.Context, userid string, prefix string, amount, offset int) (rank.Rank, error)
+		GetRolesAfter(ctx context.Context, userid string, prefix string, cursor *db.Cursor, amount int) (rank.Rank, error)
+		GetByRole(ctx context.Context, roleName string, amount, offset int) ([]string, error)
+		DeleteByRole(ctx context.Context, roleName string) error
 	}
 
 	// Service is a Roles and governor.Service
@@ -35,7 +40,7 @@ type (
 
 	service struct {
 		roles         model.Repo
-		kvroleset     kvstore.KVStore
+		kvroleset     nearcache.Cache
 		logger        governor.Logger
 		roleCacheTime int64
 	}
@@ -60,12 +65,12 @@ func setCtxRoles(inj governor.Injector, r Roles) {
 // NewCtx creates a new Roles service from a context
 func NewCtx(inj governor.Injector) Service {
 	roles := model.GetCtxRepo(inj)
-	kv := kvstore.GetCtxKVStore(inj)
+	kv := nearcache.GetCtxCache(inj)
 	return New(roles, kv)
 }
 
 // New returns a new Roles
-func New(roles model.Repo, kv kvstore.KVStore) Service {
+func New(roles model.Repo, kv nearcache.Cache) Service {
 	return &service{
 		roles:         roles,
 		kvroleset:     kv.Subtree("roleset"),
@@ -98,12 +103,12 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
 
-	if err := s.roles.Setup(); err != nil {
+	if err := s.roles.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created userrole table", nil)
@@ -111,7 +116,7 @@ func (s *service) Setup(req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
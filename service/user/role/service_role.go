@@ -1,10 +1,10 @@
 package role
 
 import (
-	"errors"
+	"context"
 
 	"xorkevin.dev/governor"
-	"xorkevin.dev/governor/service/kvstore"
+	"xorkevin.dev/governor/service/db"
 	"xorkevin.dev/governor/util/rank"
 )
 
@@ -13,47 +13,33 @@ const (
 	cacheValN = "n"
 )
 
-func (s *service) intersectRolesRepo(userid string, roles rank.Rank) (rank.Rank, error) {
-	m, err := s.roles.IntersectRoles(userid, roles)
+func (s *service) intersectRolesRepo(ctx context.Context, userid string, roles rank.Rank) (rank.Rank, error) {
+	m, err := s.roles.IntersectRoles(ctx, userid, roles)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get roles")
 	}
 	return m, nil
 }
 
-func (s *service) IntersectRoles(userid string, roles rank.Rank) (rank.Rank, error) {
+func (s *service) IntersectRoles(ctx context.Context, userid string, roles rank.Rank) (rank.Rank, error) {
 	userkv := s.kvroleset.Subtree(userid)
 
-	multiget, err := userkv.Multi()
+	cached, err := userkv.GetMulti(ctx, roles.ToSlice()...)
 	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to create kvstore multi")
-	}
-	resget := make(map[string]kvstore.Resulter, roles.Len())
-	for _, i := range roles.ToSlice() {
-		resget[i] = multiget.Get(i)
-	}
-	if err := multiget.Exec(); err != nil {
 		s.logger.Error("Failed to get user roles from cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "getroleset",
 		})
-		return s.intersectRolesRepo(userid, roles)
+		return s.intersectRolesRepo(ctx, userid, roles)
 	}
 
 	uncachedRoles := rank.Rank{}
 	res := rank.Rank{}
-	for k, v := range resget {
-		r, err := v.Result()
-		if err != nil {
-			if !errors.Is(err, kvstore.ErrNotFound{}) {
-				s.logger.Error("Failed to get user role result from cache", map[string]string{
-					"error":      err.Error(),
-					"actiontype": "getroleresult",
-				})
-			}
-			uncachedRoles.AddOne(k)
+	for _, i := range roles.ToSlice() {
+		if r, ok := cached[i]; !ok {
+			uncachedRoles.AddOne(i)
 		} else if r == cacheValY {
-			res.AddOne(k)
+			res.AddOne(i)
 		}
 	}
 
@@ -61,24 +47,21 @@ func (s *service) IntersectRoles(userid string, roles rank.Rank) (rank.Rank, err
 		return res, nil
 	}
 
-	m, err := s.intersectRolesRepo(userid, uncachedRoles)
+	m, err := s.intersectRolesRepo(ctx, userid, uncachedRoles)
 	if err != nil {
 		return nil, err
 	}
 
-	multiset, err := userkv.Multi()
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to create kvstore multi")
-	}
+	vals := make(map[string]string, uncachedRoles.Len())
 	for _, i := range uncachedRoles.ToSlice() {
 		if m.Has(i) {
 			res.AddOne(i)
-			multiset.Set(i, cacheValY, s.roleCacheTime)
+			vals[i] = cacheValY
 		} else {
-			multiset.Set(i, cacheValN, s.roleCacheTime)
+			vals[i] = cacheValN
 		}
 	}
-	if err := multiset.Exec(); err != nil {
+	if err := userkv.SetMulti(ctx, vals, s.roleCacheTime); err != nil {
 		s.logger.Error("Failed to set user roles in cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "setroleset",
@@ -88,62 +71,90 @@ func (s *service) IntersectRoles(userid string, roles rank.Rank) (rank.Rank, err
 	return res, nil
 }
 
-func (s *service) InsertRoles(userid string, roles rank.Rank) error {
-	if err := s.roles.InsertRoles(userid, roles); err != nil {
+func (s *service) InsertRoles(ctx context.Context, userid string, roles rank.Rank) error {
+	if err := s.roles.InsertRoles(ctx, userid, roles); err != nil {
+		return governor.ErrWithMsg(err, "Failed to create roles")
+	}
+	s.clearCache(ctx, userid, roles)
+	return nil
+}
+
+// InsertRolesTx inserts roles for a user in a transaction
+//
+// The role cache of the user is not cleared, and ClearUserCache should be
+// called with the inserted roles after the transaction is committed.
+func (s *service) InsertRolesTx(ctx context.Context, tx db.SQLExecutor, userid string, roles rank.Rank) error {
+	if err := s.roles.InsertRolesTx(ctx, tx, userid, roles); err != nil {
 		return governor.ErrWithMsg(err, "Failed to create roles")
 	}
-	s.clearCache(userid, roles)
 	return nil
 }
 
-func (s *service) DeleteRoles(userid string, roles rank.Rank) error {
-	if err := s.roles.DeleteRoles(userid, roles); err != nil {
+func (s *service) DeleteRoles(ctx context.Context, userid string, roles rank.Rank) error {
+	if err := s.roles.DeleteRoles(ctx, userid, roles); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete roles")
 	}
-	s.clearCache(userid, roles)
+	s.clearCache(ctx, userid, roles)
 	return nil
 }
 
-func (s *service) DeleteAllRoles(userid string) error {
-	roles, err := s.GetRoles(userid, "", 65536, 0)
+func (s *service) DeleteAllRoles(ctx context.Context, userid string) error {
+	roles, err := s.roles.DeleteUserRoles(ctx, userid)
 	if err != nil {
-		return governor.ErrWithMsg(err, "Failed to get user roles")
-	}
-	if err := s.roles.DeleteUserRoles(userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user roles")
 	}
-	s.clearCache(userid, roles)
+	s.clearCache(ctx, userid, roles)
 	return nil
 }
 
-func (s *service) GetRoles(userid string, prefix string, amount, offset int) (rank.Rank, error) {
+// DeleteAllRolesTx deletes all the roles of a user in a transaction and
+// returns them
+//
+// The role cache of the user is not cleared, and ClearUserCache should be
+// called with the returned roles after the transaction is committed.
+func (s *service) DeleteAllRolesTx(ctx context.Context, tx db.SQLExecutor, userid string) (rank.Rank, error) {
+	roles, err := s.roles.DeleteUserRolesTx(ctx, tx, userid)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to delete user roles")
+	}
+	return roles, nil
+}
+
+func (s *service) GetRoles(ctx context.Context, userid string, prefix string, amount, offset int) (rank.Rank, error) {
 	if len(prefix) == 0 {
-		return s.roles.GetRoles(userid, amount, offset)
+		return s.roles.GetRoles(ctx, userid, amount, offset)
 	}
-	return s.roles.GetRolesPrefix(userid, prefix, amount, offset)
+	return s.roles.GetRolesPrefix(ctx, userid, prefix, amount, offset)
 }
 
-func (s *service) GetByRole(roleName string, amount, offset int) ([]string, error) {
-	return s.roles.GetByRole(roleName, amount, offset)
+func (s *service) GetRolesAfter(ctx context.Context, userid string, prefix string, cursor *db.Cursor, amount int) (rank.Rank, error) {
+	if len(prefix) == 0 {
+		return s.roles.GetRolesAfter(ctx, userid, cursor, amount)
+	}
+	return s.roles.GetRolesPrefixAfter(ctx, userid, prefix, cursor, amount)
+}
+
+func (s *service) GetByRole(ctx context.Context, roleName string, amount, offset int) ([]string, error) {
+	return s.roles.GetByRole(ctx, roleName, amount, offset)
 }
 
-func (s *service) DeleteByRole(roleName string) error {
-	userids, err := s.GetByRole(roleName, 65536, 0)
+func (s *service) DeleteByRole(ctx context.Context, roleName string) error {
+	userids, err := s.GetByRole(ctx, roleName, 65536, 0)
 	if err != nil {
 		return governor.ErrWithMsg(err, "Failed to get role users")
 	}
-	if err := s.roles.DeleteByRole(roleName); err != nil {
+	if err := s.roles.DeleteByRole(ctx, roleName); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete role users")
 	}
-	s.clearCacheRoles(roleName, userids)
+	s.clearCacheRoles(ctx, roleName, userids)
 	return nil
 }
 
-func (s *service) clearCache(userid string, roles rank.Rank) {
+func (s *service) clearCache(ctx context.Context, userid string, roles rank.Rank) {
 	if len(roles) == 0 {
 		return
 	}
-	if err := s.kvroleset.Subtree(userid).Del(roles.ToSlice()...); err != nil {
+	if err := s.kvroleset.Subtree(userid).Del(ctx, roles.ToSlice()...); err != nil {
 		s.logger.Error("Failed to clear role set from cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "clearroleset",
@@ -151,7 +162,16 @@ func (s *service) clearCache(userid string, roles rank.Rank) {
 	}
 }
 
-func (s *service) clearCacheRoles(role string, userids []string) {
+// ClearUserCache clears the cached roles of a user
+//
+// Only the cache entries of the given roles are deleted, since finding all the
+// cached roles of a user requires scanning the kvstore. Cached entries for
+// roles the user does not have expire with the role cache time.
+func (s *service) ClearUserCache(ctx context.Context, userid string, roles rank.Rank) {
+	s.clearCache(ctx, userid, roles)
+}
+
+func (s *service) clearCacheRoles(ctx context.Context, role string, userids []string) {
 	if len(userids) == 0 {
 		return
 	}
@@ -159,7 +179,7 @@ func (s *service) clearCacheRoles(role string, userids []string) {
 	for _, i := range userids {
 		args = append(args, s.kvroleset.Subkey(i, role))
 	}
-	if err := s.kvroleset.Del(args...); err != nil {
+	if err := s.kvroleset.Del(ctx, args...); err != nil {
 		s.logger.Error("Failed to clear role set from cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "clearroleset",
@@ -3,13 +3,20 @@ package user
 import (
 	"net"
 	"net/http"
+	"time"
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/ratelimit"
+	"xorkevin.dev/governor/service/user/token"
 )
 
 //go:generate forge validation -o validation_auth_gen.go reqUserAuth reqRefreshToken
 
+const (
+	// authReqTimeout bounds the time spent on an auth request
+	authReqTimeout = 5 * time.Second
+)
+
 func (m *router) setAccessCookie(c governor.Context, accessToken string) {
 	c.SetCookie(&http.Cookie{
 		Name:     "access_token",
@@ -67,6 +74,18 @@ func (m *router) setSessionCookie(c governor.Context, sessionToken string, useri
 	})
 }
 
+func (m *router) setCSRFCookie(c governor.Context, csrfToken string) {
+	c.SetCookie(&http.Cookie{
+		Name:     "csrf_token",
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   int(m.s.refreshTime) - 5,
+		HttpOnly: false,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
 func getRefreshCookie(c governor.Context) (string, bool) {
 	cookie, err := c.Cookie("refresh_token")
 	if err != nil {
@@ -92,6 +111,18 @@ func getSessionCookie(c governor.Context, userid string) (string, bool) {
 	return cookie.Value, true
 }
 
+// checkRefreshCSRF validates a refresh token cookie authenticated request
+// against csrf attacks
+//
+// Invalid refresh tokens are left to be rejected by the service.
+func (m *router) checkRefreshCSRF(c governor.Context, refreshToken string) error {
+	ok, claims := m.s.tokenizer.GetClaims(token.KindRefresh, refreshToken)
+	if !ok {
+		return nil
+	}
+	return m.s.gate.CheckCSRF(c, claims.ID)
+}
+
 func (m *router) rmAccessCookie(c governor.Context) {
 	c.SetCookie(&http.Cookie{
 		Name:   "access_token",
@@ -101,6 +132,16 @@ func (m *router) rmAccessCookie(c governor.Context) {
 	})
 }
 
+func (m *router) rmCSRFCookie(c governor.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:   "csrf_token",
+		Value:  "invalid",
+		MaxAge: -1,
+		Path:   "/",
+		Secure: true,
+	})
+}
+
 func (m *router) rmRefreshCookie(c governor.Context, userid string) {
 	c.SetCookie(&http.Cookie{
 		Name:   "refresh_token",
@@ -156,7 +197,7 @@ func (m *router) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userid, err := m.s.GetUseridForLogin(req.Username)
+	userid, err := m.s.GetUseridForLogin(c.Ctx(), req.Username)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -170,13 +211,14 @@ func (m *router) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.Login(userid, req.Password, req.SessionToken, getHost(r), c.Header("User-Agent"))
+	res, err := m.s.Login(c.Ctx(), userid, req.Password, req.SessionToken, getHost(r), c.Header("User-Agent"))
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
 
 	m.setAccessCookie(c, res.AccessToken)
+	m.setCSRFCookie(c, res.CSRFToken)
 	m.setRefreshCookie(c, res.RefreshToken, res.Claims.Subject)
 	m.setSessionCookie(c, res.SessionToken, res.Claims.Subject)
 
@@ -192,8 +234,10 @@ type (
 func (m *router) exchangeToken(w http.ResponseWriter, r *http.Request) {
 	c := governor.NewContext(w, r, m.s.logger)
 	ruser := reqRefreshToken{}
+	isCookie := false
 	if t, ok := getRefreshCookie(c); ok {
 		ruser.RefreshToken = t
+		isCookie = true
 	} else if err := c.Bind(&ruser); err != nil {
 		c.WriteError(err)
 		return
@@ -202,14 +246,21 @@ func (m *router) exchangeToken(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
+	if isCookie {
+		if err := m.checkRefreshCSRF(c, ruser.RefreshToken); err != nil {
+			c.WriteError(err)
+			return
+		}
+	}
 
-	res, err := m.s.ExchangeToken(ruser.RefreshToken, getHost(r), c.Header("User-Agent"))
+	res, err := m.s.ExchangeToken(c.Ctx(), ruser.RefreshToken, getHost(r), c.Header("User-Agent"))
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
 
 	m.setAccessCookie(c, res.AccessToken)
+	m.setCSRFCookie(c, res.CSRFToken)
 	if res.Refresh {
 		m.setRefreshCookie(c, res.RefreshToken, res.Claims.Subject)
 		m.setSessionCookie(c, res.SessionToken, res.Claims.Subject)
@@ -220,8 +271,10 @@ func (m *router) exchangeToken(w http.ResponseWriter, r *http.Request) {
 func (m *router) refreshToken(w http.ResponseWriter, r *http.Request) {
 	c := governor.NewContext(w, r, m.s.logger)
 	ruser := reqRefreshToken{}
+	isCookie := false
 	if t, ok := getRefreshCookie(c); ok {
 		ruser.RefreshToken = t
+		isCookie = true
 	} else if err := c.Bind(&ruser); err != nil {
 		c.WriteError(err)
 		return
@@ -230,14 +283,21 @@ func (m *router) refreshToken(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
+	if isCookie {
+		if err := m.checkRefreshCSRF(c, ruser.RefreshToken); err != nil {
+			c.WriteError(err)
+			return
+		}
+	}
 
-	res, err := m.s.RefreshToken(ruser.RefreshToken, getHost(r), c.Header("User-Agent"))
+	res, err := m.s.RefreshToken(c.Ctx(), ruser.RefreshToken, getHost(r), c.Header("User-Agent"))
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
 
 	m.setAccessCookie(c, res.AccessToken)
+	m.setCSRFCookie(c, res.CSRFToken)
 	m.setRefreshCookie(c, res.RefreshToken, res.Claims.Subject)
 	m.setSessionCookie(c, res.SessionToken, res.Claims.Subject)
 
@@ -247,8 +307,10 @@ func (m *router) refreshToken(w http.ResponseWriter, r *http.Request) {
 func (m *router) logoutUser(w http.ResponseWriter, r *http.Request) {
 	c := governor.NewContext(w, r, m.s.logger)
 	ruser := reqRefreshToken{}
+	isCookie := false
 	if t, ok := getRefreshCookie(c); ok {
 		ruser.RefreshToken = t
+		isCookie = true
 	} else if err := c.Bind(&ruser); err != nil {
 		c.WriteError(err)
 		return
@@ -257,14 +319,21 @@ func (m *router) logoutUser(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
+	if isCookie {
+		if err := m.checkRefreshCSRF(c, ruser.RefreshToken); err != nil {
+			c.WriteError(err)
+			return
+		}
+	}
 
-	userid, err := m.s.Logout(ruser.RefreshToken)
+	userid, err := m.s.Logout(c.Ctx(), ruser.RefreshToken)
 	if err != nil {
 		c.WriteError(err)
 		return
 	}
 
 	m.rmAccessCookie(c)
+	m.rmCSRFCookie(c)
 	m.rmRefreshCookie(c, userid)
 
 	c.WriteStatus(http.StatusNoContent)
@@ -272,10 +341,11 @@ func (m *router) logoutUser(w http.ResponseWriter, r *http.Request) {
 
 func (m *router) mountAuth(r governor.Router) {
 	rt := ratelimit.Compose(m.s.ratelimiter, ratelimit.IPAddress("ip", 60, 15, 240))
-	r.Post("/login", m.loginUser, rt)
-	r.Post("/exchange", m.exchangeToken, rt)
-	r.Post("/refresh", m.refreshToken, rt)
-	r.Post("/id/{id}/exchange", m.exchangeToken, rt)
-	r.Post("/id/{id}/refresh", m.refreshToken, rt)
-	r.Post("/logout", m.logoutUser, rt)
+	to := governor.Timeout(authReqTimeout)
+	r.Post("/login", m.loginUser, rt, to)
+	r.Post("/exchange", m.exchangeToken, rt, to)
+	r.Post("/refresh", m.refreshToken, rt, to)
+	r.Post("/id/{id}/exchange", m.exchangeToken, rt, to)
+	r.Post("/id/{id}/refresh", m.refreshToken, rt, to)
+	r.Post("/logout", m.logoutUser, rt, to)
 }
@@ -0,0 +1,107 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/user/gate"
+	"xorkevin.dev/governor/service/user/token"
+)
+
+type (
+	refreshTokenizer struct {
+		token.Tokenizer
+	}
+
+	csrfGate struct {
+		gate.Gate
+	}
+)
+
+func (t refreshTokenizer) GetClaims(kind string, tokenString string) (bool, *token.Claims) {
+	if kind != token.KindRefresh || tokenString != "refresh-token" {
+		return false, nil
+	}
+	return true, &token.Claims{
+		Claims: jwt.Claims{
+			ID: "session",
+		},
+	}
+}
+
+func (g csrfGate) CheckCSRF(c governor.Context, sessionID string) error {
+	if c.Header("X-CSRF-Token") != "csrf-"+sessionID {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusForbidden,
+			Message: "Invalid CSRF token",
+		}))
+	}
+	return nil
+}
+
+func TestCheckRefreshCSRF(t *testing.T) {
+	t.Parallel()
+
+	m := &router{
+		s: service{
+			tokenizer: refreshTokenizer{},
+			gate:      csrfGate{},
+		},
+	}
+
+	for _, tc := range []struct {
+		Test   string
+		Token  string
+		Header string
+		Valid  bool
+	}{
+		{
+			Test:   "valid csrf token",
+			Token:  "refresh-token",
+			Header: "csrf-session",
+			Valid:  true,
+		},
+		{
+			Test:  "missing csrf token",
+			Token: "refresh-token",
+			Valid: false,
+		},
+		{
+			Test:   "invalid csrf token",
+			Token:  "refresh-token",
+			Header: "csrf-other",
+			Valid:  false,
+		},
+		{
+			Test:  "invalid refresh token",
+			Token: "bogus",
+			Valid: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.Test, func(t *testing.T) {
+			t.Parallel()
+
+			assert := require.New(t)
+
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/u/auth/refresh", nil)
+			if tc.Header != "" {
+				r.Header.Set("X-CSRF-Token", tc.Header)
+			}
+			c := governor.NewContext(httptest.NewRecorder(), r, nil)
+			err := m.checkRefreshCSRF(c, tc.Token)
+			if tc.Valid {
+				assert.NoError(err)
+				return
+			}
+			var gerr *governor.Error
+			assert.True(errors.As(err, &gerr))
+			assert.Equal(http.StatusForbidden, gerr.Status)
+		})
+	}
+}
@@ -31,7 +31,7 @@ func (m *router) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.CreateUser(req)
+	res, err := m.s.CreateUser(c.Ctx(), req)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -58,7 +58,7 @@ func (m *router) commitUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.CommitUser(req.Userid, req.Key)
+	res, err := m.s.CommitUser(c.Ctx(), req.Userid, req.Key)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -94,7 +94,7 @@ func (m *router) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.DeleteUser(req.Userid, req.Username, req.Password); err != nil {
+	if err := m.s.DeleteUser(c.Ctx(), req.Userid, req.Username, req.Password); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -119,7 +119,7 @@ func (m *router) getUserApprovals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetUserApprovals(req.Amount, req.Offset)
+	res, err := m.s.GetUserApprovals(c.Ctx(), req.Amount, req.Offset)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -138,7 +138,7 @@ func (m *router) approveUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.ApproveUser(req.Userid); err != nil {
+	if err := m.s.ApproveUser(c.Ctx(), req.Userid); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -156,7 +156,7 @@ func (m *router) deleteUserApproval(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.DeleteUserApproval(req.Userid); err != nil {
+	if err := m.s.DeleteUserApproval(c.Ctx(), req.Userid); err != nil {
 		c.WriteError(err)
 		return
 	}
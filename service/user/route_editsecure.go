@@ -30,7 +30,7 @@ func (m *router) putEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.UpdateEmail(req.Userid, req.Email, req.Password); err != nil {
+	if err := m.s.UpdateEmail(c.Ctx(), req.Userid, req.Email, req.Password); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -57,7 +57,7 @@ func (m *router) putEmailVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.CommitEmail(req.Userid, req.Key, req.Password); err != nil {
+	if err := m.s.CommitEmail(c.Ctx(), req.Userid, req.Key, req.Password); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -85,7 +85,7 @@ func (m *router) putPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.UpdatePassword(req.Userid, req.NewPassword, req.OldPassword); err != nil {
+	if err := m.s.UpdatePassword(c.Ctx(), req.Userid, req.NewPassword, req.OldPassword); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -110,7 +110,7 @@ func (m *router) forgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.ForgotPassword(req.Username); err != nil {
+	if err := m.s.ForgotPassword(c.Ctx(), req.Username); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -137,7 +137,7 @@ func (m *router) forgotPasswordReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.ResetPassword(req.Userid, req.Key, req.NewPassword); err != nil {
+	if err := m.s.ResetPassword(c.Ctx(), req.Userid, req.Key, req.NewPassword); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -165,7 +165,7 @@ func (m *router) addOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.AddOTP(req.Userid, req.Alg, req.Digits)
+	res, err := m.s.AddOTP(c.Ctx(), req.Userid, req.Alg, req.Digits)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -193,7 +193,7 @@ func (m *router) commitOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.CommitOTP(req.Userid, req.Code); err != nil {
+	if err := m.s.CommitOTP(c.Ctx(), req.Userid, req.Code); err != nil {
 		c.WriteError(err)
 		return
 	}
@@ -240,7 +240,7 @@ func (m *router) removeOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.RemoveOTP(req.Userid, req.Code, req.Backup); err != nil {
+	if err := m.s.RemoveOTP(c.Ctx(), req.Userid, req.Code, req.Backup); err != nil {
 		c.WriteError(err)
 		return
 	}
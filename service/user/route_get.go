@@ -27,7 +27,7 @@ func (m *router) getByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByIDPublic(req.Userid)
+	res, err := m.s.GetByIDPublic(c.Ctx(), req.Userid)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -46,7 +46,7 @@ func (m *router) getByIDPersonal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByID(req.Userid)
+	res, err := m.s.GetByID(c.Ctx(), req.Userid)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -65,7 +65,7 @@ func (m *router) getByIDPrivate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByID(req.Userid)
+	res, err := m.s.GetByID(c.Ctx(), req.Userid)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -90,7 +90,7 @@ func (m *router) getByUsername(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByUsernamePublic(req.Username)
+	res, err := m.s.GetByUsernamePublic(c.Ctx(), req.Username)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -109,7 +109,7 @@ func (m *router) getByUsernamePrivate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetByUsername(req.Username)
+	res, err := m.s.GetByUsername(c.Ctx(), req.Username)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -123,7 +123,8 @@ type (
 		Userid string `valid:"userid,has" json:"-"`
 		Prefix string `valid:"rolePrefix,has" json:"-"`
 		Amount int    `valid:"amount" json:"-"`
-		Offset int    `valid:"offset" json:"-"`
+		Offset int    `valid:"offset,opt" json:"-"`
+		Cursor string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -134,13 +135,14 @@ func (m *router) getUserRoles(w http.ResponseWriter, r *http.Request) {
 		Prefix: c.Query("prefix"),
 		Amount: c.QueryInt("amount", -1),
 		Offset: c.QueryInt("offset", -1),
+		Cursor: c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetUserRoles(req.Userid, req.Prefix, req.Amount, req.Offset)
+	res, err := m.s.GetUserRoles(c.Ctx(), req.Userid, req.Prefix, req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -156,13 +158,14 @@ func (m *router) getUserRolesPersonal(w http.ResponseWriter, r *http.Request) {
 		Prefix: c.Query("prefix"),
 		Amount: c.QueryInt("amount", -1),
 		Offset: c.QueryInt("offset", -1),
+		Cursor: c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetUserRoles(req.Userid, req.Prefix, req.Amount, req.Offset)
+	res, err := m.s.GetUserRoles(c.Ctx(), req.Userid, req.Prefix, req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -194,7 +197,7 @@ func (m *router) getUserRolesIntersect(w http.ResponseWriter, r *http.Request) {
 		c.WriteError(err)
 		return
 	}
-	res, err := m.s.GetUserRolesIntersect(req.Userid, roles)
+	res, err := m.s.GetUserRolesIntersect(c.Ctx(), req.Userid, roles)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -219,7 +222,7 @@ func (m *router) getUserRolesIntersectPersonal(w http.ResponseWriter, r *http.Re
 		c.WriteError(err)
 		return
 	}
-	res, err := m.s.GetUserRolesIntersect(req.Userid, roles)
+	res, err := m.s.GetUserRolesIntersect(c.Ctx(), req.Userid, roles)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -248,7 +251,7 @@ func (m *router) getUsersByRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetIDsByRole(req.Role, req.Amount, req.Offset)
+	res, err := m.s.GetIDsByRole(c.Ctx(), req.Role, req.Amount, req.Offset)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -258,8 +261,9 @@ func (m *router) getUsersByRole(w http.ResponseWriter, r *http.Request) {
 
 type (
 	reqGetUserBulk struct {
-		Amount int `valid:"amount" json:"-"`
-		Offset int `valid:"offset" json:"-"`
+		Amount int    `valid:"amount" json:"-"`
+		Offset int    `valid:"offset,opt" json:"-"`
+		Cursor string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -268,13 +272,14 @@ func (m *router) getAllUserInfo(w http.ResponseWriter, r *http.Request) {
 	req := reqGetUserBulk{
 		Amount: c.QueryInt("amount", -1),
 		Offset: c.QueryInt("offset", -1),
+		Cursor: c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
 
-	res, err := m.s.GetInfoAll(req.Amount, req.Offset)
+	res, err := m.s.GetInfoAll(c.Ctx(), req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -298,7 +303,7 @@ func (m *router) getUserInfoBulkPublic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := m.s.GetInfoBulkPublic(strings.Split(req.Userids, ","))
+	res, err := m.s.GetInfoBulkPublic(c.Ctx(), strings.Split(req.Userids, ","))
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -14,7 +14,8 @@ type (
 	reqGetUserSessions struct {
 		Userid string `valid:"userid,has" json:"-"`
 		Amount int    `valid:"amount" json:"-"`
-		Offset int    `valid:"offset" json:"-"`
+		Offset int    `valid:"offset,opt" json:"-"`
+		Cursor string `valid:"cursor,opt" json:"-"`
 	}
 )
 
@@ -24,12 +25,13 @@ func (m *router) getSessions(w http.ResponseWriter, r *http.Request) {
 		Userid: gate.GetCtxUserid(c),
 		Amount: c.QueryInt("amount", -1),
 		Offset: c.QueryInt("offset", -1),
+		Cursor: c.Query("cursor"),
 	}
 	if err := req.valid(); err != nil {
 		c.WriteError(err)
 		return
 	}
-	res, err := m.s.GetUserSessions(req.Userid, req.Amount, req.Offset)
+	res, err := m.s.GetUserSessions(c.Ctx(), req.Userid, req.Amount, req.Offset, req.Cursor)
 	if err != nil {
 		c.WriteError(err)
 		return
@@ -74,7 +76,7 @@ func (m *router) killSessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.s.KillSessions(req.SessionIDs); err != nil {
+	if err := m.s.KillSessions(c.Ctx(), req.SessionIDs); err != nil {
 		c.WriteError(err)
 		return
 	}
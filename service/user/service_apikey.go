@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -22,8 +23,8 @@ type (
 	}
 )
 
-func (s *service) GetUserApikeys(userid string, limit, offset int) (*resApikeys, error) {
-	m, err := s.apikeys.GetUserKeys(userid, limit, offset)
+func (s *service) GetUserApikeys(ctx context.Context, userid string, limit, offset int) (*resApikeys, error) {
+	m, err := s.apikeys.GetUserKeys(ctx, userid, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get apikeys")
 	}
@@ -49,8 +50,8 @@ type (
 	}
 )
 
-func (s *service) CreateApikey(userid string, scope string, name, desc string) (*resApikeyModel, error) {
-	m, err := s.apikeys.Insert(userid, scope, name, desc)
+func (s *service) CreateApikey(ctx context.Context, userid string, scope string, name, desc string) (*resApikeyModel, error) {
+	m, err := s.apikeys.Insert(ctx, userid, scope, name, desc)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to create apikey")
 	}
@@ -60,8 +61,8 @@ func (s *service) CreateApikey(userid string, scope string, name, desc string) (
 	}, nil
 }
 
-func (s *service) RotateApikey(keyid string) (*resApikeyModel, error) {
-	m, err := s.apikeys.RotateKey(keyid)
+func (s *service) RotateApikey(ctx context.Context, keyid string) (*resApikeyModel, error) {
+	m, err := s.apikeys.RotateKey(ctx, keyid)
 	if err != nil {
 		if errors.Is(err, apikey.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -77,8 +78,8 @@ func (s *service) RotateApikey(keyid string) (*resApikeyModel, error) {
 	}, nil
 }
 
-func (s *service) UpdateApikey(keyid string, scope string, name, desc string) error {
-	if err := s.apikeys.UpdateKey(keyid, scope, name, desc); err != nil {
+func (s *service) UpdateApikey(ctx context.Context, keyid string, scope string, name, desc string) error {
+	if err := s.apikeys.UpdateKey(ctx, keyid, scope, name, desc); err != nil {
 		if errors.Is(err, apikey.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
@@ -90,8 +91,8 @@ func (s *service) UpdateApikey(keyid string, scope string, name, desc string) er
 	return nil
 }
 
-func (s *service) DeleteApikey(keyid string) error {
-	if err := s.apikeys.DeleteKey(keyid); err != nil {
+func (s *service) DeleteApikey(ctx context.Context, keyid string) error {
+	if err := s.apikeys.DeleteKey(ctx, keyid); err != nil {
 		if errors.Is(err, apikey.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusNotFound,
@@ -103,8 +104,8 @@ func (s *service) DeleteApikey(keyid string) error {
 	return nil
 }
 
-func (s *service) DeleteUserApikeys(userid string) error {
-	if err := s.apikeys.DeleteUserKeys(userid); err != nil {
+func (s *service) DeleteUserApikeys(ctx context.Context, userid string) error {
+	if err := s.apikeys.DeleteUserKeys(ctx, userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user apikeys")
 	}
 	return nil
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -34,13 +35,14 @@ type (
 		AccessToken  string        `json:"access_token,omitempty"`
 		RefreshToken string        `json:"refresh_token,omitempty"`
 		SessionToken string        `json:"session_token,omitempty"`
+		CSRFToken    string        `json:"csrf_token,omitempty"`
 		Claims       *token.Claims `json:"claims,omitempty"`
 	}
 )
 
 // Login authenticates a user and returns auth tokens
-func (s *service) Login(userid, password, sessionID, ipaddr, useragent string) (*resUserAuth, error) {
-	m, err := s.users.GetByID(userid)
+func (s *service) Login(ctx context.Context, userid, password, sessionID, ipaddr, useragent string) (*resUserAuth, error) {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -62,7 +64,7 @@ func (s *service) Login(userid, password, sessionID, ipaddr, useragent string) (
 	sessionExists := false
 	var sm *sessionmodel.Model
 	if len(sessionID) > 0 {
-		if m, err := s.sessions.GetByID(sessionID); err != nil {
+		if m, err := s.sessions.GetByID(ctx, sessionID); err != nil {
 			if !errors.Is(err, db.ErrNotFound{}) {
 				return nil, governor.ErrWithMsg(err, "Failed to get user session")
 			}
@@ -110,7 +112,7 @@ func (s *service) Login(userid, password, sessionID, ipaddr, useragent string) (
 			Time:      time.Unix(sm.Time, 0).Format(time.RFC3339),
 			UserAgent: sm.UserAgent,
 		}
-		if err := s.mailer.Send("", "", []string{m.Email}, newLoginTemplate, emdata); err != nil {
+		if err := s.mailer.Send(ctx, "", "", []string{m.Email}, newLoginTemplate, emdata); err != nil {
 			s.logger.Error("fail send new login email", map[string]string{
 				"error":      err.Error(),
 				"actiontype": "newloginemail",
@@ -119,16 +121,16 @@ func (s *service) Login(userid, password, sessionID, ipaddr, useragent string) (
 	}
 
 	if !sessionExists {
-		if err := s.sessions.Insert(sm); err != nil {
+		if err := s.sessions.Insert(ctx, sm); err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to save user session")
 		}
 	} else {
-		if err := s.sessions.Update(sm); err != nil {
+		if err := s.sessions.Update(ctx, sm); err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to save user session")
 		}
 	}
 
-	if err := s.kvsessions.Set(sm.SessionID, sm.KeyHash, s.refreshCacheTime); err != nil {
+	if err := s.kvsessions.Set(ctx, sm.SessionID, sm.KeyHash, s.refreshCacheTime); err != nil {
 		s.logger.Error("Failed to cache user session", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "setcachesession",
@@ -141,12 +143,13 @@ func (s *service) Login(userid, password, sessionID, ipaddr, useragent string) (
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		SessionToken: sm.SessionID,
+		CSRFToken:    s.tokenizer.GenerateCSRF(sm.SessionID),
 		Claims:       accessClaims,
 	}, nil
 }
 
 // ExchangeToken validates a refresh token and returns an auth token
-func (s *service) ExchangeToken(refreshToken, ipaddr, useragent string) (*resUserAuth, error) {
+func (s *service) ExchangeToken(ctx context.Context, refreshToken, ipaddr, useragent string) (*resUserAuth, error) {
 	validToken, claims := s.tokenizer.Validate(token.KindRefresh, refreshToken)
 	if !validToken {
 		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -155,7 +158,7 @@ func (s *service) ExchangeToken(refreshToken, ipaddr, useragent string) (*resUse
 		}))
 	}
 
-	if ok, err := s.CheckUserExists(claims.Subject); err != nil {
+	if ok, err := s.CheckUserExists(ctx, claims.Subject); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user")
 	} else if !ok {
 		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -164,7 +167,7 @@ func (s *service) ExchangeToken(refreshToken, ipaddr, useragent string) (*resUse
 		}))
 	}
 
-	keyhash, err := s.kvsessions.Get(claims.ID)
+	keyhash, err := s.kvsessions.Get(ctx, claims.ID)
 	if err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			s.logger.Error("Failed to get cached session", map[string]string{
@@ -172,7 +175,7 @@ func (s *service) ExchangeToken(refreshToken, ipaddr, useragent string) (*resUse
 				"actiontype": "getcachesession",
 			})
 		}
-		return s.RefreshToken(refreshToken, ipaddr, useragent)
+		return s.RefreshToken(ctx, refreshToken, ipaddr, useragent)
 	}
 
 	if ok, err := s.sessions.ValidateKey(claims.Key, &sessionmodel.Model{
@@ -197,12 +200,13 @@ func (s *service) ExchangeToken(refreshToken, ipaddr, useragent string) (*resUse
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		SessionToken: claims.ID,
+		CSRFToken:    s.tokenizer.GenerateCSRF(claims.ID),
 		Claims:       accessClaims,
 	}, nil
 }
 
 // RefreshToken invalidates the previous refresh token and creates a new one
-func (s *service) RefreshToken(refreshToken, ipaddr, useragent string) (*resUserAuth, error) {
+func (s *service) RefreshToken(ctx context.Context, refreshToken, ipaddr, useragent string) (*resUserAuth, error) {
 	validToken, claims := s.tokenizer.Validate(token.KindRefresh, refreshToken)
 	if !validToken {
 		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -211,7 +215,7 @@ func (s *service) RefreshToken(refreshToken, ipaddr, useragent string) (*resUser
 		}))
 	}
 
-	sm, err := s.sessions.GetByID(claims.ID)
+	sm, err := s.sessions.GetByID(ctx, claims.ID)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -244,11 +248,11 @@ func (s *service) RefreshToken(refreshToken, ipaddr, useragent string) (*resUser
 		return nil, governor.ErrWithMsg(err, "Failed to generate refresh token")
 	}
 
-	if err := s.sessions.Update(sm); err != nil {
+	if err := s.sessions.Update(ctx, sm); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to save user session")
 	}
 
-	if err := s.kvsessions.Set(sm.SessionID, sm.KeyHash, s.refreshCacheTime); err != nil {
+	if err := s.kvsessions.Set(ctx, sm.SessionID, sm.KeyHash, s.refreshCacheTime); err != nil {
 		s.logger.Error("Failed to cache user session", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "setcachesession",
@@ -261,12 +265,13 @@ func (s *service) RefreshToken(refreshToken, ipaddr, useragent string) (*resUser
 		AccessToken:  accessToken,
 		RefreshToken: newRefreshToken,
 		SessionToken: sm.SessionID,
+		CSRFToken:    s.tokenizer.GenerateCSRF(sm.SessionID),
 		Claims:       accessClaims,
 	}, nil
 }
 
 // Logout removes the user session in cache
-func (s *service) Logout(refreshToken string) (string, error) {
+func (s *service) Logout(ctx context.Context, refreshToken string) (string, error) {
 	// if session_id is provided, is in cache, and is valid, set it as the sessionID
 	// the session can be expired by time
 	ok, claims := s.tokenizer.GetClaims(token.KindRefresh, refreshToken)
@@ -277,7 +282,7 @@ func (s *service) Logout(refreshToken string) (string, error) {
 		}))
 	}
 
-	sm, err := s.sessions.GetByID(claims.ID)
+	sm, err := s.sessions.GetByID(ctx, claims.ID)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -296,10 +301,10 @@ func (s *service) Logout(refreshToken string) (string, error) {
 		}))
 	}
 
-	if err := s.sessions.Delete(sm); err != nil {
+	if err := s.sessions.Delete(ctx, sm); err != nil {
 		return "", governor.ErrWithMsg(err, "Failed to delete session")
 	}
-	s.killCacheSessions([]string{claims.ID})
+	s.killCacheSessions(ctx, []string{claims.ID})
 
 	return claims.Subject, nil
 }
@@ -2,7 +2,8 @@ package user
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"database/sql"
 	"errors"
 	htmlTemplate "html/template"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/events"
+	"xorkevin.dev/governor/service/outbox"
 	approvalmodel "xorkevin.dev/governor/service/user/approval/model"
 	"xorkevin.dev/governor/util/rank"
 )
@@ -65,8 +68,8 @@ type (
 )
 
 // CreateUser creates a new user and places it into approvals
-func (s *service) CreateUser(ruser reqUserPost) (*resUserUpdate, error) {
-	if _, err := s.users.GetByUsername(ruser.Username); err != nil {
+func (s *service) CreateUser(ctx context.Context, ruser reqUserPost) (*resUserUpdate, error) {
+	if _, err := s.users.GetByUsername(ctx, ruser.Username); err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.ErrWithMsg(err, "Failed to get user")
 		}
@@ -77,7 +80,7 @@ func (s *service) CreateUser(ruser reqUserPost) (*resUserUpdate, error) {
 		}))
 	}
 
-	if _, err := s.users.GetByEmail(ruser.Email); err != nil {
+	if _, err := s.users.GetByEmail(ctx, ruser.Email); err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.ErrWithMsg(err, "Failed to get user")
 		}
@@ -95,7 +98,7 @@ func (s *service) CreateUser(ruser reqUserPost) (*resUserUpdate, error) {
 
 	am := s.approvals.New(m)
 	if s.userApproval {
-		if err := s.approvals.Insert(am); err != nil {
+		if err := s.approvals.Insert(ctx, am); err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to create new user request")
 		}
 	} else {
@@ -103,10 +106,10 @@ func (s *service) CreateUser(ruser reqUserPost) (*resUserUpdate, error) {
 		if err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to generate email verification code")
 		}
-		if err := s.approvals.Insert(am); err != nil {
+		if err := s.approvals.Insert(ctx, am); err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to create new user request")
 		}
-		if err := s.sendNewUserEmail(code, am); err != nil {
+		if err := s.sendNewUserEmail(ctx, code, am); err != nil {
 			return nil, governor.ErrWithMsg(err, "Failed to send new user email")
 		}
 	}
@@ -134,8 +137,8 @@ type (
 	}
 )
 
-func (s *service) GetUserApprovals(limit, offset int) (*resApprovals, error) {
-	m, err := s.approvals.GetGroup(limit, offset)
+func (s *service) GetUserApprovals(ctx context.Context, limit, offset int) (*resApprovals, error) {
+	m, err := s.approvals.GetGroup(ctx, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user requests")
 	}
@@ -157,8 +160,8 @@ func (s *service) GetUserApprovals(limit, offset int) (*resApprovals, error) {
 	}, nil
 }
 
-func (s *service) ApproveUser(userid string) error {
-	m, err := s.approvals.GetByID(userid)
+func (s *service) ApproveUser(ctx context.Context, userid string) error {
+	m, err := s.approvals.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -172,17 +175,17 @@ func (s *service) ApproveUser(userid string) error {
 	if err != nil {
 		return governor.ErrWithMsg(err, "Failed to generate new email verification code")
 	}
-	if err := s.approvals.Update(m); err != nil {
+	if err := s.approvals.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to approve user")
 	}
-	if err := s.sendNewUserEmail(code, m); err != nil {
+	if err := s.sendNewUserEmail(ctx, code, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to send account verification email")
 	}
 	return nil
 }
 
-func (s *service) DeleteUserApproval(userid string) error {
-	m, err := s.approvals.GetByID(userid)
+func (s *service) DeleteUserApproval(ctx context.Context, userid string) error {
+	m, err := s.approvals.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -192,13 +195,13 @@ func (s *service) DeleteUserApproval(userid string) error {
 		}
 		return governor.ErrWithMsg(err, "Failed to get user request")
 	}
-	if err := s.approvals.Delete(m); err != nil {
+	if err := s.approvals.Delete(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user request")
 	}
 	return nil
 }
 
-func (s *service) sendNewUserEmail(code string, m *approvalmodel.Model) error {
+func (s *service) sendNewUserEmail(ctx context.Context, code string, m *approvalmodel.Model) error {
 	emdata := emailNewUser{
 		Userid:    m.Userid,
 		Key:       code,
@@ -209,15 +212,15 @@ func (s *service) sendNewUserEmail(code string, m *approvalmodel.Model) error {
 	if err := emdata.computeURL(s.emailurlbase, s.tplnewuser); err != nil {
 		return governor.ErrWithMsg(err, "Failed to generate account verification email")
 	}
-	if err := s.mailer.Send("", "", []string{m.Email}, newUserTemplate, emdata); err != nil {
+	if err := s.mailer.Send(ctx, "", "", []string{m.Email}, newUserTemplate, emdata); err != nil {
 		return governor.ErrWithMsg(err, "Failed to send account verification email")
 	}
 	return nil
 }
 
 // CommitUser takes a user from approvals and places it into the user db
-func (s *service) CommitUser(userid string, key string) (*resUserUpdate, error) {
-	am, err := s.approvals.GetByID(userid)
+func (s *service) CommitUser(ctx context.Context, userid string, key string) (*resUserUpdate, error) {
+	am, err := s.approvals.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -249,21 +252,34 @@ func (s *service) CommitUser(userid string, key string) (*resUserUpdate, error)
 	}
 	m := s.approvals.ToUserModel(am)
 
-	b, err := json.Marshal(NewUserProps{
-		Userid:       m.Userid,
-		Username:     m.Username,
-		Email:        m.Email,
-		FirstName:    m.FirstName,
-		LastName:     m.LastName,
-		CreationTime: m.CreationTime,
-	})
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to encode user props to json")
-	}
-
-	if err := s.users.Insert(m); err != nil {
+	ev := events.Event{
+		Type:    newUserSchema.Type,
+		Version: newUserSchema.Version,
+		Source:  eventSource,
+		Data: NewUserProps{
+			Userid:       m.Userid,
+			Username:     m.Username,
+			Email:        m.Email,
+			FirstName:    m.FirstName,
+			LastName:     m.LastName,
+			CreationTime: m.CreationTime,
+		},
+	}
+
+	if err := s.database.WithTx(ctx, nil, func(tx db.SQLExecutor) error {
+		if err := s.users.InsertTx(ctx, tx, m); err != nil {
+			return err
+		}
+		if err := s.roles.InsertRolesTx(ctx, tx, m.Userid, rank.BaseUser()); err != nil {
+			return governor.ErrWithMsg(err, "Failed to create user roles")
+		}
+		if err := outbox.InsertEvent(ctx, s.outbox, tx, CreateChannel, events.JSONCodec, ev); err != nil {
+			return governor.ErrWithMsg(err, "Failed to publish new user")
+		}
+		return nil
+	}); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
-			if err := s.approvals.Delete(am); err != nil {
+			if err := s.approvals.Delete(ctx, am); err != nil {
 				s.logger.Error("Failed to clean up user approval", map[string]string{
 					"error":      err.Error(),
 					"actiontype": "commitusercleanup",
@@ -276,18 +292,9 @@ func (s *service) CommitUser(userid string, key string) (*resUserUpdate, error)
 		}
 		return nil, governor.ErrWithMsg(err, "Failed to create user")
 	}
-	if err := s.roles.InsertRoles(m.Userid, rank.BaseUser()); err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to create user roles")
-	}
-
-	if err := s.events.StreamPublish(CreateChannel, b); err != nil {
-		s.logger.Error("Failed to publish new user", map[string]string{
-			"error":      err.Error(),
-			"actiontype": "publishnewuser",
-		})
-	}
+	s.roles.ClearUserCache(ctx, m.Userid, rank.BaseUser())
 
-	if err := s.approvals.Delete(am); err != nil {
+	if err := s.approvals.Delete(ctx, am); err != nil {
 		s.logger.Error("Failed to clean up user approval", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "commitusercleanup",
@@ -300,7 +307,7 @@ func (s *service) CommitUser(userid string, key string) (*resUserUpdate, error)
 		"actiontype": "commituser",
 	})
 
-	s.clearUserExists(userid)
+	s.clearUserExists(ctx, userid)
 
 	return &resUserUpdate{
 		Userid:   m.Userid,
@@ -308,8 +315,8 @@ func (s *service) CommitUser(userid string, key string) (*resUserUpdate, error)
 	}, nil
 }
 
-func (s *service) DeleteUser(userid string, username string, password string) error {
-	m, err := s.users.GetByID(userid)
+func (s *service) DeleteUser(ctx context.Context, userid string, username string, password string) error {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -326,7 +333,7 @@ func (s *service) DeleteUser(userid string, username string, password string) er
 			Message: "Username does not match",
 		}))
 	}
-	if roles, err := s.roles.IntersectRoles(userid, rank.Rank{"admin": struct{}{}}); err != nil {
+	if roles, err := s.roles.IntersectRoles(ctx, userid, rank.Rank{"admin": struct{}{}}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to get user roles")
 	} else if roles.Has("admin") {
 		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -343,45 +350,57 @@ func (s *service) DeleteUser(userid string, username string, password string) er
 		}))
 	}
 
-	b, err := json.Marshal(DeleteUserProps{
-		Userid: m.Userid,
-	})
-	if err != nil {
-		return governor.ErrWithMsg(err, "Failed to encode user props to json")
-	}
-	if err := s.events.StreamPublish(DeleteChannel, b); err != nil {
-		s.logger.Error("Failed to publish delete user", map[string]string{
-			"error":      err.Error(),
-			"actiontype": "publishdeleteuser",
-		})
-	}
-
-	if err := s.resets.DeleteByUserid(userid); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user resets")
-	}
-
-	if err := s.DeleteUserApikeys(userid); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user apikeys")
+	ev := events.Event{
+		Type:    deleteUserSchema.Type,
+		Version: deleteUserSchema.Version,
+		Source:  eventSource,
+		Data: DeleteUserProps{
+			Userid: m.Userid,
+		},
 	}
 
-	if err := s.KillAllSessions(userid); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user sessions")
-	}
-
-	if err := s.roles.DeleteAllRoles(userid); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user roles")
+	var keyids []string
+	var roles rank.Rank
+	// the apikeys and roles of the user are read and then deleted, and both must
+	// see the same rows so that all of the deleted keys are cleared from the
+	// cache
+	if err := s.database.WithTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx db.SQLExecutor) error {
+		if err := s.resets.DeleteByUseridTx(ctx, tx, userid); err != nil {
+			return governor.ErrWithMsg(err, "Failed to delete user resets")
+		}
+		var err error
+		keyids, err = s.apikeys.DeleteUserKeysTx(ctx, tx, userid)
+		if err != nil {
+			return governor.ErrWithMsg(err, "Failed to delete user apikeys")
+		}
+		if err := s.sessions.DeleteUserSessionsTx(ctx, tx, userid); err != nil {
+			return governor.ErrWithMsg(err, "Failed to delete user sessions")
+		}
+		roles, err = s.roles.DeleteAllRolesTx(ctx, tx, userid)
+		if err != nil {
+			return governor.ErrWithMsg(err, "Failed to delete user roles")
+		}
+		if err := s.users.DeleteTx(ctx, tx, m); err != nil {
+			return err
+		}
+		if err := outbox.InsertEvent(ctx, s.outbox, tx, DeleteChannel, events.JSONCodec, ev); err != nil {
+			return governor.ErrWithMsg(err, "Failed to publish delete user")
+		}
+		return nil
+	}); err != nil {
+		return governor.ErrWithMsg(err, "Failed to delete user")
 	}
 
-	if err := s.users.Delete(m); err != nil {
-		return governor.ErrWithMsg(err, "Failed to delete user roles")
+	if len(keyids) > 0 {
+		s.apikeys.ClearCache(ctx, keyids...)
 	}
-
-	s.clearUserExists(userid)
+	s.roles.ClearUserCache(ctx, userid, roles)
+	s.clearUserExists(ctx, userid)
 	return nil
 }
 
-func (s *service) clearUserExists(userid string) {
-	if err := s.kvusers.Del(userid); err != nil {
+func (s *service) clearUserExists(ctx context.Context, userid string) {
+	if err := s.kvusers.Del(ctx, userid); err != nil {
 		s.logger.Error("Failed to delete user exists in cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "deluserexists",
@@ -389,19 +408,35 @@ func (s *service) clearUserExists(userid string) {
 	}
 }
 
-// DecodeNewUserProps marshals json encoded new user props into a struct
-func DecodeNewUserProps(msgdata []byte) (*NewUserProps, error) {
+// upcastNewUserPropsV1 converts version 1 new user props to version 2
+func upcastNewUserPropsV1(codec events.Codec, data []byte) ([]byte, error) {
+	m := newUserPropsV1{}
+	if err := codec.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return codec.Marshal(NewUserProps{
+		Userid:       m.Userid,
+		Username:     m.Username,
+		Email:        m.Email,
+		FirstName:    m.FirstName,
+		LastName:     m.LastName,
+		CreationTime: m.CreationTime,
+	})
+}
+
+// DecodeNewUserProps decodes new user props from an event envelope
+func DecodeNewUserProps(env *events.Envelope) (*NewUserProps, error) {
 	m := &NewUserProps{}
-	if err := json.Unmarshal(msgdata, m); err != nil {
+	if err := newUserSchema.Decode(env, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to decode new user props")
 	}
 	return m, nil
 }
 
-// DecodeDeleteUserProps marshals json encoded delete user props into a struct
-func DecodeDeleteUserProps(msgdata []byte) (*DeleteUserProps, error) {
+// DecodeDeleteUserProps decodes delete user props from an event envelope
+func DecodeDeleteUserProps(env *events.Envelope) (*DeleteUserProps, error) {
 	m := &DeleteUserProps{}
-	if err := json.Unmarshal(msgdata, m); err != nil {
+	if err := deleteUserSchema.Decode(env, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to decode delete user props")
 	}
 	return m, nil
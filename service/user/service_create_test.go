@@ -0,0 +1,75 @@
+package user
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"xorkevin.dev/governor/service/events"
+)
+
+func TestDecodeNewUserProps(t *testing.T) {
+	t.Parallel()
+
+	assert := require.New(t)
+
+	props := NewUserProps{
+		Userid:       "userid",
+		Username:     "username",
+		Email:        "user@example.com",
+		FirstName:    "First",
+		LastName:     "Last",
+		CreationTime: 1,
+	}
+
+	{
+		b, err := events.EncodeEvent(events.JSONCodec, events.Event{
+			Type:    newUserSchema.Type,
+			Version: 1,
+			Source:  eventSource,
+			Data: newUserPropsV1{
+				Userid:       props.Userid,
+				Username:     props.Username,
+				Email:        props.Email,
+				FirstName:    props.FirstName,
+				LastName:     props.LastName,
+				CreationTime: props.CreationTime,
+			},
+		})
+		assert.NoError(err)
+		env, err := events.DecodeEnvelope(b)
+		assert.NoError(err)
+		m, err := DecodeNewUserProps(env)
+		assert.NoError(err)
+		assert.Equal(props, *m, "Should upcast version 1 props")
+	}
+	{
+		b, err := json.Marshal(newUserPropsV1{
+			Userid:   props.Userid,
+			Username: props.Username,
+		})
+		assert.NoError(err)
+		env, err := events.DecodeEnvelope(b)
+		assert.NoError(err)
+		m, err := DecodeNewUserProps(env)
+		assert.NoError(err)
+		assert.Equal(props.Userid, m.Userid, "Should decode props published without an envelope")
+		assert.False(m.Admin)
+	}
+	{
+		admin := props
+		admin.Admin = true
+		b, err := events.EncodeEvent(events.JSONCodec, events.Event{
+			Type:    newUserSchema.Type,
+			Version: newUserSchema.Version,
+			Source:  eventSource,
+			Data:    admin,
+		})
+		assert.NoError(err)
+		env, err := events.DecodeEnvelope(b)
+		assert.NoError(err)
+		m, err := DecodeNewUserProps(env)
+		assert.NoError(err)
+		assert.Equal(admin, *m)
+	}
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -10,8 +11,8 @@ import (
 	"xorkevin.dev/governor/util/rank"
 )
 
-func (s *service) UpdateUser(userid string, ruser reqUserPut) error {
-	m, err := s.users.GetByID(userid)
+func (s *service) UpdateUser(ctx context.Context, userid string, ruser reqUserPut) error {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -24,7 +25,7 @@ func (s *service) UpdateUser(userid string, ruser reqUserPut) error {
 	m.Username = ruser.Username
 	m.FirstName = ruser.FirstName
 	m.LastName = ruser.LastName
-	if err = s.users.Update(m); err != nil {
+	if err = s.users.Update(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusBadRequest,
@@ -36,8 +37,8 @@ func (s *service) UpdateUser(userid string, ruser reqUserPut) error {
 	return nil
 }
 
-func (s *service) UpdateRank(userid string, updaterid string, editAddRank rank.Rank, editRemoveRank rank.Rank) error {
-	updaterRank, err := s.roles.IntersectRoles(updaterid, combineModRoles(editAddRank, editRemoveRank))
+func (s *service) UpdateRank(ctx context.Context, userid string, updaterid string, editAddRank rank.Rank, editRemoveRank rank.Rank) error {
+	updaterRank, err := s.roles.IntersectRoles(ctx, updaterid, combineModRoles(editAddRank, editRemoveRank))
 	if err != nil {
 		return governor.ErrWithMsg(err, "Failed to get updater roles")
 	}
@@ -49,7 +50,7 @@ func (s *service) UpdateRank(userid string, updaterid string, editAddRank rank.R
 		return err
 	}
 
-	m, err := s.users.GetByID(userid)
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -62,7 +63,7 @@ func (s *service) UpdateRank(userid string, updaterid string, editAddRank rank.R
 
 	editAddRank.Remove(editRemoveRank)
 
-	currentRoles, err := s.roles.IntersectRoles(userid, editAddRank)
+	currentRoles, err := s.roles.IntersectRoles(ctx, userid, editAddRank)
 	if err != nil {
 		return governor.ErrWithMsg(err, "Failed to get user roles")
 	}
@@ -87,18 +88,18 @@ func (s *service) UpdateRank(userid string, updaterid string, editAddRank rank.R
 	if editAddRank.Has(rank.TagUser) {
 		userRole := rank.Rank{}.AddOne(rank.TagUser)
 		editAddRank.Remove(userRole)
-		if err := s.roles.InsertRoles(m.Userid, userRole); err != nil {
+		if err := s.roles.InsertRoles(ctx, m.Userid, userRole); err != nil {
 			return governor.ErrWithMsg(err, "Failed to update user roles")
 		}
 	}
 
-	if err := s.invitations.DeleteByRoles(m.Userid, editAddRank.Union(editRemoveRank)); err != nil {
+	if err := s.invitations.DeleteByRoles(ctx, m.Userid, editAddRank.Union(editRemoveRank)); err != nil {
 		return governor.ErrWithMsg(err, "Failed to remove role invitations")
 	}
-	if err := s.invitations.Insert(m.Userid, editAddRank, updaterid, now); err != nil {
+	if err := s.invitations.Insert(ctx, m.Userid, editAddRank, updaterid, now); err != nil {
 		return governor.ErrWithMsg(err, "Failed to add role invitations")
 	}
-	if err := s.roles.DeleteRoles(m.Userid, editRemoveRank); err != nil {
+	if err := s.roles.DeleteRoles(ctx, m.Userid, editRemoveRank); err != nil {
 		return governor.ErrWithMsg(err, "Failed to remove user roles")
 	}
 
@@ -223,8 +224,8 @@ func canUpdateRank(edit, updater rank.Rank, editid, updaterid string, add bool)
 	return nil
 }
 
-func (s *service) AcceptRoleInvitation(userid, role string) error {
-	m, err := s.users.GetByID(userid)
+func (s *service) AcceptRoleInvitation(ctx context.Context, userid, role string) error {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -238,7 +239,7 @@ func (s *service) AcceptRoleInvitation(userid, role string) error {
 	now := time.Now().Round(0).Unix()
 	after := now - s.invitationTime
 
-	inv, err := s.invitations.GetByID(userid, role, after)
+	inv, err := s.invitations.GetByID(ctx, userid, role, after)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -254,10 +255,10 @@ func (s *service) AcceptRoleInvitation(userid, role string) error {
 			"username": m.Username,
 		})
 	}
-	if err := s.invitations.DeleteByID(userid, role); err != nil {
+	if err := s.invitations.DeleteByID(ctx, userid, role); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete role invitation")
 	}
-	if err := s.roles.InsertRoles(m.Userid, rank.Rank{}.AddOne(inv.Role)); err != nil {
+	if err := s.roles.InsertRoles(ctx, m.Userid, rank.Rank{}.AddOne(inv.Role)); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update roles")
 	}
 	return nil
@@ -276,11 +277,11 @@ type (
 	}
 )
 
-func (s *service) GetUserRoleInvitations(userid string, amount, offset int) (*resUserRoleInvitations, error) {
+func (s *service) GetUserRoleInvitations(ctx context.Context, userid string, amount, offset int) (*resUserRoleInvitations, error) {
 	now := time.Now().Round(0).Unix()
 	after := now - s.invitationTime
 
-	m, err := s.invitations.GetByUser(userid, after, amount, offset)
+	m, err := s.invitations.GetByUser(ctx, userid, after, amount, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get role invitations")
 	}
@@ -298,11 +299,11 @@ func (s *service) GetUserRoleInvitations(userid string, amount, offset int) (*re
 	}, nil
 }
 
-func (s *service) GetRoleInvitations(role string, amount, offset int) (*resUserRoleInvitations, error) {
+func (s *service) GetRoleInvitations(ctx context.Context, role string, amount, offset int) (*resUserRoleInvitations, error) {
 	now := time.Now().Round(0).Unix()
 	after := now - s.invitationTime
 
-	m, err := s.invitations.GetByRole(role, after, amount, offset)
+	m, err := s.invitations.GetByRole(ctx, role, after, amount, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get role invitations")
 	}
@@ -320,8 +321,8 @@ func (s *service) GetRoleInvitations(role string, amount, offset int) (*resUserR
 	}, nil
 }
 
-func (s *service) DeleteRoleInvitation(userid, role string) error {
-	if err := s.invitations.DeleteByID(userid, role); err != nil {
+func (s *service) DeleteRoleInvitation(ctx context.Context, userid, role string) error {
+	if err := s.invitations.DeleteByID(ctx, userid, role); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete role invitation")
 	}
 	return nil
@@ -2,6 +2,7 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	htmlTemplate "html/template"
 	"net/http"
@@ -10,7 +11,9 @@ import (
 
 	"xorkevin.dev/governor"
 	"xorkevin.dev/governor/service/db"
+	"xorkevin.dev/governor/service/kvstore"
 	"xorkevin.dev/governor/service/user/model"
+	"xorkevin.dev/governor/util/uid"
 )
 
 const (
@@ -18,6 +21,14 @@ const (
 	kindResetPass  = "pass"
 )
 
+const (
+	otpLockSize = 16
+	// otpLockTime is the max time in seconds an otp check may hold its lock
+	otpLockTime = 15
+	// otpUnlockTimeout is the max time an otp lock release may take
+	otpUnlockTimeout = 5 * time.Second
+)
+
 type (
 	emailEmailChange struct {
 		Userid    string
@@ -68,8 +79,8 @@ func (e *emailEmailChange) computeURL(base string, tpl *htmlTemplate.Template) e
 }
 
 // UpdateEmail creates a pending user email update
-func (s *service) UpdateEmail(userid string, newEmail string, password string) error {
-	if _, err := s.users.GetByEmail(newEmail); err != nil {
+func (s *service) UpdateEmail(ctx context.Context, userid string, newEmail string, password string) error {
+	if _, err := s.users.GetByEmail(ctx, newEmail); err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return governor.ErrWithMsg(err, "Failed to get user")
 		}
@@ -79,7 +90,7 @@ func (s *service) UpdateEmail(userid string, newEmail string, password string) e
 			Message: "Email is already in use",
 		}))
 	}
-	m, err := s.users.GetByID(userid)
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -105,7 +116,7 @@ func (s *service) UpdateEmail(userid string, newEmail string, password string) e
 	}
 
 	needInsert := false
-	mr, err := s.resets.GetByID(m.Userid, kindResetEmail)
+	mr, err := s.resets.GetByID(ctx, m.Userid, kindResetEmail)
 	if err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return governor.ErrWithMsg(err, "Failed to get user")
@@ -119,10 +130,10 @@ func (s *service) UpdateEmail(userid string, newEmail string, password string) e
 	}
 	mr.Params = newEmail
 	if needInsert {
-		if err := s.resets.Insert(mr); err != nil {
+		if err := s.resets.Insert(ctx, mr); err != nil {
 			return governor.ErrWithMsg(err, "Failed to create email reset request")
 		}
-	} else if err := s.resets.Update(mr); err != nil {
+	} else if err := s.resets.Update(ctx, mr); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update email reset request")
 	}
 
@@ -136,15 +147,15 @@ func (s *service) UpdateEmail(userid string, newEmail string, password string) e
 	if err := emdata.computeURL(s.emailurlbase, s.tplemailchange); err != nil {
 		return governor.ErrWithMsg(err, "Failed to generate new email verification email")
 	}
-	if err := s.mailer.Send("", "", []string{newEmail}, emailChangeTemplate, emdata); err != nil {
+	if err := s.mailer.Send(ctx, "", "", []string{newEmail}, emailChangeTemplate, emdata); err != nil {
 		return governor.ErrWithMsg(err, "Failed to send new email verification email")
 	}
 	return nil
 }
 
 // CommitEmail commits an email update from the cache
-func (s *service) CommitEmail(userid string, key string, password string) error {
-	mr, err := s.resets.GetByID(userid, kindResetEmail)
+func (s *service) CommitEmail(ctx context.Context, userid string, key string, password string) error {
+	mr, err := s.resets.GetByID(ctx, userid, kindResetEmail)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -170,7 +181,7 @@ func (s *service) CommitEmail(userid string, key string, password string) error
 		}))
 	}
 
-	m, err := s.users.GetByID(userid)
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -192,11 +203,11 @@ func (s *service) CommitEmail(userid string, key string, password string) error
 
 	m.Email = mr.Params
 
-	if err := s.resets.Delete(userid, kindResetEmail); err != nil {
+	if err := s.resets.Delete(ctx, userid, kindResetEmail); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete email reset request")
 	}
 
-	if err = s.users.Update(m); err != nil {
+	if err = s.users.Update(ctx, m); err != nil {
 		if errors.Is(err, db.ErrUnique{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
 				Status:  http.StatusBadRequest,
@@ -211,7 +222,7 @@ func (s *service) CommitEmail(userid string, key string, password string) error
 		LastName:  m.LastName,
 		Username:  m.Username,
 	}
-	if err := s.mailer.Send("", "", []string{m.Email}, emailChangeNotifyTemplate, emdatanotify); err != nil {
+	if err := s.mailer.Send(ctx, "", "", []string{m.Email}, emailChangeNotifyTemplate, emdatanotify); err != nil {
 		s.logger.Error("Failed to send old email change notification", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "commitemailoldmail",
@@ -277,8 +288,8 @@ const (
 )
 
 // UpdatePassword updates the password
-func (s *service) UpdatePassword(userid string, newPassword string, oldPassword string) error {
-	m, err := s.users.GetByID(userid)
+func (s *service) UpdatePassword(ctx context.Context, userid string, newPassword string, oldPassword string) error {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -300,7 +311,7 @@ func (s *service) UpdatePassword(userid string, newPassword string, oldPassword
 		return governor.ErrWithMsg(err, "Failed hashing password")
 	}
 
-	if err = s.users.Update(m); err != nil {
+	if err = s.users.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update user")
 	}
 
@@ -309,7 +320,7 @@ func (s *service) UpdatePassword(userid string, newPassword string, oldPassword
 		LastName:  m.LastName,
 		Username:  m.Username,
 	}
-	if err := s.mailer.Send("", "", []string{m.Email}, passChangeTemplate, emdata); err != nil {
+	if err := s.mailer.Send(ctx, "", "", []string{m.Email}, passChangeTemplate, emdata); err != nil {
 		s.logger.Error("Failed to send password change notification email", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "updatepasswordmail",
@@ -319,10 +330,10 @@ func (s *service) UpdatePassword(userid string, newPassword string, oldPassword
 }
 
 // ForgotPassword invokes the forgot password reset procedure
-func (s *service) ForgotPassword(useroremail string) error {
+func (s *service) ForgotPassword(ctx context.Context, useroremail string) error {
 	var m *model.Model
 	if isEmail(useroremail) {
-		mu, err := s.users.GetByEmail(useroremail)
+		mu, err := s.users.GetByEmail(ctx, useroremail)
 		if err != nil {
 			if errors.Is(err, db.ErrNotFound{}) {
 				// prevent email scanning
@@ -332,7 +343,7 @@ func (s *service) ForgotPassword(useroremail string) error {
 		}
 		m = mu
 	} else {
-		mu, err := s.users.GetByUsername(useroremail)
+		mu, err := s.users.GetByUsername(ctx, useroremail)
 		if err != nil {
 			if errors.Is(err, db.ErrNotFound{}) {
 				return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -346,7 +357,7 @@ func (s *service) ForgotPassword(useroremail string) error {
 	}
 
 	needInsert := false
-	mr, err := s.resets.GetByID(m.Userid, kindResetPass)
+	mr, err := s.resets.GetByID(ctx, m.Userid, kindResetPass)
 	if err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return governor.ErrWithMsg(err, "Failed to get user")
@@ -359,11 +370,11 @@ func (s *service) ForgotPassword(useroremail string) error {
 		return governor.ErrWithMsg(err, "Failed to generate password reset code")
 	}
 	if needInsert {
-		if err := s.resets.Insert(mr); err != nil {
+		if err := s.resets.Insert(ctx, mr); err != nil {
 			return governor.ErrWithMsg(err, "Failed to create password reset request")
 		}
 	} else {
-		if err := s.resets.Update(mr); err != nil {
+		if err := s.resets.Update(ctx, mr); err != nil {
 			return governor.ErrWithMsg(err, "Failed to update password reset request")
 		}
 	}
@@ -378,15 +389,15 @@ func (s *service) ForgotPassword(useroremail string) error {
 	if err := emdata.computeURL(s.emailurlbase, s.tplforgotpass); err != nil {
 		return governor.ErrWithMsg(err, "Failed to generate password reset email")
 	}
-	if err := s.mailer.Send("", "", []string{m.Email}, forgotPassTemplate, emdata); err != nil {
+	if err := s.mailer.Send(ctx, "", "", []string{m.Email}, forgotPassTemplate, emdata); err != nil {
 		return governor.ErrWithMsg(err, "Failed to send password reset email")
 	}
 	return nil
 }
 
 // ResetPassword completes the forgot password procedure
-func (s *service) ResetPassword(userid string, key string, newPassword string) error {
-	mr, err := s.resets.GetByID(userid, kindResetPass)
+func (s *service) ResetPassword(ctx context.Context, userid string, key string, newPassword string) error {
+	mr, err := s.resets.GetByID(ctx, userid, kindResetPass)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -412,7 +423,7 @@ func (s *service) ResetPassword(userid string, key string, newPassword string) e
 		}))
 	}
 
-	m, err := s.users.GetByID(userid)
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -427,11 +438,11 @@ func (s *service) ResetPassword(userid string, key string, newPassword string) e
 		return governor.ErrWithMsg(err, "Failed hashing password")
 	}
 
-	if err := s.resets.Delete(userid, kindResetPass); err != nil {
+	if err := s.resets.Delete(ctx, userid, kindResetPass); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete password reset request")
 	}
 
-	if err := s.users.Update(m); err != nil {
+	if err := s.users.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update password")
 	}
 
@@ -440,7 +451,7 @@ func (s *service) ResetPassword(userid string, key string, newPassword string) e
 		LastName:  m.LastName,
 		Username:  m.Username,
 	}
-	if err := s.mailer.Send("", "", []string{m.Email}, passResetTemplate, emdata); err != nil {
+	if err := s.mailer.Send(ctx, "", "", []string{m.Email}, passResetTemplate, emdata); err != nil {
 		s.logger.Error("Failed to send password change notification email", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "resetpasswordmail",
@@ -457,8 +468,8 @@ type (
 )
 
 // AddOTP adds an otp secret
-func (s *service) AddOTP(userid string, alg string, digits int) (*resAddOTP, error) {
-	m, err := s.users.GetByID(userid)
+func (s *service) AddOTP(ctx context.Context, userid string, alg string, digits int) (*resAddOTP, error) {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -480,7 +491,7 @@ func (s *service) AddOTP(userid string, alg string, digits int) (*resAddOTP, err
 	}
 	m.FailedLoginTime = 0
 	m.FailedLoginCount = 0
-	if err := s.users.Update(m); err != nil {
+	if err := s.users.Update(ctx, m); err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to update otp secret")
 	}
 	return &resAddOTP{
@@ -490,8 +501,8 @@ func (s *service) AddOTP(userid string, alg string, digits int) (*resAddOTP, err
 }
 
 // CommitOTP commits to using an otp
-func (s *service) CommitOTP(userid string, code string) error {
-	m, err := s.users.GetByID(userid)
+func (s *service) CommitOTP(ctx context.Context, userid string, code string) error {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -524,7 +535,7 @@ func (s *service) CommitOTP(userid string, code string) error {
 	m.OTPEnabled = true
 	m.FailedLoginTime = 0
 	m.FailedLoginCount = 0
-	if err := s.users.Update(m); err != nil {
+	if err := s.users.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update otp secret")
 	}
 	return nil
@@ -565,10 +576,59 @@ func (s *service) checkOTPCode(m *model.Model, code string, backup string) error
 	return nil
 }
 
-func (s *service) incrOTPFailCount(m *model.Model) {
+// lockOTP serializes otp checks of a user
+//
+// Otp checks read and update the user fail count, and would otherwise race
+// under parallel requests, allowing more attempts than the backoff permits.
+func (s *service) lockOTP(ctx context.Context, userid string) (string, error) {
+	u, err := uid.New(otpLockSize)
+	if err != nil {
+		return "", governor.ErrWithMsg(err, "Failed to create otp lock")
+	}
+	token := u.Base64()
+	if ok, err := s.kvotplocks.SetNX(ctx, userid, token, otpLockTime); err != nil {
+		return "", governor.ErrWithMsg(err, "Failed to acquire otp lock")
+	} else if !ok {
+		return "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusTooManyRequests,
+			Message: "OTP check in progress",
+		}))
+	}
+	return token, nil
+}
+
+// unlockOTP releases an otp lock if it is still held by token
+//
+// The lock is released with its own context, since the request context may
+// already be canceled when the otp check returns.
+func (s *service) unlockOTP(userid string, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), otpUnlockTimeout)
+	defer cancel()
+	if err := s.kvotplocks.Watch(ctx, []string{userid}, func(tx kvstore.Watcher) error {
+		v, err := tx.Get(ctx, userid)
+		if err != nil {
+			if errors.Is(err, kvstore.ErrNotFound{}) {
+				return nil
+			}
+			return err
+		}
+		if v != token {
+			return nil
+		}
+		tx.Tx().Del(userid)
+		return nil
+	}); err != nil {
+		s.logger.Error("Failed to release otp lock", map[string]string{
+			"error":      err.Error(),
+			"actiontype": "unlockotp",
+		})
+	}
+}
+
+func (s *service) incrOTPFailCount(ctx context.Context, m *model.Model) {
 	m.FailedLoginTime = time.Now().Round(0).Unix()
 	m.FailedLoginCount += 1
-	if err := s.users.Update(m); err != nil {
+	if err := s.users.Update(ctx, m); err != nil {
 		s.logger.Error("Failed to update otp fail count", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "incrotpfailcount",
@@ -576,10 +636,10 @@ func (s *service) incrOTPFailCount(m *model.Model) {
 	}
 }
 
-func (s *service) resetOTPFailCount(m *model.Model) {
+func (s *service) resetOTPFailCount(ctx context.Context, m *model.Model) {
 	m.FailedLoginTime = 0
 	m.FailedLoginCount = 0
-	if err := s.users.Update(m); err != nil {
+	if err := s.users.Update(ctx, m); err != nil {
 		s.logger.Error("Failed to reset otp fail count", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "resetotpfailcount",
@@ -588,8 +648,14 @@ func (s *service) resetOTPFailCount(m *model.Model) {
 }
 
 // RemoveOTP removes using otp
-func (s *service) RemoveOTP(userid string, code string, backup string) error {
-	m, err := s.users.GetByID(userid)
+func (s *service) RemoveOTP(ctx context.Context, userid string, code string, backup string) error {
+	token, err := s.lockOTP(ctx, userid)
+	if err != nil {
+		return err
+	}
+	defer s.unlockOTP(userid, token)
+
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -606,7 +672,7 @@ func (s *service) RemoveOTP(userid string, code string, backup string) error {
 		}))
 	}
 	if err := s.checkOTPCode(m, code, backup); err != nil {
-		s.incrOTPFailCount(m)
+		s.incrOTPFailCount(ctx, m)
 		return err
 	}
 	m.OTPEnabled = false
@@ -614,7 +680,7 @@ func (s *service) RemoveOTP(userid string, code string, backup string) error {
 	m.OTPBackup = ""
 	m.FailedLoginTime = 0
 	m.FailedLoginCount = 0
-	if err := s.users.Update(m); err != nil {
+	if err := s.users.Update(ctx, m); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update otp secret")
 	}
 	return nil
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -50,8 +51,8 @@ func getUserFields(m *model.Model, roles []string) *ResUserGet {
 	}
 }
 
-func (s *service) getRoleSummary(userid string) (rank.Rank, error) {
-	roles, err := s.roles.IntersectRoles(userid, s.rolesummary)
+func (s *service) getRoleSummary(ctx context.Context, userid string) (rank.Rank, error) {
+	roles, err := s.roles.IntersectRoles(ctx, userid, s.rolesummary)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user roles")
 	}
@@ -59,8 +60,8 @@ func (s *service) getRoleSummary(userid string) (rank.Rank, error) {
 }
 
 // GetByIDPublic gets and returns the public fields of the user
-func (s *service) GetByIDPublic(userid string) (*ResUserGetPublic, error) {
-	m, err := s.users.GetByID(userid)
+func (s *service) GetByIDPublic(ctx context.Context, userid string) (*ResUserGetPublic, error) {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -70,7 +71,7 @@ func (s *service) GetByIDPublic(userid string) (*ResUserGetPublic, error) {
 		}
 		return nil, governor.ErrWithMsg(err, "Failed to get user")
 	}
-	roles, err := s.getRoleSummary(userid)
+	roles, err := s.getRoleSummary(ctx, userid)
 	if err != nil {
 		return nil, err
 	}
@@ -78,8 +79,8 @@ func (s *service) GetByIDPublic(userid string) (*ResUserGetPublic, error) {
 }
 
 // GetByID gets and returns all fields of the user
-func (s *service) GetByID(userid string) (*ResUserGet, error) {
-	m, err := s.users.GetByID(userid)
+func (s *service) GetByID(ctx context.Context, userid string) (*ResUserGet, error) {
+	m, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -89,7 +90,7 @@ func (s *service) GetByID(userid string) (*ResUserGet, error) {
 		}
 		return nil, governor.ErrWithMsg(err, "Failed to get user")
 	}
-	roles, err := s.getRoleSummary(userid)
+	roles, err := s.getRoleSummary(ctx, userid)
 	if err != nil {
 		return nil, err
 	}
@@ -97,8 +98,8 @@ func (s *service) GetByID(userid string) (*ResUserGet, error) {
 }
 
 // GetByUsernamePublic gets and returns the public fields of the user
-func (s *service) GetByUsernamePublic(username string) (*ResUserGetPublic, error) {
-	m, err := s.users.GetByUsername(username)
+func (s *service) GetByUsernamePublic(ctx context.Context, username string) (*ResUserGetPublic, error) {
+	m, err := s.users.GetByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -108,7 +109,7 @@ func (s *service) GetByUsernamePublic(username string) (*ResUserGetPublic, error
 		}
 		return nil, governor.ErrWithMsg(err, "Failed to get user")
 	}
-	roles, err := s.getRoleSummary(m.Userid)
+	roles, err := s.getRoleSummary(ctx, m.Userid)
 	if err != nil {
 		return nil, err
 	}
@@ -116,8 +117,8 @@ func (s *service) GetByUsernamePublic(username string) (*ResUserGetPublic, error
 }
 
 // GetByUsername gets and returns all fields of the user
-func (s *service) GetByUsername(username string) (*ResUserGet, error) {
-	m, err := s.users.GetByUsername(username)
+func (s *service) GetByUsername(ctx context.Context, username string) (*ResUserGet, error) {
+	m, err := s.users.GetByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -127,7 +128,7 @@ func (s *service) GetByUsername(username string) (*ResUserGet, error) {
 		}
 		return nil, governor.ErrWithMsg(err, "Failed to get user")
 	}
-	roles, err := s.getRoleSummary(m.Userid)
+	roles, err := s.getRoleSummary(ctx, m.Userid)
 	if err != nil {
 		return nil, err
 	}
@@ -135,8 +136,8 @@ func (s *service) GetByUsername(username string) (*ResUserGet, error) {
 }
 
 // GetByEmail gets and returns all fields of the user
-func (s *service) GetByEmail(email string) (*ResUserGet, error) {
-	m, err := s.users.GetByEmail(email)
+func (s *service) GetByEmail(ctx context.Context, email string) (*ResUserGet, error) {
+	m, err := s.users.GetByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -146,7 +147,7 @@ func (s *service) GetByEmail(email string) (*ResUserGet, error) {
 		}
 		return nil, governor.ErrWithMsg(err, "Failed to get user")
 	}
-	roles, err := s.getRoleSummary(m.Userid)
+	roles, err := s.getRoleSummary(ctx, m.Userid)
 	if err != nil {
 		return nil, err
 	}
@@ -155,24 +156,59 @@ func (s *service) GetByEmail(email string) (*ResUserGet, error) {
 
 type (
 	resUserRoles struct {
-		Roles []string `json:"roles"`
+		Roles      []string `json:"roles"`
+		NextCursor string   `json:"next_cursor,omitempty"`
 	}
 )
 
 // GetUserRoles returns a list of user roles
-func (s *service) GetUserRoles(userid string, prefix string, amount, offset int) (*resUserRoles, error) {
-	roles, err := s.roles.GetRoles(userid, prefix, amount, offset)
+//
+// Roles are paged by cursor if a cursor is provided, and by offset otherwise.
+// The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetUserRoles(ctx context.Context, userid string, prefix string, amount, offset int, cursor string) (*resUserRoles, error) {
+	if cursor == "" {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		roles, err := s.roles.GetRoles(ctx, userid, prefix, amount, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get user roles")
+		}
+		return &resUserRoles{
+			Roles: roles.ToSlice(),
+		}, nil
+	}
+
+	after, err := db.ParseCursor(cursor)
+	if err != nil {
+		return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid cursor",
+		}), governor.ErrOptInner(err))
+	}
+	roles, err := s.roles.GetRolesAfter(ctx, userid, prefix, after, amount)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user roles")
 	}
+	res := roles.ToSlice()
+	var next string
+	if len(res) > 0 && len(res) == amount {
+		next = db.Cursor{
+			Key: res[len(res)-1],
+		}.Encode()
+	}
 	return &resUserRoles{
-		Roles: roles.ToSlice(),
+		Roles:      res,
+		NextCursor: next,
 	}, nil
 }
 
 // GetUserRolesIntersect returns the intersected roles of a user
-func (s *service) GetUserRolesIntersect(userid string, roleset rank.Rank) (*resUserRoles, error) {
-	roles, err := s.roles.IntersectRoles(userid, roleset)
+func (s *service) GetUserRolesIntersect(ctx context.Context, userid string, roleset rank.Rank) (*resUserRoles, error) {
+	roles, err := s.roles.IntersectRoles(ctx, userid, roleset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user roles")
 	}
@@ -189,15 +225,42 @@ type (
 	}
 
 	resUserInfoList struct {
-		Users []resUserInfo `json:"users"`
+		Users      []resUserInfo `json:"users"`
+		NextCursor string        `json:"next_cursor"`
 	}
 )
 
 // GetInfoAll gets and returns info for all users
-func (s *service) GetInfoAll(amount int, offset int) (*resUserInfoList, error) {
-	infoSlice, err := s.users.GetGroup(amount, offset)
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to get users")
+//
+// Users are paged by cursor if a cursor is provided, and by offset otherwise.
+// The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetInfoAll(ctx context.Context, amount int, offset int, cursor string) (*resUserInfoList, error) {
+	paged := cursor != ""
+	var infoSlice []model.Info
+	if paged {
+		after, err := db.ParseCursor(cursor)
+		if err != nil {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}), governor.ErrOptInner(err))
+		}
+		infoSlice, err = s.users.GetGroupAfter(ctx, after, amount)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get users")
+		}
+	} else {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		var err error
+		infoSlice, err = s.users.GetGroup(ctx, amount, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get users")
+		}
 	}
 
 	info := make([]resUserInfo, 0, len(infoSlice))
@@ -209,8 +272,16 @@ func (s *service) GetInfoAll(amount int, offset int) (*resUserInfoList, error) {
 		})
 	}
 
+	var next string
+	if paged && len(infoSlice) > 0 && len(infoSlice) == amount {
+		next = db.Cursor{
+			Key: infoSlice[len(infoSlice)-1].Userid,
+		}.Encode()
+	}
+
 	return &resUserInfoList{
-		Users: info,
+		Users:      info,
+		NextCursor: next,
 	}, nil
 }
 
@@ -228,8 +299,8 @@ type (
 )
 
 // GetInfoBulkPublic gets and returns public info for users
-func (s *service) GetInfoBulkPublic(userids []string) (*resUserInfoListPublic, error) {
-	infoSlice, err := s.users.GetBulk(userids)
+func (s *service) GetInfoBulkPublic(ctx context.Context, userids []string) (*resUserInfoListPublic, error) {
+	infoSlice, err := s.users.GetBulk(ctx, userids)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get users")
 	}
@@ -256,8 +327,8 @@ type (
 )
 
 // GetIDsByRole retrieves a list of user ids by role
-func (s *service) GetIDsByRole(role string, amount int, offset int) (*resUserList, error) {
-	userids, err := s.roles.GetByRole(role, amount, offset)
+func (s *service) GetIDsByRole(ctx context.Context, role string, amount int, offset int) (*resUserList, error) {
+	userids, err := s.roles.GetByRole(ctx, role, amount, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get users")
 	}
@@ -272,8 +343,8 @@ const (
 )
 
 // CheckUserExists is a fast check to determine if a user exists
-func (s *service) CheckUserExists(userid string) (bool, error) {
-	if v, err := s.kvusers.Get(userid); err != nil {
+func (s *service) CheckUserExists(ctx context.Context, userid string) (bool, error) {
+	if v, err := s.kvusers.Get(ctx, userid); err != nil {
 		s.logger.Error("Failed to get user exists from cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "getuserexists",
@@ -286,7 +357,7 @@ func (s *service) CheckUserExists(userid string) (bool, error) {
 	}
 
 	exists := true
-	_, err := s.users.GetByID(userid)
+	_, err := s.users.GetByID(ctx, userid)
 	if err != nil {
 		if !errors.Is(err, db.ErrNotFound{}) {
 			return false, governor.ErrWithMsg(err, "Failed to get user")
@@ -298,7 +369,7 @@ func (s *service) CheckUserExists(userid string) (bool, error) {
 	if exists {
 		v = cacheValY
 	}
-	if err := s.kvusers.Set(userid, v, s.userCacheTime); err != nil {
+	if err := s.kvusers.Set(ctx, userid, v, s.userCacheTime); err != nil {
 		s.logger.Error("Failed to set user exists in cache", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "setuserexists",
@@ -309,9 +380,9 @@ func (s *service) CheckUserExists(userid string) (bool, error) {
 }
 
 // GetUseridForLogin gets a userid for login
-func (s *service) GetUseridForLogin(useroremail string) (string, error) {
+func (s *service) GetUseridForLogin(ctx context.Context, useroremail string) (string, error) {
 	if isEmail(useroremail) {
-		m, err := s.users.GetByEmail(useroremail)
+		m, err := s.users.GetByEmail(ctx, useroremail)
 		if err != nil {
 			if errors.Is(err, db.ErrNotFound{}) {
 				return "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -323,7 +394,7 @@ func (s *service) GetUseridForLogin(useroremail string) (string, error) {
 		}
 		return m.Userid, nil
 	}
-	m, err := s.users.GetByUsername(useroremail)
+	m, err := s.users.GetByUsername(ctx, useroremail)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound{}) {
 			return "", governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
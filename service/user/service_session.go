@@ -1,7 +1,12 @@
 package user
 
 import (
+	"context"
+	"net/http"
+
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
+	sessionmodel "xorkevin.dev/governor/service/user/session/model"
 )
 
 type (
@@ -15,14 +20,42 @@ type (
 	}
 
 	resUserGetSessions struct {
-		Sessions []resSession `json:"active_sessions"`
+		Sessions   []resSession `json:"active_sessions"`
+		NextCursor string       `json:"next_cursor"`
 	}
 )
 
-func (s *service) GetUserSessions(userid string, limit, offset int) (*resUserGetSessions, error) {
-	m, err := s.sessions.GetUserSessions(userid, limit, offset)
-	if err != nil {
-		return nil, governor.ErrWithMsg(err, "Failed to get user sessions")
+// GetUserSessions returns the sessions of a user
+//
+// Sessions are paged by cursor if a cursor is provided, and by offset
+// otherwise. The first page by cursor is requested with db.CursorFirst.
+func (s *service) GetUserSessions(ctx context.Context, userid string, limit, offset int, cursor string) (*resUserGetSessions, error) {
+	paged := cursor != ""
+	var m []sessionmodel.Model
+	if paged {
+		after, err := db.ParseCursor(cursor)
+		if err != nil {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Invalid cursor",
+			}), governor.ErrOptInner(err))
+		}
+		m, err = s.sessions.GetUserSessionsAfter(ctx, userid, after, limit)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get user sessions")
+		}
+	} else {
+		if offset < 0 {
+			return nil, governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+				Status:  http.StatusBadRequest,
+				Message: "Offset or cursor must be provided",
+			}))
+		}
+		var err error
+		m, err = s.sessions.GetUserSessions(ctx, userid, limit, offset)
+		if err != nil {
+			return nil, governor.ErrWithMsg(err, "Failed to get user sessions")
+		}
 	}
 	res := make([]resSession, 0, len(m))
 	for _, i := range m {
@@ -35,13 +68,22 @@ func (s *service) GetUserSessions(userid string, limit, offset int) (*resUserGet
 			UserAgent: i.UserAgent,
 		})
 	}
+	var next string
+	if paged && len(m) > 0 && len(m) == limit {
+		last := m[len(m)-1]
+		next = db.Cursor{
+			Time: last.Time,
+			Key:  last.SessionID,
+		}.Encode()
+	}
 	return &resUserGetSessions{
-		Sessions: res,
+		Sessions:   res,
+		NextCursor: next,
 	}, nil
 }
 
-func (s *service) killCacheSessions(sessionids []string) {
-	if err := s.kvsessions.Del(sessionids...); err != nil {
+func (s *service) killCacheSessions(ctx context.Context, sessionids []string) {
+	if err := s.kvsessions.Del(ctx, sessionids...); err != nil {
 		s.logger.Error("Failed to delete session keys", map[string]string{
 			"error":      err.Error(),
 			"actiontype": "clearcachesessionids",
@@ -50,17 +92,17 @@ func (s *service) killCacheSessions(sessionids []string) {
 }
 
 // KillSessions terminates user sessions
-func (s *service) KillSessions(sessionids []string) error {
-	if err := s.sessions.DeleteSessions(sessionids); err != nil {
+func (s *service) KillSessions(ctx context.Context, sessionids []string) error {
+	if err := s.sessions.DeleteSessions(ctx, sessionids); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user sessions")
 	}
-	s.killCacheSessions(sessionids)
+	s.killCacheSessions(ctx, sessionids)
 	return nil
 }
 
 // KillAllSessions terminates all sessions of a user
-func (s *service) KillAllSessions(userid string) error {
-	if err := s.sessions.DeleteUserSessions(userid); err != nil {
+func (s *service) KillAllSessions(ctx context.Context, userid string) error {
+	if err := s.sessions.DeleteUserSessions(ctx, userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete user sessions")
 	}
 	return nil
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"xorkevin.dev/governor"
@@ -9,7 +10,7 @@ import (
 	"xorkevin.dev/hunter2"
 )
 
-//go:generate forge model -m Model -t usersessions -p session -o model_gen.go Model qID
+//go:generate go run xorkevin.dev/governor/cmd/forge model -m Model -t usersessions -p session -o model_gen.go Model qID
 
 const (
 	uidSize = 8
@@ -26,15 +27,17 @@ type (
 		New(userid, ipaddr, useragent string) (*Model, string, error)
 		ValidateKey(key string, m *Model) (bool, error)
 		RehashKey(m *Model) (string, error)
-		GetByID(sessionid string) (*Model, error)
-		GetUserSessions(userid string, limit, offset int) ([]Model, error)
-		GetUserSessionIDs(userid string, limit, offset int) ([]string, error)
-		Insert(m *Model) error
-		Update(m *Model) error
-		Delete(m *Model) error
-		DeleteSessions(sessionids []string) error
-		DeleteUserSessions(userid string) error
-		Setup() error
+		GetByID(ctx context.Context, sessionid string) (*Model, error)
+		GetUserSessions(ctx context.Context, userid string, limit, offset int) ([]Model, error)
+		GetUserSessionsAfter(ctx context.Context, userid string, cursor *db.Cursor, limit int) ([]Model, error)
+		GetUserSessionIDs(ctx context.Context, userid string, limit, offset int) ([]string, error)
+		Insert(ctx context.Context, m *Model) error
+		Update(ctx context.Context, m *Model) error
+		Delete(ctx context.Context, m *Model) error
+		DeleteSessions(ctx context.Context, sessionids []string) error
+		DeleteUserSessions(ctx context.Context, userid string) error
+		DeleteUserSessionsTx(ctx context.Context, tx db.SQLExecutor, userid string) error
+		Setup(ctx context.Context) error
 	}
 
 	repo struct {
@@ -48,7 +51,7 @@ type (
 		SessionID string `model:"sessionid,VARCHAR(63) PRIMARY KEY" query:"sessionid,getoneeq,sessionid;updeq,sessionid;deleq,sessionid;deleq,sessionid|arr"`
 		Userid    string `model:"userid,VARCHAR(31) NOT NULL;index" query:"userid,deleq,userid"`
 		KeyHash   string `model:"keyhash,VARCHAR(127) NOT NULL" query:"keyhash"`
-		Time      int64  `model:"time,BIGINT NOT NULL;index" query:"time,getgroupeq,userid"`
+		Time      int64  `model:"time,BIGINT NOT NULL;index" query:"time,getgroupeq,userid;getpageeq,userid,sessionid|tie"`
 		AuthTime  int64  `model:"auth_time,BIGINT NOT NULL" query:"auth_time"`
 		IPAddr    string `model:"ipaddr,VARCHAR(63)" query:"ipaddr"`
 		UserAgent string `model:"user_agent,VARCHAR(1023)" query:"user_agent"`
@@ -153,12 +156,12 @@ func (r *repo) RehashKey(m *Model) (string, error) {
 }
 
 // GetByID returns a user session model with the given id
-func (r *repo) GetByID(sessionID string) (*Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetByID(ctx context.Context, sessionID string) (*Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, code, err := sessionModelGetModelEqSessionID(d, sessionID)
+	m, code, err := sessionModelGetModelEqSessionID(ctx, d, sessionID)
 	if err != nil {
 		if code == 2 {
 			return nil, governor.ErrWithKind(err, db.ErrNotFound{}, "No session found with that id")
@@ -169,12 +172,31 @@ func (r *repo) GetByID(sessionID string) (*Model, error) {
 }
 
 // GetUserSessions returns all the sessions of a user
-func (r *repo) GetUserSessions(userid string, limit, offset int) ([]Model, error) {
-	d, err := r.db.DB()
+func (r *repo) GetUserSessions(ctx context.Context, userid string, limit, offset int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := sessionModelGetModelEqUseridOrdTime(d, userid, false, limit, offset)
+	m, err := sessionModelGetModelEqUseridOrdTime(ctx, d, userid, false, limit, offset)
+	if err != nil {
+		return nil, governor.ErrWithMsg(err, "Failed to get user sessions")
+	}
+	return m, nil
+}
+
+// GetUserSessionsAfter returns a page of the sessions of a user after a cursor
+//
+// The first page is returned if cursor is nil.
+func (r *repo) GetUserSessionsAfter(ctx context.Context, userid string, cursor *db.Cursor, limit int) ([]Model, error) {
+	d, err := r.db.Executor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil {
+		c := db.FirstCursor(false)
+		cursor = &c
+	}
+	m, err := sessionModelGetModelEqUseridPageTimeSessionID(ctx, d, userid, false, cursor.Time, cursor.Key, limit)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user sessions")
 	}
@@ -182,12 +204,12 @@ func (r *repo) GetUserSessions(userid string, limit, offset int) ([]Model, error
 }
 
 // GetUserSessionIDs returns all the session ids of a user
-func (r *repo) GetUserSessionIDs(userid string, limit, offset int) ([]string, error) {
-	d, err := r.db.DB()
+func (r *repo) GetUserSessionIDs(ctx context.Context, userid string, limit, offset int) ([]string, error) {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return nil, err
 	}
-	m, err := sessionModelGetqIDEqUseridOrdSessionID(d, userid, true, limit, offset)
+	m, err := sessionModelGetqIDEqUseridOrdSessionID(ctx, d, userid, true, limit, offset)
 	if err != nil {
 		return nil, governor.ErrWithMsg(err, "Failed to get user session ids")
 	}
@@ -199,12 +221,12 @@ func (r *repo) GetUserSessionIDs(userid string, limit, offset int) ([]string, er
 }
 
 // Insert inserts the model into the db
-func (r *repo) Insert(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Insert(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := sessionModelInsert(d, m); err != nil {
+	if code, err := sessionModelInsert(ctx, d, m); err != nil {
 		if code == 3 {
 			return governor.ErrWithKind(err, db.ErrUnique{}, "Session id must be unique")
 		}
@@ -214,63 +236,68 @@ func (r *repo) Insert(m *Model) error {
 }
 
 // Update updates the model in the db
-func (r *repo) Update(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Update(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if _, err := sessionModelUpdModelEqSessionID(d, m, m.SessionID); err != nil {
+	if _, err := sessionModelUpdModelEqSessionID(ctx, d, m, m.SessionID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to update session")
 	}
 	return nil
 }
 
 // Delete deletes the model in the db
-func (r *repo) Delete(m *Model) error {
-	d, err := r.db.DB()
+func (r *repo) Delete(ctx context.Context, m *Model) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := sessionModelDelEqSessionID(d, m.SessionID); err != nil {
+	if err := sessionModelDelEqSessionID(ctx, d, m.SessionID); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete session")
 	}
 	return nil
 }
 
 // DeleteSessions deletes the sessions in the set of session ids
-func (r *repo) DeleteSessions(sessionids []string) error {
+func (r *repo) DeleteSessions(ctx context.Context, sessionids []string) error {
 	if len(sessionids) == 0 {
 		return nil
 	}
-	d, err := r.db.DB()
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := sessionModelDelHasSessionID(d, sessionids); err != nil {
+	if err := sessionModelDelHasSessionID(ctx, d, sessionids); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete sessions")
 	}
 	return nil
 }
 
 // DeleteUserSessions deletes all the sessions of a user
-func (r *repo) DeleteUserSessions(userid string) error {
-	d, err := r.db.DB()
+func (r *repo) DeleteUserSessions(ctx context.Context, userid string) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if err := sessionModelDelEqUserid(d, userid); err != nil {
+	return r.DeleteUserSessionsTx(ctx, d, userid)
+}
+
+// DeleteUserSessionsTx deletes all the sessions of a user in a transaction
+func (r *repo) DeleteUserSessionsTx(ctx context.Context, tx db.SQLExecutor, userid string) error {
+	if err := sessionModelDelEqUserid(ctx, tx, userid); err != nil {
 		return governor.ErrWithMsg(err, "Failed to delete sessions")
 	}
 	return nil
 }
 
 // Setup creates a new User session table
-func (r *repo) Setup() error {
-	d, err := r.db.DB()
+func (r *repo) Setup(ctx context.Context) error {
+	d, err := r.db.Executor(ctx)
 	if err != nil {
 		return err
 	}
-	if code, err := sessionModelSetup(d); err != nil {
+	if code, err := sessionModelSetup(ctx, d); err != nil {
 		if code != 5 {
 			return governor.ErrWithMsg(err, "Failed to setup user session model")
 		}
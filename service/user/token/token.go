@@ -3,7 +3,9 @@ package token
 import (
 	"context"
 	"crypto"
+	"crypto/hmac"
 	"crypto/rsa"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
@@ -39,6 +41,10 @@ const (
 	pemBlockType = "PRIVATE KEY"
 )
 
+const (
+	csrfKeyLabel = "governor:csrf"
+)
+
 const (
 	jwtHeaderKid = "kid"
 	jwtHeaderJWT = "JWT"
@@ -62,6 +68,8 @@ type (
 		Validate(kind string, tokenString string) (bool, *Claims)
 		GetClaims(kind string, tokenString string) (bool, *Claims)
 		GetClaimsExt(kind string, tokenString string, audience []string, claims interface{}) (bool, *Claims)
+		GenerateCSRF(sessionID string) string
+		ValidateCSRF(sessionID string, csrfToken string) bool
 	}
 
 	// Service is a Tokenizer and governor.Service
@@ -72,6 +80,7 @@ type (
 
 	service struct {
 		secret     []byte
+		csrfKey    []byte
 		privateKey crypto.Signer
 		publicKey  crypto.PublicKey
 		issuer     string
@@ -150,6 +159,9 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 		return governor.ErrWithKind(nil, governor.ErrInvalidConfig{}, "Token secret is not set")
 	}
 	s.secret = []byte(secret)
+	csrfMac := hmac.New(sha512.New512_256, s.secret)
+	csrfMac.Write([]byte(csrfKeyLabel))
+	s.csrfKey = csrfMac.Sum(nil)
 	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS512, Key: s.secret}, (&jose.SignerOptions{}).WithType(jwtHeaderJWT))
 	if err != nil {
 		return governor.ErrWithKind(err, ErrSigner{}, "Failed to create new jwt signer")
@@ -217,11 +229,11 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	return nil
 }
 
@@ -378,3 +390,26 @@ func (s *service) GetClaimsExt(kind string, tokenString string, audience []strin
 	}
 	return true, baseClaims
 }
+
+func (s *service) csrfMac(sessionID string) []byte {
+	mac := hmac.New(sha512.New512_256, s.csrfKey)
+	mac.Write([]byte(sessionID))
+	return mac.Sum(nil)
+}
+
+// GenerateCSRF returns a csrf token bound to a session
+func (s *service) GenerateCSRF(sessionID string) string {
+	return base64.RawURLEncoding.EncodeToString(s.csrfMac(sessionID))
+}
+
+// ValidateCSRF returns whether a csrf token is valid for a session
+func (s *service) ValidateCSRF(sessionID string, csrfToken string) bool {
+	if sessionID == "" || csrfToken == "" {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(csrfToken)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(b, s.csrfMac(sessionID))
+}
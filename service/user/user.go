@@ -2,15 +2,16 @@ package user
 
 import (
 	"context"
-	"encoding/json"
 	htmlTemplate "html/template"
 	"strconv"
 	"time"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
 	"xorkevin.dev/governor/service/events"
 	"xorkevin.dev/governor/service/kvstore"
 	"xorkevin.dev/governor/service/mail"
+	"xorkevin.dev/governor/service/outbox"
 	"xorkevin.dev/governor/service/ratelimit"
 	"xorkevin.dev/governor/service/user/apikey"
 	approvalmodel "xorkevin.dev/governor/service/user/approval/model"
@@ -38,6 +39,21 @@ const (
 	CreateChannel = EventStream + ".create"
 	// DeleteChannel is emitted when a user is deleted
 	DeleteChannel = EventStream + ".delete"
+	eventSource   = "user"
+)
+
+var (
+	newUserSchema = events.Schema{
+		Type:    "user.create",
+		Version: 2,
+		Upcasts: map[int]events.UpcastFunc{
+			1: upcastNewUserPropsV1,
+		},
+	}
+	deleteUserSchema = events.Schema{
+		Type:    "user.delete",
+		Version: 1,
+	}
 )
 
 const (
@@ -49,8 +65,8 @@ const (
 type (
 	// Users is a user management service
 	Users interface {
-		GetByID(userid string) (*ResUserGet, error)
-		CheckUserExists(userid string) (bool, error)
+		GetByID(ctx context.Context, userid string) (*ResUserGet, error)
+		CheckUserExists(ctx context.Context, userid string) (bool, error)
 	}
 
 	// Service is a Users and governor.Service
@@ -69,7 +85,10 @@ type (
 		apikeys           apikey.Apikeys
 		kvusers           kvstore.KVStore
 		kvsessions        kvstore.KVStore
+		kvotplocks        kvstore.KVStore
+		database          db.Database
 		events            events.Events
+		outbox            outbox.Outbox
 		mailer            mail.Mailer
 		ratelimiter       ratelimit.Ratelimiter
 		gate              gate.Gate
@@ -104,6 +123,8 @@ type (
 	}
 
 	// NewUserProps are properties of a newly created user
+	//
+	// Admin is true for the first admin user created during setup.
 	NewUserProps struct {
 		Userid       string `json:"userid"`
 		Username     string `json:"username"`
@@ -111,6 +132,18 @@ type (
 		FirstName    string `json:"first_name"`
 		LastName     string `json:"last_name"`
 		CreationTime int64  `json:"creation_time"`
+		Admin        bool   `json:"admin"`
+	}
+
+	// newUserPropsV1 are version 1 new user props, which were published
+	// before users were marked as admin
+	newUserPropsV1 struct {
+		Userid       string `json:"userid"`
+		Username     string `json:"username"`
+		Email        string `json:"email"`
+		FirstName    string `json:"first_name"`
+		LastName     string `json:"last_name"`
+		CreationTime int64  `json:"creation_time"`
 	}
 
 	// DeleteUserProps are properties of a deleted user
@@ -145,7 +178,9 @@ func NewCtx(inj governor.Injector) Service {
 	roles := role.GetCtxRoles(inj)
 	apikeys := apikey.GetCtxApikeys(inj)
 	kv := kvstore.GetCtxKVStore(inj)
+	database := db.GetCtxDB(inj)
 	ev := events.GetCtxEvents(inj)
+	ob := outbox.GetCtxOutbox(inj)
 	mailer := mail.GetCtxMailer(inj)
 	ratelimiter := ratelimit.GetCtxRatelimiter(inj)
 	tokenizer := token.GetCtxTokenizer(inj)
@@ -160,7 +195,9 @@ func NewCtx(inj governor.Injector) Service {
 		roles,
 		apikeys,
 		kv,
+		database,
 		ev,
+		ob,
 		mailer,
 		ratelimiter,
 		tokenizer,
@@ -178,7 +215,9 @@ func New(
 	roles role.Roles,
 	apikeys apikey.Apikeys,
 	kv kvstore.KVStore,
+	database db.Database,
 	ev events.Events,
+	ob outbox.Outbox,
 	mailer mail.Mailer,
 	ratelimiter ratelimit.Ratelimiter,
 	tokenizer token.Tokenizer,
@@ -194,7 +233,10 @@ func New(
 		apikeys:           apikeys,
 		kvusers:           kv.Subtree("users"),
 		kvsessions:        kv.Subtree("sessions"),
+		kvotplocks:        kv.Subtree("otplocks"),
+		database:          database,
 		events:            ev,
+		outbox:            ob,
 		mailer:            mailer,
 		ratelimiter:       ratelimiter,
 		gate:              g,
@@ -367,42 +409,43 @@ func (s *service) Init(ctx context.Context, c governor.Config, r governor.Config
 	return nil
 }
 
-func (s *service) Setup(req governor.ReqSetup) error {
+func (s *service) Setup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "setup",
 	})
 
-	if err := s.events.InitStream(EventStream, []string{eventStreamChannels}, events.StreamOpts{
+	if err := s.events.InitStream(ctx, EventStream, []string{eventStreamChannels}, events.StreamOpts{
 		Replicas:   1,
 		MaxAge:     30 * 24 * time.Hour,
 		MaxBytes:   s.streamsize,
 		MaxMsgSize: s.msgsize,
+		Duplicates: 2 * time.Minute,
 	}); err != nil {
 		return governor.ErrWithMsg(err, "Failed to init user stream")
 	}
 	l.Info("Created user stream", nil)
 
-	if err := s.users.Setup(); err != nil {
+	if err := s.users.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created user table", nil)
 
-	if err := s.sessions.Setup(); err != nil {
+	if err := s.sessions.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created usersessions table", nil)
 
-	if err := s.approvals.Setup(); err != nil {
+	if err := s.approvals.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created userapprovals table", nil)
 
-	if err := s.invitations.Setup(); err != nil {
+	if err := s.invitations.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created userroleinvitations table", nil)
 
-	if err := s.resets.Setup(); err != nil {
+	if err := s.resets.Setup(ctx); err != nil {
 		return err
 	}
 	l.Info("created userresets table", nil)
@@ -410,7 +453,7 @@ func (s *service) Setup(req governor.ReqSetup) error {
 	return nil
 }
 
-func (s *service) PostSetup(req governor.ReqSetup) error {
+func (s *service) PostSetup(ctx context.Context, req governor.ReqSetup) error {
 	l := s.logger.WithData(map[string]string{
 		"phase": "postsetup",
 	})
@@ -421,31 +464,36 @@ func (s *service) PostSetup(req governor.ReqSetup) error {
 			return err
 		}
 
-		b, err := json.Marshal(NewUserProps{
-			Userid:       madmin.Userid,
-			Username:     madmin.Username,
-			Email:        madmin.Email,
-			FirstName:    madmin.FirstName,
-			LastName:     madmin.LastName,
-			CreationTime: madmin.CreationTime,
-		})
-		if err != nil {
-			return governor.ErrWithMsg(err, "Failed to encode admin user props to json")
+		ev := events.Event{
+			Type:    newUserSchema.Type,
+			Version: newUserSchema.Version,
+			Source:  eventSource,
+			Data: NewUserProps{
+				Userid:       madmin.Userid,
+				Username:     madmin.Username,
+				Email:        madmin.Email,
+				FirstName:    madmin.FirstName,
+				LastName:     madmin.LastName,
+				CreationTime: madmin.CreationTime,
+				Admin:        true,
+			},
 		}
 
-		if err := s.users.Insert(madmin); err != nil {
+		if err := s.database.WithTx(ctx, nil, func(tx db.SQLExecutor) error {
+			if err := s.users.InsertTx(ctx, tx, madmin); err != nil {
+				return err
+			}
+			if err := s.roles.InsertRolesTx(ctx, tx, madmin.Userid, rank.Admin()); err != nil {
+				return err
+			}
+			if err := outbox.InsertEvent(ctx, s.outbox, tx, CreateChannel, events.JSONCodec, ev); err != nil {
+				return governor.ErrWithMsg(err, "Failed to publish new user")
+			}
+			return nil
+		}); err != nil {
 			return err
 		}
-		if err := s.roles.InsertRoles(madmin.Userid, rank.Admin()); err != nil {
-			return err
-		}
-
-		if err := s.events.StreamPublish(CreateChannel, b); err != nil {
-			s.logger.Error("Failed to publish new user", map[string]string{
-				"error":      err.Error(),
-				"actiontype": "publishadminuser",
-			})
-		}
+		s.roles.ClearUserCache(ctx, madmin.Userid, rank.Admin())
 
 		l.Info("inserted new setup admin", map[string]string{
 			"username": madmin.Username,
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"xorkevin.dev/governor"
+	"xorkevin.dev/governor/service/db"
 	"xorkevin.dev/governor/util/rank"
 	"xorkevin.dev/hunter2"
 )
@@ -17,6 +18,7 @@ const (
 	lengthCapEmail    = 255
 	lengthCapLarge    = 4095
 	amountCap         = 1024
+	lengthCapCursor   = 255
 	lengthCapOTPCode  = 31
 )
 
@@ -131,6 +133,32 @@ func validOffset(offset int) error {
 	return nil
 }
 
+func validoptOffset(offset int) error {
+	if offset == -1 {
+		return nil
+	}
+	return validOffset(offset)
+}
+
+func validoptCursor(cursor string) error {
+	if len(cursor) == 0 {
+		return nil
+	}
+	if len(cursor) > lengthCapCursor {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Cursor must be shorter than 256 characters",
+			Status:  http.StatusBadRequest,
+		}))
+	}
+	if _, err := db.ParseCursor(cursor); err != nil {
+		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
+			Message: "Cursor is invalid",
+			Status:  http.StatusBadRequest,
+		}), governor.ErrOptInner(err))
+	}
+	return nil
+}
+
 func validhasUserids(userids string) error {
 	if len(userids) == 0 {
 		return governor.NewError(governor.ErrOptUser, governor.ErrOptRes(governor.ErrorRes{
@@ -26,7 +26,10 @@ func (r reqGetUserRoles) valid() error {
 	if err := validAmount(r.Amount); err != nil {
 		return err
 	}
-	if err := validOffset(r.Offset); err != nil {
+	if err := validoptOffset(r.Offset); err != nil {
+		return err
+	}
+	if err := validoptCursor(r.Cursor); err != nil {
 		return err
 	}
 	return nil
@@ -59,7 +62,10 @@ func (r reqGetUserBulk) valid() error {
 	if err := validAmount(r.Amount); err != nil {
 		return err
 	}
-	if err := validOffset(r.Offset); err != nil {
+	if err := validoptOffset(r.Offset); err != nil {
+		return err
+	}
+	if err := validoptCursor(r.Cursor); err != nil {
 		return err
 	}
 	return nil